@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// spoken is what renderLinear wrote last, so it writes only what changed.
+type spoken struct {
+	page, status, overlay string
+}
+
+// cursorMoves are the escape sequences overlays position their rows with.
+var cursorMoves = regexp.MustCompile(`\x1b\[[0-9]*(;[0-9]*)?H`)
+
+// renderLinear is renderPage for Config.Accessible. It does not repaint the screen but writes
+// plain lines after the previous ones, which screen readers follow like the output of a command:
+// the page when it moved, then the overlay or the status bar when they changed.
+func (r *Reader) renderLinear() {
+	var b strings.Builder
+	rows, shown := r.layoutPage(r.currentLine, r.winHeight-1)
+	r.shown = shown
+	say := func(s string, last *string) {
+		if s != *last && s != "" {
+			b.WriteString(s + "\r\n")
+		}
+		*last = s
+	}
+	if page := linear(strings.Join(rows, "\n")); page != r.spoken.page {
+		say(page, &r.spoken.page)
+		r.spoken.status = ""
+	}
+	var sb strings.Builder
+	if r.overlay != nil {
+		r.overlay.draw(r, &sb)
+		say(linear(sb.String()), &r.spoken.overlay)
+		r.spoken.status = ""
+	} else {
+		r.spoken.overlay = ""
+		r.printInfo(&sb)
+		say(linear(sb.String()), &r.spoken.status)
+	}
+	// the title is not spoken, it keeps the progress in sight in the tab bar.
+	r.drawTitle(&b)
+	_, _ = os.Stdout.WriteString(b.String())
+	r.saveProgress()
+}
+
+// linear returns frame s as plain text, rows placed on the screen become lines and blank ones are dropped.
+func linear(s string) string {
+	var ll []string
+	for _, l := range strings.Split(cursorMoves.ReplaceAllString(s, "\n"), "\n") {
+		if l = strings.TrimSpace(stripControls(l, false)); l != "" {
+			ll = append(ll, l)
+		}
+	}
+	return strings.Join(ll, "\r\n")
+}
+
+// drawList writes the box of drawBox as lines for Config.Accessible. A box to choose from
+// only tells the chosen line, it is read again on each move.
+func drawList(b *strings.Builder, title string, lines []string, sel int, foot string) {
+	if sel >= 0 && sel < len(lines) {
+		_, _ = fmt.Fprintf(b, "\n%s %d/%d: %s\n", title, sel+1, len(lines), strings.TrimSpace(lines[sel]))
+		return
+	}
+	b.WriteString("\n" + title + "\n" + strings.Join(lines, "\n") + "\n" + foot + "\n")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// RunLine reads the book a row at a time on the line of the cursor, in the normal screen
+// between shell commands. The keys moving by lines and pages step to the next or previous row,
+// q clears the line again.
+func (r *Reader) RunLine() error {
+	defer r.close()
+	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New(tr("fish line reads in a terminal"))
+	}
+	if e := r.loadConfig(); e != nil {
+		return e
+	}
+	if e := r.loadProgress(); e != nil {
+		return e
+	}
+	r.cfg.Margin = 0
+	r.lineSize()
+	if e := r.createIndex(); e != nil {
+		return e
+	}
+	r.toView()
+	if r.totalLine == 0 {
+		return nil
+	}
+	restore, e := r.enterRawMode()
+	if e != nil {
+		return e
+	}
+	defer restore()
+	go r.daemonCatchInput()
+	if dir := syncDir(r.conf); dir != "" && r.saves() {
+		// there is no main loop, the pull is taken when the line is cleared.
+		r.pullInBackground(dir)
+	}
+	sub := 0
+	for {
+		r.skipBlank(1)
+		rows := r.layoutLine(r.currentLine)
+		sub = max(0, min(sub, len(rows)-1))
+		row := rows[sub]
+		row = termColors(row)
+		_, _ = os.Stdout.WriteString("\r" + row + "\x1b[0m\x1b[K")
+		r.saveProgress()
+		switch keymap[<-r.keySignal] {
+		case CmdExit:
+			_, _ = os.Stdout.WriteString("\r\x1b[K")
+			return nil
+		case CmdNextLine, CmdNextHalfPage, CmdNextPage:
+			r.lineSize()
+			if sub++; sub >= len(rows) && r.currentLine+1 < r.totalLine {
+				r.currentLine, sub = r.currentLine+1, 0
+			}
+		case CmdPrevLine, CmdPrevPage:
+			r.lineSize()
+			if sub--; sub < 0 && r.currentLine > 0 {
+				r.currentLine--
+				r.skipBlank(-1)
+				sub = len(r.layoutLine(r.currentLine)) - 1
+			}
+		}
+	}
+}
+
+// lineSize takes the width of the terminal, less a column so the cursor does not wrap.
+func (r *Reader) lineSize() {
+	if w, h, e := term.GetSize(int(os.Stdout.Fd())); e == nil {
+		r.winWidth, r.winHeight = max(11, w-1), h
+	}
+}
+
+// skipBlank moves the current line in direction d past blank lines, there is nothing to read in them.
+func (r *Reader) skipBlank(d int) {
+	blank := func(i int) bool { return strings.TrimSpace(stripControls(r.doc.lines.at(i), false)) == "" }
+	for i := r.currentLine; i >= 0 && i < r.totalLine; i += d {
+		if !blank(i) {
+			r.currentLine = i
+			return
+		}
+	}
+}
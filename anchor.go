@@ -0,0 +1,65 @@
+package main
+
+import "unicode/utf8"
+
+// rowLetters counts the letters and digits of row, which are the same however the line is wrapped.
+func rowLetters(row string) int {
+	return utf8.RuneCountInString(letters(uncolor(row)))
+}
+
+// topRow is the row of rows, the rows of the current line, the page starts at. The page starts
+// inside a line taller than it at the letter r.top.off of the line, so the same text stays on
+// top when the window is resized or the line is wrapped anew.
+func (r *Reader) topRow(rows []string) int {
+	if r.top.line != r.currentLine || r.top.off == 0 {
+		return 0
+	}
+	n := 0
+	for i, row := range rows {
+		if n += rowLetters(row); n > r.top.off {
+			return i
+		}
+	}
+	return max(0, len(rows)-1)
+}
+
+// setTopRow starts the page at row k of rows, the rows of the current line.
+func (r *Reader) setTopRow(rows []string, k int) {
+	off := 0
+	for _, row := range rows[:k] {
+		off += rowLetters(row)
+	}
+	r.top = place{r.currentLine, off}
+}
+
+// rowDown moves the page n rows down inside the current line if the rest of it does not fit
+// on the page, it tells if it did.
+func (r *Reader) rowDown(n int) bool {
+	if r.shown > 0 || r.cfg.Accessible {
+		return false
+	}
+	rows := r.layoutLine(r.currentLine)
+	k := r.topRow(rows) + n
+	if k >= len(rows) {
+		return false
+	}
+	r.setTopRow(rows, k)
+	return true
+}
+
+// rowUp moves the page n rows up inside the current line if it starts inside, it tells if it did.
+func (r *Reader) rowUp(n int) bool {
+	rows := r.layoutLine(r.currentLine)
+	k := r.topRow(rows)
+	if k == 0 {
+		return false
+	}
+	for j := max(0, k-n); j > 0; j-- {
+		// rows without letters start at the row after them.
+		if r.setTopRow(rows, j); r.topRow(rows) < k {
+			return true
+		}
+	}
+	r.top.off = 0
+	return true
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// passwords are the passwords of the archives opened in the session, by file. They are only
+// kept in memory, as is the text of the books in the archives.
+var passwords = make(map[string]string)
+
+// askPassword asks for the password of archive f, nil once the reader takes the keys.
+// Archives opened after that take the passwords given so far.
+var askPassword = func(f string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errors.New(filepath.Base(f) + tr(" is encrypted, give its password in a terminal"))
+	}
+	_, _ = fmt.Fprintf(os.Stderr, tr("password for %s: "), filepath.Base(f))
+	pw, e := term.ReadPassword(fd)
+	_, _ = fmt.Fprint(os.Stderr, "\r\n")
+	return string(pw), e
+}
+
+// errPassword is the error of decrypting with a wrong password.
+var errPassword = errors.New("wrong password")
+
+// loadArchive reads the books in zip archive f, encrypted or not, see archiveBooks.
+func loadArchive(f, enc string) (*document, error) {
+	z, e := zip.OpenReader(f)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = z.Close() }()
+	var ff []*zip.File
+	for _, zf := range z.File {
+		if isBook(zf.Name, zf.FileInfo().IsDir()) {
+			ff = append(ff, zf)
+		}
+	}
+	slices.SortFunc(ff, func(a, b *zip.File) int { return cmp.Compare(a.Name, b.Name) })
+	names := make([]string, len(ff))
+	for i, zf := range ff {
+		names[i] = zf.Name
+	}
+	return archiveBooks(f, names, enc, func(i int) ([]byte, error) { return readZipped(f, ff[i]) })
+}
+
+// isBook tells if the file of an archive at path name is a book. EPUBs are archives themselves
+// and are left out.
+func isBook(name string, dir bool) bool {
+	base := path.Base(name)
+	switch {
+	case dir, strings.HasPrefix(name, "__MACOSX/"), strings.HasPrefix(base, "."):
+	case slices.Contains([]string{".epub", ".jpg", ".jpeg", ".png", ".gif", ".webp"}, strings.ToLower(path.Ext(base))):
+	default:
+		return true
+	}
+	return false
+}
+
+// archiveBooks reads the books names of archive f, the contents of which read returns. One book
+// is read as it is, several one after the other as one book like loadPlaylist does.
+func archiveBooks(f string, names []string, enc string, read func(i int) ([]byte, error)) (*document, error) {
+	if len(names) == 0 {
+		return nil, errors.New(filepath.Base(f) + tr(": no books in the archive"))
+	}
+	load := func(i int) (*document, error) {
+		dd, e := read(i)
+		if e != nil {
+			return nil, e
+		}
+		return parseDocument(names[i], dd, enc)
+	}
+	if len(names) == 1 {
+		return load(0)
+	}
+	return joinParts(f, names, load)
+}
+
+// readZipped returns the contents of zf in archive f, asking for the password of f if it is
+// encrypted and none was given yet.
+func readZipped(f string, zf *zip.File) ([]byte, error) {
+	if zf.Flags&1 == 0 {
+		rc, e := zf.Open()
+		if e != nil {
+			return nil, e
+		}
+		defer func() { _ = rc.Close() }()
+		return io.ReadAll(rc)
+	}
+	return decryptArchive(f, func(pw string) ([]byte, error) { return decryptZipped(zf, pw) })
+}
+
+// decryptArchive returns what decrypt decrypts of archive f with the password of f, asked for if
+// none was given yet. decrypt returns errPassword for a wrong password, which is asked again.
+func decryptArchive(f string, decrypt func(pw string) ([]byte, error)) ([]byte, error) {
+	for try := 1; ; try++ {
+		pw, given := passwords[f]
+		if !given {
+			if askPassword == nil {
+				return nil, errors.New(filepath.Base(f) + tr(" is encrypted, open it with fish to give its password"))
+			}
+			var e error
+			if pw, e = askPassword(f); e != nil {
+				return nil, e
+			}
+		}
+		dd, e := decrypt(pw)
+		switch {
+		case e == nil:
+			passwords[f] = pw
+			return dd, nil
+		case !errors.Is(e, errPassword):
+			return nil, e
+		}
+		delete(passwords, f)
+		if try == 3 || askPassword == nil {
+			return nil, fmt.Errorf(tr("wrong password for %s"), filepath.Base(f))
+		}
+	}
+}
+
+// decryptZipped decrypts and inflates encrypted zf with password pw, in memory. Archives are
+// encrypted with the traditional PKWARE encryption or with AES as WinZip and 7-Zip do.
+func decryptZipped(zf *zip.File, pw string) ([]byte, error) {
+	raw, e := zf.OpenRaw()
+	if e != nil {
+		return nil, e
+	}
+	dd, e := io.ReadAll(raw)
+	if e != nil {
+		return nil, e
+	}
+	method, check := zf.Method, true
+	if method == 99 {
+		var aesMethod uint16
+		if dd, aesMethod, check, e = decryptAES(zf, dd, pw); e != nil {
+			return nil, e
+		}
+		method = aesMethod
+	} else if dd, e = decryptPKWARE(zf, dd, pw); e != nil {
+		return nil, e
+	}
+	switch method {
+	case zip.Store:
+	case zip.Deflate:
+		if dd, e = io.ReadAll(flate.NewReader(bytes.NewReader(dd))); e != nil {
+			return nil, errPassword // a wrong password passing the check byte makes noise.
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported compression method %d", zf.Name, method)
+	}
+	if check && crc32.ChecksumIEEE(dd) != zf.CRC32 {
+		return nil, errPassword
+	}
+	return dd, nil
+}
+
+// decryptPKWARE decrypts dd, encrypted with the traditional PKWARE encryption of APPNOTE.TXT 6.1.
+func decryptPKWARE(zf *zip.File, dd []byte, pw string) ([]byte, error) {
+	if len(dd) < 12 {
+		return nil, errors.New(zf.Name + ": bad encryption header")
+	}
+	k := [3]uint32{0x12345678, 0x23456789, 0x34567890}
+	update := func(c byte) {
+		k[0] = crc32.IEEETable[byte(k[0])^c] ^ k[0]>>8
+		k[1] = (k[1]+k[0]&0xff)*134775813 + 1
+		k[2] = crc32.IEEETable[byte(k[2])^byte(k[1]>>24)] ^ k[2]>>8
+	}
+	for i := range len(pw) {
+		update(pw[i])
+	}
+	out := make([]byte, len(dd))
+	for i, c := range dd {
+		t := k[2] | 2
+		out[i] = c ^ byte(t*(t^1)>>8)
+		update(out[i])
+	}
+	// the last byte of the header is the high byte of the CRC, or of the time with a data descriptor.
+	want := byte(zf.CRC32 >> 24)
+	if zf.Flags&8 != 0 {
+		want = byte(zf.ModifiedTime >> 8)
+	}
+	if out[11] != want {
+		return nil, errPassword
+	}
+	return out[12:], nil
+}
+
+// decryptAES decrypts dd, encrypted with the AES encryption of WinZip. It returns the compression
+// method and if the CRC is to be checked, which AE-2 leaves out.
+func decryptAES(zf *zip.File, dd []byte, pw string) ([]byte, uint16, bool, error) {
+	bad := errors.New(zf.Name + ": bad AES header")
+	var version, method uint16
+	var strength byte
+	for x := zf.Extra; len(x) >= 4; {
+		id, n := binary.LittleEndian.Uint16(x), int(binary.LittleEndian.Uint16(x[2:]))
+		if len(x) < 4+n {
+			break
+		}
+		if id == 0x9901 && n >= 7 {
+			version, strength, method = binary.LittleEndian.Uint16(x[4:]), x[8], binary.LittleEndian.Uint16(x[9:])
+		}
+		x = x[4+n:]
+	}
+	if strength < 1 || strength > 3 {
+		return nil, 0, false, bad
+	}
+	keyLen := 8 + 8*int(strength) // 16, 24 or 32 bytes.
+	saltLen := keyLen / 2
+	if len(dd) < saltLen+2+10 {
+		return nil, 0, false, bad
+	}
+	salt, verifier := dd[:saltLen], dd[saltLen:saltLen+2]
+	data, auth := dd[saltLen+2:len(dd)-10], dd[len(dd)-10:]
+	key, e := pbkdf2.Key(sha1.New, pw, salt, 1000, 2*keyLen+2)
+	if e != nil {
+		return nil, 0, false, e
+	}
+	if !bytes.Equal(key[2*keyLen:], verifier) {
+		return nil, 0, false, errPassword
+	}
+	mac := hmac.New(sha1.New, key[keyLen:2*keyLen])
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil)[:10], auth) {
+		return nil, 0, false, errors.New(zf.Name + ": the archive is damaged")
+	}
+	block, e := aes.NewCipher(key[:keyLen])
+	if e != nil {
+		return nil, 0, false, e
+	}
+	// CTR mode with a little-endian counter from 1.
+	out := make([]byte, len(data))
+	var ctr, ks [aes.BlockSize]byte
+	for i := 0; i < len(data); i += aes.BlockSize {
+		binary.LittleEndian.PutUint64(ctr[:], uint64(i/aes.BlockSize+1))
+		block.Encrypt(ks[:], ctr[:])
+		for j := i; j < min(i+aes.BlockSize, len(data)); j++ {
+			out[j] = data[j] ^ ks[j-i]
+		}
+	}
+	return out, method, version == 1, nil
+}
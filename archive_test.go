@@ -0,0 +1,212 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// the texts of the books of testdata, packed by testdata's archives with password "fish".
+var (
+	chapter1 = strings.Repeat("Chapter 1\n\nIt was a bright cold day in April, and the clocks were striking thirteen.\n", 3)
+	chapter2 = strings.Repeat("Chapter 2\n\nThe hallway smelt of boiled cabbage and old rag mats.\n", 3)
+)
+
+func TestDecryptZipped(t *testing.T) {
+	tests := []struct {
+		f, pw string
+		e     error
+	}{
+		{"testdata/pkware.zip", "fish", nil},
+		{"testdata/pkware.zip", "fosh", errPassword},
+		{"testdata/aes128.zip", "fish", nil},
+		{"testdata/aes128.zip", "fosh", errPassword},
+		{"testdata/aes256.zip", "fish", nil},
+		{"testdata/aes256.zip", "fosh", errPassword},
+	}
+	for _, tt := range tests {
+		z, e := zip.OpenReader(tt.f)
+		if e != nil {
+			t.Fatal(e)
+		}
+		dd, e := decryptZipped(z.File[0], tt.pw)
+		switch {
+		case !errors.Is(e, tt.e):
+			t.Errorf("%s with %q: error %v, want %v", tt.f, tt.pw, e, tt.e)
+		case e == nil && string(dd) != chapter1:
+			t.Errorf("%s with %q: %q, want %q", tt.f, tt.pw, dd, chapter1)
+		}
+		_ = z.Close()
+	}
+}
+
+// TestDecryptZippedDamaged checks a changed byte of the encrypted data is told from a wrong password.
+func TestDecryptZippedDamaged(t *testing.T) {
+	dd, e := os.ReadFile("testdata/aes256.zip")
+	if e != nil {
+		t.Fatal(e)
+	}
+	// past the local header, the name, the extra field, the salt and the verifier.
+	dd[30+8+11+16+2] ^= 1
+	f := t.TempDir() + "/damaged.zip"
+	if e := os.WriteFile(f, dd, 0o600); e != nil {
+		t.Fatal(e)
+	}
+	z, e := zip.OpenReader(f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer func() { _ = z.Close() }()
+	if _, e := decryptZipped(z.File[0], "fish"); e == nil || errors.Is(e, errPassword) {
+		t.Errorf("error %v, want the archive damaged", e)
+	}
+}
+
+// withPasswords gives the passwords of the archives of a test instead of asking for them.
+func withPasswords(t *testing.T, pw map[string]string) {
+	saved, savedAsk := passwords, askPassword
+	passwords, askPassword = pw, nil
+	t.Cleanup(func() { passwords, askPassword = saved, savedAsk })
+}
+
+func TestSevenZip(t *testing.T) {
+	tests := []struct {
+		f     string
+		names []string
+		texts []string
+	}{
+		{"testdata/lzma.7z", []string{"a.txt"}, []string{chapter1}},
+		{"testdata/solid.7z", []string{"book/1.txt", "book/2.txt"}, []string{chapter1, chapter2}},
+		{"testdata/encrypted.7z", []string{"1.txt", "2.txt"}, []string{chapter1, chapter2}},
+	}
+	withPasswords(t, map[string]string{"testdata/encrypted.7z": "fish"})
+	for _, tt := range tests {
+		fd, e := os.Open(tt.f)
+		if e != nil {
+			t.Fatal(e)
+		}
+		fi, _ := fd.Stat()
+		z, e := openSevenZip(tt.f, fd, fi.Size())
+		if e != nil {
+			t.Errorf("%s: %v", tt.f, e)
+			_ = fd.Close()
+			continue
+		}
+		var names []string
+		for _, zf := range z.files {
+			if !isBook(zf.name, zf.dir) {
+				continue
+			}
+			names = append(names, zf.name)
+			dd, e := z.read(zf)
+			switch {
+			case e != nil:
+				t.Errorf("%s: %s: %v", tt.f, zf.name, e)
+			case len(names) <= len(tt.texts) && string(dd) != tt.texts[len(names)-1]:
+				t.Errorf("%s: %s: %q, want %q", tt.f, zf.name, dd, tt.texts[len(names)-1])
+			}
+		}
+		if strings.Join(names, " ") != strings.Join(tt.names, " ") {
+			t.Errorf("%s: books %q, want %q", tt.f, names, tt.names)
+		}
+		_ = fd.Close()
+	}
+}
+
+func TestSevenZipWrongPassword(t *testing.T) {
+	withPasswords(t, map[string]string{"testdata/encrypted.7z": "fosh"})
+	_, e := loadSevenZip("testdata/encrypted.7z", "")
+	if e == nil || !strings.Contains(e.Error(), "wrong password") {
+		t.Errorf("error %v, want a wrong password", e)
+	}
+	if _, ok := passwords["testdata/encrypted.7z"]; ok {
+		t.Error("the wrong password is kept")
+	}
+}
+
+func TestLoadSevenZip(t *testing.T) {
+	d, e := loadSevenZip("testdata/solid.7z", "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	text := d.lines.joined()
+	for _, s := range []string{"bright cold day", "boiled cabbage"} {
+		if !strings.Contains(text, s) {
+			t.Errorf("the book has no %q", s)
+		}
+	}
+	if len(d.headings) == 0 || d.headings[0].title != "1" {
+		t.Errorf("headings %+v, want the first titled 1", d.headings)
+	}
+}
+
+// withHeaderCRCs returns 7z archive dd with the CRCs of its start header and next header made
+// right, so a changed header is parsed instead of refused.
+func withHeaderCRCs(dd []byte) []byte {
+	dd = slices.Clone(dd)
+	if len(dd) < 32 {
+		return dd
+	}
+	off, n := binary.LittleEndian.Uint64(dd[12:]), binary.LittleEndian.Uint64(dd[20:])
+	if off < uint64(len(dd)-32) && n <= uint64(len(dd)-32)-off {
+		binary.LittleEndian.PutUint32(dd[28:], crc32.ChecksumIEEE(dd[32+off:32+off+n]))
+	}
+	binary.LittleEndian.PutUint32(dd[8:], crc32.ChecksumIEEE(dd[12:32]))
+	return dd
+}
+
+// readSevenZip opens 7z archive dd and reads all its files, for the errors they give.
+func readSevenZip(dd []byte) {
+	z, e := openSevenZip("damaged.7z", bytes.NewReader(dd), int64(len(dd)))
+	if e != nil {
+		return
+	}
+	for _, zf := range z.files {
+		_, _ = z.read(zf)
+	}
+}
+
+// TestSevenZipDamagedHeader checks changing any byte of the headers of the testdata archives
+// gives an error or a wrong book, and does not crash.
+func TestSevenZipDamagedHeader(t *testing.T) {
+	withPasswords(t, map[string]string{"damaged.7z": "fish"})
+	for _, f := range []string{"testdata/lzma.7z", "testdata/solid.7z"} {
+		dd, e := os.ReadFile(f)
+		if e != nil {
+			t.Fatal(e)
+		}
+		off, n := int(binary.LittleEndian.Uint64(dd[12:])), int(binary.LittleEndian.Uint64(dd[20:]))
+		for i := 32 + off; i < 32+off+n; i++ {
+			for _, x := range []byte{0x01, 0x80, 0xff} {
+				dd[i] ^= x
+				readSevenZip(withHeaderCRCs(dd))
+				dd[i] ^= x
+			}
+		}
+		for i := 32 + off; i < 32+off+n; i++ {
+			// cut short, with the size of the next header saying so.
+			cut := slices.Clone(dd[:i])
+			binary.LittleEndian.PutUint64(cut[20:], uint64(i-32-off))
+			readSevenZip(withHeaderCRCs(cut))
+		}
+	}
+}
+
+func FuzzSevenZip(f *testing.F) {
+	for _, s := range []string{"testdata/lzma.7z", "testdata/solid.7z"} {
+		dd, e := os.ReadFile(s)
+		if e != nil {
+			f.Fatal(e)
+		}
+		f.Add(dd)
+	}
+	f.Fuzz(func(t *testing.T, dd []byte) {
+		readSevenZip(withHeaderCRCs(dd))
+	})
+}
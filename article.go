@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ArticleDir in the home directory keeps the web pages read, as the article taken out of them.
+// A page is fetched once, its copy is read again later and offline.
+const ArticleDir = ".cmdline-reader-articles"
+
+// articleTimeout is how long fetching a page may take, maxArticle the bytes read of it.
+const (
+	articleTimeout = 30 * time.Second
+	maxArticle     = 32 << 20
+)
+
+// isURL tells if the book given is a web or Gemini page.
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "gemini://")
+}
+
+// page is a page fetched, with its media type and the address it was found at after redirects.
+type page struct {
+	body  []byte
+	ctype string
+	url   *url.URL
+}
+
+// tempArticles is the temporary directory of the pages fetched by a session which saves
+// nothing, "" until one is. The caller of the session removes it on quit.
+var tempArticles string
+
+// articleFile returns the file of book s: s itself unless it is a URL, whose page is fetched
+// and saved in ArticleDir the first time. The article of an HTML page is saved without the
+// menus, sidebars and comments around it, Gemini pages with their links made absolute, other
+// files like .txt as they are. Without keep, a page not saved yet is fetched into tempArticles
+// instead, for --private and --no-save.
+func articleFile(s string, keep bool) (string, error) {
+	if !isURL(s) {
+		return s, nil
+	}
+	u, e := url.Parse(s)
+	if e != nil {
+		return "", e
+	}
+	home, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+	dir := filepath.Join(home, ArticleDir)
+	base := filepath.Join(dir, articleName(u))
+	ext := strings.ToLower(path.Ext(u.Path))
+	for _, x := range []string{".html", ".gmi", ext, ".txt"} {
+		if _, e := os.Stat(base + x); x != "" && e == nil {
+			return base + x, nil
+		}
+	}
+	if !keep {
+		if tempArticles == "" {
+			if tempArticles, e = os.MkdirTemp("", "fish-"); e != nil {
+				return "", e
+			}
+		}
+		dir = tempArticles
+		base = filepath.Join(dir, articleName(u))
+	}
+	var p *page
+	if u.Scheme == "gemini" {
+		p, e = fetchGemini(u, keep)
+	} else {
+		p, e = fetchHTTP(s)
+	}
+	if e != nil {
+		return "", e
+	}
+	mt, _, _ := mime.ParseMediaType(p.ctype)
+	dd := p.body
+	switch {
+	case mt == "text/html" || mt == "application/xhtml+xml":
+		in, e := charset.NewReader(bytes.NewReader(dd), p.ctype)
+		if e != nil {
+			return "", e
+		}
+		doc, e := html.Parse(in)
+		if e != nil {
+			return "", e
+		}
+		a := extractArticle(doc, p.url)
+		if a.content == nil {
+			return "", errors.New(s + tr(": no article found on the page"))
+		}
+		dd, ext = []byte(a.html(s)), ".html"
+	case mt == "text/gemini":
+		dd, ext = []byte(absoluteLinks(string(dd), p.url)), ".gmi"
+	case ext != "" && ext != ".html" && ext != ".htm", strings.HasPrefix(mt, "text/"):
+		if ext == "" {
+			ext = ".txt"
+		}
+	default:
+		return "", fmt.Errorf(tr("%s is %s, fish reads web pages and text"), s, mt)
+	}
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return "", e
+	}
+	if e := os.WriteFile(base+ext, dd, 0644); e != nil {
+		return "", e
+	}
+	return base + ext, nil
+}
+
+// fetchHTTP gets the web page at s.
+func fetchHTTP(s string) (*page, error) {
+	c := http.Client{Timeout: articleTimeout}
+	req, e := http.NewRequest(http.MethodGet, s, nil)
+	if e != nil {
+		return nil, e
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; fish/"+version+")")
+	res, e := c.Do(req)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", s, res.Status)
+	}
+	dd, e := io.ReadAll(io.LimitReader(res.Body, maxArticle))
+	if e != nil {
+		return nil, e
+	}
+	return &page{body: dd, ctype: res.Header.Get("Content-Type"), url: res.Request.URL}, nil
+}
+
+// nameJunk are the runs of characters left out of the names of the copies.
+var nameJunk = regexp.MustCompile(`[^A-Za-z0-9.]+`)
+
+// articleName is the file name of the copy of page u without its extension, its host and path.
+// A query is told apart by a hash of it.
+func articleName(u *url.URL) string {
+	p := strings.TrimSuffix(u.Path, path.Ext(u.Path))
+	name := strings.Trim(nameJunk.ReplaceAllString(u.Host+" "+p, "-"), "-.")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	if u.RawQuery != "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(u.RawQuery))
+		name += fmt.Sprintf("-%08x", h.Sum32())
+	}
+	return name
+}
+
+// article is the readable part of a web page.
+type article struct {
+	title, byline, lang string
+	content             []*html.Node // the article and the paragraphs beside it, in page order.
+}
+
+// html writes the article as a standalone page, with its title, byline and address on top.
+func (a article) html(src string) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html")
+	if a.lang != "" {
+		b.WriteString(` lang="` + html.EscapeString(a.lang) + `"`)
+	}
+	b.WriteString(`><head><meta charset="utf-8"><title>` + html.EscapeString(a.title) + "</title>")
+	if a.byline != "" {
+		b.WriteString(`<meta name="author" content="` + html.EscapeString(a.byline) + `">`)
+	}
+	b.WriteString("</head>\n<body>\n")
+	if a.title != "" {
+		b.WriteString("<h1>" + html.EscapeString(a.title) + "</h1>\n")
+	}
+	if a.byline != "" {
+		b.WriteString("<p>" + html.EscapeString(a.byline) + "</p>\n")
+	}
+	b.WriteString(`<p><a href="` + html.EscapeString(src) + `">` + html.EscapeString(src) + "</a></p>\n")
+	for _, n := range a.content {
+		_ = html.Render(&b, n)
+		b.WriteString("\n")
+	}
+	b.WriteString("</body></html>\n")
+	return b.String()
+}
+
+// The extraction goes the way of Readability: boilerplate is dropped, paragraphs give points to
+// the elements holding them, and the element of most points less its share of link text is the
+// article. Class names and ids tell menus and comments from content.
+var (
+	unlikelyClass = regexp.MustCompile(`(?i)banner|breadcrumb|comment|community|cookie|disqus|extra|footer|header|menu|modal|nav|newsletter|pager|popup|promo|related|remark|replies|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|widget|ad-break|agegate|pagination`)
+	likelyClass   = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow|story|entry|post|text`)
+	positiveClass = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeClass = regexp.MustCompile(`(?i)hidden|banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylineClass   = regexp.MustCompile(`(?i)byline|author|dateline|writtenby`)
+)
+
+// junkTags are elements which are never part of an article.
+var junkTags = []atom.Atom{
+	atom.Script, atom.Style, atom.Noscript, atom.Iframe, atom.Form, atom.Button, atom.Input,
+	atom.Select, atom.Textarea, atom.Svg, atom.Canvas, atom.Nav, atom.Aside, atom.Footer,
+	atom.Header, atom.Object, atom.Embed, atom.Link, atom.Meta,
+}
+
+// extractArticle finds the article of page doc fetched from u.
+func extractArticle(doc *html.Node, u *url.URL) article {
+	var a article
+	a.title, a.byline, a.lang = pageMeta(doc)
+	var body *html.Node
+	var junk []*html.Node
+	walkNodes(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		switch {
+		case n.DataAtom == atom.Body:
+			body = n
+			return true
+		case n.DataAtom == atom.Base:
+			if href, ok := attr(n, "href"); ok {
+				if b, e := u.Parse(href); e == nil {
+					u = b
+				}
+			}
+		case slices.Contains(junkTags, n.DataAtom), hiddenNode(n):
+			junk = append(junk, n)
+			return false
+		}
+		class := classID(n)
+		if bylineClass.MatchString(class) {
+			if t := strings.Join(strings.Fields(nodeText(n)), " "); t != "" && len(t) < 100 {
+				if a.byline == "" {
+					a.byline = t
+				}
+				junk = append(junk, n)
+				return false
+			}
+		}
+		switch n.DataAtom {
+		case atom.Html, atom.Body, atom.Article, atom.Main:
+		default:
+			if unlikelyClass.MatchString(class) && !likelyClass.MatchString(class) {
+				junk = append(junk, n)
+				return false
+			}
+		}
+		return true
+	})
+	for _, n := range junk {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+	if body == nil {
+		return a
+	}
+	scores := scoreNodes(body)
+	top := topCandidate(body, scores)
+	a.content = []*html.Node{top}
+	if top != body {
+		a.content = siblingContent(top, scores)
+	}
+	for _, n := range a.content {
+		cleanArticle(n, a.title, u)
+	}
+	return a
+}
+
+// pageMeta returns the title, author and language of a page.
+func pageMeta(doc *html.Node) (title, byline, lang string) {
+	var h1 []string
+	walkNodes(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+		switch n.DataAtom {
+		case atom.Html:
+			lang, _ = attr(n, "lang")
+		case atom.Title:
+			if title == "" {
+				title = strings.Join(strings.Fields(nodeText(n)), " ")
+			}
+		case atom.H1:
+			h1 = append(h1, strings.Join(strings.Fields(nodeText(n)), " "))
+		case atom.Meta:
+			name, _ := attr(n, "name")
+			if name == "" {
+				name, _ = attr(n, "property")
+			}
+			content, _ := attr(n, "content")
+			content = strings.TrimSpace(content)
+			switch strings.ToLower(name) {
+			case "og:title", "twitter:title":
+				title = content
+			case "author", "article:author", "dc.creator":
+				if !isURL(content) {
+					byline = content
+				}
+			}
+		}
+		return true
+	})
+	// "Title | Site", unless a heading of the page is the whole of it.
+	for _, sep := range []string{" | ", " - ", " – ", " — ", " :: ", " » "} {
+		if i := strings.LastIndex(title, sep); i > 0 && !slices.Contains(h1, title) {
+			if t := title[:i]; len(strings.Fields(t)) >= 3 || slices.Contains(h1, t) {
+				title = t
+			}
+			break
+		}
+	}
+	if title == "" && len(h1) == 1 {
+		title = h1[0]
+	}
+	return title, byline, lang
+}
+
+// walkNodes calls fn on n and the nodes under it in document order, skipping those under a
+// node fn returns false for.
+func walkNodes(n *html.Node, fn func(*html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		walkNodes(c, fn)
+		c = next
+	}
+}
+
+// classID is the class and id of n, as the patterns match them.
+func classID(n *html.Node) string {
+	class, _ := attr(n, "class")
+	id, _ := attr(n, "id")
+	return class + " " + id
+}
+
+// hiddenNode tells if n is not shown by browsers.
+func hiddenNode(n *html.Node) bool {
+	if _, ok := attr(n, "hidden"); ok {
+		return true
+	}
+	if v, _ := attr(n, "aria-hidden"); v == "true" {
+		return true
+	}
+	style, _ := attr(n, "style")
+	style = strings.ReplaceAll(style, " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// classWeight is the points of n by its class and id.
+func classWeight(n *html.Node) float64 {
+	w := 0.0
+	class := classID(n)
+	if negativeClass.MatchString(class) {
+		w -= 25
+	}
+	if positiveClass.MatchString(class) {
+		w += 25
+	}
+	return w
+}
+
+// textLength is the length of the text of n with its white space collapsed.
+func textLength(n *html.Node) int {
+	return len(strings.Join(strings.Fields(nodeText(n)), " "))
+}
+
+// linkDensity is the share of the text of n which is in links.
+func linkDensity(n *html.Node) float64 {
+	total := textLength(n)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walkNodes(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			links += textLength(c)
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// scoreNodes gives each paragraph's parent its points and half of them to its grandparent.
+// An element starts with points by its tag and class. The scores are less the link share.
+func scoreNodes(body *html.Node) map[*html.Node]float64 {
+	scores := make(map[*html.Node]float64)
+	start := func(n *html.Node) {
+		if _, ok := scores[n]; ok || n.Type != html.ElementNode {
+			return
+		}
+		s := classWeight(n)
+		switch n.DataAtom {
+		case atom.Div, atom.Article, atom.Section, atom.Main:
+			s += 5
+		case atom.Pre, atom.Td, atom.Blockquote:
+			s += 3
+		case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li:
+			s -= 3
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+			s -= 5
+		}
+		scores[n] = s
+	}
+	walkNodes(body, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td:
+		default:
+			return true
+		}
+		text := strings.Join(strings.Fields(nodeText(n)), " ")
+		if len(text) < 25 || n.Parent == nil {
+			return false
+		}
+		points := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，")) + min(float64(len(text))/100, 3)
+		p := n.Parent
+		start(p)
+		scores[p] += points
+		if g := p.Parent; g != nil && g.Type == html.ElementNode {
+			start(g)
+			scores[g] += points / 2
+		}
+		return false
+	})
+	for n, s := range scores {
+		scores[n] = s * (1 - linkDensity(n))
+	}
+	return scores
+}
+
+// topCandidate is the element of most points under body, body itself if no paragraph was found.
+func topCandidate(body *html.Node, scores map[*html.Node]float64) *html.Node {
+	top, best := body, 0.0
+	walkNodes(body, func(n *html.Node) bool {
+		if s, ok := scores[n]; ok && (top == body || s > best) {
+			top, best = n, s
+		}
+		return true
+	})
+	return top
+}
+
+// siblingContent returns top with the siblings which are part of the article too: those of
+// enough points, and paragraphs with little link text.
+func siblingContent(top *html.Node, scores map[*html.Node]float64) []*html.Node {
+	threshold := max(10, scores[top]*0.2)
+	var out []*html.Node
+	for s := top.Parent.FirstChild; s != nil; s = s.NextSibling {
+		if s == top {
+			out = append(out, s)
+			continue
+		}
+		if s.Type != html.ElementNode {
+			continue
+		}
+		bonus := 0.0
+		if class, _ := attr(s, "class"); class != "" {
+			if c, _ := attr(top, "class"); c == class {
+				bonus = scores[top] * 0.2
+			}
+		}
+		if sc, ok := scores[s]; ok && sc+bonus >= threshold {
+			out = append(out, s)
+			continue
+		}
+		if s.DataAtom == atom.P {
+			text := strings.Join(strings.Fields(nodeText(s)), " ")
+			ld := linkDensity(s)
+			if len(text) > 80 && ld < 0.25 || len(text) > 0 && ld == 0 && strings.HasSuffix(text, ".") {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// cleanArticle drops the lists and boxes of mostly links in n, headings repeating the title and
+// attributes besides a few, and makes the links absolute against the page u.
+func cleanArticle(n *html.Node, title string, u *url.URL) {
+	var drop []*html.Node
+	walkNodes(n, func(c *html.Node) bool {
+		if c.Type != html.ElementNode {
+			return true
+		}
+		switch c.DataAtom {
+		case atom.H1, atom.H2:
+			if strings.Join(strings.Fields(nodeText(c)), " ") == title {
+				drop = append(drop, c)
+				return false
+			}
+		case atom.Ul, atom.Ol, atom.Div, atom.Section, atom.Table:
+			if c != n && (linkDensity(c) > 0.5 || classWeight(c) < 0 && textLength(c) < 250) {
+				drop = append(drop, c)
+				return false
+			}
+		}
+		var keep []html.Attribute
+		for _, a := range c.Attr {
+			switch a.Key {
+			case "href", "src":
+				if v, e := u.Parse(a.Val); e == nil && !strings.HasPrefix(a.Val, "#") {
+					a.Val = v.String()
+				}
+			case "id", "name", "alt", "lang", "role", "epub:type":
+			default:
+				continue
+			}
+			keep = append(keep, a)
+		}
+		c.Attr = keep
+		return true
+	})
+	for _, c := range drop {
+		if c.Parent != nil {
+			c.Parent.RemoveChild(c)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Bookmark is a line of a book marked by the reader, with an optional note.
+type Bookmark struct {
+	Line int       `json:"line"` // of the source, like Book.Line.
+	Note string    `json:"note,omitempty"`
+	Text string    `json:"text,omitempty"` // of the line when it was marked, searched without opening the book.
+	At   time.Time `json:"at,omitzero"`    // when it was made, a deletion synced before does not remove it.
+}
+
+// addBookmark asks for a note and marks the line at the top of the page, a line marked before
+// gets the new note.
+func (r *Reader) addBookmark() {
+	if r.stream != nil {
+		r.notice = tr("streamed books keep no bookmarks")
+		return
+	}
+	line := r.doc.source(r.currentLine)
+	note := ""
+	if i := r.bookmark(line); i >= 0 {
+		note = r.book().Marks[i].Note
+	}
+	r.overlay = &prompt{label: tr("bookmark note: "), text: []rune(note), done: func(r *Reader, s string) {
+		b := r.book()
+		if i := r.bookmark(line); i >= 0 {
+			b.Marks[i].Note = s
+		} else {
+			text := strings.TrimSpace(stripControls(r.index.at(r.currentLine), false))
+			b.Marks = append(b.Marks, Bookmark{Line: line, Note: s, Text: text, At: time.Now()})
+			slices.SortFunc(b.Marks, func(x, y Bookmark) int { return x.Line - y.Line })
+		}
+		r.journalMarks()
+		r.notice = fmt.Sprintf(tr("line %d bookmarked, ' lists the bookmarks"), line+1)
+	}}
+}
+
+// bookmark returns the index of the bookmark of source line line, -1 if it has none.
+func (r *Reader) bookmark(line int) int {
+	return slices.IndexFunc(r.book().Marks, func(m Bookmark) bool { return m.Line == line })
+}
+
+// marks is the overlay listing the bookmarks of the book, the one at sel is chosen.
+type marks struct {
+	sel int
+}
+
+// openBookmarks lists the bookmarks of the book, the last one before the page chosen.
+func (r *Reader) openBookmarks() {
+	mm := r.book().Marks
+	if len(mm) == 0 {
+		r.notice = tr("no bookmarks, m marks the page")
+		return
+	}
+	at := r.doc.source(r.currentLine)
+	sel := 0
+	for i, m := range mm {
+		if m.Line <= at {
+			sel = i
+		}
+	}
+	r.overlay = &marks{sel: sel}
+}
+
+func (o *marks) key(r *Reader, k string) bool {
+	b := r.book()
+	page := r.winHeight - 5
+	switch k {
+	case "up":
+		o.sel--
+	case "down":
+		o.sel++
+	case "pgup", "left":
+		o.sel -= page
+	case "pgdn", "right":
+		o.sel += page
+	case "home":
+		o.sel = 0
+	case "end":
+		o.sel = len(b.Marks) - 1
+	case "enter":
+		r.jump(r.doc.view(b.Marks[o.sel].Line))
+		return true
+	case "d", "delete":
+		r.forget(r.f, b.Marks[o.sel].Line)
+		b.Marks = slices.Delete(b.Marks, o.sel, o.sel+1)
+		r.journalMarks()
+		if len(b.Marks) == 0 {
+			return true
+		}
+	case "r":
+		m := &b.Marks[o.sel]
+		r.overlay = &prompt{label: tr("bookmark note: "), text: []rune(m.Note), done: func(r *Reader, s string) {
+			m.Note = s
+			r.journalMarks()
+			r.overlay = o
+		}}
+		return false
+	case "esc", "q":
+		return true
+	}
+	o.sel = max(0, min(o.sel, len(b.Marks)-1))
+	return false
+}
+
+func (o *marks) draw(r *Reader, b *strings.Builder) {
+	mm := r.book().Marks
+	items := make([]string, len(mm))
+	for i, m := range mm {
+		text := ""
+		if v := r.doc.view(m.Line); v >= 0 && v < r.totalLine {
+			text = strings.TrimSpace(stripControls(r.index.at(v), false))
+		}
+		if m.Note != "" {
+			text = m.Note + " — " + text
+		}
+		items[i] = fmt.Sprintf("%6d  %s", m.Line+1, text)
+	}
+	r.drawBox(b, "Bookmarks", items, o.sel, "Enter:Jump D:Delete R:Rename Esc:Close")
+}
+
+// searchBookmarks lists the bookmarks of the book whose note or text contain s, ignoring case,
+// those of all books in the progress file if all is set. Enter opens the book at one.
+func (r *Reader) searchBookmarks(s string, all bool) error {
+	if s == "" {
+		return errors.New(tr("usage: :notes <text>, :allnotes <text> searches all books"))
+	}
+	ff := []string{r.f}
+	if all {
+		ff = slices.Sorted(maps.Keys(r.progress))
+	}
+	type found struct {
+		f string
+		Bookmark
+	}
+	var hits []found
+	var items []string
+	s = strings.ToLower(s)
+	for _, f := range ff {
+		b := r.progress[f]
+		if b == nil {
+			continue
+		}
+		for _, m := range b.Marks {
+			if f == r.f {
+				if v := r.doc.view(m.Line); v >= 0 && v < r.totalLine {
+					m.Text = strings.TrimSpace(stripControls(r.index.at(v), false))
+				}
+			}
+			if !strings.Contains(strings.ToLower(m.Note+"\n"+m.Text), s) {
+				continue
+			}
+			item := fmt.Sprintf("%6d  %s", m.Line+1, m.Text)
+			if m.Note != "" {
+				item = fmt.Sprintf("%6d  %s — %s", m.Line+1, m.Note, m.Text)
+			}
+			if all {
+				item = bookName(f, b) + "  " + item
+			}
+			hits, items = append(hits, found{f, m}), append(items, item)
+		}
+	}
+	if len(hits) == 0 {
+		r.notice = tr("no bookmarks match")
+		return nil
+	}
+	r.overlay = &list{
+		title: "Bookmarks",
+		items: items,
+		foot:  "Enter:Jump Esc:Close",
+		pick: func(r *Reader, i int) {
+			if e := r.open(hits[i].f); e != nil {
+				r.notice = e.Error()
+				return
+			}
+			r.jump(r.doc.view(hits[i].Line))
+			r.overlay = nil
+		},
+	}
+	return nil
+}
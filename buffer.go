@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// buffer is the state of a book open in the session while another one is read.
+type buffer struct {
+	f                     string
+	doc, base             *document
+	folded                map[int]bool
+	grep                  *regexp.Regexp
+	follow                bool
+	stamp                 fileStamp
+	stream                *window
+	diff, parallel        string
+	cfg                   Config
+	index                 lineIndex
+	chapters              []chapter
+	totalLine             int
+	currentLine           int
+	previousSavedLine     int
+	jumpBreakMark         int
+	displayBreakMark      bool
+	resumeMark            int
+	displayResumeMark     bool
+	jumpBack, jumpForward []int
+	hscroll               int
+	split, lower          bool
+	other                 int
+	scrolling             time.Duration
+}
+
+// stash returns the state of the current book.
+func (r *Reader) stash() *buffer {
+	return &buffer{r.f, r.doc, r.base, r.folded, r.grep, r.follow, r.stamp, r.stream, r.diff, r.parallel, r.cfg,
+		r.index, r.chapters, r.totalLine, r.currentLine, r.previousSavedLine, r.jumpBreakMark,
+		r.displayBreakMark, r.resumeMark, r.displayResumeMark, r.jumpBack, r.jumpForward,
+		r.hscroll, r.split, r.lower, r.other, r.scrolling}
+}
+
+// restore makes the book of buffer b the current one.
+func (r *Reader) restore(b *buffer) {
+	r.f, r.doc, r.base, r.folded, r.grep, r.follow, r.stamp, r.diff, r.parallel, r.cfg = b.f, b.doc, b.base, b.folded, b.grep, b.follow, b.stamp, b.diff, b.parallel, b.cfg
+	r.stream = b.stream
+	r.index, r.chapters, r.totalLine, r.currentLine, r.previousSavedLine = b.index, b.chapters, b.totalLine, b.currentLine, b.previousSavedLine
+	r.jumpBreakMark, r.displayBreakMark, r.resumeMark, r.displayResumeMark = b.jumpBreakMark, b.displayBreakMark, b.resumeMark, b.displayResumeMark
+	r.jumpBack, r.jumpForward = b.jumpBack, b.jumpForward
+	r.hscroll, r.split, r.lower, r.other = b.hscroll, b.split, b.lower, b.other
+	r.wordsBefore = nil
+	r.setScrolling(b.scrolling)
+}
+
+// switchTo makes book f current, from its buffer if it is open. It tells if it was.
+func (r *Reader) switchTo(f string) bool {
+	b, ok := r.buffers[f]
+	if !ok {
+		return false
+	}
+	r.buffers[r.f] = r.stash()
+	delete(r.buffers, f)
+	r.restore(b)
+	return true
+}
+
+// homePath returns path p with a leading ~/ in the home directory.
+func homePath(p string) (string, error) {
+	rest, ok := strings.CutPrefix(p, "~/")
+	if !ok {
+		return p, nil
+	}
+	home, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// openPath opens the book at path p, relative to the working directory or ~, or the web page at URL p.
+func (r *Reader) openPath(p string) error {
+	if p == "" {
+		return errors.New(tr("usage: :open <file>"))
+	}
+	p, e := articleFile(p, r.saves())
+	if e != nil {
+		return e
+	}
+	if p, e = homePath(p); e != nil {
+		return e
+	}
+	if p, e = filepath.Abs(p); e != nil {
+		return e
+	}
+	if _, e := os.Stat(p); e != nil {
+		return e
+	}
+	return r.open(p)
+}
+
+// openBuffers lists the books open in the session, in the order they were opened. Enter switches to one.
+func (r *Reader) openBuffers() {
+	ff := slices.Clone(r.opened)
+	items := make([]string, len(ff))
+	sel := 0
+	for i, f := range ff {
+		b := r.book()
+		if f != r.f {
+			b = r.progress[f]
+		}
+		if b == nil {
+			b = &Book{}
+		}
+		items[i] = libraryItem(f, b)
+		if f == r.f {
+			items[i] = "* " + items[i]
+			sel = i
+		} else {
+			items[i] = "  " + items[i]
+		}
+	}
+	r.overlay = &list{
+		title: "Open books",
+		items: items,
+		sel:   sel,
+		foot:  "Enter:Switch Esc:Close",
+		pick: func(r *Reader, i int) {
+			if e := r.open(ff[i]); e != nil {
+				r.notice = e.Error()
+			}
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// calendarCells are the cells of the calendar, for days not read and for more and more minutes.
+var calendarCells = []string{"·", "░", "▒", "▓", "█"}
+
+// calendarCell is the index in calendarCells of a day with seconds of reading.
+func calendarCell(seconds int) int {
+	switch m := seconds / 60; {
+	case seconds == 0:
+		return 0
+	case m < 15:
+		return 1
+	case m < 30:
+		return 2
+	case m < 60:
+		return 3
+	}
+	return 4
+}
+
+// Calendar draws the time read each day of the year until now as a heatmap, a column a week
+// from Monday to Sunday, in the colors of the theme in the config when the output is a terminal.
+func Calendar(now time.Time) (string, error) {
+	c, e := LoadConfig()
+	if e != nil {
+		return "", e
+	}
+	useColors(c)
+	_, progress, e := readBooks()
+	if e != nil {
+		return "", e
+	}
+	days := make(map[string]int) // date:seconds read in all books.
+	for _, b := range progress {
+		if b != nil {
+			for d, s := range b.Days {
+				days[d] += s
+			}
+		}
+	}
+	t, ok := themes[c.Theme]
+	if !ok || !term.IsTerminal(int(os.Stdout.Fd())) {
+		t = Theme{}
+	}
+	cell, empty, reset := sgr(t.StatusBg, t.Bg), t.mark(), "\x1b[0m"
+	if t.StatusBg == "" {
+		cell = sgr(t.Fg, t.Bg)
+	}
+	if t == (Theme{}) {
+		cell, empty, reset = "", "", ""
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	start := today.AddDate(-1, 0, 1)
+	start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7) // the Monday before.
+	var rows [7]strings.Builder
+	months := []rune(strings.Repeat(" ", 60))
+	total, read := 0, 0
+	week := 0
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		row := (int(d.Weekday()) + 6) % 7
+		if row == 0 && d.Day() <= 7 && week+3 < len(months) && (week == 0 || months[week-1] == ' ') {
+			copy(months[week:], []rune(d.Format("Jan")))
+		}
+		s := days[d.Format(time.DateOnly)]
+		if s > 0 {
+			total += s
+			read++
+		}
+		i := calendarCell(s)
+		if i == 0 {
+			rows[row].WriteString(empty + calendarCells[0] + reset)
+		} else {
+			rows[row].WriteString(cell + calendarCells[i] + reset)
+		}
+		if row == 6 {
+			week++
+		}
+	}
+	labels := [7]string{"Mon", "", "Wed", "", "Fri", "", "Sun"}
+	var b strings.Builder
+	b.WriteString("    " + strings.TrimRight(string(months), " ") + "\n")
+	for i := range rows {
+		_, _ = fmt.Fprintf(&b, "%-4s%s\n", labels[i], rows[i].String())
+	}
+	b.WriteString("\n    " + empty + calendarCells[0] + reset + " none")
+	for i, s := range []string{"under 15m", "under 30m", "under 1h", "1h or more"} {
+		b.WriteString("  " + cell + calendarCells[i+1] + reset + " " + s)
+	}
+	b.WriteString("\n")
+	_, _ = fmt.Fprintf(&b, "    %s read in %d days of the past year\n", readTime(total), read)
+	return termColors(b.String()), nil
+}
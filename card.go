@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// cardWidth is the columns of the text inside a quote card, without its frame and margins.
+const cardWidth = 44
+
+// quoteCard returns the text of lines framed as a card to share, the quote wrapped and centered
+// and the title and author of the book under it. Lines with text between blank ones are joined
+// into paragraphs, ruby notation is left out.
+func quoteCard(title, author string, lines []string) string {
+	var paras []string
+	p := ""
+	for _, l := range lines {
+		l = strings.TrimSpace(stripControls(l, false))
+		if strings.ContainsAny(l, "《［") {
+			s, rr := parseRuby(l)
+			l = rubyInline(s, rr, true)
+		}
+		switch {
+		case l == "":
+			if p != "" {
+				paras, p = append(paras, p), ""
+			}
+		case p == "":
+			p = l
+		default:
+			last, _ := utf8.DecodeLastRuneInString(p)
+			first, _ := utf8.DecodeRuneInString(l)
+			if !cjk(last) && !cjk(first) && runeWidth(first) < 2 {
+				p += " "
+			}
+			p += l
+		}
+	}
+	if p != "" {
+		paras = append(paras, p)
+	}
+	var rows []string
+	for i, p := range paras {
+		if i > 0 {
+			rows = append(rows, "")
+		}
+		rows = append(rows, center(wrap(p, cardWidth, nil), cardWidth)...)
+	}
+	by := "— " + title
+	if author != "" {
+		by += ", " + author
+	}
+	rows = append(rows, "", strings.Repeat(" ", max(0, cardWidth-strWidth(by)))+by)
+	var sb strings.Builder
+	line := func(s string) {
+		sb.WriteString("│   " + s + strings.Repeat(" ", max(0, cardWidth-strWidth(s))) + "   │\n")
+	}
+	sb.WriteString("╭" + strings.Repeat("─", cardWidth+6) + "╮\n")
+	line("")
+	for _, row := range rows {
+		line(row)
+	}
+	line("")
+	sb.WriteString("╰" + strings.Repeat("─", cardWidth+6) + "╯\n")
+	return sb.String()
+}
+
+// saveCard writes lines start to end as a quote card to the file of Config.Card and runs
+// the card hook with the file in $FISH_CARD, which can render it as an image.
+func (r *Reader) saveCard(start, end int) error {
+	p, e := homePath(r.cfg.Card)
+	if e != nil {
+		return e
+	}
+	card := quoteCard(stripControls(r.title(), false), r.doc.meta.Author, r.index.slice(start, end+1).strings())
+	if e := os.WriteFile(p, []byte(card), 0644); e != nil {
+		return e
+	}
+	r.notice = fmt.Sprintf(tr("quote card written to %s"), r.cfg.Card)
+	r.hook("card", "FISH_CARD="+p)
+	return nil
+}
+
+// Card returns the quote card of source lines from to to of book f, counted from 1.
+func Card(f string, from, to int) (string, error) {
+	c, b, e := loadBookConfig(f)
+	if e != nil {
+		return "", e
+	}
+	d, e := loadDocument(f, c.Encoding)
+	if e != nil {
+		return "", e
+	}
+	if d, e = c.filter(d, b); e != nil {
+		return "", e
+	}
+	if from < 1 || to < from || d.lines.len() == 0 || from-1 > d.source(d.lines.len()-1) {
+		return "", fmt.Errorf("no lines %d-%d in %s", from, to, filepath.Base(f))
+	}
+	start, end := d.view(from-1), d.view(to-1)
+	title := d.meta.Title
+	if title == "" {
+		title = filepath.Base(f)
+	}
+	return quoteCard(title, d.meta.Author, d.lines.slice(start, end+1).strings()), nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// chapter is a heading found in the text.
+type chapter struct {
+	line  int
+	level int // 1 for top level headings.
+	title string
+}
+
+// chapterPatterns match lines which look like chapter headings of novels, in Chinese or English.
+var chapterPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^第[0-9０-９零〇一二三四五六七八九十百千万两]+[章节回卷集部篇]`),
+	regexp.MustCompile(`^(序章|序言|序|楔子|引子|前言|尾声|后记|终章|番外)(\s|$|[:：、0-9一二三四五六七八九十])`),
+	regexp.MustCompile(`(?i)^(chapter|part|book)\s+([0-9]+|[ivxlcdm]+|one|two|three|four|five|six|seven|eight|nine|ten|[a-z]+teen|[a-z]+ty)\b`),
+	regexp.MustCompile(`(?i)^(prologue|epilogue|interlude|preface|introduction|afterword)\b`),
+	regexp.MustCompile(`^#{1,3}\s+\S`),
+}
+
+// maxChapterTitle is the rune length above which a line is too long to be a heading.
+const maxChapterTitle = 50
+
+// detectChapters returns the lines of ll which look like chapter headings.
+func detectChapters(ll lineIndex) []chapter {
+	return slices.Concat(eachChunk(ll.len(), func(from, to int) []chapter {
+		var cc []chapter
+		for i, s := range ll.slice(from, to).all() {
+			s = strings.TrimSpace(s)
+			if s == "" || utf8.RuneCountInString(s) > maxChapterTitle ||
+				strings.HasSuffix(s, "。") || strings.HasSuffix(s, "，") {
+				continue
+			}
+			for _, p := range chapterPatterns {
+				if p.MatchString(s) {
+					level := max(1, len(s)-len(strings.TrimLeft(s, "#")))
+					cc = append(cc, chapter{line: from + i, level: level, title: strings.TrimLeft(s, "# ")})
+					break
+				}
+			}
+		}
+		return cc
+	})...)
+}
+
+// chapterAt returns the index of the chapter line belongs to, -1 if it is before the first one.
+func (r *Reader) chapterAt(line int) int {
+	return sort.Search(len(r.chapters), func(i int) bool { return r.chapters[i].line > line }) - 1
+}
+
+// breadcrumb is the path of the headings the current line is under, like "Part II › Ch. 3 › The Siege".
+// It is "" unless the headings are nested, as in Markdown and HTML, one level is the chapter field's.
+func (r *Reader) breadcrumb() string {
+	if !slices.ContainsFunc(r.chapters, func(c chapter) bool { return c.level > 1 }) {
+		return ""
+	}
+	var path []string
+	var levels []int
+	for _, c := range r.chapters[:r.chapterAt(r.currentLine)+1] {
+		for len(levels) > 0 && levels[len(levels)-1] >= c.level {
+			path, levels = path[:len(path)-1], levels[:len(levels)-1]
+		}
+		path, levels = append(path, stripControls(c.title, false)), append(levels, c.level)
+	}
+	return strings.Join(path, " › ")
+}
+
+// chapterEnd is the line after chapter i.
+func (r *Reader) chapterEnd(i int) int {
+	if i+1 < len(r.chapters) {
+		return r.chapters[i+1].line
+	}
+	return r.totalLine
+}
+
+// chapterProgress tells the chapter of the current line and how far into it the line is,
+// like "Ch. 12 · 63%". It is "" before the first chapter.
+func (r *Reader) chapterProgress() string {
+	i := r.chapterAt(r.currentLine)
+	if i < 0 {
+		return ""
+	}
+	start, end := r.chapters[i].line, r.chapterEnd(i)
+	p := float64(r.currentLine-start) / float64(max(1, end-start)) * 100
+	return fmt.Sprintf(tr("Ch. %d · %.0f%%"), i+1, p)
+}
+
+// openTOC opens the table of contents, Enter jumps to the chosen chapter.
+func (r *Reader) openTOC() {
+	if len(r.chapters) == 0 {
+		r.notice = tr("no chapters found")
+		return
+	}
+	items := make([]string, len(r.chapters))
+	for i, c := range r.chapters {
+		items[i] = c.title
+	}
+	r.overlay = &list{
+		title: "Contents",
+		items: items,
+		sel:   max(0, r.chapterAt(r.currentLine)),
+		foot:  "Enter:Jump Esc:Close",
+		pick: func(r *Reader, i int) {
+			r.currentLine = r.chapters[i].line
+		},
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minChunk is the fewest lines a goroutine of eachChunk scans, fewer are not worth one.
+const minChunk = 1 << 16
+
+// eachChunk splits n lines into consecutive chunks, one a core, and calls fn on each from
+// its own goroutine. It returns the results of the chunks in their order, so line numbers
+// found in them are merged by concatenating. Books of few lines are a single chunk.
+func eachChunk[T any](n int, fn func(from, to int) T) []T {
+	k := max(1, min(runtime.GOMAXPROCS(0), n/minChunk))
+	out := make([]T, k)
+	if k == 1 {
+		out[0] = fn(0, n)
+		return out
+	}
+	var wg sync.WaitGroup
+	for i := range k {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i] = fn(n*i/k, n*(i+1)/k)
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// clipping is a highlight or note of the "My Clippings.txt" file of a Kindle.
+type clipping struct {
+	title string
+	note  bool // a note typed on the Kindle, not a highlight.
+	loc   string
+	text  string
+}
+
+// clippingSeparator is the line between the clippings.
+const clippingSeparator = "=========="
+
+// clippingLocation finds the location of a clipping in its second line, as in "Location 40-42".
+var clippingLocation = regexp.MustCompile(`(?i)location (\d+(-\d+)?)`)
+
+// parseClippings reads the clippings of file s. Each has the title and author of the book,
+// a line like "- Your Highlight on page 3 | Location 40-42 | Added on ...", a blank line
+// and the text. Bookmarks, which have no text, are left out.
+func parseClippings(s string) []clipping {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	var cc []clipping
+	for _, part := range strings.Split(s, clippingSeparator) {
+		ll := strings.Split(strings.Trim(part, "\n"), "\n")
+		if len(ll) < 3 {
+			continue
+		}
+		c := clipping{title: strings.TrimSpace(strings.TrimPrefix(ll[0], "\ufeff"))}
+		if i := strings.LastIndex(c.title, " ("); i > 0 && strings.HasSuffix(c.title, ")") {
+			c.title = c.title[:i]
+		}
+		c.note = strings.Contains(strings.ToLower(ll[1]), "note")
+		if m := clippingLocation.FindStringSubmatch(ll[1]); m != nil {
+			c.loc = m[1]
+		}
+		c.text = strings.TrimSpace(strings.Join(ll[2:], "\n"))
+		if c.text != "" {
+			cc = append(cc, c)
+		}
+	}
+	return cc
+}
+
+// letters returns s in lower case with only its letters and digits, to compare texts which
+// differ in spacing, punctuation and line breaks.
+func letters(s string) string {
+	var sb strings.Builder
+	for _, c := range strings.ToLower(s) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+// titleScore tells how well Kindle title a matches title b of the library, from 0 to 1.
+// Titles containing one another match, others by the words they share.
+func titleScore(a, b string) float64 {
+	la, lb := letters(a), letters(b)
+	if la == "" || lb == "" {
+		return 0
+	}
+	if strings.Contains(la, lb) || strings.Contains(lb, la) {
+		return 1
+	}
+	words := func(s string) []string {
+		return strings.FieldsFunc(strings.ToLower(s), func(c rune) bool { return !unicode.IsLetter(c) && !unicode.IsDigit(c) })
+	}
+	wa, wb := words(a), words(b)
+	shared := 0
+	for _, w := range wa {
+		if slices.Contains(wb, w) {
+			shared++
+		}
+	}
+	return float64(shared) / float64(max(len(wa), len(wb)))
+}
+
+// minTitleScore is the titleScore a book must reach to take the clippings of a title.
+const minTitleScore = 0.6
+
+// ImportClippings turns the highlights and notes of Kindle clippings file f into bookmarks
+// of the books in the library with the same titles, at the lines their text is found.
+// A note goes with the highlight it was typed at. It returns a report of what it did.
+func ImportClippings(f string) (string, error) {
+	dd, e := os.ReadFile(f)
+	if e != nil {
+		return "", e
+	}
+	cc := parseClippings(string(dd))
+	p, e := progressPath()
+	if e != nil {
+		return "", e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return "", e
+	}
+	books := make(map[string]string) // Kindle title:book of the library, "" if none.
+	for _, c := range cc {
+		if _, ok := books[c.title]; ok {
+			continue
+		}
+		best, score := "", minTitleScore
+		for bf, b := range progress {
+			if b == nil {
+				continue
+			}
+			title := b.Meta.Title
+			if title == "" {
+				title = strings.TrimSuffix(filepath.Base(bf), filepath.Ext(bf))
+			}
+			if s := titleScore(c.title, title); s > score || s == score && best == "" {
+				best, score = bf, s
+			}
+		}
+		books[c.title] = best
+	}
+	var b strings.Builder
+	finders := make(map[string]func(string) (int, string, bool))
+	type mark struct {
+		i   int
+		loc string
+	}
+	last := make(map[string]mark) // book:its bookmark of the last highlight, and where that ends.
+	imported := 0
+	for _, c := range cc {
+		bf := books[c.title]
+		if bf == "" {
+			continue
+		}
+		find, ok := finders[bf]
+		if !ok {
+			if find, e = clippingFinder(bf, progress[bf]); e != nil {
+				_, _ = fmt.Fprintf(&b, "%s: %v\n", bf, e)
+			}
+			finders[bf] = find
+		}
+		if find == nil {
+			continue
+		}
+		book := progress[bf]
+		if c.note {
+			// notes follow their highlight, which ends at their location.
+			if m, ok := last[bf]; ok && m.loc == c.loc && !strings.Contains(book.Marks[m.i].Note, c.text) {
+				book.Marks[m.i].Note += " — " + c.text
+			}
+			continue
+		}
+		line, text, ok := find(c.text)
+		if !ok {
+			_, _ = fmt.Fprintf(&b, "not found in %s: %s\n", filepath.Base(bf), cut(c.text, 60))
+			continue
+		}
+		end := c.loc[strings.LastIndex(c.loc, "-")+1:]
+		i := slices.IndexFunc(book.Marks, func(m Bookmark) bool {
+			return m.Line == line && strings.HasPrefix(m.Note, c.text)
+		})
+		if i < 0 {
+			i = len(book.Marks)
+			book.Marks = append(book.Marks, Bookmark{Line: line, Note: c.text, Text: text, At: time.Now()})
+			imported++
+		}
+		last[bf] = mark{i, end}
+	}
+	for t, bf := range books {
+		if bf == "" {
+			_, _ = fmt.Fprintf(&b, "no book in the library matches %s\n", t)
+		}
+	}
+	for _, book := range progress {
+		if book != nil {
+			slices.SortStableFunc(book.Marks, func(x, y Bookmark) int { return x.Line - y.Line })
+		}
+	}
+	if e := writeProgressFile(p, progress); e != nil {
+		return b.String(), e
+	}
+	_ = os.Remove(journalPath(p))
+	_, _ = fmt.Fprintf(&b, "%d highlights imported\n", imported)
+	return b.String(), nil
+}
+
+// clippingFinder loads book f of progress record b and returns a function finding the source
+// line text s starts at, and the text of the line.
+func clippingFinder(f string, b *Book) (func(string) (int, string, bool), error) {
+	c, e := LoadConfig()
+	if e != nil {
+		return nil, e
+	}
+	c = c.with(b.Settings)
+	rr, e := bookRules(f, b)
+	if e != nil {
+		return nil, e
+	}
+	d, e := loadDocument(f, c.Encoding)
+	if e != nil {
+		return nil, e
+	}
+	if d, e = c.filter(d, rr); e != nil {
+		return nil, e
+	}
+	// the letters of the book, with the offset in them each line starts at.
+	var all strings.Builder
+	starts := make([]int, d.lines.len())
+	for i, l := range d.lines.all() {
+		starts[i] = all.Len()
+		all.WriteString(letters(stripControls(l, false)))
+	}
+	text := all.String()
+	return func(s string) (int, string, bool) {
+		key := letters(s)
+		if k := []rune(key); len(k) > 40 {
+			key = string(k[:40])
+		}
+		at := strings.Index(text, key)
+		if key == "" || at < 0 {
+			return 0, "", false
+		}
+		i := sort.Search(len(starts), func(i int) bool { return starts[i] > at }) - 1
+		return d.source(i), strings.TrimSpace(stripControls(d.lines.at(i), false)), true
+	}, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+// batteryEvery is how often the battery is read again.
+const batteryEvery = time.Minute
+
+// battery is the charge of the battery as the status bar shows it, read at most every batteryEvery.
+var battery struct {
+	at   time.Time
+	text string
+}
+
+// batteryInfo is the charge of the battery like "87%", with a + while it charges. It is "" on
+// machines without a battery.
+func batteryInfo() string {
+	if time.Since(battery.at) < batteryEvery {
+		return battery.text
+	}
+	battery.at, battery.text = time.Now(), readBattery()
+	return battery.text
+}
+
+// pmsetCharge is the charge and state in the output of pmset -g batt, like "87%; charging".
+var pmsetCharge = regexp.MustCompile(`(\d+)%; (\w+)`)
+
+// readBattery reads the charge of the first battery, from /sys on Linux and pmset on macOS.
+func readBattery() string {
+	if runtime.GOOS == "darwin" {
+		out, e := exec.Command("pmset", "-g", "batt").Output()
+		if e != nil {
+			return ""
+		}
+		m := pmsetCharge.FindStringSubmatch(string(out))
+		if m == nil {
+			return ""
+		}
+		if m[2] == "charging" {
+			return m[1] + "%+"
+		}
+		return m[1] + "%"
+	}
+	dd, _ := filepath.Glob("/sys/class/power_supply/BAT*")
+	for _, d := range dd {
+		c, e := os.ReadFile(filepath.Join(d, "capacity"))
+		if e != nil {
+			continue
+		}
+		s := strings.TrimSpace(string(c)) + "%"
+		if st, _ := os.ReadFile(filepath.Join(d, "status")); strings.TrimSpace(string(st)) == "Charging" {
+			s += "+"
+		}
+		return s
+	}
+	return ""
+}
+
+// clockChanged tells if the clock or battery fields of the status bar are to be drawn again,
+// which change while no key is pressed.
+func (r *Reader) clockChanged() bool {
+	clock := slices.Contains(r.cfg.Status, "clock") && time.Now().Truncate(time.Minute) != r.drawn.Truncate(time.Minute)
+	charge := slices.Contains(r.cfg.Status, "battery") && time.Since(battery.at) >= batteryEvery
+	return clock || charge
+}
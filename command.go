@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// commands can be run from the command line opened by ':', arg is the rest of the line.
+var commands = map[string]func(r *Reader, arg string) error{
+	"reset": func(r *Reader, _ string) error {
+		delete(r.progress, r.f)
+		r.forget(r.f, -1)
+		r.writeProgress()
+		r.currentLine = 0
+		r.previousSavedLine = 0
+		r.displayBreakMark = false
+		r.displayResumeMark = false
+		r.notice = fmt.Sprintf(tr("progress of %s cleared"), filepath.Base(r.f))
+		return nil
+	},
+	"hide": func(r *Reader, arg string) error {
+		b := r.book()
+		if arg == "" {
+			if len(b.Hide) == 0 && len(r.conf.Hide) == 0 {
+				r.notice = tr("no lines are hidden, :hide <regexp> hides matching lines")
+				return nil
+			}
+			lines := append(slices.Clone(r.conf.Hide), b.Hide...)
+			r.overlay = &box{title: "Hidden lines", lines: lines}
+			return nil
+		}
+		if _, e := regexp.Compile(arg); e != nil {
+			return e
+		}
+		n := r.totalLine
+		b.Hide = append(b.Hide, arg)
+		r.writeProgress()
+		r.reload()
+		r.notice = fmt.Sprintf(tr("%d lines hidden in %s"), n-r.totalLine, filepath.Base(r.f))
+		return nil
+	},
+	"next-chapter": func(r *Reader, _ string) error { return r.nextChapter(1) },
+	"prev-chapter": func(r *Reader, _ string) error { return r.nextChapter(-1) },
+	"goto": func(r *Reader, arg string) error {
+		if p, ok := strings.CutSuffix(arg, "%"); ok {
+			f, e := strconv.ParseFloat(p, 64)
+			if e != nil {
+				return e
+			}
+			r.jumpPercent(f)
+			return nil
+		}
+		n, e := strconv.Atoi(arg)
+		if e != nil {
+			return errors.New(tr("usage: :goto <line>|<percent>%"))
+		}
+		r.jump(n)
+		return nil
+	},
+	"page": func(r *Reader, arg string) error {
+		return r.gotoPage(arg)
+	},
+	"open": func(r *Reader, arg string) error {
+		return r.openPath(arg)
+	},
+	"notes": func(r *Reader, arg string) error {
+		return r.searchBookmarks(arg, false)
+	},
+	"allnotes": func(r *Reader, arg string) error {
+		return r.searchBookmarks(arg, true)
+	},
+	"pomodoro": func(r *Reader, arg string) error {
+		return r.startPomodoro(arg)
+	},
+	"unhide": func(r *Reader, _ string) error {
+		r.book().Hide = nil
+		r.writeProgress()
+		r.reload()
+		r.notice = fmt.Sprintf(tr("hide patterns of %s cleared"), filepath.Base(r.f))
+		return nil
+	},
+}
+
+// moves are commands moving in the book like their keys do, they take no argument.
+var moves = map[string]byte{
+	"next-page":      CmdNextPage,
+	"prev-page":      CmdPrevPage,
+	"next-line":      CmdNextLine,
+	"prev-line":      CmdPrevLine,
+	"next-half-page": CmdNextHalfPage,
+	"next-sentence":  CmdNextSentence,
+	"prev-sentence":  CmdPrevSentence,
+	"next-paragraph": CmdNextParagraph,
+	"prev-paragraph": CmdPrevParagraph,
+	"scroll":         CmdSwitchScrolling,
+	"scroll-faster":  CmdScrollFaster,
+	"scroll-slower":  CmdScrollSlower,
+	"back":           CmdJumpBack,
+	"forward":        CmdJumpForward,
+}
+
+// runCommand runs command line s, errors are shown in the status bar.
+func (r *Reader) runCommand(s string) {
+	if e := r.command(s); e != nil {
+		r.notice = e.Error()
+	}
+}
+
+// command runs command line s.
+func (r *Reader) command(s string) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(s), " ")
+	if name == "" {
+		return nil
+	}
+	if c, ok := moves[name]; ok {
+		r.exec(c)
+		return nil
+	}
+	fn, ok := commands[name]
+	if !ok {
+		if ok, e := r.pluginCommand(name, strings.TrimSpace(arg)); ok {
+			return e
+		}
+		return fmt.Errorf(tr("unknown command: %s"), name)
+	}
+	return fn(r, strings.TrimSpace(arg))
+}
+
+// nextChapter jumps n chapters on from the one the page starts in.
+func (r *Reader) nextChapter(n int) error {
+	i := r.chapterAt(r.currentLine)
+	if n < 0 && i >= 0 && r.chapters[i].line < r.currentLine {
+		n++ // back to the start of the chapter first.
+	}
+	if i+n < 0 || i+n >= len(r.chapters) {
+		return errors.New(tr("no chapter there"))
+	}
+	r.jump(r.chapters[i+n].line)
+	return nil
+}
+
+// prompt reads a line of text in the status bar, enter calls done with it.
+type prompt struct {
+	label string
+	text  []rune
+	done  func(r *Reader, s string)
+}
+
+func (p *prompt) key(r *Reader, k string) bool {
+	switch k {
+	case "enter":
+		p.done(r, string(p.text))
+		return true
+	case "esc":
+		return true
+	case "backspace":
+		if len(p.text) > 0 {
+			p.text = p.text[:len(p.text)-1]
+		} else {
+			return true
+		}
+	case "space":
+		p.text = append(p.text, ' ')
+	default:
+		if utf8.RuneCountInString(k) == 1 {
+			p.text = append(p.text, []rune(k)...)
+		}
+	}
+	return false
+}
+
+func (p *prompt) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	s := p.label + string(p.text)
+	if w := strWidth(s); w >= r.winWidth {
+		// keep the end of the text visible.
+		for w >= r.winWidth && s != "" {
+			n, cw := cluster(s)
+			w -= cw
+			s = s[n:]
+		}
+	}
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K", r.winHeight, t.status(), s)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const ConfigFile = ".cmdline-reader-config"
+
+// Config is the user configuration, stored as JSON in ConfigFile under home dir.
+// Missing keys fall back to DefaultConfig.
+type Config struct {
+	Encoding   string            `json:"encoding"`   // text encoding of books, see decode.
+	Wrap       bool              `json:"wrap"`       // wrap long lines to the terminal width, otherwise cut them.
+	Margin     int               `json:"margin"`     // blank columns on both left and right side.
+	Theme      string            `json:"theme"`      // see themes.
+	Scroll     int               `json:"scroll"`     // auto-scrolling lines per second at startup, 0 is off.
+	ScrollMs   int               `json:"scrollms"`   // milliseconds between lines of auto-scrolling at startup, over Scroll.
+	Status     []string          `json:"status"`     // status bar fields in display order, see statusFields.
+	Resume     int               `json:"resume"`     // ask before resuming a book not opened for this many days, 0 never asks.
+	Graphics   string            `json:"graphics"`   // how to show cover images, see graphicsModes.
+	Gutenberg  bool              `json:"gutenberg"`  // hide the Project Gutenberg license before and after the text.
+	Hide       []string          `json:"hide"`       // regular expressions of lines to hide, like ads in web novels.
+	Replace    []Replace         `json:"replace"`    // text replacements in every book, see Rules.
+	Normalize  bool              `json:"normalize"`  // even out punctuation and spacing of Chinese text, see normalizeLine.
+	Typography bool              `json:"typography"` // curly quotes, dashes and ellipses in text books, see typography.
+	Squeeze    bool              `json:"squeeze"`    // show runs of 3 or more blank lines as one.
+	Tab        int               `json:"tab"`        // columns between tab stops.
+	ShowTabs   bool              `json:"showtabs"`   // show tabs as arrows.
+	ANSI       bool              `json:"ansi"`       // keep the colors of ANSI escape sequences in books, other escapes are always removed.
+	Highlight  string            `json:"highlight"`  // chroma style to color code files in, see highlightStyles.
+	JSON       bool              `json:"json"`       // show JSON files indented, with foldable objects and arrays.
+	Timing     bool              `json:"timing"`     // show the start time of subtitles.
+	Log        bool              `json:"log"`        // dim times and color levels in log files, see logColors.
+	Hooks      map[string]string `json:"hooks"`      // event:shell command, events are open, chapter, finish and quit.
+	Plugins    []Plugin          `json:"plugins"`    // extension programs, see Plugin.
+	Title      bool              `json:"title"`      // show the book and progress in the terminal title.
+	Remind     string            `json:"remind"`     // time of day "fish remind" notifies at if no book was read, like "20:00".
+	Notify     string            `json:"notify"`     // shell command sending $FISH_MESSAGE as a desktop notification, see notifyCommand.
+	Disguise   string            `json:"disguise"`   // what D makes the book look like, see disguises.
+	Lang       string            `json:"lang"`       // language of the interface, see languages.
+	Accessible bool              `json:"accessible"` // write plain lines for screen readers instead of drawing the screen.
+	Ruby       string            `json:"ruby"`       // how readings of Japanese texts are shown, see rubyModes.
+	Hyphenate  bool              `json:"hyphenate"`  // hyphenate words at the end of rows, in English and German books.
+	Justify    bool              `json:"justify"`    // widen the spaces of wrapped rows to the full width.
+	Poetry     string            `json:"poetry"`     // center the lines of poems, see poetryModes.
+	Indent     int               `json:"indent"`     // columns the first line of a paragraph is indented by, 0 keeps the book's.
+	Spacing    int               `json:"spacing"`    // blank rows between paragraphs of a line each.
+	Pages      bool              `json:"pages"`      // turn whole pages which end between paragraphs, see Reader.pageLines.
+	Keys       map[string]string `json:"keys"`       // key:command line it runs instead of its command in keymap.
+	Quotes     string            `json:"quotes"`     // file the lines selected with v are appended to, see Reader.saveQuote.
+	Card       string            `json:"card"`       // file the quote card of the lines selected with v is written to.
+	Sync       string            `json:"sync"`       // git repository the progress file is kept in, pulled on start and pushed on quit.
+	Colors     string            `json:"colors"`     // colors of the terminal, see colorNames, "auto" detects them.
+	Smooth     bool              `json:"smooth"`     // move the rows smoothly in auto-scroll, on terminals with smooth scrolling.
+	HideStatus int               `json:"hidestatus"` // seconds without a key after which the status bar is hidden, 0 never hides it.
+	Slow       bool              `json:"slow"`       // repaint only the rows which changed and hold frames back, for slow SSH links.
+	StatusTick int               `json:"statustick"` // seconds between status bar updates of the timers with Slow, like the pomodoro countdown.
+	Lock       string            `json:"lock"`       // key file the progress file is encrypted with, "ask" asks for a passphrase instead.
+	Memory     int               `json:"memory"`     // megabytes a book may take, bigger plain text files are streamed, see window.
+}
+
+// DefaultConfig returns the configuration used when there is no config file.
+func DefaultConfig() Config {
+	return Config{
+		Encoding:  "auto",
+		Wrap:      true,
+		Theme:     "default",
+		Status:    []string{"name", "line", "percent", "chapter", "path", "keys", "scroll"},
+		Resume:    30,
+		Graphics:  "auto",
+		Gutenberg: true,
+		Tab:       4,
+		Highlight: "auto",
+		JSON:      true,
+		Log:       true,
+		Title:     true,
+		Disguise:  "code",
+		Memory:    1024,
+		Lang:      "auto",
+		Ruby:      "inline",
+		Poetry:    "off",
+		Quotes:    "~/fish-quotes.md",
+		Card:      "~/fish-card.txt",
+		Colors:    "auto",
+		Smooth:    true,
+	}
+}
+
+func configPath() (string, error) {
+	u, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+	return filepath.Join(u, ConfigFile), nil
+}
+
+// LoadConfig reads the config file, a missing file is not an error.
+func LoadConfig() (Config, error) {
+	c := DefaultConfig()
+	p, e := configPath()
+	if e != nil {
+		return c, e
+	}
+	dd, e := os.ReadFile(p)
+	if os.IsNotExist(e) {
+		return c, nil
+	}
+	if e != nil {
+		return c, e
+	}
+	if e := json.Unmarshal(dd, &c); e != nil {
+		return c, e
+	}
+	return c, nil
+}
+
+// Save writes c back to the config file.
+func (c Config) Save() error {
+	p, e := configPath()
+	if e != nil {
+		return e
+	}
+	dd, e := json.MarshalIndent(c, "", "  ")
+	if e != nil {
+		return e
+	}
+	return os.WriteFile(p, dd, 0644)
+}
+
+// bookSettings are the config keys which can be overridden per book.
+var bookSettings = []string{"encoding", "wrap", "margin", "theme", "scroll", "scrollms", "gutenberg", "normalize", "typography", "squeeze", "tab", "showtabs", "ansi", "highlight", "json", "timing", "log"}
+
+// values returns the values of config keys kk, encoded as in the config file.
+func (c Config) values(kk []string) map[string]json.RawMessage {
+	dd, _ := json.Marshal(c)
+	var all map[string]json.RawMessage
+	_ = json.Unmarshal(dd, &all)
+	m := make(map[string]json.RawMessage)
+	for _, k := range kk {
+		if v, ok := all[k]; ok {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// with returns c with config keys in o replaced.
+func (c Config) with(o map[string]json.RawMessage) Config {
+	c.Status = slices.Clone(c.Status)
+	if len(o) == 0 {
+		return c
+	}
+	dd, _ := json.Marshal(o)
+	_ = json.Unmarshal(dd, &c)
+	return c
+}
+
+// overrides returns the book settings of c which differ from g.
+func (c Config) overrides(g Config) map[string]json.RawMessage {
+	o, gv := c.values(bookSettings), g.values(bookSettings)
+	for k, v := range o {
+		if bytes.Equal(v, gv[k]) {
+			delete(o, k)
+		}
+	}
+	return o
+}
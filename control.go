@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Status is the reading state reported to other programs.
+type Status struct {
+	File    string  `json:"file"`
+	Title   string  `json:"title"`
+	Author  string  `json:"author,omitempty"`
+	Line    int     `json:"line"` // the top line of the page, counted from 0.
+	Lines   int     `json:"lines"`
+	Percent float64 `json:"percent"`
+	Chapter string  `json:"chapter,omitempty"`
+}
+
+// status returns the reading state.
+func (r *Reader) status() Status {
+	s := Status{File: r.f, Title: r.title(), Author: r.doc.meta.Author, Line: r.currentLine, Lines: r.totalLine, Percent: r.percent()}
+	if i := r.chapterAt(r.currentLine); i >= 0 {
+		s.Chapter = r.chapters[i].title
+	}
+	return s
+}
+
+// control is a call from another goroutine, run by the main loop between key presses.
+type control struct {
+	fn   func(r *Reader)
+	done chan struct{}
+}
+
+// call runs fn in the main loop and waits for it, it tells if the reader was still running.
+func (r *Reader) call(fn func(r *Reader)) bool {
+	c := control{fn, make(chan struct{})}
+	select {
+	case r.controlSignal <- c:
+	case <-r.quitSignal:
+		return false
+	}
+	select {
+	case <-c.done:
+		return true
+	case <-r.quitSignal:
+		return false
+	}
+}
+
+// request is the body of POST /command.
+type request struct {
+	Command string `json:"command"`
+}
+
+// listenLocal listens on TCP address addr, which must be on the loopback interface:
+// the API runs any command, other hosts must not reach it.
+func listenLocal(addr string) (net.Listener, error) {
+	host, _, e := net.SplitHostPort(addr)
+	if e != nil {
+		return nil, e
+	}
+	if !loopback(host) {
+		return nil, fmt.Errorf(tr("--listen takes a loopback address like 127.0.0.1:7777, not %s"), addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// loopback tells if host names the loopback interface.
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// guard lets through the requests of local programs only. A web page can send requests to
+// the API from the browser, those carry an Origin or, through DNS rebinding, a Host of their own.
+func guard(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host, _, e := net.SplitHostPort(req.Host)
+		if e != nil {
+			host = req.Host
+		}
+		if req.Header.Get("Origin") != "" || !loopback(host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// serve answers the HTTP control API on ln:
+//
+//	GET  /status   the reading state as JSON, see Status.
+//	POST /command  runs the command line of the JSON body {"command": "..."} like ':' does,
+//	               then answers the state.
+//
+// Only requests with the Host localhost or a loopback address and without an Origin are answered,
+// POST /command takes only application/json, which a web page cannot send without asking first.
+func (r *Reader) serve(ln net.Listener) {
+	answer := func(w http.ResponseWriter, s Status) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, _ *http.Request) {
+		var s Status
+		if !r.call(func(r *Reader) { s = r.status() }) {
+			http.Error(w, "fish has quit", http.StatusServiceUnavailable)
+			return
+		}
+		answer(w, s)
+	})
+	mux.HandleFunc("POST /command", func(w http.ResponseWriter, req *http.Request) {
+		if t, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); t != "application/json" {
+			http.Error(w, "the body must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var c request
+		if e := json.NewDecoder(io.LimitReader(req.Body, 4096)).Decode(&c); e != nil {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+		var s Status
+		var e error
+		if !r.call(func(r *Reader) {
+			e = r.command(strings.TrimSpace(c.Command))
+			s = r.status()
+		}) {
+			http.Error(w, "fish has quit", http.StatusServiceUnavailable)
+			return
+		}
+		if e != nil {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+		answer(w, s)
+	})
+	_ = http.Serve(ln, guard(mux))
+}
+
+// socketPath returns the path of the control socket, in a directory of the user's runtime directory
+// that only they may enter.
+func socketPath() (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, fmt.Sprintf("fish-%d", os.Getuid()))
+	if e := os.Mkdir(dir, 0700); e != nil && !os.IsExist(e) {
+		return "", e
+	}
+	// someone else may have made it first, in a shared temporary directory.
+	if e := owned(dir, fs.ModeDir); e != nil {
+		return "", e
+	}
+	if e := os.Chmod(dir, 0700); e != nil {
+		return "", e
+	}
+	return filepath.Join(dir, "reader.sock"), nil
+}
+
+// owned checks that p is a file of type t, not a link, which belongs to the user.
+func owned(p string, t fs.FileMode) error {
+	fi, e := os.Lstat(p)
+	if e != nil {
+		return e
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if fi.Mode().Type() != t || !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not yours", p)
+	}
+	return nil
+}
+
+// listenSocket opens the control socket, only the user may connect to it.
+// It returns nil if another reader has it or it cannot be opened, reading goes on without it.
+func listenSocket() net.Listener {
+	p, e := socketPath()
+	if e != nil {
+		return nil
+	}
+	if e := owned(p, fs.ModeSocket); e == nil {
+		if c, e := net.Dial("unix", p); e == nil {
+			_ = c.Close()
+			return nil
+		}
+		_ = os.Remove(p)
+	} else if !os.IsNotExist(e) {
+		return nil
+	}
+	// the socket is made with the permissions of the umask.
+	mask := syscall.Umask(0077)
+	ln, e := net.Listen("unix", p)
+	syscall.Umask(mask)
+	if e != nil {
+		return nil
+	}
+	return ln
+}
+
+// Control runs command line s in the reader running in another terminal, "status" prints its state.
+func Control(s string) (string, error) {
+	p, e := socketPath()
+	if e != nil {
+		return "", e
+	}
+	if e := owned(p, fs.ModeSocket); os.IsNotExist(e) {
+		return "", errors.New("no fish is running")
+	} else if e != nil {
+		return "", e
+	}
+	c := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", p)
+		},
+	}}
+	var res *http.Response
+	if s == "status" {
+		res, e = c.Get("http://localhost/status")
+	} else {
+		dd, _ := json.Marshal(request{s})
+		res, e = c.Post("http://localhost/command", "application/json", bytes.NewReader(dd))
+	}
+	if e != nil {
+		return "", errors.New("no fish is running")
+	}
+	defer func() { _ = res.Body.Close() }()
+	dd, e := io.ReadAll(res.Body)
+	if e != nil {
+		return "", e
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", errors.New(strings.TrimSpace(string(dd)))
+	}
+	return strings.TrimSpace(string(dd)), nil
+}
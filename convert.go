@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Convert reads book in by its format backend and writes it to out as plain text,
+// or Markdown if out ends in .md. Out "-" is the standard output.
+func Convert(in, out string) error {
+	c, b, e := loadBookConfig(in)
+	if e != nil {
+		return e
+	}
+	d, e := loadDocument(in, c.Encoding)
+	if e != nil {
+		return e
+	}
+	if d, e = c.filter(d, b); e != nil {
+		return e
+	}
+	if d.styled {
+		d = d.replace(func(s string, _ int) string { return stripControls(s, false) })
+	}
+	var s string
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".md", ".markdown":
+		s = d.markdown()
+	case ".txt", "":
+		s = d.lines.joined()
+	default:
+		return fmt.Errorf("cannot convert to %s, only .txt and .md are supported", filepath.Ext(out))
+	}
+	s = strings.TrimRight(s, "\n") + "\n"
+	if out == "-" {
+		_, e = os.Stdout.WriteString(s)
+		return e
+	}
+	return os.WriteFile(out, []byte(s), 0644)
+}
+
+// markdown returns d as Markdown: chapters become headings, external links are kept,
+// and paragraphs are separated by blank lines.
+func (d *document) markdown() string {
+	heads := make(map[int]chapter)
+	for _, c := range d.chapters() {
+		heads[c.line] = c
+	}
+	var sb strings.Builder
+	for i, l := range d.lines.all() {
+		if c, ok := heads[i]; ok {
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(strings.Repeat("#", max(1, c.level)) + " " + strings.TrimLeft(strings.TrimSpace(l), "# ") + "\n\n")
+			continue
+		}
+		if strings.TrimSpace(l) == "" {
+			if !d.paras {
+				sb.WriteString("\n")
+			}
+			continue
+		}
+		s := l
+		ll := d.links[i]
+		for j := len(ll) - 1; j >= 0; j-- { // from the end, so the offsets stay right.
+			k := ll[j]
+			if strings.Contains(k.target, "://") {
+				s = s[:k.start] + "[" + s[k.start:k.end] + "](" + k.target + ")" + s[k.end:]
+			}
+		}
+		if !d.paras {
+			s = strings.TrimLeft(s, " ") // indents would start code blocks.
+		}
+		if strings.HasPrefix(s, "#") {
+			s = "\\" + s
+		}
+		sb.WriteString(s + "\n")
+		if d.paras {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// graphicsModes are the values of Config.Graphics, "auto" detects the terminal.
+var graphicsModes = []string{"auto", "kitty", "iterm", "sixel", "off"}
+
+// graphicsProtocol returns the image protocol of the terminal for mode, "" if it shows no images.
+// Terminals are recognized by the environment, asking them would race with the key reader.
+func graphicsProtocol(mode string) string {
+	switch mode {
+	case "kitty", "iterm", "sixel":
+		return mode
+	case "auto":
+	default:
+		return ""
+	}
+	if os.Getenv("TMUX") != "" || strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		return "" // the multiplexer would need passthrough.
+	}
+	term, prog := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || prog == "ghostty":
+		return "kitty"
+	case prog == "iTerm.app" || prog == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return "iterm"
+	case strings.Contains(term, "sixel") || strings.HasPrefix(term, "foot") || term == "mlterm" || term == "contour":
+		return "sixel"
+	}
+	return ""
+}
+
+// cellSize returns the size of a character cell in pixels, guessed if the terminal does not tell.
+func cellSize(cols, rows int) (int, int) {
+	ws, e := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if e != nil || ws.Xpixel == 0 || ws.Ypixel == 0 || cols == 0 || rows == 0 {
+		return 8, 16
+	}
+	return int(ws.Xpixel) / cols, int(ws.Ypixel) / rows
+}
+
+// loadCover returns the cover of book f: the cover image of an EPUB, or an image next to
+// the book with the same name or named cover. It returns nil if there is none.
+func loadCover(f string) image.Image {
+	var dd []byte
+	if strings.EqualFold(filepath.Ext(f), ".epub") {
+		dd = epubCover(f)
+	} else {
+		base := strings.TrimSuffix(f, filepath.Ext(f))
+		for _, p := range []string{base + ".jpg", base + ".jpeg", base + ".png",
+			filepath.Join(filepath.Dir(f), "cover.jpg"), filepath.Join(filepath.Dir(f), "cover.png")} {
+			if dd, _ = os.ReadFile(p); dd != nil {
+				break
+			}
+		}
+	}
+	if dd == nil {
+		return nil
+	}
+	img, _, e := image.Decode(bytes.NewReader(dd))
+	if e != nil {
+		return nil
+	}
+	return img
+}
+
+// cover returns the cover of book f, loaded once.
+func (r *Reader) cover(f string) image.Image {
+	if r.covers == nil {
+		r.covers = make(map[string]image.Image)
+	}
+	img, ok := r.covers[f]
+	if !ok {
+		img = loadCover(f)
+		r.covers[f] = img
+	}
+	return img
+}
+
+// fit scales img down to fit w×h pixels, keeping its aspect ratio.
+func fit(img image.Image, w, h int) image.Image {
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return img
+	}
+	s := min(float64(w)/float64(b.Dx()), float64(h)/float64(b.Dy()), 1)
+	dw, dh := max(1, int(float64(b.Dx())*s)), max(1, int(float64(b.Dy())*s))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/dw, b.Min.Y+y*b.Dy()/dh))
+		}
+	}
+	return dst
+}
+
+// coverSize returns the cells a cover takes in a box, 0 if the screen is too small for one.
+func (r *Reader) coverSize() (cols, rows int) {
+	cw, ch := cellSize(r.winWidth, r.winHeight)
+	cols = min(24, r.winWidth/4)
+	rows = min(cols*cw*3/(2*ch), r.winHeight-6)
+	if cols < 8 || rows < 4 {
+		return 0, 0
+	}
+	return cols, rows
+}
+
+// drawCover draws the cover of book f at column x, row y in cols×rows cells.
+// Without terminal graphics or a cover image, a text cover with title and author is drawn.
+func (r *Reader) drawCover(b *strings.Builder, x, y, cols, rows int, f string, m Meta) {
+	if proto := graphicsProtocol(r.cfg.Graphics); proto != "" {
+		if img := r.cover(f); img != nil {
+			cw, ch := cellSize(r.winWidth, r.winHeight)
+			img = fit(img, cols*cw, rows*ch)
+			_, _ = fmt.Fprintf(b, "\x1b[%d;%dH", y, x)
+			writeImage(b, proto, img)
+			r.imageShown = proto == "kitty"
+			return
+		}
+	}
+	t := r.theme()
+	title := m.Title
+	if title == "" {
+		title = filepath.Base(f)
+	}
+	text := wrap(title, cols-4, nil)
+	if m.Author != "" {
+		text = append(append(text, ""), wrap(m.Author, cols-4, nil)...)
+	}
+	text = text[:min(len(text), rows-2)]
+	top := (rows - 2 - len(text)) / 2
+	for i := range rows {
+		s := strings.Repeat(" ", cols-2)
+		switch {
+		case i == 0:
+			s = "┌" + strings.Repeat("─", cols-2) + "┐"
+		case i == rows-1:
+			s = "└" + strings.Repeat("─", cols-2) + "┘"
+		case i-1 >= top && i-1 < top+len(text):
+			l := text[i-1-top]
+			pad := cols - 2 - strWidth(l)
+			s = "│" + strings.Repeat(" ", pad/2) + l + strings.Repeat(" ", pad-pad/2) + "│"
+		default:
+			s = "│" + s + "│"
+		}
+		_, _ = fmt.Fprintf(b, "\x1b[%d;%dH%s%s", y+i, x, t.status(), s)
+	}
+	b.WriteString(t.base())
+}
+
+// writeImage writes img at the cursor by protocol proto.
+func writeImage(b *strings.Builder, proto string, img image.Image) {
+	if proto == "sixel" {
+		writeSixel(b, img)
+		return
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	s := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if proto == "iterm" {
+		bb := img.Bounds()
+		_, _ = fmt.Fprintf(b, "\x1b]1337;File=inline=1;size=%d;width=%dpx;height=%dpx:%s\a", buf.Len(), bb.Dx(), bb.Dy(), s)
+		return
+	}
+	// kitty takes the data in chunks of at most 4096 bytes.
+	for first := true; len(s) > 0; first = false {
+		n := min(4096, len(s))
+		more := 0
+		if n < len(s) {
+			more = 1
+		}
+		if first {
+			_, _ = fmt.Fprintf(b, "\x1b_Ga=T,f=100,C=1,q=2,m=%d;%s\x1b\\", more, s[:n])
+		} else {
+			_, _ = fmt.Fprintf(b, "\x1b_Gm=%d;%s\x1b\\", more, s[:n])
+		}
+		s = s[n:]
+	}
+}
+
+// kittyClear removes the images kitty keeps over the text.
+const kittyClear = "\x1b_Ga=d,d=a,q=2\x1b\\"
+
+// writeSixel writes img as sixel graphics, in the 6×6×6 color cube.
+func writeSixel(b *strings.Builder, img image.Image) {
+	bb := img.Bounds()
+	w, h := bb.Dx(), bb.Dy()
+	idx := make([]int, w*h) // color of each pixel, -1 is transparent.
+	for y := range h {
+		for x := range w {
+			c := color.NRGBAModel.Convert(img.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			if c.A < 128 {
+				idx[y*w+x] = -1
+				continue
+			}
+			idx[y*w+x] = int(c.R)*6/256*36 + int(c.G)*6/256*6 + int(c.B)*6/256
+		}
+	}
+	_, _ = fmt.Fprintf(b, "\x1bP0;1q\"1;1;%d;%d", w, h)
+	for i := range 216 {
+		_, _ = fmt.Fprintf(b, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+	}
+	row := make([]byte, w)
+	for y0 := 0; y0 < h; y0 += 6 {
+		used := make(map[int]bool)
+		for y := y0; y < min(y0+6, h); y++ {
+			for x := range w {
+				if c := idx[y*w+x]; c >= 0 {
+					used[c] = true
+				}
+			}
+		}
+		for c := range 216 {
+			if !used[c] {
+				continue
+			}
+			for x := range w {
+				bits := byte(0)
+				for k := 0; k < 6 && y0+k < h; k++ {
+					if idx[(y0+k)*w+x] == c {
+						bits |= 1 << k
+					}
+				}
+				row[x] = '?' + bits
+			}
+			_, _ = fmt.Fprintf(b, "#%d", c)
+			for x := 0; x < w; {
+				n := 1
+				for x+n < w && row[x+n] == row[x] {
+					n++
+				}
+				if n > 3 {
+					_, _ = fmt.Fprintf(b, "!%d%c", n, row[x])
+				} else {
+					b.WriteString(strings.Repeat(string(row[x]), n))
+				}
+				x += n
+			}
+			b.WriteByte('$')
+		}
+		b.WriteByte('-')
+	}
+	b.WriteString("\x1b\\")
+}
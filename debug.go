@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// started is when fish started, startup times count from it.
+var started = time.Now()
+
+// startup times the steps of opening a book until the first page is drawn, for --debug.
+// It is nil without --debug, and its methods do nothing then.
+var startup *timings
+
+// timings are the steps timed so far.
+type timings struct {
+	last  time.Time
+	steps []string // like "read 12ms".
+	done  bool     // the first page is drawn, later steps are not startup.
+}
+
+// lap ends step, which took the time since the last one ended.
+func (t *timings) lap(step string) {
+	if t == nil || t.done {
+		return
+	}
+	now := time.Now()
+	t.steps = append(t.steps, step+" "+now.Sub(t.last).Round(10*time.Microsecond).String())
+	t.last = now
+}
+
+// finish ends the startup after the first page is drawn.
+func (t *timings) finish() {
+	if t != nil {
+		t.lap("render")
+		t.done = true
+	}
+}
+
+// report writes the steps to the standard error, once the screen is given back.
+func (t *timings) report() {
+	if t == nil || len(t.steps) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stderr, "fish: startup %s, %s in all\n", strings.Join(t.steps, ", "),
+		t.last.Sub(started).Round(10*time.Microsecond))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// maxEdits is the most line changes diffLines looks for, files differing more are shown as replaced.
+const maxEdits = 4000
+
+// edit is a line of a diff: a line of a only if del, of b only if add, of both otherwise.
+type edit struct {
+	a, b     int
+	del, add bool
+}
+
+// diffLines returns the shortest edit script from lines a to b, by Myers' algorithm.
+func diffLines(a, b []string) []edit {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	var ee []edit
+	for i := range pre {
+		ee = append(ee, edit{a: i, b: i})
+	}
+	ee = append(ee, myers(a[pre:len(a)-suf], b[pre:len(b)-suf], pre)...)
+	for i := range suf {
+		ee = append(ee, edit{a: len(a) - suf + i, b: len(b) - suf + i})
+	}
+	return ee
+}
+
+// myers returns the edit script from a to b, their lines are numbered from off.
+func myers(a, b []string, off int) []edit {
+	n, m := len(a), len(b)
+	var trace [][]int32 // trace[d][k+d] is the furthest x on diagonal k after d edits.
+	// at returns the furthest x on diagonal k after d edits.
+	at := func(d, k int) int { return int(trace[d][k+d]) }
+	// down tells if diagonal k is reached from k+1 by an insertion after d-1 edits, not by a deletion.
+	down := func(d, k int) bool { return k == -d || (k != d && at(d-1, k-1) < at(d-1, k+1)) }
+	end := -1
+	for d := 0; d <= min(n+m, maxEdits) && end < 0; d++ {
+		v := make([]int32, 2*d+1)
+		trace = append(trace, v)
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			switch {
+			case d == 0:
+			case down(d, k):
+				x = at(d-1, k+1)
+			default:
+				x = at(d-1, k-1) + 1
+			}
+			for x < n && x-k < m && a[x] == b[x-k] {
+				x++
+			}
+			v[k+d] = int32(x)
+			if x >= n && x-k >= m {
+				end = d
+			}
+		}
+	}
+	if end < 0 {
+		ee := make([]edit, 0, n+m)
+		for i := range n {
+			ee = append(ee, edit{a: off + i, b: off, del: true})
+		}
+		for i := range m {
+			ee = append(ee, edit{a: off + n, b: off + i, add: true})
+		}
+		return ee
+	}
+	var rev []edit
+	x, y := n, m
+	for d := end; d > 0; d-- {
+		k := x - y
+		pk := k - 1
+		if down(d, k) {
+			pk = k + 1
+		}
+		px := at(d-1, pk)
+		py := px - pk
+		for x > px && y > py {
+			x, y = x-1, y-1
+			rev = append(rev, edit{a: off + x, b: off + y})
+		}
+		if pk == k+1 {
+			rev = append(rev, edit{a: off + px, b: off + py, add: true})
+		} else {
+			rev = append(rev, edit{a: off + px, b: off + py, del: true})
+		}
+		x, y = px, py
+	}
+	for x > 0 && y > 0 {
+		x, y = x-1, y-1
+		rev = append(rev, edit{a: off + x, b: off + y})
+	}
+	ee := make([]edit, len(rev))
+	for i, e := range rev {
+		ee[len(rev)-1-i] = e
+	}
+	return ee
+}
+
+// diffSGR are the colors of deleted and added lines.
+const (
+	delSGR = "\x1b[31m"
+	addSGR = "\x1b[32m"
+)
+
+// common returns the lengths of the prefix and suffix a and b share, in whole runes.
+func common(a, b string) (pre, suf int) {
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for pre > 0 && pre < len(a) && !utf8.RuneStart(a[pre]) {
+		pre--
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for suf > 0 && !utf8.RuneStart(a[len(a)-suf]) {
+		suf--
+	}
+	return pre, suf
+}
+
+// diffLine returns line s of a diff marked by sign in SGR sgr, the part of s after pre bytes
+// and before the last suf bytes in reverse video if they are not all of it.
+func diffLine(s, sign, sgr string, pre, suf int) string {
+	if pre+suf == 0 || pre+suf >= len(s) {
+		return sgr + sign + " " + s + "\x1b[0m"
+	}
+	return sgr + sign + " " + s[:pre] + "\x1b[7m" + s[pre:len(s)-suf] + "\x1b[0m" + sgr + s[len(s)-suf:] + "\x1b[0m"
+}
+
+// diffDocument returns the lines of b with the lines deleted from a and added in b marked
+// by - and +. Where as many lines are added as deleted, the changes within each pair are emphasized.
+// Positions in it are lines of b, the deleted lines are at the line of b after them.
+func diffDocument(a, b *document) *document {
+	ee := diffLines(a.lines.strings(), b.lines.strings())
+	n := newDocument()
+	n.meta, n.styled = b.meta, true
+	n.origin = make([]int, 0, len(ee))
+	var ll []string
+	at := make([]int, b.lines.len()+1) // line of b:its line in n.
+	for i := 0; i < len(ee); {
+		e := ee[i]
+		if !e.del && !e.add {
+			at[e.b] = len(ll)
+			ll = append(ll, "  "+b.lines.at(e.b))
+			n.origin = append(n.origin, b.source(e.b))
+			i++
+			continue
+		}
+		n.hunks = append(n.hunks, len(ll))
+		var dd, aa []edit
+		for ; i < len(ee) && ee[i].del; i++ {
+			dd = append(dd, ee[i])
+		}
+		for ; i < len(ee) && ee[i].add; i++ {
+			aa = append(aa, ee[i])
+		}
+		next := b.lines.len()
+		if len(aa) > 0 {
+			next = aa[0].b
+		} else if i < len(ee) {
+			next = ee[i].b
+		}
+		paired := len(dd) == len(aa)
+		for j, e := range dd {
+			pre, suf := 0, 0
+			if paired {
+				pre, suf = common(a.lines.at(e.a), b.lines.at(aa[j].b))
+			}
+			ll = append(ll, diffLine(a.lines.at(e.a), "-", delSGR, pre, suf))
+			n.origin = append(n.origin, b.source(next))
+		}
+		for j, e := range aa {
+			pre, suf := 0, 0
+			if paired {
+				pre, suf = common(b.lines.at(e.b), a.lines.at(dd[j].a))
+			}
+			at[e.b] = len(ll)
+			ll = append(ll, diffLine(b.lines.at(e.b), "+", addSGR, pre, suf))
+			n.origin = append(n.origin, b.source(e.b))
+		}
+	}
+	for _, h := range b.headings {
+		h.line = at[h.line]
+		n.headings = append(n.headings, h)
+	}
+	n.lines = lineIndexOf(ll)
+	return n
+}
+
+// nextHunk moves to the next change of the diff after the top of the page, or before it if back.
+func (r *Reader) nextHunk(back bool) {
+	hh := r.doc.hunks
+	if r.diff == "" {
+		r.notice = tr("not comparing files, see fish diff")
+		return
+	}
+	if back {
+		for i := len(hh) - 1; i >= 0; i-- {
+			if hh[i] < r.currentLine {
+				r.jump(hh[i])
+				r.notice = fmt.Sprintf(tr("change %d/%d"), i+1, len(hh))
+				return
+			}
+		}
+	} else {
+		for i, h := range hh {
+			if h > r.currentLine {
+				r.jump(h)
+				r.notice = fmt.Sprintf(tr("change %d/%d"), i+1, len(hh))
+				return
+			}
+		}
+	}
+	r.notice = tr("no more changes")
+}
+
+// diffWith returns d compared with the other file of the diff, filtered by the same rules.
+func (r *Reader) diffWith(d *document, rr Rules) *document {
+	od, e := loadDocument(r.diff, r.cfg.Encoding)
+	if e == nil {
+		od, e = r.cfg.filter(od, rr)
+	}
+	if e != nil {
+		r.notice = e.Error()
+		return d
+	}
+	if od.lines.joined() == d.lines.joined() {
+		r.notice = tr("the files are the same")
+	}
+	return diffDocument(od, d)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	keep := func(a, b int) edit { return edit{a: a, b: b} }
+	del := func(a, b int) edit { return edit{a: a, b: b, del: true} }
+	add := func(a, b int) edit { return edit{a: a, b: b, add: true} }
+	tests := []struct {
+		name string
+		a, b string
+		want []edit
+	}{
+		{"same", "a b c", "a b c", []edit{keep(0, 0), keep(1, 1), keep(2, 2)}},
+		{"empty", "", "", nil},
+		{"insert", "a b c", "a x b c", []edit{keep(0, 0), add(1, 1), keep(1, 2), keep(2, 3)}},
+		{"insert at the end", "a b", "a b c", []edit{keep(0, 0), keep(1, 1), add(2, 2)}},
+		{"insert into nothing", "", "a b", []edit{add(0, 0), add(0, 1)}},
+		{"delete", "a b c", "a c", []edit{keep(0, 0), del(1, 1), keep(2, 1)}},
+		{"delete at the start", "a b c", "b c", []edit{del(0, 0), keep(1, 0), keep(2, 1)}},
+		{"delete all", "a", "", []edit{del(0, 0)}},
+		{"replace", "a b c", "a x c", []edit{keep(0, 0), del(1, 1), add(2, 1), keep(2, 2)}},
+		{"replace all", "a b", "x y", []edit{del(0, 0), del(1, 0), add(2, 0), add(2, 1)}},
+	}
+	for _, tt := range tests {
+		got := diffLines(strings.Fields(tt.a), strings.Fields(tt.b))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: diffLines(%q, %q) = %+v, want %+v", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestDiffLinesShortest checks the script of the example of Myers' paper, which has 5 edits.
+func TestDiffLinesShortest(t *testing.T) {
+	a, b := strings.Fields("a b c a b b a"), strings.Fields("c b a b a c")
+	var got []string
+	n := 0
+	for _, e := range diffLines(a, b) {
+		switch {
+		case e.del:
+			n++
+		case e.add:
+			n++
+			got = append(got, b[e.b])
+		default:
+			if a[e.a] != b[e.b] {
+				t.Fatalf("line %d of a kept as line %d of b: %q != %q", e.a, e.b, a[e.a], b[e.b])
+			}
+			got = append(got, a[e.a])
+		}
+	}
+	if !slices.Equal(got, b) {
+		t.Errorf("the script makes %q, want %q", got, b)
+	}
+	if n != 5 {
+		t.Errorf("the script has %d edits, want 5", n)
+	}
+}
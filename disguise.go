@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// disguises are the looks D gives the book: comments of a source file, or log output.
+var disguises = []string{"code", "log"}
+
+// Escape sequence of the comment color of the code disguise, the gray of most editor themes.
+const commentSGR = "\x1b[90m"
+
+// disguiseNames are the files the book pretends to be, in the status bar and the title.
+var disguiseNames = map[string]string{"code": "segment.go", "log": "worker.log"}
+
+// toggleDisguise switches the disguise of Config.Disguise on and off.
+func (r *Reader) toggleDisguise() {
+	r.disguised = !r.disguised
+}
+
+// disguise returns the look in use, "" if the book is shown as it is.
+func (r *Reader) disguise() string {
+	if !r.disguised {
+		return ""
+	}
+	if r.cfg.Disguise == "log" {
+		return "log"
+	}
+	return "code"
+}
+
+// disguiseWidth is the number of columns the disguise takes at the start of each row.
+func (r *Reader) disguiseWidth() int {
+	switch r.disguise() {
+	case "code":
+		return len("// ")
+	case "log":
+		return len("09:00:00.000 INFO  ")
+	}
+	return 0
+}
+
+// disguiseHeader returns the fake file header shown at the top of the page.
+func (r *Reader) disguiseHeader() []string {
+	switch r.disguise() {
+	case "code":
+		return []string{
+			commentSGR + "// Code generated by segmentgen. DO NOT EDIT." + r.theme().base(),
+			"",
+			"\x1b[35mpackage\x1b[39m render" + r.theme().base(),
+			"",
+		}
+	case "log":
+		return []string{r.theme().mark() + "==> /var/log/app/" + disguiseNames["log"] + " <==" + r.theme().base()}
+	}
+	return nil
+}
+
+// camouflage dresses rows of line i in the disguise: commented out, or as log records
+// with their time counting on from 9 o'clock, so a page looks the same each time it is drawn.
+func (r *Reader) camouflage(i int, rows []string) []string {
+	base := r.theme().base()
+	switch r.disguise() {
+	case "code":
+		for j, row := range rows {
+			rows[j] = commentSGR + "// " + strings.ReplaceAll(row, base, base+commentSGR) + base
+		}
+	case "log":
+		day := time.Date(2000, 1, 1, 9, 0, 0, 0, time.UTC)
+		level := "INFO "
+		switch {
+		case i%23 == 5:
+			level = "WARN "
+		case i%7 == 3:
+			level = "DEBUG"
+		}
+		for j, row := range rows {
+			t := day.Add(time.Duration(i)*1733*time.Millisecond + time.Duration(j)*3*time.Millisecond)
+			rows[j] = r.theme().mark() + t.Format("15:04:05.000") + " " + level + base + " " + row
+		}
+	}
+	return rows
+}
+
+// disguiseStatus is the status bar of an editor or a pager, showing nothing of the book.
+func (r *Reader) disguiseStatus() string {
+	name := disguiseNames[r.disguise()]
+	if r.disguise() == "log" {
+		return fmt.Sprintf("%s  %d lines", name, r.totalLine)
+	}
+	return fmt.Sprintf("%s  Ln %d, Col 1  UTF-8  Go", name, r.currentLine+1)
+}
+
+// blankScreen hides the page until a key is pressed. Auto-scroll and the pomodoro timer wait meanwhile.
+type blankScreen struct {
+	scroll time.Duration
+	since  time.Time
+}
+
+// blankOut blanks the screen.
+func (r *Reader) blankOut() {
+	r.overlay = &blankScreen{r.scrolling, time.Now()}
+	r.setScrolling(0)
+}
+
+func (x *blankScreen) key(r *Reader, _ string) bool {
+	r.setScrolling(x.scroll)
+	if p := r.pomo; p != nil {
+		p.end = p.end.Add(time.Since(x.since))
+	}
+	return true
+}
+
+func (*blankScreen) draw(_ *Reader, b *strings.Builder) {
+	b.WriteString("\x1b[0m\x1b[H\x1b[2J")
+}
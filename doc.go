@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// document is a book converted to lines of text, with the structure its format carries.
+type document struct {
+	lines     lineIndex      // the text, indexed by line.
+	anchors   map[string]int // anchor:line number, see link.target.
+	links     map[int][]link // line number:links on the line, ordered by start.
+	headings  []chapter      // headings marked up in the source, preferred over detected chapters.
+	meta      Meta
+	paras     bool           // every line is a paragraph, as converted from markup.
+	origin    []int          // line number:source line, nil if no line is hidden, see document.filter.
+	styled    bool           // lines have SGR sequences of their own.
+	json      bool           // the text is JSON, see prettyJSON.
+	folds     map[int]fold   // first line:range which can be collapsed.
+	collapsed map[int]fold   // first line:range collapsed into it, see foldView.
+	cues      map[int]string // line:start time of the subtitle on it, see subtitleDocument.
+	right     []string       // line number:translation shown beside it, nil if none, see parallelDocument.
+	hunks     []int          // first lines of the changes of a diff, see diffDocument.
+	cols      []int          // start columns of the cells of table rows, nil if d is no table, see tableDocument.
+}
+
+// link is a cross-reference in the text, start and end are byte offsets in its line.
+type link struct {
+	start, end int
+	target     string // key of document.anchors, or an external URL.
+	note       bool   // the link refers to a footnote.
+}
+
+func newDocument() *document {
+	return &document{anchors: make(map[string]int), links: make(map[int][]link)}
+}
+
+// chapters returns the headings of d, detected ones if its format has none.
+func (d *document) chapters() []chapter {
+	if len(d.headings) > 0 {
+		return d.headings
+	}
+	return detectChapters(d.lines)
+}
+
+// loadDocument reads book f, converting it by the backend its extension selects.
+// enc is the text encoding for plain text and HTML without a declared charset.
+func loadDocument(f, enc string) (*document, error) {
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".epub":
+		return loadEPUB(f)
+	case playlistExt:
+		return loadPlaylist(f, enc)
+	case ".zip":
+		return loadArchive(f, enc)
+	case ".7z":
+		return loadSevenZip(f, enc)
+	case ".rar":
+		return nil, errors.New(tr("fish reads zip and 7z archives, unpack rar ones or pack them as zip"))
+	}
+	dd, e := os.ReadFile(f)
+	if e != nil {
+		return nil, e
+	}
+	startup.lap("read")
+	if strings.EqualFold(filepath.Ext(f), ".gz") {
+		// like the man pages of /usr/share/man, the name without .gz tells the format.
+		if dd, e = gunzip(dd); e != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(f), e)
+		}
+		f = strings.TrimSuffix(f, filepath.Ext(f))
+	}
+	return parseDocument(f, dd, enc)
+}
+
+// gunzip returns dd decompressed.
+func gunzip(dd []byte) ([]byte, error) {
+	zr, e := gzip.NewReader(bytes.NewReader(dd))
+	if e != nil {
+		return nil, e
+	}
+	return io.ReadAll(zr)
+}
+
+// parseDocument parses the contents dd of file f.
+func parseDocument(f string, dd []byte, enc string) (*document, error) {
+	if strings.EqualFold(filepath.Ext(f), ".docx") {
+		return docxDocument(f, dd)
+	}
+	s, e := decode(dd, enc)
+	if e != nil {
+		return nil, e
+	}
+	startup.lap("decode")
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".html", ".htm", ".xhtml":
+		d := htmlDocument(s, "")
+		if d.meta.Title == "" {
+			d.meta.Title = textMeta(f, lineIndex{}).Title
+		}
+		return d, nil
+	case ".gmi", ".gemini":
+		d := geminiDocument(s)
+		if d.meta.Title == "" {
+			d.meta.Title = textMeta(f, lineIndex{}).Title
+		}
+		return d, nil
+	case ".org":
+		d := orgDocument(s)
+		if d.meta.Title == "" {
+			d.meta.Title = textMeta(f, lineIndex{}).Title
+		}
+		return d, nil
+	case ".tex", ".latex", ".ltx":
+		d := texDocument(s, filepath.Dir(f))
+		if d.meta.Title == "" {
+			d.meta.Title = textMeta(f, lineIndex{}).Title
+		}
+		return d, nil
+	case ".srt", ".vtt":
+		d := subtitleDocument(s)
+		d.meta = textMeta(f, lineIndex{})
+		return d, nil
+	case ".csv", ".tsv":
+		comma := ','
+		if strings.EqualFold(filepath.Ext(f), ".tsv") {
+			comma = '\t'
+		}
+		if d := tableDocument(s, comma); d != nil {
+			d.meta.Title = textMeta(f, lineIndex{}).Title
+			return d, nil
+		}
+	}
+	// printers' overstrikes are looked for in what man pipes, a backspace in a book is no bold.
+	if manExt.MatchString(strings.ToLower(filepath.Ext(f))) || f == pipedPath && strings.Contains(s, "\b") {
+		if d := manDocument(s); d != nil {
+			if d.meta.Title == "" {
+				d.meta.Title = textMeta(f, lineIndex{}).Title
+			}
+			return d, nil
+		}
+	}
+	d := newDocument()
+	d.lines = indexLines(strings.ReplaceAll(s, "\r\n", "\n"))
+	d.meta = textMeta(f, d.lines)
+	d.json = isJSON(f, s)
+	startup.lap("parse")
+	return d, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// docxFile is an opened Word document, a zip archive of XML parts.
+type docxFile struct {
+	files map[string]*zip.File
+}
+
+func (x *docxFile) read(name string) ([]byte, error) {
+	f, ok := x.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing in the DOCX", name)
+	}
+	rc, e := f.Open()
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// docxDocument converts the paragraphs of Word document f, read as dd, to a document, a line
+// a paragraph. Paragraphs of the heading styles 1 to 3 become chapters.
+func docxDocument(f string, dd []byte) (*document, error) {
+	z, e := zip.NewReader(bytes.NewReader(dd), int64(len(dd)))
+	if e != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(f), e)
+	}
+	x := &docxFile{files: make(map[string]*zip.File)}
+	for _, zf := range z.File {
+		x.files[zf.Name] = zf
+	}
+	body, e := x.read("word/document.xml")
+	if e != nil {
+		return nil, e
+	}
+	d := newDocument()
+	d.paras = true
+	w := &docxWriter{htmlWriter: htmlWriter{d: d}, levels: x.headingStyles(), rels: x.links()}
+	if e := w.parse(body); e != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(f), e)
+	}
+	d.lines = lineIndexOf(w.lines)
+	d.meta = x.meta()
+	if d.meta.Title == "" {
+		d.meta.Title = textMeta(f, lineIndex{}).Title
+	}
+	return d, nil
+}
+
+// headingStyles returns the outline level of the heading styles by their id, 1 for "heading 1".
+// Ids are translated in Word of other languages, the names of built-in styles are not.
+func (x *docxFile) headingStyles() map[string]int {
+	levels := make(map[string]int)
+	dd, e := x.read("word/styles.xml")
+	if e != nil {
+		return levels
+	}
+	var ss struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+			Outline *struct {
+				Val int `xml:"val,attr"`
+			} `xml:"pPr>outlineLvl"`
+		} `xml:"style"`
+	}
+	if xml.Unmarshal(dd, &ss) != nil {
+		return levels
+	}
+	for _, s := range ss.Styles {
+		name := strings.ToLower(s.Name.Val)
+		switch {
+		case name == "title":
+			levels[s.ID] = 1
+		case strings.HasPrefix(name, "heading "):
+			if n, e := strconv.Atoi(strings.TrimPrefix(name, "heading ")); e == nil {
+				levels[s.ID] = n
+			}
+		case s.Outline != nil && s.Outline.Val < 9:
+			levels[s.ID] = s.Outline.Val + 1
+		}
+	}
+	return levels
+}
+
+// links returns the targets of the external hyperlinks of the document by their relationship id.
+func (x *docxFile) links() map[string]string {
+	rels := make(map[string]string)
+	dd, e := x.read("word/_rels/document.xml.rels")
+	if e != nil {
+		return rels
+	}
+	var rr struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+			Mode   string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if xml.Unmarshal(dd, &rr) != nil {
+		return rels
+	}
+	for _, r := range rr.Rels {
+		if r.Mode == "External" {
+			rels[r.ID] = r.Target
+		}
+	}
+	return rels
+}
+
+// meta reads the title, author and language from the core properties.
+func (x *docxFile) meta() Meta {
+	dd, e := x.read("docProps/core.xml")
+	if e != nil {
+		return Meta{}
+	}
+	var p struct {
+		Title    string `xml:"title"`
+		Creator  string `xml:"creator"`
+		Language string `xml:"language"`
+	}
+	if xml.Unmarshal(dd, &p) != nil {
+		return Meta{}
+	}
+	return Meta{Title: strings.TrimSpace(p.Title), Author: strings.TrimSpace(p.Creator), Language: strings.TrimSpace(p.Language)}
+}
+
+// docxWriter converts the body of a Word document into lines, by the htmlWriter for the lines,
+// links and anchors.
+type docxWriter struct {
+	htmlWriter
+	levels map[string]int    // style id:heading level.
+	rels   map[string]string // relationship id:URL.
+	start  int               // first line of the paragraph.
+	level  int               // heading level of the paragraph, 0 if it is no heading.
+	list   bool              // the paragraph is an item of a list.
+	begun  bool              // a run of the paragraph was met, its properties are read.
+	run    bool              // in a <w:r>, tabs and breaks there are text.
+	text   bool              // in a <w:t>, whose text is written.
+	skip   int               // depth of elements whose text is not shown, like deleted text.
+}
+
+// parse writes the paragraphs of document.xml dd.
+func (w *docxWriter) parse(dd []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(dd))
+	for {
+		t, e := dec.Token()
+		if e == io.EOF {
+			w.flush()
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			w.startElement(t)
+		case xml.EndElement:
+			w.endElement(t)
+		case xml.CharData:
+			if w.text && w.skip == 0 {
+				w.line.Write(t)
+			}
+		}
+	}
+}
+
+func (w *docxWriter) startElement(t xml.StartElement) {
+	if w.skip > 0 {
+		w.skip++
+		return
+	}
+	val := func(name string) string {
+		for _, a := range t.Attr {
+			if a.Name.Local == name {
+				return a.Value
+			}
+		}
+		return ""
+	}
+	switch t.Name.Local {
+	case "p":
+		w.flush()
+		w.level, w.list, w.begun = 0, false, false
+	case "pStyle":
+		w.level = w.levels[val("val")]
+	case "outlineLvl":
+		if n, e := strconv.Atoi(val("val")); e == nil && n < 9 {
+			w.level = n + 1
+		}
+	case "numPr":
+		w.list = true
+	case "r":
+		w.run = true
+		w.begin()
+	case "t":
+		w.text = true
+	case "tab":
+		if w.run {
+			w.line.WriteString(" ")
+		}
+	case "br", "cr":
+		if w.run && val("type") != "page" {
+			w.flush()
+		}
+	case "bookmarkStart":
+		if name := val("name"); name != "" && name != "_GoBack" {
+			w.d.anchors[name] = len(w.lines)
+		}
+	case "hyperlink":
+		target := w.rels[val("id")]
+		if a := val("anchor"); a != "" {
+			target = a
+		}
+		w.begin()
+		if target != "" && w.open == nil {
+			w.open = &link{start: w.line.Len(), target: target}
+		}
+	case "del", "moveFrom", "Fallback":
+		// deleted text, and the copy of text boxes for old versions of Word.
+		w.skip = 1
+	}
+}
+
+// begin starts the text of the paragraph at its first run, after its properties.
+func (w *docxWriter) begin() {
+	if w.begun {
+		return
+	}
+	w.begun = true
+	if w.level > 0 {
+		w.blank()
+	}
+	w.start = len(w.lines)
+	if w.list {
+		w.line.WriteString("• ")
+	}
+}
+
+func (w *docxWriter) endElement(t xml.EndElement) {
+	if w.skip > 0 {
+		w.skip--
+		return
+	}
+	switch t.Name.Local {
+	case "t":
+		w.text = false
+	case "r":
+		w.run = false
+	case "hyperlink":
+		if w.open != nil {
+			w.open.end = w.line.Len()
+			w.links = append(w.links, *w.open)
+			w.open = nil
+		}
+	case "p":
+		w.paragraph()
+	}
+}
+
+// paragraph ends a paragraph, a heading gets blank lines around it like in HTML.
+func (w *docxWriter) paragraph() {
+	w.flush()
+	if w.level == 0 || !w.begun {
+		return
+	}
+	if w.level <= 3 {
+		if t := strings.TrimSpace(strings.Join(w.lines[w.start:], " ")); t != "" {
+			w.d.headings = append(w.d.headings, chapter{line: w.start, level: w.level, title: t})
+		}
+	}
+	w.blank()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// encodings are the choices offered by the settings menu, any name known by htmlindex works in config.
+var encodings = []string{"auto", "utf-8", "gb18030", "gbk", "big5", "shift_jis", "euc-jp", "euc-kr", "utf-16le", "utf-16be", "windows-1252"}
+
+// decode converts dd in encoding enc to UTF-8. enc "auto" (or empty) picks UTF-16 by BOM,
+// then UTF-8 if dd is valid, otherwise GB18030 which most non-UTF-8 Chinese text files use.
+func decode(dd []byte, enc string) (string, error) {
+	var d encoding.Encoding
+	switch enc {
+	case "", "auto":
+		switch {
+		case bytes.HasPrefix(dd, []byte{0xff, 0xfe}), bytes.HasPrefix(dd, []byte{0xfe, 0xff}):
+			d = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+		case utf8.Valid(dd):
+			return string(bytes.TrimPrefix(dd, []byte("\xef\xbb\xbf"))), nil
+		default:
+			d = simplifiedchinese.GB18030
+		}
+	default:
+		var e error
+		if d, e = htmlindex.Get(enc); e != nil {
+			return "", fmt.Errorf("unknown encoding %q", enc)
+		}
+	}
+	out, e := d.NewDecoder().Bytes(dd)
+	if e != nil {
+		return "", e
+	}
+	return string(bytes.TrimPrefix(out, []byte("\xef\xbb\xbf"))), nil
+}
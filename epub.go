@@ -0,0 +1,275 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// epubFile is an opened EPUB archive.
+type epubFile struct {
+	z     *zip.ReadCloser
+	files map[string]*zip.File
+}
+
+func (b *epubFile) read(name string) ([]byte, error) {
+	f, ok := b.files[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is missing in the EPUB", name)
+	}
+	rc, e := f.Open()
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// epubPackage is the part of the OPF package document fish uses.
+type epubPackage struct {
+	Meta struct {
+		Title    []string `xml:"title"`
+		Creator  []string `xml:"creator"`
+		Language []string `xml:"language"`
+		Metas    []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID    string `xml:"id,attr"`
+		Href  string `xml:"href,attr"`
+		Type  string `xml:"media-type,attr"`
+		Props string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc   string `xml:"toc,attr"`
+		Items []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type ncxPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Points []ncxPoint `xml:"navPoint"`
+}
+
+// tocEntry is an entry of the EPUB table of contents, target is an anchor key.
+type tocEntry struct {
+	title, target string
+	level         int
+}
+
+// openEPUB opens EPUB book f and reads its package document, found at opf.
+func openEPUB(f string) (b *epubFile, opf string, p *epubPackage, e error) {
+	z, e := zip.OpenReader(f)
+	if e != nil {
+		return nil, "", nil, e
+	}
+	b = &epubFile{z: z, files: make(map[string]*zip.File)}
+	for _, zf := range z.File {
+		b.files[zf.Name] = zf
+	}
+	if opf, p, e = b.pkg(); e != nil {
+		_ = z.Close()
+		return nil, "", nil, e
+	}
+	return b, opf, p, nil
+}
+
+func (b *epubFile) pkg() (string, *epubPackage, error) {
+	dd, e := b.read("META-INF/container.xml")
+	if e != nil {
+		return "", nil, e
+	}
+	var c struct {
+		Rootfiles []struct {
+			Path string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if e := xml.Unmarshal(dd, &c); e != nil {
+		return "", nil, e
+	}
+	if len(c.Rootfiles) == 0 {
+		return "", nil, fmt.Errorf("no rootfile in the EPUB container")
+	}
+	opf := c.Rootfiles[0].Path
+	if dd, e = b.read(opf); e != nil {
+		return "", nil, e
+	}
+	p := &epubPackage{}
+	if e := xml.Unmarshal(dd, p); e != nil {
+		return "", nil, e
+	}
+	return opf, p, nil
+}
+
+// loadEPUB converts the spine of an EPUB book to a document, with its table of contents as headings.
+func loadEPUB(f string) (*document, error) {
+	b, opf, p, e := openEPUB(f)
+	if e != nil {
+		return nil, e
+	}
+	defer b.z.Close()
+	items := make(map[string]int)
+	for i, it := range p.Manifest {
+		items[it.ID] = i
+	}
+	d := newDocument()
+	d.paras = true
+	w := &htmlWriter{d: d}
+	for _, ref := range p.Spine.Items {
+		i, ok := items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		name := resolveHref(opf, p.Manifest[i].Href)
+		dd, e := b.read(name)
+		if e != nil {
+			return nil, e
+		}
+		w.add(string(dd), name)
+		w.blank()
+	}
+	d.lines = lineIndexOf(w.lines)
+	var toc []tocEntry
+	for _, it := range p.Manifest {
+		name := resolveHref(opf, it.Href)
+		switch {
+		case strings.Contains(it.Props, "nav"):
+			toc = b.navTOC(name)
+		case it.ID == p.Spine.Toc && len(toc) == 0:
+			toc = b.ncxTOC(name)
+		}
+	}
+	if hh := tocHeadings(d, toc); len(hh) > 0 {
+		d.headings = hh
+	}
+	first := func(ss []string) string {
+		if len(ss) == 0 {
+			return ""
+		}
+		return strings.TrimSpace(ss[0])
+	}
+	d.meta = Meta{Title: first(p.Meta.Title), Author: strings.Join(p.Meta.Creator, ", "), Language: first(p.Meta.Language)}
+	return d, nil
+}
+
+// ncxTOC reads the EPUB 2 table of contents.
+func (b *epubFile) ncxTOC(name string) []tocEntry {
+	dd, e := b.read(name)
+	if e != nil {
+		return nil
+	}
+	var n struct {
+		Points []ncxPoint `xml:"navMap>navPoint"`
+	}
+	if xml.Unmarshal(dd, &n) != nil {
+		return nil
+	}
+	var toc []tocEntry
+	var walk func(pp []ncxPoint, level int)
+	walk = func(pp []ncxPoint, level int) {
+		for _, p := range pp {
+			toc = append(toc, tocEntry{strings.TrimSpace(p.Label), resolveHref(name, p.Content.Src), level})
+			walk(p.Points, level+1)
+		}
+	}
+	walk(n.Points, 1)
+	return toc
+}
+
+// navTOC reads the EPUB 3 navigation document.
+func (b *epubFile) navTOC(name string) []tocEntry {
+	dd, e := b.read(name)
+	if e != nil {
+		return nil
+	}
+	n, e := html.Parse(strings.NewReader(string(dd)))
+	if e != nil {
+		return nil
+	}
+	var toc []tocEntry
+	var walk func(n *html.Node, in bool, level int)
+	walk = func(n *html.Node, in bool, level int) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Nav {
+			t, _ := attr(n, "epub:type")
+			in = strings.Contains(t, "toc")
+		}
+		if in && n.Type == html.ElementNode && n.DataAtom == atom.Ol {
+			level++
+		}
+		if in && n.Type == html.ElementNode && n.DataAtom == atom.A {
+			if href, ok := attr(n, "href"); ok {
+				toc = append(toc, tocEntry{strings.Join(strings.Fields(nodeText(n)), " "), resolveHref(name, href), max(1, level)})
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, in, level)
+		}
+	}
+	walk(n, false, 0)
+	return toc
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
+
+// tocHeadings finds the lines of the table of contents entries.
+func tocHeadings(d *document, toc []tocEntry) []chapter {
+	var hh []chapter
+	seen := make(map[int]bool)
+	for _, t := range toc {
+		l, ok := d.anchors[t.target]
+		if !ok {
+			l, ok = d.anchors[strings.SplitN(t.target, "#", 2)[0]]
+		}
+		if !ok || seen[l] || t.title == "" {
+			continue
+		}
+		seen[l] = true
+		hh = append(hh, chapter{line: l, level: t.level, title: t.title})
+	}
+	sort.Slice(hh, func(i, j int) bool { return hh[i].line < hh[j].line })
+	return hh
+}
+
+// epubCover returns the cover image of EPUB book f, nil if it has none.
+func epubCover(f string) []byte {
+	b, opf, p, e := openEPUB(f)
+	if e != nil {
+		return nil
+	}
+	defer b.z.Close()
+	id := ""
+	for _, m := range p.Meta.Metas {
+		if m.Name == "cover" {
+			id = m.Content
+		}
+	}
+	for _, it := range p.Manifest {
+		if strings.Contains(it.Props, "cover-image") || (it.ID == id && strings.HasPrefix(it.Type, "image/")) {
+			dd, _ := b.read(resolveHref(opf, it.Href))
+			return dd
+		}
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// source returns the line of the book file line i of d was converted from.
+// Progress is saved by source lines, so it survives changing the filters.
+func (d *document) source(i int) int {
+	if d == nil || d.origin == nil || i < 0 {
+		return i
+	}
+	if i >= len(d.origin) {
+		if len(d.origin) == 0 {
+			return 0
+		}
+		return d.origin[len(d.origin)-1] + 1
+	}
+	return d.origin[i]
+}
+
+// view returns the line of d showing source line src, or the next line if src is hidden.
+func (d *document) view(src int) int {
+	if d == nil || d.origin == nil {
+		return src
+	}
+	i := sort.SearchInts(d.origin, src)
+	return min(i, max(0, len(d.origin)-1))
+}
+
+// filter returns d without the lines keep rejects. Anchors and headings of hidden lines move
+// to the next line shown.
+func (d *document) filter(keep func(i int, s string) bool) *document {
+	n := newDocument()
+	n.meta, n.paras, n.json, n.cols, n.styled = d.meta, d.paras, d.json, d.cols, d.styled
+	n.origin = make([]int, 0, d.lines.len())
+	at := make([]int, d.lines.len()+1) // line of d:line of n it is shown at or before.
+	k := 0
+	for i, s := range d.lines.all() {
+		at[i] = k
+		if !keep(i, s) {
+			continue
+		}
+		if ll, ok := d.links[i]; ok {
+			n.links[k] = ll
+		}
+		n.origin = append(n.origin, d.source(i))
+		if d.right != nil {
+			n.right = append(n.right, d.right[i])
+		}
+		k++
+	}
+	at[d.lines.len()] = k
+	if k == d.lines.len() {
+		return d
+	}
+	// the text is copied once it is known lines are left out.
+	var lb lineBuilder
+	for i, s := range d.lines.all() {
+		if at[i+1] > at[i] {
+			lb.add(s)
+		}
+	}
+	n.lines = lb.index()
+	last := max(0, k-1)
+	for k, l := range d.anchors {
+		n.anchors[k] = min(at[l], last)
+	}
+	for _, h := range d.headings {
+		if h.line < d.lines.len() && keep(h.line, d.lines.at(h.line)) {
+			h.line = at[h.line]
+			n.headings = append(n.headings, h)
+		}
+	}
+	n.cues = keepLines(d, d.cues, keep, at)
+	n.collapsed = keepLines(d, d.collapsed, keep, at)
+	if d.folds != nil {
+		n.folds = make(map[int]fold)
+		for s, f := range d.folds {
+			if f.end < d.lines.len() && keep(s, d.lines.at(s)) && at[f.end+1]-1 > at[s] {
+				f.end = at[f.end+1] - 1
+				n.folds[at[s]] = f
+			}
+		}
+	}
+	return n
+}
+
+// keepLines returns m, keyed by lines of d, with the lines keep rejects left out and the others
+// moved to their lines at in the filtered document.
+func keepLines[T any](d *document, m map[int]T, keep func(i int, s string) bool, at []int) map[int]T {
+	if m == nil {
+		return nil
+	}
+	n := make(map[int]T)
+	for l, v := range m {
+		if l < d.lines.len() && keep(l, d.lines.at(l)) {
+			n[at[l]] = v
+		}
+	}
+	return n
+}
+
+// Rules hide and change lines of books as they are loaded.
+type Rules struct {
+	Hide    []string  `json:"hide,omitempty"`    // regular expressions of lines to hide.
+	Replace []Replace `json:"replace,omitempty"` // applied in order, after hiding lines.
+}
+
+// Replace replaces matches of regular expression Find by With, which may refer to groups like $1.
+type Replace struct {
+	Find string `json:"find"`
+	With string `json:"with"`
+}
+
+// replace returns d with the text of its lines replaced by fn, links are kept on the same text.
+// Lines with links are replaced part by part, fn gets the offset of the part in its line.
+func (d *document) replace(fn func(s string, at int) string) *document {
+	n := *d
+	var lb lineBuilder
+	n.links = make(map[int][]link)
+	for i, s := range d.lines.all() {
+		ll := d.links[i]
+		if len(ll) == 0 {
+			lb.add(fn(s, 0))
+			continue
+		}
+		var sb strings.Builder
+		at := 0
+		nl := make([]link, len(ll))
+		for j, l := range ll {
+			sb.WriteString(fn(s[at:l.start], at))
+			nl[j] = l
+			nl[j].start = sb.Len()
+			sb.WriteString(fn(s[l.start:l.end], l.start))
+			nl[j].end = sb.Len()
+			at = l.end
+		}
+		sb.WriteString(fn(s[at:], at))
+		lb.add(sb.String())
+		n.links[i] = nl
+	}
+	n.lines = lb.index()
+	n.headings = make([]chapter, len(d.headings))
+	for i, h := range d.headings {
+		h.title = fn(h.title, 0)
+		n.headings[i] = h
+	}
+	return &n
+}
+
+// filter applies the filters c enables and its rules to d, then the rules of the book in b.
+// Invalid patterns are skipped and reported by the error.
+func (c Config) filter(d *document, b Rules) (*document, error) {
+	if c.JSON && d.json {
+		d = prettyJSON(d)
+	}
+	if d.lines.containsFunc(hasControls) {
+		// the SGR sequences of a styled format, like the fonts of man pages, are kept.
+		d = d.replace(func(s string, _ int) string { return stripControls(s, c.ANSI || d.styled) })
+	}
+	if c.Gutenberg {
+		if start, end := gutenbergText(d.lines); start > 0 || end < d.lines.len() {
+			d = d.filter(func(i int, _ string) bool { return i >= start && i < end })
+		}
+	}
+	if c.Squeeze {
+		d = squeezeBlanks(d)
+	}
+	if c.Normalize {
+		d = d.replace(normalizeLine)
+	}
+	var ee []error
+	var pp []*regexp.Regexp
+	for _, s := range append(slices.Clone(c.Hide), b.Hide...) {
+		p, e := regexp.Compile(s)
+		if e != nil {
+			ee = append(ee, fmt.Errorf("bad hide pattern %q: %w", s, e))
+			continue
+		}
+		pp = append(pp, p)
+	}
+	if len(pp) > 0 {
+		d = d.filter(func(_ int, s string) bool {
+			return !slices.ContainsFunc(pp, func(p *regexp.Regexp) bool { return p.MatchString(s) })
+		})
+	}
+	type rule struct {
+		p    *regexp.Regexp
+		with string
+	}
+	var rr []rule
+	for _, x := range append(slices.Clone(c.Replace), b.Replace...) {
+		p, e := regexp.Compile(x.Find)
+		if e != nil {
+			ee = append(ee, fmt.Errorf("bad replace pattern %q: %w", x.Find, e))
+			continue
+		}
+		rr = append(rr, rule{p, x.With})
+	}
+	if len(rr) > 0 {
+		d = d.replace(func(s string, _ int) string {
+			for _, x := range rr {
+				s = x.p.ReplaceAllString(s, x.with)
+			}
+			return s
+		})
+	}
+	return d, errors.Join(ee...)
+}
+
+var (
+	gutenbergStart = regexp.MustCompile(`(?i)^\s*\*{3}\s*START OF (THE|THIS) PROJECT GUTENBERG|^\s*\*END\*THE SMALL PRINT`)
+	gutenbergEnd   = regexp.MustCompile(`(?i)^\s*(\*{3}\s*)?END OF (THE|THIS) PROJECT GUTENBERG`)
+)
+
+// gutenbergLine tells if s may be a license header or footer, which start with a star or END
+// after spaces, so the patterns need not run on every line of big books.
+func gutenbergLine(s string) bool {
+	s = strings.TrimLeft(s, " \t\r\n\f")
+	return s != "" && (s[0] == '*' || s[0] == 'E' || s[0] == 'e')
+}
+
+// gutenbergText returns the range of lines between the license header and footer
+// Project Gutenberg puts around its books, all lines if there are none.
+func gutenbergText(ll lineIndex) (start, end int) {
+	// the chunks find their first header and last footer, the first and last of all are taken.
+	type found struct{ start, end int }
+	ff := eachChunk(ll.len(), func(from, to int) found {
+		f := found{-1, -1}
+		for i := from; i < to; i++ {
+			if s := ll.at(i); gutenbergLine(s) && gutenbergStart.MatchString(s) {
+				f.start = i + 1
+				break
+			}
+		}
+		for i := to - 1; i >= max(from, f.start); i-- {
+			if s := ll.at(i); gutenbergLine(s) && gutenbergEnd.MatchString(s) {
+				f.end = i
+				break
+			}
+		}
+		return f
+	})
+	end = ll.len()
+	for _, f := range ff {
+		if f.start >= 0 {
+			start = f.start
+			break
+		}
+	}
+	for _, f := range slices.Backward(ff) {
+		if f.end >= start {
+			end = f.end
+			break
+		}
+	}
+	return start, end
+}
+
+// hasControls tells if s has control characters other than tabs.
+func hasControls(s string) bool {
+	return strings.ContainsFunc(s, func(c rune) bool { return (c < 0x20 && c != '\t') || c == 0x7f })
+}
+
+// stripControls removes control characters and escape sequences from s, which would move the
+// cursor or change the screen. SGR sequences are kept if sgr is true.
+func stripControls(s string, sgr bool) string {
+	if !hasControls(s) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == 0x1b && i+1 < len(s) && (s[i+1] == ']' || s[i+1] == 'P' || s[i+1] == '_'):
+			// OSC, DCS and APC strings end with BEL or ST.
+			j := i + 2
+			for j < len(s) && s[j] != 0x07 && !(s[j] == 0x1b && j+1 < len(s) && s[j+1] == '\\') {
+				j++
+			}
+			if j < len(s) && s[j] == 0x1b {
+				j++
+			}
+			i = min(j+1, len(s))
+		case c == 0x1b:
+			n := max(1, escLen(s[i:]))
+			if seq := s[i : i+n]; sgr && strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m") {
+				sb.WriteString(seq)
+			}
+			i += n
+		case (c < 0x20 && c != '\t') || c == 0x7f:
+			i++
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// themeSGR makes the SGR resets in s reset to theme base instead of the terminal colors.
+func themeSGR(s, base string) string {
+	if !strings.Contains(s, "\x1b[") {
+		return s
+	}
+	s = strings.ReplaceAll(s, "\x1b[m", "\x1b[0m")
+	s = strings.ReplaceAll(s, "\x1b[0m", base)
+	return strings.ReplaceAll(s, "\x1b[0;", base+"\x1b[")
+}
+
+// squeezeBlanks hides all but the first of 3 or more blank lines in a row.
+func squeezeBlanks(d *document) *document {
+	blank := func(i int) bool { return i >= 0 && i < d.lines.len() && strings.TrimSpace(d.lines.at(i)) == "" }
+	run := make([]int, d.lines.len()) // length of the run of blank lines line i is in.
+	for i := 0; i < d.lines.len(); {
+		j := i
+		for blank(j) {
+			j++
+		}
+		for k := i; k < j; k++ {
+			run[k] = j - i
+		}
+		i = max(j, i+1)
+	}
+	return d.filter(func(i int, _ string) bool { return run[i] < 3 || !blank(i-1) })
+}
+
+// cjk tells if c is a Chinese character or full-width punctuation.
+func cjk(c rune) bool {
+	return unicode.Is(unicode.Han, c) || (c >= 0x3000 && c <= 0x303f) || (c >= 0xff00 && c <= 0xffef) ||
+		strings.ContainsRune("“”‘’…—", c)
+}
+
+// fullPunct are the full-width forms of ASCII punctuation used in Chinese text.
+var fullPunct = map[rune]rune{',': '，', ';': '；', ':': '：', '?': '？', '!': '！', ')': '）'}
+
+// normalizeLine evens out punctuation and spacing of scraped Chinese text: ASCII punctuation
+// next to Chinese characters becomes full-width, full-width letters and digits half-width,
+// spaces between Chinese characters are dropped and paragraph indents become two ideographic spaces.
+// at is the offset of s in its line, only the start of a line is indented.
+func normalizeLine(s string, at int) string {
+	body := s
+	indent := false
+	if at == 0 {
+		body = strings.TrimLeft(s, " \t　")
+		indent = len(body) < len(s) && strings.ContainsFunc(body, cjk)
+	}
+	rs := []rune(body)
+	// around returns the nearest runes before and after i which are not spaces, 0 if none.
+	around := func(rs []rune, i int) (rune, rune) {
+		var p, n rune
+		for j := i - 1; j >= 0 && p == 0; j-- {
+			if rs[j] != ' ' {
+				p = rs[j]
+			}
+		}
+		for j := i + 1; j < len(rs) && n == 0; j++ {
+			if rs[j] != ' ' {
+				n = rs[j]
+			}
+		}
+		return p, n
+	}
+	out := make([]rune, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		p, n := around(rs, i)
+		switch {
+		case c >= '０' && c <= '９', c >= 'Ａ' && c <= 'Ｚ', c >= 'ａ' && c <= 'ｚ':
+			c -= 0xfee0
+		case (c == '.' || c == '。') && i+2 < len(rs) && rs[i+1] == c && rs[i+2] == c && (c == '。' || cjk(p) || cjk(n)):
+			for i+1 < len(rs) && rs[i+1] == c {
+				i++
+			}
+			out = append(out, '…', '…')
+			continue
+		case c == '(':
+			if cjk(n) {
+				c = '（'
+			}
+		case c == '.':
+			if cjk(p) && (n == 0 || cjk(n)) {
+				c = '。'
+			}
+		default:
+			if f, ok := fullPunct[c]; ok && cjk(p) {
+				c = f
+			}
+		}
+		out = append(out, c)
+	}
+	rs, out = out, out[:0:0]
+	for i, c := range rs {
+		if p, n := around(rs, i); c == ' ' && cjk(p) && cjk(n) {
+			continue
+		}
+		out = append(out, c)
+	}
+	if indent {
+		return "　　" + string(out)
+	}
+	return string(out)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fold is a range of lines which can be collapsed into its first line.
+type fold struct {
+	end     int    // last line of the range.
+	level   int    // 0 for the outermost range.
+	items   int    // entries directly in the range.
+	summary string // shown after the first line when collapsed.
+	closed  bool   // starts collapsed, like the drawers of Org files.
+}
+
+// foldView returns d with the folds starting at the lines in folded collapsed.
+func foldView(d *document, folded map[int]bool) *document {
+	hidden := make([]bool, d.lines.len())
+	for s, f := range d.folds {
+		if folded[s] {
+			for i := s + 1; i <= min(f.end, d.lines.len()-1); i++ {
+				hidden[i] = true
+			}
+		}
+	}
+	n := d.filter(func(i int, _ string) bool { return !hidden[i] })
+	if n == d {
+		return d
+	}
+	n.collapsed = make(map[int]fold)
+	at := 0
+	for i := range d.lines.all() {
+		if hidden[i] {
+			continue
+		}
+		if f, ok := d.folds[i]; ok && folded[i] {
+			n.collapsed[at] = f
+		}
+		at++
+	}
+	return n
+}
+
+// baseLine returns the line of the unfolded document line i of the view shows.
+func (r *Reader) baseLine(i int) int {
+	return r.base.view(r.doc.source(i))
+}
+
+// pageFolds returns the lines on the page where folds start.
+func (r *Reader) pageFolds() []int {
+	var ll []int
+	for i := r.currentLine; i < min(r.currentLine+max(1, r.shown+1), r.totalLine); i++ {
+		if _, ok := r.base.folds[r.baseLine(i)]; ok {
+			ll = append(ll, i)
+		}
+	}
+	return ll
+}
+
+// foldSelect is the overlay choosing a fold on the page to toggle, the chosen one is highlighted.
+type foldSelect struct {
+	lines []int
+	sel   int
+}
+
+// selectFold starts choosing a fold on the page to collapse or expand.
+func (r *Reader) selectFold() {
+	ll := r.pageFolds()
+	if len(ll) == 0 {
+		r.notice = tr("nothing to fold on this page")
+		return
+	}
+	r.overlay = &foldSelect{lines: ll}
+}
+
+func (s *foldSelect) key(r *Reader, k string) bool {
+	switch k {
+	case "tab", "right", "down", "j":
+		s.sel = (s.sel + 1) % len(s.lines)
+	case "left", "up", "k":
+		s.sel = (s.sel + len(s.lines) - 1) % len(s.lines)
+	case "enter", "z", "space":
+		b := r.baseLine(s.lines[s.sel])
+		r.folded[b] = !r.folded[b]
+		r.rebuild()
+		return true
+	case "esc", "q":
+		return true
+	}
+	return false
+}
+
+func (s *foldSelect) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K%s", r.winHeight, t.status(),
+		cut(fmt.Sprintf("> Fold %d/%d  [Tab]:Next [Enter]:Toggle [Esc]:Cancel", s.sel+1, len(s.lines)), r.winWidth-1), t.base())
+}
+
+// selectedFold returns the line of the fold being chosen, -1 if none.
+func (r *Reader) selectedFold() int {
+	if s, ok := r.overlay.(*foldSelect); ok {
+		return s.lines[s.sel]
+	}
+	return -1
+}
+
+// toggleFolds expands all folds if any is collapsed, otherwise collapses the ones in the outermost.
+func (r *Reader) toggleFolds() {
+	if len(r.base.folds) == 0 {
+		r.notice = tr("nothing to fold in this book")
+		return
+	}
+	any := false
+	for _, v := range r.folded {
+		any = any || v
+	}
+	r.folded = make(map[int]bool)
+	if !any {
+		for s, f := range r.base.folds {
+			r.folded[s] = f.level == 1
+		}
+	}
+	r.rebuild()
+}
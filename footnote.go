@@ -0,0 +1,130 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// footnoteRef matches footnote references like "[1]", "¹²" or "①".
+var footnoteRef = regexp.MustCompile(`\[\d{1,3}\]|[⁰¹²³⁴⁵⁶⁷⁸⁹]+|[①-⑳]`)
+
+// footnote is a reference to a footnote found in the text.
+type footnote struct {
+	line int // line of the reference.
+	mark string
+	text int // line of the footnote text, -1 if not found.
+}
+
+// footnoteNumber returns the number a reference mark stands for.
+func footnoteNumber(mark string) int {
+	if strings.HasPrefix(mark, "[") {
+		n, _ := strconv.Atoi(strings.Trim(mark, "[]"))
+		return n
+	}
+	n := 0
+	for _, c := range mark {
+		switch {
+		case c >= '①' && c <= '⑳':
+			return int(c-'①') + 1
+		case c == '¹':
+			n = n*10 + 1
+		case c == '²':
+			n = n*10 + 2
+		case c == '³':
+			n = n*10 + 3
+		default: // ⁰, ⁴ to ⁹ are a block.
+			n = n*10 + int(c-'⁰')
+		}
+	}
+	return n
+}
+
+// footnoteKind tells bracketed, superscript and circled marks apart, as they number separately.
+func footnoteKind(mark string) byte {
+	switch {
+	case strings.HasPrefix(mark, "["):
+		return '['
+	case strings.ContainsAny(mark, "①②③④⑤⑥⑦⑧⑨⑩⑪⑫⑬⑭⑮⑯⑰⑱⑲⑳"):
+		return 'o'
+	}
+	return '^'
+}
+
+// footnoteStart returns the footnote mark s starts with, "" if none.
+func footnoteStart(s string) string {
+	s = strings.TrimSpace(s)
+	if loc := footnoteRef.FindStringIndex(s); loc != nil && loc[0] == 0 {
+		return s[:loc[1]]
+	}
+	return ""
+}
+
+// findFootnote returns the line of the text of footnote mark referenced at line from,
+// looking after the reference first. It returns -1 if there is none.
+func (r *Reader) findFootnote(from int, mark string) int {
+	n, k := footnoteNumber(mark), footnoteKind(mark)
+	is := func(i int) bool {
+		m := footnoteStart(r.index.at(i))
+		return m != "" && footnoteKind(m) == k && footnoteNumber(m) == n
+	}
+	for i := from + 1; i < r.totalLine; i++ {
+		if is(i) {
+			return i
+		}
+	}
+	for i := from - 1; i >= 0; i-- {
+		if is(i) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pageFootnotes returns the footnote references on the current page which have a footnote text.
+func (r *Reader) pageFootnotes() []footnote {
+	var ff []footnote
+	for i := r.currentLine; i < min(r.currentLine+max(1, r.shown+1), r.totalLine); i++ {
+		s := r.index.at(i)
+		start := footnoteStart(s) != ""
+		for j, loc := range footnoteRef.FindAllStringIndex(s, -1) {
+			if j == 0 && start {
+				continue // it is a footnote text, not a reference.
+			}
+			f := footnote{line: i, mark: s[loc[0]:loc[1]]}
+			if f.text = r.findFootnote(i, f.mark); f.text >= 0 {
+				ff = append(ff, f)
+			}
+		}
+		for _, l := range r.doc.links[i] {
+			if t, ok := r.doc.anchors[l.target]; ok && l.note {
+				ff = append(ff, footnote{line: i, mark: s[l.start:l.end], text: t})
+			}
+		}
+	}
+	return ff
+}
+
+// openFootnote jumps to the footnote referenced on the page, asking which one if there are several.
+func (r *Reader) openFootnote() {
+	ff := r.pageFootnotes()
+	switch len(ff) {
+	case 0:
+		r.notice = tr("no footnotes on this page")
+	case 1:
+		r.jump(ff[0].text)
+	default:
+		items := make([]string, len(ff))
+		for i, f := range ff {
+			items[i] = strings.TrimSpace(r.index.at(f.text))
+		}
+		r.overlay = &list{
+			title: "Footnotes",
+			items: items,
+			foot:  "Enter:Jump Ctrl-O:Back Esc:Close",
+			pick: func(r *Reader, i int) {
+				r.jump(ff[i].text)
+			},
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// geminiHosts in ArticleDir pins the certificates of the Gemini hosts met, a line for each:
+// the host, the SHA-256 of its certificate and when that expires. Capsules sign their own
+// certificates, so the first one seen is trusted, until it expires.
+const geminiHosts = "gemini-hosts"
+
+// geminiRedirects is how many redirects are followed.
+const geminiRedirects = 5
+
+// fetchGemini gets the page at Gemini address u, pinning the certificates of new hosts if keep.
+func fetchGemini(u *url.URL, keep bool) (*page, error) {
+	for range geminiRedirects + 1 {
+		status, meta, body, e := geminiRequest(u, keep)
+		if e != nil {
+			return nil, e
+		}
+		switch status / 10 {
+		case 2:
+			if meta == "" {
+				meta = "text/gemini"
+			}
+			return &page{body: body, ctype: meta, url: u}, nil
+		case 3:
+			if u, e = u.Parse(meta); e != nil {
+				return nil, e
+			}
+		case 1:
+			return nil, fmt.Errorf(tr("%s asks for input, which fish cannot give: %s"), u, meta)
+		case 6:
+			return nil, fmt.Errorf(tr("%s needs a client certificate, which fish has none of"), u)
+		default:
+			return nil, fmt.Errorf("%s: %d %s", u, status, meta)
+		}
+	}
+	return nil, fmt.Errorf("%s: too many redirects", u)
+}
+
+// geminiRequest sends one request for u, it returns the status and meta of the response
+// header and the body of a success.
+func geminiRequest(u *url.URL, keep bool) (status int, meta string, body []byte, e error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "1965")
+	}
+	d := &net.Dialer{Timeout: articleTimeout}
+	conn, e := tls.DialWithDialer(d, "tcp", host, &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: true, // checked by trustHost instead.
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New(u.Host + ": no certificate")
+			}
+			return trustHost(host, cs.PeerCertificates[0], keep)
+		},
+	})
+	if e != nil {
+		return 0, "", nil, e
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(articleTimeout))
+	if _, e := conn.Write([]byte(u.String() + "\r\n")); e != nil {
+		return 0, "", nil, e
+	}
+	br := bufio.NewReader(io.LimitReader(conn, maxArticle))
+	header, e := br.ReadString('\n')
+	if e != nil {
+		return 0, "", nil, fmt.Errorf("%s: %w", u, e)
+	}
+	code, meta, _ := strings.Cut(strings.TrimRight(header, "\r\n"), " ")
+	if status, e = strconv.Atoi(code); e != nil || len(code) != 2 {
+		return 0, "", nil, fmt.Errorf("%s: bad response %q", u, header)
+	}
+	if status/10 == 2 {
+		// servers often close the connection without close_notify, what came is the page then.
+		// Other errors, like a timeout, cut it short.
+		if body, e = io.ReadAll(br); e != nil && !errors.Is(e, io.ErrUnexpectedEOF) {
+			return 0, "", nil, fmt.Errorf("%s: %w", u, e)
+		}
+	}
+	return status, strings.TrimSpace(meta), body, nil
+}
+
+// trustHost checks certificate c of host against the one pinned for it, pinning it the first
+// time or after the pinned one expired. Without keep it is trusted then but not pinned.
+func trustHost(host string, c *x509.Certificate, keep bool) error {
+	home, e := os.UserHomeDir()
+	if e != nil {
+		return e
+	}
+	p := filepath.Join(home, ArticleDir, geminiHosts)
+	sum := sha256.Sum256(c.Raw)
+	fp := hex.EncodeToString(sum[:])
+	dd, _ := os.ReadFile(p)
+	var kept []string
+	for _, l := range strings.Split(strings.TrimSpace(string(dd)), "\n") {
+		ff := strings.Fields(l)
+		if len(ff) != 3 || ff[0] != host {
+			if l != "" {
+				kept = append(kept, l)
+			}
+			continue
+		}
+		if ff[1] == fp {
+			return nil
+		}
+		if until, e := strconv.ParseInt(ff[2], 10, 64); e != nil || time.Now().Unix() < until {
+			return fmt.Errorf(tr("the certificate of %s changed, if right delete its line in %s"), host, p)
+		}
+	}
+	if !keep {
+		return nil
+	}
+	kept = append(kept, fmt.Sprintf("%s %s %d", host, fp, c.NotAfter.Unix()))
+	if e := os.MkdirAll(filepath.Dir(p), 0755); e != nil {
+		return e
+	}
+	return os.WriteFile(p, []byte(strings.Join(kept, "\n")+"\n"), 0644)
+}
+
+// absoluteLinks makes the links of gemtext s absolute against page u, as its copy is read
+// from another place.
+func absoluteLinks(s string, u *url.URL) string {
+	ll := strings.Split(s, "\n")
+	pre := false
+	for i, l := range ll {
+		if strings.HasPrefix(l, "```") {
+			pre = !pre
+		}
+		rest, ok := strings.CutPrefix(l, "=>")
+		if pre || !ok {
+			continue
+		}
+		target, label := geminiLink(rest)
+		if t, e := u.Parse(target); e == nil && target != "" {
+			ll[i] = "=> " + t.String()
+			if label != "" {
+				ll[i] += " " + label
+			}
+		}
+	}
+	return strings.Join(ll, "\n")
+}
+
+// geminiLink splits the rest of a link line after "=>" into its address and label.
+func geminiLink(rest string) (target, label string) {
+	rest = strings.TrimLeft(rest, " \t")
+	i := strings.IndexAny(rest, " \t")
+	if i < 0 {
+		return strings.TrimRight(rest, "\r"), ""
+	}
+	return rest[:i], strings.TrimSpace(rest[i:])
+}
+
+// geminiDocument converts gemtext s: headings become chapters, link lines show their label
+// and can be followed, preformatted blocks are kept as they are.
+func geminiDocument(s string) *document {
+	d := newDocument()
+	d.paras = true
+	pre := false
+	var lb lineBuilder
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(l, "```") {
+			pre = !pre
+			continue
+		}
+		i := lb.len()
+		switch {
+		case pre:
+			lb.add(l)
+		case strings.HasPrefix(l, "=>"):
+			target, label := geminiLink(l[2:])
+			if label == "" {
+				label = target
+			}
+			lb.add("→ " + label)
+			if target != "" {
+				d.links[i] = []link{{start: len("→ "), end: len("→ " + label), target: target}}
+			}
+		case strings.HasPrefix(l, "#"):
+			level := min(len(l)-len(strings.TrimLeft(l, "#")), 3)
+			t := strings.TrimSpace(strings.TrimLeft(l, "#"))
+			lb.add(t)
+			if t != "" {
+				d.headings = append(d.headings, chapter{line: i, level: level, title: t})
+				if level == 1 && d.meta.Title == "" {
+					d.meta.Title = t
+				}
+			}
+		case strings.HasPrefix(l, "* "):
+			lb.add("• " + strings.TrimSpace(l[2:]))
+		default:
+			lb.add(l)
+		}
+	}
+	d.lines = lb.index()
+	return d
+}
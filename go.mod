@@ -1,6 +1,13 @@
 module github.com/fx-slayer/fish
+
 go 1.24
 
-require golang.org/x/term v0.32.0
+require (
+	github.com/alecthomas/chroma/v2 v2.24.0
+	golang.org/x/net v0.41.0
+	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
+	golang.org/x/text v0.26.0
+)
 
-require golang.org/x/sys v0.33.0 // indirect
+require github.com/dlclark/regexp2 v1.12.0 // indirect
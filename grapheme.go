@@ -0,0 +1,86 @@
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Runes of emoji sequences: the joiner, the variation selectors asking for text or emoji
+// presentation, and the range of the regional indicators, two of which make a flag.
+const (
+	zwj              = '\u200d'
+	textPresentation = '\ufe0e'
+	emojiPresent     = '\ufe0f'
+	regionalA        = '\U0001f1e6'
+	regionalZ        = '\U0001f1ff'
+)
+
+// cluster returns the length in bytes of the grapheme cluster s starts with, the characters
+// a terminal draws in one cell or two, and the columns it takes. It follows the rules of UAX #29
+// for combining marks, emoji sequences and flags, Hangul syllables of jamo and such rare cases aside.
+func cluster(s string) (int, int) {
+	c, n := utf8.DecodeRuneInString(s)
+	if n == 0 {
+		return 0, 0
+	}
+	w := runeWidth(c)
+	if c >= regionalA && c <= regionalZ {
+		if d, m := utf8.DecodeRuneInString(s[n:]); d >= regionalA && d <= regionalZ {
+			return n + m, 2
+		}
+		return n, 1
+	}
+	if c < 0x20 || c == 0x7f {
+		return n, 0
+	}
+	emoji := pictographic(c)
+	for n < len(s) {
+		d, m := utf8.DecodeRuneInString(s[n:])
+		switch {
+		case d == zwj:
+			n += m
+			if e, k := utf8.DecodeRuneInString(s[n:]); emoji && pictographic(e) {
+				n += k
+			}
+			continue
+		case d == emojiPresent && emoji:
+			w = 2
+		case d == textPresentation && emoji:
+			w = 1
+		case d >= 0x1f3fb && d <= 0x1f3ff && emoji: // skin tones.
+			w = 2
+		case !extends(d):
+			return n, w
+		}
+		n += m
+	}
+	return n, w
+}
+
+// extends tells if c belongs to the cluster of the character before it.
+func extends(c rune) bool {
+	switch {
+	case unicode.In(c, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case c >= 0xfe00 && c <= 0xfe0f, c >= 0xe0020 && c <= 0xe007f: // variation selectors and emoji tags.
+		return true
+	case c >= 0x1160 && c <= 0x11ff, c >= 0xd7b0 && c <= 0xd7ff: // Hangul vowels and final consonants.
+		return true
+	}
+	return false
+}
+
+// pictographic tells if c is one of the characters emoji sequences are made of, close to
+// Extended_Pictographic of Unicode.
+func pictographic(c rune) bool {
+	switch {
+	case c >= 0x1f000 && c <= 0x1faff, c >= 0x2600 && c <= 0x27bf, c >= 0x2300 && c <= 0x23ff,
+		c >= 0x2b00 && c <= 0x2bff, c >= 0x2190 && c <= 0x21ff:
+		return true
+	}
+	switch c {
+	case 0xa9, 0xae, 0x203c, 0x2049, 0x2122, 0x2139, 0x3030, 0x303d, 0x3297, 0x3299:
+		return true
+	}
+	return false
+}
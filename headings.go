@@ -0,0 +1,95 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// headingSearch finds a chapter by typing letters of its title, the matches are listed
+// above the prompt and enter jumps to the selected one.
+type headingSearch struct {
+	prompt
+	matches []int // chapters matching the text, the best first.
+	sel     int
+}
+
+// searchHeadings opens the heading search, all chapters are listed until something is typed.
+func (r *Reader) searchHeadings() {
+	if len(r.chapters) == 0 {
+		r.notice = tr("no chapters found")
+		return
+	}
+	h := &headingSearch{prompt: prompt{label: tr("heading: ")}}
+	h.match(r)
+	h.sel = max(0, r.chapterAt(r.currentLine))
+	r.overlay = h
+}
+
+// fuzzyScore tells how well title s matches q, whose letters must be in it in the same order.
+// Letters in a row and at the start of words score more.
+func fuzzyScore(q, s string) (int, bool) {
+	qq := []rune(strings.ToLower(q))
+	score, prev, last := 0, ' ', -2
+	i := 0
+	for j, c := range []rune(strings.ToLower(s)) {
+		if i < len(qq) && c == qq[i] {
+			score++
+			if last == j-1 {
+				score += 2
+			}
+			if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+				score += 3
+			}
+			i, last = i+1, j
+		}
+		prev = c
+	}
+	return score, i == len(qq)
+}
+
+// match lists the chapters matching the text.
+func (h *headingSearch) match(r *Reader) {
+	q := strings.ReplaceAll(string(h.text), " ", "")
+	scores := make(map[int]int)
+	h.matches = h.matches[:0]
+	for i, c := range r.chapters {
+		if s, ok := fuzzyScore(q, stripControls(c.title, false)); ok {
+			h.matches, scores[i] = append(h.matches, i), s
+		}
+	}
+	if q != "" {
+		slices.SortStableFunc(h.matches, func(a, b int) int { return cmp.Compare(scores[b], scores[a]) })
+	}
+	h.sel = 0
+}
+
+func (h *headingSearch) key(r *Reader, k string) bool {
+	switch k {
+	case "up":
+		h.sel = max(0, h.sel-1)
+	case "down":
+		h.sel = min(h.sel+1, max(0, len(h.matches)-1))
+	case "enter":
+		if len(h.matches) > 0 {
+			r.jump(r.chapters[h.matches[h.sel]].line)
+		}
+		return true
+	default:
+		if h.prompt.key(r, k) {
+			return true
+		}
+		h.match(r)
+	}
+	return false
+}
+
+func (h *headingSearch) draw(r *Reader, b *strings.Builder) {
+	items := make([]string, len(h.matches))
+	for i, c := range h.matches {
+		items[i] = strings.Repeat("  ", max(0, r.chapters[c].level-1)) + stripControls(r.chapters[c].title, false)
+	}
+	r.drawBox(b, "Headings", items, h.sel, "")
+	h.prompt.draw(r, b)
+}
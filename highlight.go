@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// proseLexers are languages of files which are read as books, not code.
+var proseLexers = []string{"plaintext", "markdown", "reStructuredText", "org"}
+
+// highlightStyles returns the values of Config.Highlight, "auto" picks a style for the theme.
+func highlightStyles() []string {
+	return append([]string{"auto", "off"}, styles.Names()...)
+}
+
+// codeLexer returns the lexer of code file f, nil if it is not code.
+func codeLexer(f string) chroma.Lexer {
+	l := lexers.Match(filepath.Base(f))
+	if l == nil || slices.Contains(proseLexers, l.Config().Name) {
+		return nil
+	}
+	return l
+}
+
+// highlight returns d with its lines colored as source code of file f in chroma style name.
+// Each colored token ends with a reset, so lines keep no colors from the lines before them.
+func highlight(d *document, f, name string, t Theme) *document {
+	l := codeLexer(f)
+	if d.json {
+		l = lexers.Get("json")
+	}
+	if l == nil || name == "off" || d.paras || d.styled {
+		return d
+	}
+	if name == "auto" {
+		name = "monokai"
+		if c, ok := rgb(t.Bg); ok && int(c[0])+int(c[1])+int(c[2]) > 3*128 {
+			name = "github"
+		}
+	}
+	it, e := chroma.Coalesce(l).Tokenise(nil, d.lines.joined())
+	if e != nil {
+		return d
+	}
+	style := styles.Get(name)
+	var lb lineBuilder
+	var sb strings.Builder
+	for tk := it(); tk != chroma.EOF; tk = it() {
+		sgr := tokenSGR(style.Get(tk.Type))
+		for i, s := range strings.Split(tk.Value, "\n") {
+			if i > 0 {
+				lb.add(sb.String())
+				sb.Reset()
+			}
+			if s == "" {
+				continue
+			}
+			if sgr == "" {
+				sb.WriteString(s)
+			} else {
+				sb.WriteString(sgr + s + "\x1b[0m")
+			}
+		}
+	}
+	lb.add(sb.String())
+	if lb.len() != d.lines.len() {
+		return d // the lexer changed the text, keep it plain.
+	}
+	n := *d
+	n.lines, n.styled = lb.index(), true
+	return &n
+}
+
+// tokenSGR returns the SGR sequence of style entry e, "" if it has no style.
+func tokenSGR(e chroma.StyleEntry) string {
+	var ss []string
+	if e.Colour.IsSet() {
+		ss = append(ss, fmt.Sprintf("38;2;%d;%d;%d", e.Colour.Red(), e.Colour.Green(), e.Colour.Blue()))
+	}
+	if e.Bold == chroma.Yes {
+		ss = append(ss, "1")
+	}
+	if e.Italic == chroma.Yes {
+		ss = append(ss, "3")
+	}
+	if len(ss) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(ss, ";") + "m"
+}
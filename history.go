@@ -0,0 +1,93 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// readBooks returns the books of the progress file, the last opened or read first.
+func readBooks() ([]string, map[string]*Book, error) {
+	p, e := progressPath()
+	if e != nil {
+		return nil, nil, e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return nil, nil, e
+	}
+	ff := make([]string, 0, len(progress))
+	for f, b := range progress {
+		if b != nil {
+			ff = append(ff, f)
+		}
+	}
+	slices.SortFunc(ff, func(a, b string) int {
+		if c := lastRead(progress[b]).Compare(lastRead(progress[a])); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	return ff, progress, nil
+}
+
+// lastRead is when book b was opened or read last.
+func lastRead(b *Book) time.Time {
+	if b.Opened.After(b.Read) {
+		return b.Opened
+	}
+	return b.Read
+}
+
+// History lists the books read, the last one first, with their progress and path.
+func History() (string, error) {
+	ff, progress, e := readBooks()
+	if e != nil {
+		return "", e
+	}
+	var b strings.Builder
+	for _, f := range ff {
+		day := strings.Repeat(" ", len(time.DateOnly))
+		if t := lastRead(progress[f]); !t.IsZero() {
+			day = t.Format(time.DateOnly)
+		}
+		_, _ = fmt.Fprintf(&b, "%s %4.0f%%  %s  %s\n", day, progress[f].Percent, bookName(f, progress[f]), f)
+	}
+	return b.String(), nil
+}
+
+// Stats sums up the progress file.
+func Stats() (string, error) {
+	ff, progress, e := readBooks()
+	if e != nil {
+		return "", e
+	}
+	var week, done, pomodoros, seconds int
+	var percent float64
+	for _, f := range ff {
+		b := progress[f]
+		if time.Since(lastRead(b)) < 7*24*time.Hour {
+			week++
+		}
+		if b.Percent >= 99 {
+			done++
+		}
+		percent += b.Percent
+		pomodoros += b.Pomodoros
+		seconds += b.Seconds
+	}
+	s := fmt.Sprintf("Books:            %d\n", len(ff))
+	s += fmt.Sprintf("Read this week:   %d\n", week)
+	s += fmt.Sprintf("Read to the end:  %d\n", done)
+	if len(ff) > 0 {
+		s += fmt.Sprintf("Average progress: %.0f%%\n", percent/float64(len(ff)))
+	}
+	s += fmt.Sprintf("Pomodoros:        %d\n", pomodoros)
+	s += fmt.Sprintf("Time read:        %s\n", readTime(seconds))
+	if len(ff) > 0 {
+		s += "Last read:        " + bookName(ff[0], progress[ff[0]]) + "\n"
+	}
+	return s, nil
+}
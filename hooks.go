@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// hook runs the command configured for event in the background, with the reading state in
+// FISH_* environment variables and env. Its output is discarded, it would garble the screen.
+// A private session runs none, they would log what is read.
+func (r *Reader) hook(event string, env ...string) {
+	s, ok := r.cfg.Hooks[event]
+	if !ok || s == "" || r.private {
+		return
+	}
+	st := r.status()
+	c := exec.Command("sh", "-c", s)
+	c.Env = append(os.Environ(),
+		"FISH_EVENT="+event,
+		"FISH_FILE="+st.File,
+		"FISH_TITLE="+st.Title,
+		"FISH_AUTHOR="+st.Author,
+		"FISH_CHAPTER="+st.Chapter,
+		"FISH_LINE="+strconv.Itoa(st.Line),
+		"FISH_LINES="+strconv.Itoa(st.Lines),
+		"FISH_PERCENT="+strconv.FormatFloat(st.Percent, 'f', 1, 64),
+	)
+	c.Env = append(c.Env, env...)
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true} // outlive the terminal when fish quits.
+	if e := c.Start(); e != nil {
+		r.notice = fmt.Sprintf(tr("%s hook: %v"), event, e)
+		return
+	}
+	go func() { _ = c.Wait() }()
+}
+
+// checkEvents runs the hooks of the chapter and finish events after moving in the book.
+func (r *Reader) checkEvents() {
+	if ch := r.chapterAt(r.currentLine); ch != r.chapter {
+		moved := r.chapter != noChapter
+		r.chapter = ch
+		if moved && ch >= 0 {
+			r.hook("chapter")
+		}
+	}
+	if !r.finished[r.f] && r.totalLine > 0 && r.currentLine+r.shown >= r.totalLine {
+		r.finished[r.f] = true
+		r.hook("finish")
+	}
+}
+
+// noChapter is Reader.chapter before the first check, so opening a book is no chapter event.
+const noChapter = -2
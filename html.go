@@ -0,0 +1,301 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlBlocks are elements which start their own line.
+var htmlBlocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Li: true, atom.Ul: true, atom.Ol: true, atom.Dl: true,
+	atom.Dt: true, atom.Dd: true, atom.Blockquote: true, atom.Pre: true, atom.Table: true,
+	atom.Tr: true, atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true,
+	atom.Aside: true, atom.Nav: true, atom.Figure: true, atom.Figcaption: true, atom.Hr: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Body: true, atom.Main: true, atom.Address: true,
+}
+
+// htmlWriter converts HTML into lines of a document, one line per paragraph.
+type htmlWriter struct {
+	d     *document
+	lines []string // the lines written, the document indexes them when done.
+	file  string   // path of the HTML file in its book, anchors are named "file#id".
+	line  strings.Builder
+	links []link // links on the current line.
+	open  *link  // link whose end tag has not been met.
+	pre   int    // depth of <pre> elements.
+}
+
+// htmlDocument converts a standalone HTML page.
+func htmlDocument(s, file string) *document {
+	d := newDocument()
+	d.paras = true
+	w := &htmlWriter{d: d}
+	w.add(s, file)
+	d.lines = lineIndexOf(w.lines)
+	return d
+}
+
+// add converts HTML s of file, appending it to the document.
+func (w *htmlWriter) add(s, file string) {
+	n, e := html.Parse(strings.NewReader(s))
+	if e != nil {
+		return
+	}
+	w.file = file
+	w.d.anchors[file] = len(w.lines)
+	w.walk(n)
+	w.flush()
+}
+
+// resolveHref returns the anchor key href points to from file, URLs with a scheme are kept as is.
+func resolveHref(file, href string) string {
+	if u, e := url.Parse(href); e != nil || u.Scheme != "" {
+		return href
+	}
+	p, frag, _ := strings.Cut(href, "#")
+	if p == "" {
+		p = file
+	} else {
+		if up, e := url.PathUnescape(p); e == nil {
+			p = up
+		}
+		p = path.Join(path.Dir(file), p)
+	}
+	if frag != "" {
+		return p + "#" + frag
+	}
+	return p
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+	switch n.DataAtom {
+	case atom.Head:
+		w.head(n)
+		return
+	case atom.Script, atom.Style, atom.Template:
+		return
+	case atom.Ruby:
+		w.ruby(n)
+		return
+	case atom.Html:
+		if l, ok := attr(n, "lang"); ok && w.d.meta.Language == "" {
+			w.d.meta.Language = l
+		}
+	}
+	block := htmlBlocks[n.DataAtom]
+	if block {
+		w.flush()
+	}
+	heading := false
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		heading = true
+	}
+	if heading {
+		w.blank()
+	}
+	if id, ok := attr(n, "id"); ok {
+		w.d.anchors[w.file+"#"+id] = len(w.lines)
+	}
+	if name, ok := attr(n, "name"); ok && n.DataAtom == atom.A {
+		w.d.anchors[w.file+"#"+name] = len(w.lines)
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		w.flush()
+		return
+	case atom.Img:
+		if alt, _ := attr(n, "alt"); strings.TrimSpace(alt) != "" {
+			w.text("[" + strings.TrimSpace(alt) + "]")
+		}
+		return
+	case atom.Li:
+		w.line.WriteString("• ")
+	case atom.Td, atom.Th:
+		if w.line.Len() > 0 {
+			w.line.WriteString("  ")
+		}
+	case atom.Pre:
+		w.pre++
+		defer func() { w.pre-- }()
+	case atom.A:
+		if href, ok := attr(n, "href"); ok && w.open == nil {
+			et, _ := attr(n, "epub:type")
+			role, _ := attr(n, "role")
+			w.open = &link{
+				start:  w.line.Len(),
+				target: resolveHref(w.file, href),
+				note:   strings.Contains(et, "noteref") || role == "doc-noteref",
+			}
+			w.children(n)
+			if w.open != nil {
+				w.open.end = w.line.Len()
+				w.links = append(w.links, *w.open)
+				w.open = nil
+			}
+			return
+		}
+	}
+	if heading {
+		w.heading(n)
+		return
+	}
+	w.children(n)
+	if block {
+		w.flush()
+	}
+}
+
+// head takes the title and author of a standalone page, books have them in their package instead.
+func (w *htmlWriter) head(n *html.Node) {
+	if w.file != "" {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Title:
+			w.d.meta.Title = strings.Join(strings.Fields(nodeText(c)), " ")
+		case atom.Meta:
+			if name, _ := attr(c, "name"); strings.EqualFold(name, "author") {
+				w.d.meta.Author, _ = attr(c, "content")
+			}
+		}
+	}
+}
+
+func (w *htmlWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+}
+
+// heading writes a heading on its own line followed by a blank line, h1 to h3 become chapters.
+func (w *htmlWriter) heading(n *html.Node) {
+	start := len(w.lines)
+	w.children(n)
+	w.flush()
+	if level := int(n.Data[1] - '0'); level <= 3 {
+		if t := strings.TrimSpace(strings.Join(w.lines[start:], " ")); t != "" {
+			w.d.headings = append(w.d.headings, chapter{line: start, level: level, title: t})
+		}
+	}
+	w.blank()
+}
+
+// text writes text, runs of white space become a single space as browsers show them,
+// except line breaks between wide characters which are dropped.
+func (w *htmlWriter) text(s string) {
+	if w.pre > 0 {
+		for i, l := range strings.Split(s, "\n") {
+			if i > 0 {
+				w.flushLine()
+			}
+			w.line.WriteString(l)
+		}
+		return
+	}
+	for len(s) > 0 {
+		i := strings.IndexAny(s, " \t\r\n\f")
+		if i < 0 {
+			w.line.WriteString(s)
+			return
+		}
+		w.line.WriteString(s[:i])
+		j := i
+		for j < len(s) && strings.IndexByte(" \t\r\n\f", s[j]) >= 0 {
+			j++
+		}
+		cur := w.line.String()
+		prev, _ := utf8.DecodeLastRuneInString(cur)
+		next, _ := utf8.DecodeRuneInString(s[j:])
+		cjk := strings.ContainsAny(s[i:j], "\r\n") && runeWidth(prev) == 2 && (j == len(s) || runeWidth(next) == 2)
+		if cur != "" && !strings.HasSuffix(cur, " ") && !cjk {
+			w.line.WriteByte(' ')
+		}
+		s = s[j:]
+	}
+}
+
+// ruby writes the text of a <ruby> element in the notation of Aozora Bunko, ｜漢字《かんじ》,
+// which the layout shows like Config.Ruby. <rp> holds brackets for browsers without ruby.
+func (w *htmlWriter) ruby(n *html.Node) {
+	var text strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c.DataAtom == atom.Rp:
+		case c.DataAtom == atom.Rt:
+			if rt := strings.TrimSpace(nodeText(c)); rt != "" && text.Len() > 0 {
+				w.text("｜" + text.String() + "《" + rt + "》")
+				text.Reset()
+			}
+		default:
+			text.WriteString(strings.TrimSpace(nodeText(c)))
+		}
+	}
+	w.text(text.String())
+}
+
+// blank adds an empty line unless the last line is empty already.
+func (w *htmlWriter) blank() {
+	if n := len(w.lines); n > 0 && w.lines[n-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+// flush ends the current line, if it has any text.
+func (w *htmlWriter) flush() {
+	if strings.TrimSpace(w.line.String()) == "" {
+		w.line.Reset()
+		w.links = w.links[:0]
+		if w.open != nil {
+			w.open.start = 0
+		}
+		return
+	}
+	w.flushLine()
+}
+
+// flushLine ends the current line even if it is empty.
+func (w *htmlWriter) flushLine() {
+	s := strings.TrimRight(w.line.String(), " ")
+	i := len(w.lines)
+	w.lines = append(w.lines, s)
+	if w.open != nil {
+		w.links = append(w.links, link{start: w.open.start, end: len(s), target: w.open.target, note: w.open.note})
+		w.open.start = 0
+	}
+	for _, l := range w.links {
+		if l.end = min(l.end, len(s)); l.start < l.end {
+			w.d.links[i] = append(w.d.links[i], l)
+		}
+	}
+	w.links = w.links[:0]
+	w.line.Reset()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"embed"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The TeX hyphenation patterns of hyph-utf8 for English and German, compiled into the packed
+// tries of the minikin line breaker of Android, which browsers ship them in.
+//
+//go:embed hyphen/*.hyb
+var hyphenFiles embed.FS
+
+// hyphenLanguages are the pattern files by language, with the fewest letters left before
+// and after a hyphen.
+var hyphenLanguages = map[string]struct {
+	file           string
+	prefix, suffix int
+}{
+	"en": {"hyphen/hyph-en-us.hyb", 2, 3},
+	"de": {"hyphen/hyph-de-1996.hyb", 2, 2},
+}
+
+// hyphenator finds where words may be hyphenated with the patterns of a language.
+type hyphenator struct {
+	alphabet       map[rune]uint32 // letter:its code in the trie, 0 for the ends of the word.
+	trie           []uint32
+	charMask       uint32
+	linkShift      uint32
+	linkMask       uint32
+	patternShift   uint32
+	patterns       []uint32 // length<<26 | trailing zeros<<20 | offset in values.
+	values         []byte
+	prefix, suffix int
+}
+
+var (
+	hyphenMu    sync.Mutex
+	hyphenators = map[string]*hyphenator{}
+)
+
+// hyphenatorFor returns the hyphenator of language lang, like "en" or "de-AT", nil if there is none.
+func hyphenatorFor(lang string) *hyphenator {
+	lang, _, _ = strings.Cut(strings.ToLower(lang), "-")
+	l, ok := hyphenLanguages[lang]
+	if !ok {
+		return nil
+	}
+	hyphenMu.Lock()
+	defer hyphenMu.Unlock()
+	if h, ok := hyphenators[lang]; ok {
+		return h
+	}
+	dd, _ := hyphenFiles.ReadFile(l.file)
+	h := parseHyphenator(dd)
+	if h != nil {
+		h.prefix, h.suffix = l.prefix, l.suffix
+	}
+	hyphenators[lang] = h
+	return h
+}
+
+// parseHyphenator reads a file of packed patterns, nil if it is broken.
+func parseHyphenator(dd []byte) *hyphenator {
+	u := func(off uint32) uint32 {
+		if int(off)+4 > len(dd) {
+			return 0
+		}
+		return binary.LittleEndian.Uint32(dd[off:])
+	}
+	words := func(off, n uint32) []uint32 {
+		if int(off)+4*int(n) > len(dd) {
+			return nil
+		}
+		ww := make([]uint32, n)
+		for i := range ww {
+			ww[i] = u(off + 4*uint32(i))
+		}
+		return ww
+	}
+	if len(dd) < 24 || u(0) != 0x62ad7968 {
+		return nil
+	}
+	alpha, trie, pat := u(8), u(12), u(16)
+	h := &hyphenator{alphabet: map[rune]uint32{}}
+	switch u(alpha) {
+	case 0: // a byte for each letter from min to max.
+		lo, hi := u(alpha+4), u(alpha+8)
+		for c := lo; c < hi && int(alpha+12+c-lo) < len(dd); c++ {
+			if v := dd[alpha+12+c-lo]; v != 0 {
+				h.alphabet[rune(c)] = uint32(v)
+			}
+		}
+	case 1: // letter<<11 | code pairs.
+		for _, e := range words(alpha+8, u(alpha+4)) {
+			h.alphabet[rune(e>>11)] = e & 0x7ff
+		}
+	}
+	h.charMask, h.linkShift, h.linkMask, h.patternShift = u(trie+4), u(trie+8), u(trie+12), u(trie+16)
+	h.trie = words(trie+24, u(trie+20))
+	h.patterns = words(pat+16, u(pat+4))
+	if off := pat + u(pat+8); int(off) <= len(dd) {
+		h.values = dd[off:]
+	}
+	if h.trie == nil || h.patterns == nil {
+		return nil
+	}
+	return h
+}
+
+// points returns the offsets in word where a hyphen may go, word is letters only.
+func (h *hyphenator) points(word string) []int {
+	n := utf8.RuneCountInString(word)
+	if n < h.prefix+h.suffix {
+		return nil
+	}
+	codes := make([]uint32, 0, n+2)
+	offs := make([]int, 0, n)
+	codes = append(codes, 0)
+	for i, c := range word {
+		v, ok := h.alphabet[unicode.ToLower(c)]
+		if !ok {
+			return nil
+		}
+		codes, offs = append(codes, v), append(offs, i)
+	}
+	codes = append(codes, 0)
+	// values[k] is the highest value of the patterns matching between codes k-1 and k, odd ones allow a hyphen.
+	values := make([]byte, len(codes))
+	for i := range codes {
+		node := uint32(0)
+		for j := i; j < len(codes); j++ {
+			c := codes[j]
+			if int(node+c) >= len(h.trie) {
+				break
+			}
+			e := h.trie[node+c]
+			if e&h.charMask != c {
+				break
+			}
+			node = (e & h.linkMask) >> h.linkShift
+			if int(node) >= len(h.trie) {
+				break
+			}
+			p := h.trie[node] >> h.patternShift
+			if p == 0 || int(p) >= len(h.patterns) {
+				continue
+			}
+			pe := h.patterns[p]
+			length, shift, off := int(pe>>26), int(pe>>20&0x1f), int(pe&0xfffff)
+			start := j + 1 - length - shift
+			for k := range length {
+				if x := start + k; x >= 0 && x < len(values) && off+k < len(h.values) {
+					values[x] = max(values[x], h.values[off+k])
+				}
+			}
+		}
+	}
+	var pp []int
+	for k := h.prefix; k <= n-h.suffix; k++ {
+		if values[k]&1 == 1 {
+			pp = append(pp, offs[k])
+		}
+	}
+	return pp
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// languages are the values of Config.Lang, auto follows the locale of the environment.
+var languages = []string{"auto", "en", "zh"}
+
+// lang is the language of the interface, "en" shows the strings as they are written.
+var lang = localeLanguage()
+
+// localeLanguage returns the language of the locale in $LC_ALL, $LC_MESSAGES or $LANG.
+func localeLanguage() string {
+	for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if s := os.Getenv(v); s != "" {
+			if strings.HasPrefix(s, "zh") {
+				return "zh"
+			}
+			return "en"
+		}
+	}
+	return "en"
+}
+
+// setLanguage switches the interface to the language of c.
+func setLanguage(c Config) {
+	if c.Lang == "" || c.Lang == "auto" {
+		lang = localeLanguage()
+		return
+	}
+	lang = c.Lang
+}
+
+// tr returns English string s in the language of the interface, s itself if it has no translation.
+// Format strings are translated before they are formatted, translations reorder verbs like %[2]s.
+func tr(s string) string {
+	if t, ok := translations[lang][s]; ok {
+		return t
+	}
+	return s
+}
+
+// translations are the strings of each language but English, by their English text.
+var translations = map[string]map[string]string{
+	"zh": {
+		// status bar and prompts.
+		"[Q]:Quit [S]:Settings":          "[Q]:退出 [S]:设置",
+		"[A]:Scroll(%s)":                 "[A]:滚动(%s)",
+		"%d wpm":                         "%d 字/分",
+		"%g/s":                           "%g 行/秒",
+		"after %ds":                      "%d 秒后",
+		"p. %d / %d":                     "第 %d / %d 页",
+		"Ch. %d · %.0f%%":                "第 %d 章 · %.0f%%",
+		"%d lines to chapter end":        "本章还剩 %d 行",
+		"~%d min to chapter end":         "本章还需约 %d 分钟",
+		"on":                             "开",
+		"off":                            "关",
+		"recording @":                    "录制中 @",
+		"record to register: ":           "录制到寄存器: ",
+		"play register: ":                "播放寄存器: ",
+		"play %d times register: ":       "播放 %d 次寄存器: ",
+		"read ":                          "阅读 ",
+		"bookmark note: ":                "书签备注: ",
+		"heading: ":                      "标题: ",
+		"break ":                         "休息 ",
+		"-- last time you stopped here ": "-- 上次读到这里 ",
+		"> Last read %d days ago. [R]esume at %.1f%% / [S]tart over / [C]hapters": "> 上次阅读是 %d 天前。[R]从 %.1f%% 继续 / [S]从头开始 / [C]章节",
+		"-- VISUAL -- %d lines, y saves them to %s, c makes a card, esc cancels":  "-- 选择 -- %d 行,y 保存到 %s,c 生成卡片,esc 取消",
+		"-- OUTLINE -- enter jumps, ←→ fold, tab to the book, esc closes":         "-- 大纲 -- enter 跳转,←→ 折叠,tab 回到书,esc 关闭",
+
+		// boxes.
+		"Settings": "设置",
+		"←→:Change W:Save B:Save for book X:Clear book Esc:Close": "←→:修改 W:保存 B:只存本书 X:清除本书设置 Esc:关闭",
+		"Bookmarks":                              "书签",
+		"Enter:Jump D:Delete R:Rename Esc:Close": "Enter:跳转 D:删除 R:改备注 Esc:关闭",
+		"Contents":                               "目录",
+		"Headings":                               "标题",
+		"Enter:Jump Esc:Close":                   "Enter:跳转 Esc:关闭",
+		"Footnotes":                              "脚注",
+		"Enter:Jump Ctrl-O:Back Esc:Close":       "Enter:跳转 Ctrl-O:返回 Esc:关闭",
+		"Library":                                "书库",
+		"Enter:Open Esc:Close":                   "Enter:打开 Esc:关闭",
+		"Open books":                             "已打开的书",
+		"Enter:Switch Esc:Close":                 "Enter:切换 Esc:关闭",
+		"Hidden lines":                           "隐藏的行",
+		"Scan to continue":                       "扫码继续阅读",
+		"Book":                                   "书籍",
+		"Title":                                  "书名",
+		"Author":                                 "作者",
+		"Language":                               "语言",
+		"File":                                   "文件",
+		"Lines":                                  "行数",
+		"Chapters":                               "章节",
+		"Progress":                               "进度",
+		"Pomodoros":                              "番茄钟",
+		"Time read":                              "阅读时间",
+		"%s read":                                "已读 %s",
+		"unknown":                                "未知",
+		"Take a break":                           "休息一下",
+		"esc skips the break":                    "esc 跳过休息",
+
+		// settings.
+		"Encoding":              "编码",
+		"Wrap":                  "自动换行",
+		"Margin":                "页边距",
+		"Tab width":             "制表符宽度",
+		"Show tabs":             "显示制表符",
+		"Theme":                 "主题",
+		"Scroll speed":          "滚动速度",
+		"Strip Gutenberg":       "去掉古登堡声明",
+		"Squeeze blank lines":   "合并空行",
+		"ANSI colors":           "ANSI 颜色",
+		"Code highlighting":     "代码高亮",
+		"Pretty JSON":           "格式化 JSON",
+		"Log colors":            "日志着色",
+		"Subtitle timing":       "字幕时间",
+		"Terminal title":        "终端标题",
+		"Disguise":              "伪装",
+		"Normalize punctuation": "统一标点",
+		"Smart typography":      "智能排版",
+		"Cover images":          "封面图片",
+		"Ruby":                  "注音",
+		"Hyphenation":           "断字",
+		"Justify":               "两端对齐",
+		"Poetry":                "诗歌居中",
+		"Paragraph indent":      "段首缩进",
+		"Whole pages":           "整页翻页",
+		"Slow link":             "慢速连接",
+		"Hide status bar":       "隐藏状态栏",
+		"Paragraph spacing":     "段间空行",
+		"Status %s":             "状态栏 %s",
+
+		// notices.
+		"%d keys recorded to @%s":                                  "已录制 %d 个按键到 @%s",
+		"%d lines hidden in %s":                                    "%[2]s 中隐藏了 %[1]d 行",
+		"%d lines match, & and enter shows all":                    "%d 行匹配,& 加回车显示全部",
+		"%d lines saved to %s":                                     "已把 %d 行保存到 %s",
+		"%s hook: %v":                                              "%s 钩子: %v",
+		"book settings cleared":                                    "已清除本书设置",
+		"break is over":                                            "休息结束",
+		"change %d/%d":                                             "改动 %d/%d",
+		"following the end of %s, F stops":                         "正在跟随 %s 的末尾,按 F 停止",
+		"hide patterns of %s cleared":                              "已清除 %s 的隐藏规则",
+		"lines are wrapped, turn off wrap to scroll sideways":      "已自动换行,关闭换行后才能左右滚动",
+		"line %d bookmarked, ' lists the bookmarks":                "已为第 %d 行加书签,' 列出书签",
+		"link leads out of the book: ":                             "链接指向书外: ",
+		"macro stopped after too many keys":                        "按键过多,宏已停止",
+		"macros play each other too deep":                          "宏互相调用层数过深",
+		"no bookmarks match":                                       "没有匹配的书签",
+		"no bookmarks, m marks the page":                           "没有书签,按 m 给本页加书签",
+		"no chapter there":                                         "那里没有章节",
+		"no chapters found":                                        "没有找到章节",
+		"no footnotes on this page":                                "本页没有脚注",
+		"no lines are hidden, :hide <regexp> hides matching lines": "没有隐藏的行,:hide <正则> 隐藏匹配的行",
+		"no lines match":                                           "没有匹配的行",
+		"no links on this page":                                    "本页没有链接",
+		"no more changes":                                          "没有更多改动",
+		"no newer position":                                        "没有更新的位置",
+		"no older position":                                        "没有更早的位置",
+		"no subtitles in this book":                                "本书没有字幕",
+		"not comparing files, see fish diff":                       "没有在比较文件,见 fish diff",
+		"nothing to fold in this book":                             "本书没有可折叠的内容",
+		"nothing to fold on this page":                             "本页没有可折叠的内容",
+		"pomodoro off":                                             "番茄钟已关闭",
+		"progress of %s cleared":                                   "已清除 %s 的进度",
+		"quote card written to %s":                                 "已把引文卡片写到 %s",
+		"reading %d minutes, then a %d minute break":               "阅读 %d 分钟,然后休息 %d 分钟",
+		"register @%s is empty":                                    "寄存器 @%s 是空的",
+		"settings saved for %s":                                    "已为 %s 保存设置",
+		"settings saved to ~/":                                     "设置已保存到 ~/",
+		"stopped following":                                        "已停止跟随",
+		"streamed books keep no bookmarks":                         "流式读取的书不保存书签",
+		"sync: read up to line %d elsewhere":                       "同步: 在别处已读到第 %d 行",
+		"the files are the same":                                   "两个文件相同",
+		"the position is too long for a QR code":                   "位置太长,无法生成二维码",
+		"the screen is not split, W splits it":                     "屏幕没有分割,按 W 分割",
+		"the window is too small for the QR code":                  "窗口太小,放不下二维码",
+		"the window is too small to split":                         "窗口太小,无法分割",
+		"w switches panes, W closes the other one":                 "w 切换窗格,W 关闭另一个",
+		"You have not read today.":                                 "你今天还没有读书。",
+		"You have not read today, %s is at %.0f%%.":                "你今天还没有读书,《%s》读到了 %.0f%%。",
+
+		// errors.
+		"unknown command: %s":                                                  "未知命令: %s",
+		"usage: :goto <line>|<percent>%":                                       "用法: :goto <行号>|<百分比>%",
+		"usage: :page <number>":                                                "用法: :page <页码>",
+		"the book has %d pages":                                                "本书共 %d 页",
+		"usage: :notes <text>, :allnotes <text> searches all books":            "用法: :notes <文字>,:allnotes <文字> 搜索所有书",
+		"usage: :open <file>":                                                  "用法: :open <文件>",
+		"usage: :pomodoro [<read minutes>/<break minutes>]":                    "用法: :pomodoro [<阅读分钟>/<休息分钟>]",
+		"usage: fish [flags] <FILE>, fish --help lists the flags and commands": "用法: fish [选项] <文件>,fish --help 列出选项和命令",
+		"fish line reads in a terminal":                                        "fish line 需要在终端中运行",
+		"fish reads in a terminal, convert writes a book to a file or pipe":    "fish 需要在终端中阅读,convert 可以把书写到文件或管道",
+		"password for %s: ":                                                    "%s 的密码: ",
+		"wrong password for %s":                                                "%s 的密码错误",
+		" is locked, set lock in the config to read it":                        " 已加密,在配置中设置 lock 才能读取",
+		"nothing is saved in this session":                                     "本次阅读不保存任何东西",
+		"the key file %s is empty":                                             "密钥文件 %s 是空的",
+		`set lock in the config to a key file or "ask" first`:                  `请先在配置中把 lock 设为密钥文件或 "ask"`,
+		" is encrypted, give its password in a terminal":                       " 已加密,请在终端中输入密码",
+		" is encrypted, open it with fish to give its password":                " 已加密,请用 fish 打开并输入密码",
+		": no books in the archive":                                            ": 压缩包里没有书",
+		"fish reads zip and 7z archives, unpack rar ones or pack them as zip":  "fish 能读 zip 和 7z 压缩包,rar 请先解压或重新打包成 zip",
+		"--listen takes a loopback address like 127.0.0.1:7777, not %s":        "--listen 只接受回环地址,如 127.0.0.1:7777,而不是 %s",
+		": no article found on the page":                                       ": 网页上没有找到文章",
+		"%s is %s, fish reads web pages and text":                              "%s 是 %s,fish 只能读网页和文本",
+		"%s asks for input, which fish cannot give: %s":                        "%s 需要输入,fish 无法提供: %s",
+		"%s needs a client certificate, which fish has none of":                "%s 需要客户端证书,fish 没有",
+		"the certificate of %s changed, if right delete its line in %s":        "%s 的证书变了,如果新证书可信,请删除 %s 中它的那一行",
+
+		helpText: helpTextZh,
+	},
+}
+
+// helpTextZh is helpText in Chinese.
+const helpTextZh = `名称:
+  fish - 在命令行里读小说和长文的极简阅读器。
+
+版本:
+  {version}
+
+用法:
+  fish [选项] <文件>
+  fish reset <文件>
+  fish split <文件> [--out 目录]
+  fish convert <文件> <输出.txt|输出.md|->
+  fish diff [选项] <旧文件> <新文件>
+  fish parallel [选项] <文件> <译文>
+  fish line [选项] <文件>
+  fish ctl <命令>|status
+  fish notes export <文件>
+  fish clippings <My Clippings.txt>
+  fish card <文件> <行号>[-<行号>]
+  fish progress prune [--days N] [--dry-run] [--archive 文件]
+  fish progress lock|unlock
+  fish history
+  fish stats
+  fish stats calendar
+  fish remind
+  fish version
+
+选项:
+  --listen 地址    在回环地址上提供 HTTP API,如 127.0.0.1:7777。
+  --encoding 名称  用指定编码读书,如 gbk 或 big5。
+  --theme 名称     使用指定主题。
+  --wrap=false     不自动换行。
+  --accessible     输出屏幕阅读器能跟读的纯文本行,而不绘制整个屏幕。
+  --slow           只重绘变化的部分,适合缓慢或不稳定的 SSH 连接。
+  --private        不读取也不保存进度,书不会出现在历史和统计中,也不运行钩子。
+  --no-save        从保存的进度继续读,但什么也不写回,适合只读的主目录。
+  --debug          退出后打印打开书的每一步花了多长时间。
+  选项可以放在文件前后,优先于配置文件和本书的设置。
+
+说明:
+  fish 在终端中阅读指定的文本文件。
+  阅读进度自动保存在: ~/.cmdline-reader-progress。
+  设置读自: ~/.cmdline-reader-config,阅读时按 S 修改。
+  fish 会从上次停下的地方继续。
+  FILE.fishlist 按顺序列出合为一本书来读的文件,每行一个路径,# 开头为注释。
+  FILE 可以是 https://example.com/post 或 gemini://example.org/ 这样的网址,只下载一次,文章保存在 ~/.cmdline-reader-articles,--private 和 --no-save 时不保存。
+  通过管道传给 fish 的文字读完不保存任何东西,MANPAGER=fish 可以让 fish 做 man 的分页器。
+  --listen 的 HTTP API 供其他程序使用:
+  GET /status 以 JSON 返回书和位置,POST /command 执行 JSON 请求体 {"command": "..."} 中的命令,
+  如 next-page、prev-chapter 或 goto 50%。只监听回环地址,带 Origin 的请求会被拒绝。
+  ctl 在另一个终端里运行的 fish 中执行命令,status 以 JSON 打印它的状态。
+  reset 忘掉关于文件保存的一切,和阅读时的 :reset 相同。
+  history 列出读过的书,最近的在前,stats 汇总它们。
+  stats calendar 显示过去一年每天读了多少分钟,每周一列。
+  progress prune 忘掉文件已不存在的书,加 --days 时只忘掉 N 天没读的。
+  --dry-run 只列出而不忘掉,--archive 把它们移到文件中。
+  progress lock 用配置中的 "lock" 加密进度文件,它是密钥文件或 "ask" 表示询问口令,
+  unlock 把它重新写成明文。
+  notes export 把文件的书签和备注按章节打印成 Markdown。
+  clippings 把 Kindle 的标注和笔记变成书库中同名书的书签,放在找到其文字的行。
+  card 把文件中的这些行连同书名和作者框成一张卡片打印出来,用于分享。
+  split 把文件的每一章写成目录中单独的文件,目录默认是书名。
+  convert 把文件写成纯文本或 Markdown,- 把文本写到标准输出。
+  diff 阅读新文件并标出自旧文件以来改动的行,] 和 [ 跳到下一处和上一处改动。
+  parallel 把文件和译文逐段并排显示。
+  文件.align 中的段落编号对在两边错开处重新对齐。
+  remind 保持运行,在配置的 "remind" 时间发送桌面通知,
+  如 "20:00",当天到那时还没读书才发送。随桌面会话启动它。
+  line 在光标所在的行上一次读一行,不占用整个屏幕,
+  回车或空格显示下一行,上方向键显示上一行,q 清除这一行。
+
+示例:
+  fish story.txt
+  fish ~/books/novel.txt
+  fish --listen 127.0.0.1:7777 novel.txt
+  fish --encoding gbk --wrap=false novel.txt
+  fish split novel.txt --out chapters/
+  fish convert book.epub book.md
+  fish notes export novel.txt > novel-notes.md
+  fish diff draft1.txt draft2.txt
+  fish parallel novel.txt novel.en.txt`
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+)
+
+// journalEntry is a line of the journal, the bookmarks of a book after they changed.
+type journalEntry struct {
+	Book  string     `json:"book"`
+	Marks []Bookmark `json:"marks"`
+}
+
+// journalPath is the file next to progress file p bookmarks are journaled to, until the
+// progress file takes them on a clean quit. After a crash the next start replays it.
+func journalPath(p string) string {
+	return p + ".journal"
+}
+
+// journalMarks appends the bookmarks of the book to the journal and syncs it to the disk.
+func (r *Reader) journalMarks() {
+	if !r.saves() {
+		return
+	}
+	if lockState.secret != nil {
+		// the journal would keep the bookmarks and notes in plain text.
+		r.writeProgress()
+		_ = r.progressFD.Sync()
+		return
+	}
+	f, e := os.OpenFile(journalPath(r.progressFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if e != nil {
+		r.writeProgress()
+		return
+	}
+	dd, _ := json.Marshal(journalEntry{r.f, r.book().Marks})
+	_, _ = f.Write(append(dd, '\n'))
+	_ = f.Sync()
+	_ = f.Close()
+}
+
+// replayJournal applies the journal of progress file p to progress. A line cut short by the crash is skipped.
+func replayJournal(p string, progress map[string]*Book) {
+	f, e := os.Open(journalPath(p))
+	if e != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var j journalEntry
+		if json.Unmarshal(sc.Bytes(), &j) != nil || j.Book == "" {
+			continue
+		}
+		b := progress[j.Book]
+		if b == nil {
+			b = &Book{}
+			progress[j.Book] = b
+		}
+		b.Marks = j.Marks
+	}
+}
+
+// compactJournal writes the progress file with the journaled bookmarks and removes the journal.
+func (r *Reader) compactJournal() {
+	p := journalPath(r.progressFile)
+	if _, e := os.Stat(p); e != nil {
+		return
+	}
+	r.writeProgress()
+	if r.progressFD.Sync() == nil {
+		_ = os.Remove(p)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// bigFold is the number of items a JSON object or array needs to start folded.
+const bigFold = 100
+
+// isJSON tells if file f with text s is JSON: by extension, or an object or array without one.
+func isJSON(f, s string) bool {
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".json":
+		return true
+	case "":
+		t := strings.TrimSpace(s)
+		return (strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[")) && json.Valid([]byte(t))
+	}
+	return false
+}
+
+// prettyJSON returns d indented, with its objects and arrays as folds and the keys of a top-level
+// object as headings. d is returned as it is if it does not parse.
+func prettyJSON(d *document) *document {
+	var buf bytes.Buffer
+	if e := json.Indent(&buf, []byte(d.lines.joined()), "", "  "); e != nil {
+		return d
+	}
+	n := newDocument()
+	n.meta, n.json = d.meta, true
+	n.lines = indexLines(buf.String())
+	n.folds = jsonFolds(n.lines)
+	for i, s := range n.lines.all() {
+		if strings.HasPrefix(s, `  "`) && !strings.HasPrefix(s, `   `) {
+			if k, _, ok := strings.Cut(s[2:], `":`); ok {
+				n.headings = append(n.headings, chapter{line: i, level: 1, title: strings.TrimPrefix(k, `"`)})
+			}
+		}
+	}
+	return n
+}
+
+// jsonFolds returns the objects and arrays of indented JSON lines ll by their first line.
+func jsonFolds(ll lineIndex) map[int]fold {
+	type open struct {
+		line, items int
+	}
+	folds := make(map[int]fold)
+	var stack []open
+	for i, s := range ll.all() {
+		t := strings.TrimSpace(s)
+		if strings.HasPrefix(t, "}") || strings.HasPrefix(t, "]") {
+			if len(stack) == 0 {
+				continue
+			}
+			o := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			what := "items"
+			if t[0] == '}' {
+				what = "keys"
+			}
+			folds[o.line] = fold{end: i, level: len(stack), items: o.items, summary: fmt.Sprintf("… %d %s %s", o.items, what, t)}
+			continue
+		}
+		if len(stack) > 0 {
+			stack[len(stack)-1].items++
+		}
+		if strings.HasSuffix(t, "{") || strings.HasSuffix(t, "[") {
+			stack = append(stack, open{line: i})
+		}
+	}
+	return folds
+}
+
+// bigFolds returns the folds of d which start collapsed: the closed ones and those with more
+// than bigFold items, except the outermost.
+func bigFolds(d *document) map[int]bool {
+	m := make(map[int]bool)
+	for s, f := range d.folds {
+		if f.closed || f.level > 0 && f.items > bigFold {
+			m[s] = true
+		}
+	}
+	return m
+}
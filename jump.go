@@ -0,0 +1,47 @@
+package main
+
+// maxJumps is how many positions the jump list remembers.
+const maxJumps = 100
+
+// jump moves to line, the current position is remembered so ctrl+o can go back to it.
+func (r *Reader) jump(line int) {
+	r.jumpBack = append(r.jumpBack, r.currentLine)
+	if len(r.jumpBack) > maxJumps {
+		r.jumpBack = r.jumpBack[1:]
+	}
+	r.jumpForward = r.jumpForward[:0]
+	r.currentLine = max(0, min(line, r.totalLine-1))
+}
+
+// jumpPercent jumps to percent p of the book.
+func (r *Reader) jumpPercent(p float64) {
+	if r.stream != nil {
+		if e := r.seekPercent(p); e != nil {
+			r.notice = e.Error()
+		}
+		return
+	}
+	r.jump(int(p / 100 * float64(r.totalLine)))
+}
+
+// jumpOlder goes back to the position before the last jump.
+func (r *Reader) jumpOlder() {
+	if len(r.jumpBack) == 0 {
+		r.notice = tr("no older position")
+		return
+	}
+	r.jumpForward = append(r.jumpForward, r.currentLine)
+	r.currentLine = r.jumpBack[len(r.jumpBack)-1]
+	r.jumpBack = r.jumpBack[:len(r.jumpBack)-1]
+}
+
+// jumpNewer undoes a jumpOlder.
+func (r *Reader) jumpNewer() {
+	if len(r.jumpForward) == 0 {
+		r.notice = tr("no newer position")
+		return
+	}
+	r.jumpBack = append(r.jumpBack, r.currentLine)
+	r.currentLine = r.jumpForward[len(r.jumpForward)-1]
+	r.jumpForward = r.jumpForward[:len(r.jumpForward)-1]
+}
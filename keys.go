@@ -0,0 +1,129 @@
+package main
+
+import (
+	"unicode/utf8"
+)
+
+// keymap binds key names to commands in normal reading mode.
+var keymap = map[string]byte{
+	"ctrl+c": CmdExit, // ctrl + c = 0x03
+	"ctrl+d": CmdExit, // ctrl + d = 0x04
+	"q":      CmdExit,
+	"a":      CmdSwitchScrolling,
+	"enter":  CmdNextLine,
+	"space":  CmdNextHalfPage,
+	"up":     CmdPrevLine,
+	"down":   CmdNextLine,
+	"right":  CmdNextPage,
+	"left":   CmdPrevPage,
+	"s":      CmdSettings,
+	"t":      CmdTOC,
+	":":      CmdCommand,
+	"f":      CmdFootnote,
+	"ctrl+o": CmdJumpBack,
+	"tab":    CmdJumpForward,
+	"l":      CmdLinks,
+	"i":      CmdInfo,
+	"L":      CmdLibrary,
+	"z":      CmdFold,
+	"Z":      CmdFoldAll,
+	"<":      CmdScrollLeft,
+	">":      CmdScrollRight,
+	"T":      CmdTiming,
+	"&":      CmdFilter,
+	"F":      CmdFollow,
+	"]":      CmdNextHunk,
+	"[":      CmdPrevHunk,
+	"W":      CmdSplit,
+	"w":      CmdSwitchPane,
+	"B":      CmdBuffers,
+	"M":      CmdRecord,
+	"@":      CmdPlay,
+	"P":      CmdShare,
+	"D":      CmdDisguise,
+	"b":      CmdBlank,
+	")":      CmdNextSentence,
+	"(":      CmdPrevSentence,
+	"}":      CmdNextParagraph,
+	"{":      CmdPrevParagraph,
+	"%":      CmdPercent,
+	"m":      CmdBookmark,
+	"'":      CmdBookmarks,
+	"v":      CmdVisual,
+	"o":      CmdOutline,
+	"g":      CmdHeading,
+	"+":      CmdScrollFaster,
+	"-":      CmdScrollSlower,
+}
+
+// decodeKeys decodes raw terminal input into key names. Printable characters are named by
+// themselves, the others like "enter", "space", "up", "ctrl+o".
+func decodeKeys(b []byte) []string {
+	var kk []string
+	for len(b) > 0 {
+		k, n := decodeKey(b)
+		if k != "" {
+			kk = append(kk, k)
+		}
+		b = b[n:]
+	}
+	return kk
+}
+
+func decodeKey(b []byte) (string, int) {
+	switch c := b[0]; {
+	case c == 0x1b:
+		if len(b) >= 3 && (b[1] == '[' || b[1] == 'O') {
+			return csiKey(b)
+		}
+		return "esc", 1
+	case c == 0x0d || c == 0x0a:
+		return "enter", 1
+	case c == 0x09:
+		return "tab", 1
+	case c == 0x7f || c == 0x08:
+		return "backspace", 1
+	case c == ' ':
+		return "space", 1
+	case c < 0x20:
+		return "ctrl+" + string(rune('a'+c-1)), 1
+	}
+	r, n := utf8.DecodeRune(b)
+	if r == utf8.RuneError {
+		return "", n
+	}
+	return string(r), n
+}
+
+// csiKey decodes the escape sequences sent by arrows and the navigation keys.
+func csiKey(b []byte) (string, int) {
+	i := 2
+	for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+		i++
+	}
+	if i == len(b) {
+		return "", len(b)
+	}
+	n := i + 1
+	switch string(b[2:n]) {
+	case "A":
+		return "up", n
+	case "B":
+		return "down", n
+	case "C":
+		return "right", n
+	case "D":
+		return "left", n
+	case "H", "1~", "7~":
+		return "home", n
+	case "F", "4~", "8~":
+		return "end", n
+	case "5~":
+		return "pgup", n
+	case "6~":
+		return "pgdn", n
+	case "3~":
+		return "delete", n
+	}
+	return "", n
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// runeWidth returns the number of terminal columns c takes.
+func runeWidth(c rune) int {
+	switch {
+	case c < 0x20 || c == 0x7f:
+		return 0
+	case unicode.In(c, unicode.Mn, unicode.Me, unicode.Cf):
+		return 0
+	}
+	switch width.LookupRune(c).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	return 1
+}
+
+// escLen returns the length of the escape sequence at the start of s, 0 if s does not start with one.
+func escLen(s string) int {
+	if len(s) < 2 || s[0] != 0x1b {
+		return 0
+	}
+	if s[1] != '[' {
+		return 2
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// trackSGR returns the SGR sequences in effect after seq, given active ones before it.
+func trackSGR(active, seq string) string {
+	switch {
+	case !strings.HasSuffix(seq, "m") || !strings.HasPrefix(seq, "\x1b["):
+		return active
+	case seq == "\x1b[m" || seq == "\x1b[0m":
+		return ""
+	case strings.HasPrefix(seq, "\x1b[0;"):
+		return seq
+	}
+	return active + seq
+}
+
+// strWidth returns the number of terminal columns s takes, escape sequences take none.
+func strWidth(s string) int {
+	w := 0
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		n, cw := cluster(s[i:])
+		w += cw
+		i += n
+	}
+	return w
+}
+
+// noBreakBefore are characters which should not start a row, noBreakAfter should not end one.
+const (
+	noBreakBefore = "，。、；：？！）》」』】〉”’…—,.;:?!)]}"
+	noBreakAfter  = "（《「『【〈“‘([{"
+)
+
+// wrap splits s into rows no wider than w columns. Rows break after spaces or around wide
+// characters where possible, otherwise wherever the width runs out. With hyphenator h,
+// the word running past the end of a row is hyphenated if a part of it fits.
+// SGR sequences take no columns and the ones in effect are repeated at the start of every row.
+func wrap(s string, w int, h *hyphenator) []string {
+	if w < 1 {
+		w = 1
+	}
+	var (
+		rows     []string
+		start    int    // offset where the current row starts.
+		startSGR string // SGR sequences in effect at start.
+		active   string // SGR sequences in effect at i.
+		col      int    // columns taken by s[start:i].
+		brk      = -1   // latest offset the current row can break at.
+		brkSGR   string
+		prev     rune
+	)
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			active = trackSGR(active, s[i:i+n])
+			i += n
+			continue
+		}
+		c, _ := utf8.DecodeRuneInString(s[i:])
+		n, cw := cluster(s[i:])
+		// a space after a full row goes with it, it is trimmed at the break after it.
+		if col+cw > w && col > 0 && (c != ' ' || col > w) {
+			end, next, nextSGR := i, i, active
+			if brk > start {
+				end, next, nextSGR = brk, brk, brkSGR
+			}
+			hyphen := ""
+			if p := hyphenPoint(h, s, max(brk, start), i, w-strWidth(s[start:max(brk, start)])); p > 0 {
+				end, next, nextSGR, hyphen = p, p, active, "-"
+			}
+			rows = append(rows, startSGR+strings.TrimRight(s[start:end], " ")+hyphen)
+			for next < len(s) && s[next] == ' ' {
+				next++
+			}
+			start, startSGR, brk = next, nextSGR, -1
+			if start > i {
+				i = start
+			}
+			col = strWidth(s[start:i])
+			continue
+		}
+		switch {
+		case c == ' ':
+			brk, brkSGR = i+n, active
+		case (cw == 2 || runeWidth(prev) == 2) && i > start &&
+			!strings.ContainsRune(noBreakBefore, c) && !strings.ContainsRune(noBreakAfter, prev):
+			brk, brkSGR = i, active
+		}
+		prev = c
+		col += cw
+		i += n
+	}
+	return append(rows, startSGR+s[start:])
+}
+
+// justify widens the spaces between the words of row until it is w columns wide,
+// the extra ones go to the gaps on the left first. Spaces indenting the row are kept.
+func justify(row string, w int) string {
+	extra := w - strWidth(row)
+	indent := len(row) - len(strings.TrimLeft(row, " "))
+	words := strings.Fields(row[indent:])
+	if extra <= 0 || len(words) < 2 {
+		return row
+	}
+	gaps := len(words) - 1
+	var sb strings.Builder
+	sb.WriteString(row[:indent])
+	for i, word := range words {
+		sb.WriteString(word)
+		if i < gaps {
+			n := 1 + extra/gaps
+			if i < extra%gaps {
+				n++
+			}
+			sb.WriteString(strings.Repeat(" ", n))
+		}
+	}
+	return sb.String()
+}
+
+// hyphenPoint returns the offset in s of the last hyphen in the word at ws which leaves the part
+// before it and the hyphen room columns, if the word runs past i. It returns -1 if there is none.
+func hyphenPoint(h *hyphenator, s string, ws, i, room int) int {
+	if h == nil {
+		return -1
+	}
+	lead := ws
+	for lead < i {
+		c, n := utf8.DecodeRuneInString(s[lead:])
+		if unicode.IsLetter(c) {
+			break
+		}
+		lead += n
+	}
+	end := lead
+	for end < len(s) {
+		c, n := utf8.DecodeRuneInString(s[end:])
+		if !unicode.IsLetter(c) {
+			break
+		}
+		end += n
+	}
+	if end <= i {
+		return -1
+	}
+	best := -1
+	for _, p := range h.points(s[lead:end]) {
+		if strWidth(s[ws:lead+p])+1 <= room {
+			best = lead + p
+		}
+	}
+	return best
+}
+
+// cut truncates s to w columns, escape sequences are kept.
+func cut(s string, w int) string {
+	col := 0
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		n, cw := cluster(s[i:])
+		if col+cw > w {
+			return s[:i]
+		}
+		col += cw
+		i += n
+	}
+	return s
+}
+
+// skip drops the first w columns of s, the SGR sequences in effect are kept.
+func skip(s string, w int) string {
+	col := 0
+	active := ""
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			active = trackSGR(active, s[i:i+n])
+			i += n
+			continue
+		}
+		if col >= w {
+			return active + s[i:]
+		}
+		n, cw := cluster(s[i:])
+		col += cw
+		i += n
+	}
+	return active
+}
+
+// expandTabs replaces tabs in s by spaces up to the next multiple of w columns.
+// If mark is set, tabs are shown as an arrow in SGR mark, followed by base if no other SGR is active.
+func expandTabs(s string, w int, mark, base string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	w = max(1, w)
+	var sb strings.Builder
+	col := 0
+	active := ""
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			active = trackSGR(active, s[i:i+n])
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		if s[i] != '\t' {
+			n, cw := cluster(s[i:])
+			sb.WriteString(s[i : i+n])
+			col += cw
+			i += n
+			continue
+		}
+		i++
+		pad := w - col%w
+		col += pad
+		if mark == "" {
+			sb.WriteString(strings.Repeat(" ", pad))
+			continue
+		}
+		restore := active
+		if restore == "" {
+			restore = base
+		}
+		sb.WriteString(mark + "→" + strings.Repeat(" ", pad-1) + restore)
+	}
+	return sb.String()
+}
+
+const resumeMarkText = "-- last time you stopped here "
+
+// textWidth is the number of columns available for text in a row.
+func (r *Reader) textWidth() int {
+	page := r.winWidth - r.outlineWidth()
+	w := page - 2*r.cfg.Margin
+	if w < 10 {
+		w = page
+	}
+	return max(1, w-r.disguiseWidth())
+}
+
+// layoutLine returns the rows line i takes on screen, without the left margin.
+func (r *Reader) layoutLine(i int) []string {
+	s := r.decorate(i)
+	if r.cfg.ANSI || r.doc.styled {
+		s = themeSGR(s, r.theme().base())
+	}
+	if t, ok := r.doc.cues[i]; ok && r.cfg.Timing {
+		s = r.theme().mark() + t + r.theme().base() + " " + s
+	}
+	if i < r.doc.header() {
+		s = "\x1b[1m" + s
+	}
+	if f, ok := r.doc.collapsed[i]; ok {
+		s += " " + r.theme().mark() + f.summary + r.theme().base()
+	}
+	if i == r.selectedFold() || r.selected(i) {
+		base := r.theme().base()
+		s = "\x1b[7m" + strings.ReplaceAll(s, base, base+"\x1b[7m") + base
+	}
+	if r.doc.right != nil {
+		return r.camouflage(i, r.layoutPair(s, r.doc.right[i]))
+	}
+	s, gap := r.paragraph(i, s)
+	return r.camouflage(i, append(r.layoutText(s, r.textWidth()), make([]string, gap)...))
+}
+
+// layoutText returns the rows text s takes in w columns.
+func (r *Reader) layoutText(s string, w int) []string {
+	if r.cfg.Typography && !r.doc.paras && !r.doc.json && r.doc.cols == nil {
+		s = typography(s)
+	}
+	mark := ""
+	if r.cfg.ShowTabs {
+		mark = r.theme().mark()
+	}
+	s = expandTabs(s, r.cfg.Tab, mark, r.theme().base())
+	var rr []ruby
+	if r.cfg.Ruby != "off" && r.doc.cols == nil && strings.ContainsAny(s, "《［") {
+		s, rr = parseRuby(s)
+		if r.cfg.Ruby != "above" {
+			s, rr = rubyInline(s, rr, r.cfg.Ruby == "hide"), nil
+		}
+	}
+	if !r.cfg.Wrap || r.doc.cols != nil {
+		return []string{cut(skip(s, r.hscroll), w)}
+	}
+	if len(rr) > 0 {
+		return rubyRows(s, rr, wrap(s, w, nil), w, r.theme().mark(), r.theme().base())
+	}
+	var h *hyphenator
+	if r.cfg.Hyphenate {
+		h = hyphenatorFor(r.doc.meta.Language)
+	}
+	rows := wrap(s, w, h)
+	if r.poetry() {
+		return center(rows, w)
+	}
+	if r.cfg.Justify && !r.doc.json && r.doc.cols == nil {
+		for i := range len(rows) - 1 {
+			rows[i] = justify(rows[i], w)
+		}
+	}
+	return rows
+}
+
+// layoutPage lays out lines from start until n rows are filled,
+// it returns the rows and the number of lines fully displayed.
+func (r *Reader) layoutPage(start, n int) ([]string, int) {
+	t := r.theme()
+	margin := strings.Repeat(" ", r.cfg.Margin)
+	var rows []string
+	for _, row := range r.disguiseHeader() {
+		rows = append(rows, margin+row)
+	}
+	if h := r.doc.header(); start >= h {
+		for i := range h {
+			rows = append(rows, margin+r.layoutLine(i)[0])
+		}
+	}
+	shown := 0
+	var ends []int // rows taken by the lines from start up to each.
+	for i := start; i < r.totalLine && len(rows) < n; i++ {
+		if r.displayBreakMark && i == r.jumpBreakMark && !r.cfg.Accessible && !r.cfg.Pages {
+			rows = append(rows, margin+t.mark()+strings.Repeat("=", r.textWidth()/2)+"↓")
+		}
+		if r.displayResumeMark && i == r.resumeMark {
+			text := tr(resumeMarkText)
+			if !r.cfg.Accessible {
+				text += strings.Repeat("-", max(0, r.textWidth()/2-strWidth(text)))
+			}
+			rows = append(rows, margin+t.mark()+text)
+		}
+		lr := r.layoutLine(i)
+		if i == start && start == r.currentLine {
+			lr = lr[r.topRow(lr):]
+		}
+		for _, row := range lr {
+			rows = append(rows, margin+row)
+		}
+		ends = append(ends, len(rows))
+		if len(rows) <= n {
+			shown++
+		}
+	}
+	if r.cfg.Pages && shown > 0 {
+		shown = r.pageLines(start, shown)
+		rows = rows[:ends[shown-1]]
+	}
+	if len(rows) > n {
+		rows = rows[:n]
+	}
+	return rows, shown
+}
+
+// pageLines returns how many of the lines from start a page shows when n of them fit, for Config.Pages.
+// A paragraph is not split if its first line would be left alone at the bottom of the page,
+// or its last line at the top of the next one. Paragraphs of a line each are not split at all.
+func (r *Reader) pageLines(start, n int) int {
+	e := start + n // the first line of the next page.
+	if n < 2 || e >= r.totalLine || r.blankLine(e) || r.blankLine(e-1) || r.lineParas {
+		return n
+	}
+	if r.blankLine(e-2) || r.blankLine(e+1) {
+		return n - 1
+	}
+	return n
+}
+
+// linesBefore returns how many lines before end fit into n rows.
+func (r *Reader) linesBefore(end, n int) int {
+	c := 0
+	for i := end - 1; i >= 0; i-- {
+		n -= len(r.layoutLine(i))
+		if n < 0 {
+			break
+		}
+		c++
+	}
+	return c
+}
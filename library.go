@@ -0,0 +1,133 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// bookName is the title and author of book f of the progress file.
+func bookName(f string, b *Book) string {
+	title := b.Meta.Title
+	if title == "" {
+		title = filepath.Base(f)
+	}
+	if b.Meta.Author != "" {
+		title += " · " + b.Meta.Author
+	}
+	return title
+}
+
+// libraryItem describes book f of the progress file in the library.
+func libraryItem(f string, b *Book) string {
+	s := bookName(f, b)
+	s += fmt.Sprintf("  %.0f%%", b.Percent)
+	if b.Seconds > 0 {
+		s += "  " + fmt.Sprintf(tr("%s read"), readTime(b.Seconds))
+	}
+	if !b.Opened.IsZero() {
+		s += "  " + b.Opened.Format(time.DateOnly)
+	}
+	return s
+}
+
+// openLibrary lists the books in the progress file, the last opened first. Enter opens one.
+func (r *Reader) openLibrary() {
+	ff := make([]string, 0, len(r.progress))
+	for f, b := range r.progress {
+		if b != nil {
+			ff = append(ff, f)
+		}
+	}
+	slices.SortFunc(ff, func(a, b string) int {
+		if c := r.progress[b].Opened.Compare(r.progress[a].Opened); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	items := make([]string, len(ff))
+	sel := 0
+	for i, f := range ff {
+		items[i] = libraryItem(f, r.progress[f])
+		if f == r.f {
+			sel = i
+		}
+	}
+	r.overlay = &library{list{
+		title: "Library",
+		items: items,
+		sel:   sel,
+		foot:  "Enter:Open Esc:Close",
+		pick: func(r *Reader, i int) {
+			if e := r.open(ff[i]); e != nil {
+				r.notice = e.Error()
+			}
+		},
+	}, ff}
+}
+
+// library is the list of books, showing the cover of the selected one.
+type library struct {
+	list
+	ff []string
+}
+
+func (l *library) draw(r *Reader, b *strings.Builder) {
+	if len(l.ff) == 0 {
+		l.list.draw(r, b)
+		return
+	}
+	f := l.ff[l.sel]
+	r.drawCoverBox(b, l.title, l.items, l.sel, l.foot, f, r.progress[f].Meta)
+}
+
+// open switches to book f, it stays at the current book if f cannot be read.
+// The current book stays open in a buffer, f is taken from its buffer if it has one.
+func (r *Reader) open(f string) error {
+	if f == r.f {
+		return nil
+	}
+	if !r.switchTo(f) {
+		if e := r.load(f); e != nil {
+			return e
+		}
+	}
+	r.chapter = noChapter
+	r.hook("open")
+	return nil
+}
+
+// load reads book f and makes it the current one, keeping the current one in a buffer.
+func (r *Reader) load(f string) error {
+	cfg := r.conf
+	if b, ok := r.progress[f]; ok && b != nil {
+		cfg = r.conf.with(b.Settings)
+	}
+	var d *document
+	if !streamed(f, cfg) {
+		var e error
+		if d, e = loadDocument(f, cfg.Encoding); e != nil {
+			return e
+		}
+	}
+	r.buffers[r.f] = r.stash()
+	r.opened = append(r.opened, f)
+	r.f, r.diff, r.parallel, r.stream = f, "", "", nil
+	r.applyBook()
+	if d == nil {
+		if e := r.streamIndex(); e != nil {
+			return e
+		}
+	} else {
+		r.setDocument(d)
+	}
+	r.toView()
+	r.currentLine = min(r.currentLine, max(0, r.totalLine-1))
+	r.jumpBack, r.jumpForward = nil, nil
+	r.displayBreakMark = false
+	r.askResume()
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"iter"
+	"strings"
+)
+
+// lineIndex is the lines of a document as one text and the offsets the lines start at in it.
+// A line is sliced from the text when it is read, so a line costs an int of the index and no
+// string of its own: a big book is its text and little more.
+type lineIndex struct {
+	text   string
+	starts []int // line number:offset of its start in text, then one past the newline ending the last.
+}
+
+// indexLines indexes the lines of s, split at newlines like strings.Split splits them.
+func indexLines(s string) lineIndex {
+	starts := make([]int, 1, strings.Count(s, "\n")+2)
+	for i := 0; ; {
+		j := strings.IndexByte(s[i:], '\n')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+		starts = append(starts, i)
+	}
+	return lineIndex{text: s, starts: append(starts, len(s)+1)}
+}
+
+// lineIndexOf indexes lines ss, joined into one text.
+func lineIndexOf(ss []string) lineIndex {
+	starts := make([]int, len(ss)+1)
+	n := 0
+	for i, s := range ss {
+		starts[i] = n
+		n += len(s) + 1
+	}
+	starts[len(ss)] = n
+	return lineIndex{text: strings.Join(ss, "\n"), starts: starts}
+}
+
+func (l lineIndex) len() int {
+	return max(0, len(l.starts)-1)
+}
+
+// at returns line i.
+func (l lineIndex) at(i int) string {
+	return l.text[l.starts[i] : l.starts[i+1]-1]
+}
+
+// slice returns lines from to to, sharing the text of l.
+func (l lineIndex) slice(from, to int) lineIndex {
+	if from == to {
+		// no lines, which the index of no lines has no offsets to slice for.
+		return lineIndex{}
+	}
+	return lineIndex{text: l.text, starts: l.starts[from : to+1]}
+}
+
+// joined returns the lines joined by newlines, which is a part of the text and takes no copy.
+func (l lineIndex) joined() string {
+	if l.len() == 0 {
+		return ""
+	}
+	return l.text[l.starts[0] : l.starts[len(l.starts)-1]-1]
+}
+
+// all yields the lines with their numbers.
+func (l lineIndex) all() iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i := range l.len() {
+			if !yield(i, l.at(i)) {
+				return
+			}
+		}
+	}
+}
+
+// strings returns the lines as strings, for the few users of a slice.
+func (l lineIndex) strings() []string {
+	ss := make([]string, l.len())
+	for i := range ss {
+		ss[i] = l.at(i)
+	}
+	return ss
+}
+
+// containsFunc tells if a line satisfies f, like slices.ContainsFunc.
+func (l lineIndex) containsFunc(f func(s string) bool) bool {
+	for _, s := range l.all() {
+		if f(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// lineBuilder builds a lineIndex a line at a time, into one text.
+type lineBuilder struct {
+	sb     strings.Builder
+	starts []int
+}
+
+func (b *lineBuilder) add(s string) {
+	b.starts = append(b.starts, b.sb.Len())
+	b.sb.WriteString(s)
+	b.sb.WriteByte('\n')
+}
+
+func (b *lineBuilder) len() int {
+	return len(b.starts)
+}
+
+// index returns the lines added.
+func (b *lineBuilder) index() lineIndex {
+	if len(b.starts) == 0 {
+		return lineIndex{}
+	}
+	s := b.sb.String()
+	return lineIndex{text: s[:len(s)-1], starts: append(b.starts, len(s))}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIndexLines(t *testing.T) {
+	for _, s := range []string{"", "a", "a\n", "\n", "a\nb", "a\n\nb\n", "\n\n", "αβ\nγ"} {
+		want := strings.Split(s, "\n")
+		for _, l := range []lineIndex{indexLines(s), lineIndexOf(want)} {
+			if got := l.strings(); !slices.Equal(got, want) {
+				t.Errorf("lines of %q = %q, want %q", s, got, want)
+			}
+			if l.joined() != s {
+				t.Errorf("lines of %q joined = %q", s, l.joined())
+			}
+		}
+	}
+	for _, l := range []lineIndex{lineIndexOf(nil), {}} {
+		if l.len() != 0 || l.joined() != "" || len(l.strings()) != 0 || l.slice(0, 0).len() != 0 {
+			t.Errorf("no lines have %d lines %q", l.len(), l.joined())
+		}
+	}
+}
+
+func TestLineIndexSlice(t *testing.T) {
+	l := indexLines("a\nbb\n\nccc\nd")
+	tests := []struct {
+		from, to int
+		want     []string
+	}{
+		{0, 5, []string{"a", "bb", "", "ccc", "d"}},
+		{1, 3, []string{"bb", ""}},
+		{3, 5, []string{"ccc", "d"}},
+		{2, 2, []string{}},
+	}
+	for _, tt := range tests {
+		s := l.slice(tt.from, tt.to)
+		if got := s.strings(); !slices.Equal(got, tt.want) {
+			t.Errorf("slice(%d, %d) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+		if s.joined() != strings.Join(tt.want, "\n") {
+			t.Errorf("slice(%d, %d) joined = %q", tt.from, tt.to, s.joined())
+		}
+	}
+}
+
+func TestLineBuilder(t *testing.T) {
+	var lb lineBuilder
+	if l := lb.index(); l.len() != 0 {
+		t.Errorf("an empty builder has %d lines", l.len())
+	}
+	want := []string{"a", "", "bc", ""}
+	for i, s := range want {
+		if lb.len() != i {
+			t.Errorf("builder of %d lines has len %d", i, lb.len())
+		}
+		lb.add(s)
+	}
+	l := lb.index()
+	if got := l.strings(); !slices.Equal(got, want) {
+		t.Errorf("built %q, want %q", got, want)
+	}
+	if l.joined() != "a\n\nbc\n" {
+		t.Errorf("built text %q", l.joined())
+	}
+}
+
+// TestFilter checks the lines left by document.filter and where the headings and links move.
+func TestFilter(t *testing.T) {
+	d := newDocument()
+	d.lines = indexLines("one\n\ntwo\n\nthree")
+	d.headings = []chapter{{line: 2, level: 1, title: "two"}, {line: 3, level: 1, title: "gone"}}
+	d.links[4] = []link{{start: 0, end: 5, target: "x"}}
+	n := d.filter(func(_ int, s string) bool { return s != "" })
+	if got := n.lines.strings(); !slices.Equal(got, []string{"one", "two", "three"}) {
+		t.Errorf("lines %q", got)
+	}
+	if len(n.headings) != 1 || n.headings[0].line != 1 {
+		t.Errorf("headings %+v, want two on line 1", n.headings)
+	}
+	if _, ok := n.links[2]; !ok || len(n.links) != 1 {
+		t.Errorf("links %+v, want the link on line 2", n.links)
+	}
+	if !slices.Equal(n.origin, []int{0, 2, 4}) {
+		t.Errorf("origin %v", n.origin)
+	}
+	if d.filter(func(int, string) bool { return true }) != d {
+		t.Error("filtering no line out made a new document")
+	}
+}
+
+// TestHideAll checks a book with every line hidden is left with no lines and no chapters.
+func TestHideAll(t *testing.T) {
+	d := newDocument()
+	d.lines = indexLines("Chapter 1\n\nIt was a bright cold day.")
+	n, e := Config{Hide: []string{"."}}.filter(d, Rules{Hide: []string{"^$"}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if n.lines.len() != 0 {
+		t.Errorf("lines %q, want none", n.lines.strings())
+	}
+	if cc := n.chapters(); len(cc) != 0 {
+		t.Errorf("chapters %+v, want none", cc)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pageLink is a link found on the page.
+type pageLink struct {
+	line int
+	link
+}
+
+// linkSelect is the overlay choosing one of the links on the page, the chosen one is highlighted.
+type linkSelect struct {
+	links []pageLink
+	sel   int
+}
+
+// selectLink starts choosing a link on the page.
+func (r *Reader) selectLink() {
+	var ll []pageLink
+	for i := r.currentLine; i < min(r.currentLine+max(1, r.shown+1), r.totalLine); i++ {
+		for _, l := range r.doc.links[i] {
+			ll = append(ll, pageLink{i, l})
+		}
+	}
+	if len(ll) == 0 {
+		r.notice = tr("no links on this page")
+		return
+	}
+	r.overlay = &linkSelect{links: ll}
+}
+
+func (s *linkSelect) key(r *Reader, k string) bool {
+	switch k {
+	case "tab", "right", "down", "l":
+		s.sel = (s.sel + 1) % len(s.links)
+	case "left", "up", "h":
+		s.sel = (s.sel + len(s.links) - 1) % len(s.links)
+	case "enter":
+		r.followLink(s.links[s.sel].target)
+		return true
+	case "esc", "q":
+		return true
+	}
+	return false
+}
+
+func (s *linkSelect) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	l := s.links[s.sel]
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K%s", r.winHeight, t.status(),
+		cut(fmt.Sprintf("> Link %d/%d: %s  [Tab]:Next [Enter]:Follow [Esc]:Cancel", s.sel+1, len(s.links), l.target), r.winWidth-1), t.base())
+}
+
+// followLink jumps to the anchor target, ctrl+o returns. Gemini pages are opened as books.
+func (r *Reader) followLink(target string) {
+	if l, ok := r.doc.anchors[target]; ok {
+		r.jump(l)
+		return
+	}
+	if strings.HasPrefix(target, "gemini://") {
+		if e := r.openPath(target); e != nil {
+			r.notice = e.Error()
+		}
+		return
+	}
+	r.notice = tr("link leads out of the book: ") + target
+}
+
+// decorate returns line i with its links styled, the link being selected and the selected
+// sentence are highlighted.
+func (r *Reader) decorate(i int) string {
+	s := r.index.at(i)
+	ll := r.doc.links[i]
+	start, end, sentence := r.sentenceSpan(i)
+	if len(ll) == 0 && !sentence {
+		return s
+	}
+	var sel *link
+	if ls, ok := r.overlay.(*linkSelect); ok && ls.links[ls.sel].line == i {
+		sel = &ls.links[ls.sel].link
+	}
+	base := r.theme().base()
+	// outside is the style after a link ends at off, the selected sentence stays reversed.
+	outside := func(off int) string {
+		if sentence && off >= start && off < end {
+			return base + "\x1b[7m"
+		}
+		return base
+	}
+	type style struct {
+		off  int
+		seq  string
+		ends bool
+	}
+	var ss []style
+	if sentence {
+		ss = append(ss, style{start, "\x1b[7m", false}, style{end, base, true})
+	}
+	for _, l := range ll {
+		if l.end > len(s) || l.start >= l.end {
+			continue
+		}
+		seq := "\x1b[4m"
+		if sel != nil && l.start == sel.start && l.end == sel.end {
+			seq = "\x1b[7m"
+		}
+		ss = append(ss, style{l.start, seq, false}, style{l.end, outside(l.end), true})
+	}
+	sort.SliceStable(ss, func(a, b int) bool {
+		return ss[a].off < ss[b].off || ss[a].off == ss[b].off && ss[a].ends && !ss[b].ends
+	})
+	var sb strings.Builder
+	last := 0
+	for _, x := range ss {
+		sb.WriteString(s[last:x.off] + x.seq)
+		last = x.off
+	}
+	return sb.String() + s[last:]
+}
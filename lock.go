@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// lockMagic starts a locked progress file, the salt, the nonce and the sealed JSON follow it.
+const lockMagic = "fish-locked-1\n"
+
+// Keys are derived from the passphrase or key file with PBKDF2-SHA256, once a salt.
+const (
+	lockRounds   = 600_000
+	lockSaltSize = 16
+)
+
+// lockState is the secret the progress files are locked with, read once from Config.Lock.
+var lockState struct {
+	read   bool
+	asked  bool              // the secret is a passphrase typed in, which may be typed again.
+	secret []byte            // nil if progress is not locked.
+	salt   []byte            // of the file unlocked last, files are locked with it too.
+	keys   map[string][]byte // by salt.
+}
+
+// lockSecret returns the passphrase or the contents of the key file of Config.Lock, asking for
+// the passphrase of progress file p the first time. It is nil if progress is not locked.
+func lockSecret(p string) ([]byte, error) {
+	if lockState.read {
+		return lockState.secret, nil
+	}
+	c, e := LoadConfig()
+	if e != nil {
+		return nil, e
+	}
+	var secret []byte
+	switch c.Lock {
+	case "":
+	case "ask":
+		if askPassword == nil {
+			return nil, errors.New(filepath.Base(p) + tr(" is encrypted, open it with fish to give its password"))
+		}
+		pw, e := askPassword(p)
+		if e != nil {
+			return nil, e
+		}
+		secret = []byte(pw)
+	default:
+		k, e := homePath(c.Lock)
+		if e != nil {
+			return nil, e
+		}
+		if secret, e = os.ReadFile(k); e != nil {
+			return nil, e
+		}
+		if secret = bytes.TrimSpace(secret); len(secret) == 0 {
+			return nil, fmt.Errorf(tr("the key file %s is empty"), c.Lock)
+		}
+	}
+	lockState.read, lockState.asked = true, c.Lock == "ask"
+	lockState.secret, lockState.keys = secret, make(map[string][]byte)
+	return secret, nil
+}
+
+// lockCipher returns the cipher of the secret with salt.
+func lockCipher(salt []byte) (cipher.AEAD, error) {
+	k := lockState.keys[string(salt)]
+	if k == nil {
+		var e error
+		if k, e = pbkdf2.Key(sha256.New, string(lockState.secret), salt, lockRounds, 32); e != nil {
+			return nil, e
+		}
+		lockState.keys[string(salt)] = k
+	}
+	block, e := aes.NewCipher(k)
+	if e != nil {
+		return nil, e
+	}
+	return cipher.NewGCM(block)
+}
+
+// lock seals dd, the contents of progress file p, if Config.Lock is set.
+func lock(p string, dd []byte) ([]byte, error) {
+	secret, e := lockSecret(p)
+	if e != nil || secret == nil {
+		return dd, e
+	}
+	if lockState.salt == nil {
+		lockState.salt = make([]byte, lockSaltSize)
+		_, _ = rand.Read(lockState.salt)
+	}
+	aead, e := lockCipher(lockState.salt)
+	if e != nil {
+		return nil, e
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	out := append([]byte(lockMagic), lockState.salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, dd, []byte(lockMagic)), nil
+}
+
+// unlock opens dd, the contents of progress file p, if it is locked. A passphrase may be typed
+// three times.
+func unlock(p string, dd []byte) ([]byte, error) {
+	for try := 1; ; try++ {
+		secret, e := lockSecret(p)
+		if e != nil {
+			return nil, e
+		}
+		rest, locked := bytes.CutPrefix(dd, []byte(lockMagic))
+		if !locked {
+			return dd, nil
+		}
+		if secret == nil {
+			return nil, errors.New(filepath.Base(p) + tr(" is locked, set lock in the config to read it"))
+		}
+		if len(rest) < lockSaltSize {
+			return nil, errors.New(filepath.Base(p) + ": the lock is damaged")
+		}
+		salt := rest[:lockSaltSize]
+		aead, e := lockCipher(salt)
+		if e != nil {
+			return nil, e
+		}
+		rest = rest[lockSaltSize:]
+		if len(rest) < aead.NonceSize() {
+			return nil, errors.New(filepath.Base(p) + ": the lock is damaged")
+		}
+		plain, e := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], []byte(lockMagic))
+		if e == nil {
+			lockState.salt = slices.Clone(salt)
+			return plain, nil
+		}
+		if !lockState.asked || try == 3 || askPassword == nil {
+			return nil, fmt.Errorf(tr("wrong password for %s"), filepath.Base(p))
+		}
+		lockState.read = false
+	}
+}
+
+// LockProgress writes the progress file locked with Config.Lock, or unlocked in plain text
+// for on false. It returns a report of what it did.
+func LockProgress(on bool) (string, error) {
+	p, e := progressPath()
+	if e != nil {
+		return "", e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return "", e
+	}
+	// the deletions synced beside it are locked with it.
+	dp := filepath.Join(filepath.Dir(p), deletedFile)
+	_, e = os.Stat(dp)
+	hasDeleted := e == nil
+	deleted, e := readDeleted(dp)
+	if e != nil {
+		return "", e
+	}
+	if _, e := lockSecret(p); e != nil {
+		return "", e
+	}
+	switch {
+	case !on:
+		lockState.secret = nil
+	case lockState.secret == nil:
+		return "", errors.New(tr(`set lock in the config to a key file or "ask" first`))
+	}
+	if e := writeProgressFile(p, progress); e != nil {
+		return "", e
+	}
+	if hasDeleted {
+		if e := writeDeleted(dp, deleted); e != nil {
+			return "", e
+		}
+	}
+	_ = os.Remove(journalPath(p))
+	if !on {
+		return p + " unlocked\n", nil
+	}
+	return p + " locked\n", nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	logTime  = regexp.MustCompile(`^\[?(\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d([.,]\d+)?(Z|[+-]\d\d:?\d\d)?|[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d|\d\d:\d\d:\d\d([.,]\d+)?)\]?`)
+	logLevel = regexp.MustCompile(`\b(FATAL|PANIC|CRIT(ICAL)?|ERROR|ERR|WARN(ING)?|INFO|NOTICE|DEBUG|TRACE)\b|\blevel=\w+`)
+)
+
+// levelSGR returns the SGR sequence a log level is colored in.
+func levelSGR(level string) string {
+	switch level = strings.ToUpper(strings.TrimPrefix(level, "level=")); {
+	case strings.HasPrefix(level, "ERR"), level == "FATAL", level == "PANIC", strings.HasPrefix(level, "CRIT"):
+		return "\x1b[1;31m"
+	case strings.HasPrefix(level, "WARN"):
+		return "\x1b[33m"
+	case level == "INFO", level == "NOTICE":
+		return "\x1b[32m"
+	}
+	return "\x1b[2m"
+}
+
+// isLog tells if file f with lines ll is a log: by extension, or most of its first lines start with a time.
+func isLog(f string, ll lineIndex) bool {
+	if strings.EqualFold(filepath.Ext(f), ".log") {
+		return true
+	}
+	n, timed := 0, 0
+	for _, s := range ll.all() {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		if logTime.MatchString(s) {
+			timed++
+		}
+		if n++; n == 20 {
+			break
+		}
+	}
+	return n >= 3 && timed*2 > n
+}
+
+// logColors returns log d with the times at the start of lines dimmed and the first level on each line colored.
+func logColors(d *document, f string) *document {
+	if d.styled || d.paras || d.json || d.cols != nil || !isLog(f, d.lines) {
+		return d
+	}
+	n := *d
+	var lb lineBuilder
+	for _, s := range d.lines.all() {
+		if strings.Contains(s, "\x1b[") {
+			lb.add(s)
+			continue
+		}
+		head := ""
+		if m := logTime.FindString(s); m != "" {
+			head, s = "\x1b[2m"+m+"\x1b[0m", s[len(m):]
+		}
+		if l := logLevel.FindStringIndex(s); l != nil {
+			s = s[:l[0]] + levelSGR(s[l[0]:l[1]]) + s[l[0]:l[1]] + "\x1b[0m" + s[l[1]:]
+		}
+		lb.add(head + s)
+	}
+	n.lines, n.styled = lb.index(), true
+	return &n
+}
+
+// setGrep shows only the lines matching regular expression p, all lines if p is empty.
+// The match ignores case unless p has upper case letters.
+func (r *Reader) setGrep(p string) {
+	if p == "" {
+		if r.grep != nil {
+			r.grep = nil
+			r.rebuild()
+		}
+		return
+	}
+	if !strings.ContainsFunc(p, unicode.IsUpper) {
+		p = "(?i)" + p
+	}
+	re, e := regexp.Compile(p)
+	if e != nil {
+		r.notice = e.Error()
+		return
+	}
+	old := r.grep
+	r.grep = re
+	r.rebuild()
+	if r.totalLine == 0 {
+		r.notice = tr("no lines match")
+		r.grep = old
+		r.rebuild()
+		return
+	}
+	r.notice = fmt.Sprintf(tr("%d lines match, & and enter shows all"), r.totalLine)
+}
+
+// fileStamp tells when a file changed.
+type fileStamp struct {
+	size int64
+	mod  time.Time
+}
+
+// toggleFollow starts or stops following the end of the file as it grows.
+func (r *Reader) toggleFollow() {
+	r.follow = !r.follow
+	if !r.follow {
+		r.notice = tr("stopped following")
+		return
+	}
+	r.reload()
+	r.stamp = fileStamp{}
+	r.checkFile()
+	r.notice = fmt.Sprintf(tr("following the end of %s, F stops"), filepath.Base(r.f))
+}
+
+// checkFile reloads the file if it changed since the last check and shows its end, it tells if it did.
+func (r *Reader) checkFile() bool {
+	fi, e := os.Stat(r.f)
+	if e != nil {
+		return false
+	}
+	s := fileStamp{fi.Size(), fi.ModTime()}
+	if s == r.stamp {
+		return false
+	}
+	if r.stamp != (fileStamp{}) {
+		r.reload()
+	}
+	r.stamp = s
+	r.currentLine = max(0, r.totalLine-max(1, r.linesBefore(r.totalLine, r.pageHeight())))
+	r.displayBreakMark, r.displayResumeMark = false, false
+	return true
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// maxPrealloc is the most memory taken at once for the size a header claims, more is taken as
+// the data comes, a damaged header cannot take it all.
+const maxPrealloc = 64 << 20
+
+// errLZMA is the error of a damaged LZMA stream, or of one decrypted with a wrong password.
+var errLZMA = errors.New("lzma: bad data")
+
+// rangeDecoder is the range decoder of LZMA, reading in.
+type rangeDecoder struct {
+	in   []byte
+	pos  int
+	rng  uint32
+	code uint32
+}
+
+func newRangeDecoder(in []byte) (*rangeDecoder, error) {
+	if len(in) < 5 || in[0] != 0 {
+		return nil, errLZMA
+	}
+	rc := &rangeDecoder{in: in, pos: 5, rng: 0xffffffff}
+	for _, b := range in[1:5] {
+		rc.code = rc.code<<8 | uint32(b)
+	}
+	if rc.code == rc.rng {
+		return nil, errLZMA
+	}
+	return rc, nil
+}
+
+// overrun tells if the decoder read past the end of its input.
+func (rc *rangeDecoder) overrun() bool {
+	return rc.pos > len(rc.in)
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng >= 1<<24 {
+		return
+	}
+	rc.rng <<= 8
+	rc.code <<= 8
+	if rc.pos < len(rc.in) {
+		rc.code |= uint32(rc.in[rc.pos])
+	}
+	rc.pos++
+}
+
+// bit decodes a bit of probability p, which it adapts.
+func (rc *rangeDecoder) bit(p *uint16) uint32 {
+	bound := (rc.rng >> 11) * uint32(*p)
+	var b uint32
+	if rc.code < bound {
+		*p += (1<<11 - *p) >> 5
+		rc.rng = bound
+	} else {
+		*p -= *p >> 5
+		rc.code -= bound
+		rc.rng -= bound
+		b = 1
+	}
+	rc.normalize()
+	return b
+}
+
+// direct decodes n bits of even probability.
+func (rc *rangeDecoder) direct(n int) uint32 {
+	var res uint32
+	for range n {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - rc.code>>31
+		rc.code += rc.rng & t
+		res = res<<1 + t + 1
+		rc.normalize()
+	}
+	return res
+}
+
+// tree decodes a symbol of the bit tree pp, of len(pp) symbols, highest bit first.
+func (rc *rangeDecoder) tree(pp []uint16) uint32 {
+	m := uint32(1)
+	for m < uint32(len(pp)) {
+		m = m<<1 + rc.bit(&pp[m])
+	}
+	return m - uint32(len(pp))
+}
+
+// reverse decodes a symbol of the bit tree pp, lowest bit first.
+func (rc *rangeDecoder) reverse(pp []uint16) uint32 {
+	m, sym := uint32(1), uint32(0)
+	for i := 0; 1<<i < len(pp); i++ {
+		b := rc.bit(&pp[m])
+		m = m<<1 + b
+		sym |= b << i
+	}
+	return sym
+}
+
+// lzmaLength decodes the lengths of matches.
+type lzmaLength struct {
+	choice, choice2 uint16
+	low, mid        [16][8]uint16 // by position state.
+	high            [256]uint16
+}
+
+func (l *lzmaLength) decode(rc *rangeDecoder, posState int) uint32 {
+	if rc.bit(&l.choice) == 0 {
+		return rc.tree(l.low[posState][:])
+	}
+	if rc.bit(&l.choice2) == 0 {
+		return 8 + rc.tree(l.mid[posState][:])
+	}
+	return 16 + rc.tree(l.high[:])
+}
+
+// lzmaDecoder decodes LZMA into out, the end of which is the dictionary.
+type lzmaDecoder struct {
+	lc, lp, pb int
+	out        []byte
+	dict       int // where the dictionary starts in out, positions count from there.
+	literal    []uint16
+	posSlot    [4][64]uint16
+	posDist    [115]uint16
+	align      [16]uint16
+	isMatch    [12 << 4]uint16 // by state and position state.
+	isRep      [12]uint16
+	isRepG0    [12]uint16
+	isRepG1    [12]uint16
+	isRepG2    [12]uint16
+	isRep0Long [12 << 4]uint16
+	length     lzmaLength
+	repLength  lzmaLength
+	state      int
+	rep        [4]uint32
+}
+
+// setProps takes the lc, lp and pb properties coded in byte p.
+func (d *lzmaDecoder) setProps(p byte) error {
+	if p >= 9*5*5 {
+		return errLZMA
+	}
+	d.lc, d.lp, d.pb = int(p%9), int(p/9%5), int(p/45)
+	return nil
+}
+
+// reset resets the state and the probabilities, the dictionary stays.
+func (d *lzmaDecoder) reset() {
+	d.literal = slices.Grow(d.literal[:0], 0x300<<(d.lc+d.lp))[:0x300<<(d.lc+d.lp)]
+	for _, pp := range [][]uint16{d.literal, d.posDist[:], d.align[:], d.isMatch[:], d.isRep[:],
+		d.isRepG0[:], d.isRepG1[:], d.isRepG2[:], d.isRep0Long[:]} {
+		fill(pp)
+	}
+	for i := range d.posSlot {
+		fill(d.posSlot[i][:])
+	}
+	for _, l := range []*lzmaLength{&d.length, &d.repLength} {
+		l.choice, l.choice2 = 1024, 1024
+		for i := range l.low {
+			fill(l.low[i][:])
+			fill(l.mid[i][:])
+		}
+		fill(l.high[:])
+	}
+	d.state, d.rep = 0, [4]uint32{}
+}
+
+// fill sets the probabilities pp to one half.
+func fill(pp []uint16) {
+	for i := range pp {
+		pp[i] = 1024
+	}
+}
+
+// decode decodes n bytes, or fewer if the stream has an end marker.
+func (d *lzmaDecoder) decode(rc *rangeDecoder, n int) error {
+	end := len(d.out) + n
+	for len(d.out) < end {
+		pos := len(d.out) - d.dict
+		posState := pos & (1<<d.pb - 1)
+		if rc.bit(&d.isMatch[d.state<<4+posState]) == 0 {
+			d.decodeLiteral(rc, pos)
+			switch {
+			case d.state < 4:
+				d.state = 0
+			case d.state < 10:
+				d.state -= 3
+			default:
+				d.state -= 6
+			}
+			continue
+		}
+		var length uint32
+		if rc.bit(&d.isRep[d.state]) != 0 {
+			if pos == 0 {
+				return errLZMA
+			}
+			if rc.bit(&d.isRepG0[d.state]) == 0 {
+				if rc.bit(&d.isRep0Long[d.state<<4+posState]) == 0 {
+					d.state = min(11, 9+d.state/7*2)
+					d.out = append(d.out, d.out[len(d.out)-int(d.rep[0])-1])
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.bit(&d.isRepG1[d.state]) == 0 {
+					dist = d.rep[1]
+				} else {
+					if rc.bit(&d.isRepG2[d.state]) == 0 {
+						dist = d.rep[2]
+					} else {
+						dist = d.rep[3]
+						d.rep[3] = d.rep[2]
+					}
+					d.rep[2] = d.rep[1]
+				}
+				d.rep[1] = d.rep[0]
+				d.rep[0] = dist
+			}
+			length = d.repLength.decode(rc, posState)
+			d.state = min(11, 8+d.state/7*3)
+		} else {
+			d.rep[3], d.rep[2], d.rep[1] = d.rep[2], d.rep[1], d.rep[0]
+			length = d.length.decode(rc, posState)
+			d.state = min(10, 7+d.state/7*3)
+			d.rep[0] = d.decodeDistance(rc, length)
+			if d.rep[0] == 0xffffffff {
+				// the end marker.
+				if rc.overrun() || rc.code != 0 {
+					return errLZMA
+				}
+				return nil
+			}
+			if int64(d.rep[0]) >= int64(pos) {
+				return errLZMA
+			}
+		}
+		k := int(length) + 2
+		if len(d.out)+k > end {
+			return errLZMA
+		}
+		from := len(d.out) - int(d.rep[0]) - 1
+		for i := range k {
+			d.out = append(d.out, d.out[from+i])
+		}
+	}
+	if rc.overrun() {
+		return errLZMA
+	}
+	return nil
+}
+
+func (d *lzmaDecoder) decodeLiteral(rc *rangeDecoder, pos int) {
+	prev := 0
+	if pos > 0 {
+		prev = int(d.out[len(d.out)-1])
+	}
+	lit := (pos&(1<<d.lp-1))<<d.lc + prev>>(8-d.lc)
+	pp := d.literal[0x300*lit : 0x300*(lit+1)]
+	sym := uint32(1)
+	if d.state >= 7 {
+		match := uint32(d.out[len(d.out)-int(d.rep[0])-1])
+		for sym < 0x100 {
+			mb := match >> 7 & 1
+			match <<= 1
+			b := rc.bit(&pp[(1+mb)<<8+sym])
+			sym = sym<<1 | b
+			if mb != b {
+				break
+			}
+		}
+	}
+	for sym < 0x100 {
+		sym = sym<<1 | rc.bit(&pp[sym])
+	}
+	d.out = append(d.out, byte(sym))
+}
+
+func (d *lzmaDecoder) decodeDistance(rc *rangeDecoder, length uint32) uint32 {
+	slot := rc.tree(d.posSlot[min(length, 3)][:])
+	if slot < 4 {
+		return slot
+	}
+	bits := int(slot>>1) - 1
+	dist := (2 | slot&1) << bits
+	if slot < 14 {
+		return dist + rc.reverse(d.posDist[dist-slot:dist-slot+1<<bits])
+	}
+	return dist + rc.direct(bits-4)<<4 + rc.reverse(d.align[:])
+}
+
+// unLZMA decodes LZMA stream in of size bytes, with the 5 bytes of properties props of 7z.
+func unLZMA(in, props []byte, size int) ([]byte, error) {
+	if len(props) != 5 {
+		return nil, errLZMA
+	}
+	d := &lzmaDecoder{out: make([]byte, 0, min(size, maxPrealloc))}
+	if e := d.setProps(props[0]); e != nil {
+		return nil, e
+	}
+	d.reset()
+	rc, e := newRangeDecoder(in)
+	if e != nil {
+		return nil, e
+	}
+	if e := d.decode(rc, size); e != nil {
+		return nil, e
+	}
+	if len(d.out) != size {
+		return nil, errLZMA
+	}
+	return d.out, nil
+}
+
+// unLZMA2 decodes LZMA2 stream in of size bytes, a sequence of chunks of LZMA or stored data.
+func unLZMA2(in []byte, size int) ([]byte, error) {
+	d := &lzmaDecoder{out: make([]byte, 0, min(size, maxPrealloc))}
+	for {
+		if len(in) == 0 {
+			return nil, errLZMA
+		}
+		c := in[0]
+		switch {
+		case c == 0:
+			if len(d.out) != size {
+				return nil, errLZMA
+			}
+			return d.out, nil
+		case c == 1 || c == 2:
+			if len(in) < 3 {
+				return nil, errLZMA
+			}
+			n := int(in[1])<<8 | int(in[2]) + 1
+			if c == 1 {
+				d.dict = len(d.out)
+			}
+			if in = in[3:]; len(in) < n || len(d.out)+n > size {
+				return nil, errLZMA
+			}
+			d.out = append(d.out, in[:n]...)
+			in = in[n:]
+		case c >= 0x80:
+			if len(in) < 5 {
+				return nil, errLZMA
+			}
+			un := int(c&0x1f)<<16 | int(in[1])<<8 | int(in[2]) + 1
+			packed := int(in[3])<<8 | int(in[4]) + 1
+			in = in[5:]
+			mode := c >> 5 & 3
+			if mode == 3 {
+				d.dict = len(d.out)
+			}
+			if mode >= 2 {
+				if len(in) == 0 {
+					return nil, errLZMA
+				}
+				if e := d.setProps(in[0]); e != nil || d.lc+d.lp > 4 {
+					return nil, errLZMA
+				}
+				in = in[1:]
+			}
+			switch {
+			case mode >= 1:
+				d.reset()
+			case d.literal == nil:
+				return nil, errLZMA // no properties yet.
+			}
+			if len(in) < packed || len(d.out)+un > size {
+				return nil, errLZMA
+			}
+			rc, e := newRangeDecoder(in[:packed])
+			if e != nil {
+				return nil, e
+			}
+			if e := d.decode(rc, un); e != nil {
+				return nil, e
+			}
+			in = in[packed:]
+		default:
+			return nil, errLZMA
+		}
+	}
+}
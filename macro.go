@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxMacroDepth is how deep macros may play each other, a macro playing itself stops there.
+// maxMacroKeys is how many keys one play may press in all.
+const (
+	maxMacroDepth = 20
+	maxMacroKeys  = 100000
+)
+
+// register reads the name of a macro register, a letter, and calls done with it.
+type register struct {
+	label string
+	done  func(r *Reader, reg string)
+}
+
+func (g *register) key(r *Reader, k string) bool {
+	if k == "esc" {
+		return true
+	}
+	if len(k) != 1 || k[0] < 'a' || k[0] > 'z' {
+		return false
+	}
+	// done runs with the prompt closed, a macro playing keys must not type into it.
+	r.overlay = nil
+	g.done(r, k)
+	return true
+}
+
+func (g *register) draw(r *Reader, b *strings.Builder) {
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K", r.winHeight, r.theme().status(), g.label)
+}
+
+// toggleRecording stops recording the keys of a macro, or asks for the register to record to.
+func (r *Reader) toggleRecording() {
+	if r.recording != "" {
+		keys := r.macro
+		if r.playing == 0 && len(keys) > 0 {
+			keys = keys[:len(keys)-1] // the key stopping the recording.
+		}
+		r.macros[r.recording] = keys
+		r.notice = fmt.Sprintf(tr("%d keys recorded to @%s"), len(keys), r.recording)
+		r.recording, r.macro = "", nil
+		return
+	}
+	r.overlay = &register{label: tr("record to register: "), done: func(r *Reader, reg string) {
+		r.recording, r.macro = reg, nil
+	}}
+}
+
+// askPlay asks for the register of the macro to play n times.
+func (r *Reader) askPlay(n int) {
+	label := tr("play register: ")
+	if n > 1 {
+		label = fmt.Sprintf(tr("play %d times register: "), n)
+	}
+	r.overlay = &register{label: label, done: func(r *Reader, reg string) { r.play(reg, n) }}
+}
+
+// play presses the keys of the macro in register reg n times, like they were typed.
+func (r *Reader) play(reg string, n int) {
+	keys, ok := r.macros[reg]
+	if !ok {
+		r.notice = fmt.Sprintf(tr("register @%s is empty"), reg)
+		return
+	}
+	if r.playing >= maxMacroDepth {
+		r.notice = tr("macros play each other too deep")
+		return
+	}
+	if r.playing == 0 {
+		r.played = 0
+	}
+	r.playing++
+	defer func() { r.playing-- }()
+	for range n {
+		for _, k := range keys {
+			if r.played++; r.played > maxMacroKeys {
+				r.notice = tr("macro stopped after too many keys")
+				return
+			}
+			if c := r.keyCommand(k); c != CmdExit {
+				r.exec(c)
+			}
+		}
+	}
+}
+
+// countKey adds digit key k to the count of the next command, it tells if k was one.
+func (r *Reader) countKey(k string) bool {
+	if len(k) != 1 || k[0] < '0' || k[0] > '9' || (k == "0" && r.count == 0) {
+		return false
+	}
+	r.count = min(r.count*10+int(k[0]-'0'), 9999)
+	return true
+}
@@ -1,18 +1,291 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// subcommand is a command of fish besides reading a book, like "fish split".
+type subcommand struct {
+	name  string                 // a word, or two like "stats calendar".
+	nargs int                    // arguments it takes, -1 for one or more.
+	flags func(fs *flag.FlagSet) // defines its flags, nil if it has none.
+	run   func(args []string) error
+}
+
+// the flags of the subcommands, set when parsing them.
+var (
+	splitOut string
+	pruning  pruneOptions
+	reading  readFlags
+)
+
+var subcommands = []subcommand{
+	{"reset", 1, nil, func(args []string) error {
+		fn := absPath(args[0])
+		ok, e := ResetBook(fn)
+		if e != nil {
+			return e
+		}
+		if !ok {
+			return errors.New("no saved progress for " + fn)
+		}
+		fmt.Println("progress of " + fn + " cleared")
+		return nil
+	}},
+	{"split", 1, func(fs *flag.FlagSet) {
+		fs.StringVar(&splitOut, "out", "", "")
+	}, func(args []string) error {
+		out := splitOut
+		if out == "" {
+			out = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		}
+		n, e := Split(absPath(args[0]), out)
+		if e != nil {
+			return e
+		}
+		fmt.Printf("%d files written to %s\n", n, out)
+		return nil
+	}},
+	{"convert", 2, nil, func(args []string) error {
+		fn, e := articleFile(args[0], true)
+		if e != nil {
+			return e
+		}
+		return Convert(absPath(fn), args[1])
+	}},
+	{"diff", 2, reading.define, func(args []string) error {
+		r := reading.reader(args[1])
+		r.diff = absPath(args[0])
+		return r.Run()
+	}},
+	{"parallel", 2, reading.define, func(args []string) error {
+		r := reading.reader(args[0])
+		r.parallel = absPath(args[1])
+		return r.Run()
+	}},
+	{"line", 1, reading.define, func(args []string) error {
+		r := reading.reader(args[0])
+		return r.RunLine()
+	}},
+	{"ctl", -1, nil, func(args []string) error {
+		s, e := Control(strings.Join(args, " "))
+		if e != nil {
+			return e
+		}
+		fmt.Println(s)
+		return nil
+	}},
+	{"notes", 2, nil, func(args []string) error {
+		if args[0] != "export" {
+			usage()
+		}
+		s, e := ExportNotes(absPath(args[1]))
+		if e != nil {
+			return e
+		}
+		fmt.Print(s)
+		return nil
+	}},
+	{"card", 2, nil, func(args []string) error {
+		a, b, _ := strings.Cut(args[1], "-")
+		from, e := strconv.Atoi(a)
+		to := from
+		if e == nil && b != "" {
+			to, e = strconv.Atoi(b)
+		}
+		if e != nil {
+			usage()
+		}
+		s, e := Card(absPath(args[0]), from, to)
+		if e != nil {
+			return e
+		}
+		fmt.Print(s)
+		return nil
+	}},
+	{"clippings", 1, nil, func(args []string) error {
+		s, e := ImportClippings(args[0])
+		fmt.Print(s)
+		return e
+	}},
+	{"progress", 1, func(fs *flag.FlagSet) {
+		fs.IntVar(&pruning.days, "days", 0, "")
+		fs.BoolVar(&pruning.dryRun, "dry-run", false, "")
+		fs.StringVar(&pruning.archive, "archive", "", "")
+	}, func(args []string) error {
+		var s string
+		var e error
+		switch args[0] {
+		case "prune":
+			s, e = Prune(pruning)
+		case "lock", "unlock":
+			s, e = LockProgress(args[0] == "lock")
+		default:
+			usage()
+		}
+		fmt.Print(s)
+		return e
+	}},
+	{"history", 0, nil, func([]string) error {
+		s, e := History()
+		if e != nil {
+			return e
+		}
+		fmt.Print(s)
+		return nil
+	}},
+	{"stats calendar", 0, nil, func([]string) error {
+		s, e := Calendar(time.Now())
+		if e != nil {
+			return e
+		}
+		fmt.Print(s)
+		return nil
+	}},
+	{"stats", 0, nil, func([]string) error {
+		s, e := Stats()
+		if e != nil {
+			return e
+		}
+		fmt.Print(s)
+		return nil
+	}},
+	{"remind", 0, nil, func([]string) error { return Remind() }},
+	{"version", 0, nil, func([]string) error {
+		fmt.Println(versionInfo())
+		return nil
+	}},
+}
+
+// readFlags are the flags of the commands reading a book.
+type readFlags struct {
+	listen, encoding, theme string
+	wrap, accessible, slow  bool
+	private, noSave, debug  bool
+	fs                      *flag.FlagSet
+}
+
+func (f *readFlags) define(fs *flag.FlagSet) {
+	f.fs = fs
+	fs.StringVar(&f.listen, "listen", "", "")
+	fs.StringVar(&f.encoding, "encoding", "", "")
+	fs.StringVar(&f.theme, "theme", "", "")
+	fs.BoolVar(&f.wrap, "wrap", true, "")
+	fs.BoolVar(&f.accessible, "accessible", false, "")
+	fs.BoolVar(&f.slow, "slow", false, "")
+	fs.BoolVar(&f.private, "private", false, "")
+	fs.BoolVar(&f.noSave, "no-save", false, "")
+	fs.BoolVar(&f.debug, "debug", false, "")
+}
+
+// reader returns the reader of book fn with the settings given as flags.
+func (f *readFlags) reader(fn string) *Reader {
+	r := NewReader(absPath(fn))
+	r.listen, r.private, r.noSave = f.listen, f.private, f.noSave
+	if f.debug {
+		startup = &timings{last: started}
+	}
+	r.flags = make(map[string]json.RawMessage)
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "encoding", "theme", "wrap", "accessible", "slow":
+			dd, _ := json.Marshal(fl.Value.(flag.Getter).Get())
+			r.flags[fl.Name] = dd
+		}
+	})
+	return &r
+}
+
+// parseArgs parses the flags in args, before or after the other arguments, and returns those.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if e := fs.Parse(args); e != nil {
+			return nil, e
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest, nil
+		}
+		rest, args = append(rest, args[0]), args[1:]
+	}
+}
+
 func main() {
-	if len(os.Args) <= 1 ||
-		(os.Args[1] == "--help" || os.Args[1] == "-h") {
-		printHelp()
+	args := os.Args[1:]
+	if len(args) == 0 && !piped() {
+		usage()
+	}
+	switch {
+	case len(args) == 0:
+	case args[0] == "--version", args[0] == "-v":
+		args[0] = "version"
+	case args[0] == "--reset":
+		args[0] = "reset"
+	}
+	sc := subcommand{"", 1, reading.define, func(args []string) error {
+		if args[0] == "-" {
+			// a pipe is read once, nothing is saved about it.
+			fn, e := pipedFile()
+			if e != nil {
+				return e
+			}
+			defer func() { _ = os.RemoveAll(filepath.Dir(fn)) }()
+			r := reading.reader(fn)
+			r.noSave = true
+			return r.Run()
+		}
+		fn, e := articleFile(args[0], !reading.private && !reading.noSave)
+		if e != nil {
+			return e
+		}
+		return reading.reader(fn).Run()
+	}}
+	for _, c := range subcommands {
+		if n := len(strings.Fields(c.name)); n <= len(args) && c.name == strings.Join(args[:n], " ") {
+			sc, args = c, args[n:]
+			break
+		}
+	}
+	fs := flag.NewFlagSet("fish", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if sc.flags != nil {
+		sc.flags(fs)
+	}
+	args, e := parseArgs(fs, args)
+	if errors.Is(e, flag.ErrHelp) {
+		printHelp(os.Stdout)
 		return
 	}
-	fn := os.Args[1]
+	if e != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "fish:", e)
+		usage()
+	}
+	if sc.name == "" && len(args) == 0 && piped() {
+		args = []string{"-"}
+	}
+	if sc.nargs >= 0 && len(args) != sc.nargs || sc.nargs < 0 && len(args) == 0 {
+		usage()
+	}
+	e = sc.run(args)
+	// the pages fetched by a session which saves nothing, see articleFile.
+	_ = os.RemoveAll(tempArticles)
+	if e != nil {
+		exit(e)
+	}
+}
+
+// absPath makes fn absolute against the working directory.
+func absPath(fn string) string {
 	if !filepath.IsAbs(fn) {
 		wd, e := os.Getwd()
 		if e != nil {
@@ -20,43 +293,105 @@ func main() {
 		}
 		fn = filepath.Join(wd, fn)
 	}
+	return fn
+}
 
-	r := NewReader(fn)
-	if e := r.Run(); e != nil {
-		exit(e)
-	}
+func printHelp(w io.Writer) {
+	_, _ = fmt.Fprintln(w, strings.Replace(tr(helpText), "{version}", versionInfo(), 1))
 }
 
-func printHelp() {
-	fmt.Println(`Name:
+// helpText is the text of --help, {version} is replaced by the version.
+const helpText = `Name:
   fish - A minimalist command-line reader for novels and long-form text.
 
+Version:
+  {version}
+
 Usage:
-  fish <FILE>
+  fish [flags] <FILE>
+  fish reset <FILE>
+  fish split <FILE> [--out DIR]
+  fish convert <FILE> <OUT.txt|OUT.md|->
+  fish diff [flags] <OLD> <NEW>
+  fish parallel [flags] <FILE> <TRANSLATION>
+  fish line [flags] <FILE>
+  fish ctl <COMMAND>|status
+  fish notes export <FILE>
+  fish clippings <My Clippings.txt>
+  fish card <FILE> <LINE>[-<LINE>]
+  fish progress prune [--days N] [--dry-run] [--archive FILE]
+  fish progress lock|unlock
+  fish history
+  fish stats
+  fish stats calendar
+  fish remind
+  fish version
+
+Flags:
+  --listen ADDR    serve the HTTP API on loopback address ADDR, like 127.0.0.1:7777.
+  --encoding NAME  read the book in encoding NAME, like gbk or big5.
+  --theme NAME     use theme NAME.
+  --wrap=false     do not wrap long lines.
+  --accessible     write plain lines a screen reader can follow, instead of drawing the screen.
+  --slow           repaint only what changed, for slow or flaky SSH links.
+  --private        neither read nor save the progress, leaving no history, stats or hooks.
+  --no-save        resume from the saved progress but write nothing back, for a read-only home.
+  --debug          print how long each step of opening the book took, after quitting.
+  Flags go before or after FILE and win over the config and the settings of the book.
 
 Description:
   fish reads the specified text file in the terminal.
   Your reading progress is automatically saved to: ~/.cmdline-reader-progress.
+  Settings are read from: ~/.cmdline-reader-config, press S while reading to change them.
   fish will resume from where you left off.
+  A FILE.fishlist lists files to read in order as one book, a path a line, # starts a comment.
+  A FILE like https://example.com/post or gemini://example.org/ is fetched once and kept in ~/.cmdline-reader-articles, not with --private or --no-save.
+  Text piped to fish is read without saving anything, MANPAGER=fish makes it the pager of man.
+  The HTTP API of --listen is for other programs:
+  GET /status answers the book and position as JSON, POST /command runs the command of the JSON
+  body {"command": "..."}, like next-page, prev-chapter or goto 50%. ADDR must be a loopback
+  address and requests with an Origin are refused, web pages cannot reach the API.
+  ctl runs COMMAND in the fish running in another terminal, status prints its state as JSON.
+  reset forgets everything saved about FILE, same as :reset while reading.
+  history lists the books read, the last one first, and stats sums them up.
+  stats calendar shows the minutes read each day of the past year, a column a week.
+  progress prune forgets the books whose files are gone, only those not read for N days with --days.
+  --dry-run lists them without forgetting them, --archive moves them to FILE instead.
+  progress lock encrypts the progress file with the "lock" of the config, a key file or "ask"
+  for a passphrase, and unlock writes it in plain text again.
+  notes export prints the bookmarks of FILE and their notes as Markdown, by chapter.
+  clippings makes bookmarks of the highlights and notes of a Kindle, in the books of the library
+  with their titles, at the lines their text is found.
+  card prints the lines of FILE framed as a card to share, with the title and author.
+  split writes each chapter of FILE to its own file in DIR, the book name by default.
+  convert writes FILE as plain text or Markdown, - writes text to the standard output.
+  diff reads NEW with the lines changed since OLD marked, ] and [ go to the next and previous change.
+  parallel reads FILE with TRANSLATION beside it, paragraph by paragraph.
+  The pairs of paragraph numbers in FILE.align realign them where they drift apart.
+  remind stays running and sends a desktop notification at the "remind" time of the config,
+  like "20:00", on the days no book was read by then. Start it with your desktop session.
+  line reads FILE a row at a time on the line of the cursor, without taking the screen,
+  enter or space shows the next row, up the previous one and q clears the line.
 
 Examples:
   fish story.txt
-  fish ~/books/novel.txt`)
-}
-
-// exit gentle quit with any message.
-func exit(i ...any) {
-	if len(i) > 0 {
-		switch i[0].(type) {
-		case string:
-			si := i[0].(string)
-			fmt.Println(si)
-		case error:
-			ei := i[0].(error)
-			fmt.Println(ei.Error())
-		default:
-			fmt.Println(i)
-		}
-	}
-	os.Exit(0)
+  fish ~/books/novel.txt
+  fish --listen 127.0.0.1:7777 novel.txt
+  fish --encoding gbk --wrap=false novel.txt
+  fish split novel.txt --out chapters/
+  fish convert book.epub book.md
+  fish notes export novel.txt > novel-notes.md
+  fish diff draft1.txt draft2.txt
+  fish parallel novel.txt novel.en.txt`
+
+// usage reports wrong arguments on the standard error and quits with status 2.
+func usage() {
+	_, _ = fmt.Fprintln(os.Stderr, tr("usage: fish [flags] <FILE>, fish --help lists the flags and commands"))
+	os.Exit(2)
+}
+
+// exit reports error e on the standard error and quits with status 1.
+func exit(e error) {
+	_, _ = fmt.Fprintln(os.Stderr, "fish:", e)
+	os.Exit(1)
 }
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Man pages are read from their roff sources, like ls.1 or printf.3p, or as man writes them to
+// a pager, with characters overstruck for bold and underline. Both become lines with SGR
+// sequences, and the section headers become chapters.
+
+// manExt matches the extensions of man page sources.
+var manExt = regexp.MustCompile(`^\.([1-9][a-z]*|man|roff)$`)
+
+// The fonts of man pages as SGR sequences, italic is underlined as man does.
+const (
+	manBold      = "\x1b[1m"
+	manUnderline = "\x1b[4m"
+	manRoman     = "\x1b[0m"
+)
+
+// manDocument converts man page s, nil if it is neither roff nor overstruck text.
+func manDocument(s string) *document {
+	if strings.Contains(s, "\b") {
+		return overstrikeDocument(s)
+	}
+	if roffSource.MatchString(s) {
+		return roffDocument(s)
+	}
+	return nil
+}
+
+// roffSource matches a line of the man macros every page has.
+// The mdoc macros of BSD pages are not read, those are plain text.
+var roffSource = regexp.MustCompile(`(?m)^[.'](TH|SH)\s`)
+
+// overstrikeDocument converts text formatted for a printer, where "c\bc" is a bold c and
+// "_\bc" an underlined one. Unindented lines are sections, bold ones indented by three spaces
+// subsections, except the header and footer of the page.
+func overstrikeDocument(s string) *document {
+	d := newDocument()
+	d.styled = true
+	ss := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	first, last := -1, -1
+	for i, l := range ss {
+		if strings.TrimSpace(l) != "" {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	for i, l := range ss {
+		var bold bool
+		ss[i], bold = overstrike(l)
+		plain := stripControls(ss[i], false)
+		t := strings.TrimSpace(plain)
+		switch {
+		case t == "" || i == first || i == last:
+		case plain[0] != ' ':
+			d.headings = append(d.headings, chapter{line: i, level: 1, title: t})
+		case bold && strings.HasPrefix(plain, "   ") && plain[3] != ' ':
+			d.headings = append(d.headings, chapter{line: i, level: 2, title: t})
+		}
+	}
+	d.lines = lineIndexOf(ss)
+	return d
+}
+
+// overstrike returns line l with its overstruck characters as SGR sequences, and if all of its
+// text is bold.
+func overstrike(l string) (string, bool) {
+	if !strings.Contains(l, "\b") {
+		return l, false
+	}
+	type cell struct {
+		c               rune
+		bold, underline bool
+	}
+	var cc []cell
+	rr := []rune(l)
+	for i := 0; i < len(rr); i++ {
+		if rr[i] != '\b' || len(cc) == 0 || i+1 == len(rr) {
+			cc = append(cc, cell{c: rr[i]})
+			continue
+		}
+		i++
+		p := &cc[len(cc)-1]
+		switch {
+		case p.c == '_' && rr[i] != '_':
+			p.c, p.underline = rr[i], true
+		case rr[i] == '_' && p.c != '_':
+			p.underline = true
+		case p.c == rr[i]:
+			p.bold = true
+		default:
+			p.c = rr[i]
+		}
+	}
+	var b strings.Builder
+	var bold, underline bool
+	all := true
+	for _, c := range cc {
+		if c.bold != bold || c.underline != underline {
+			if bold || underline {
+				b.WriteString(manRoman)
+			}
+			if c.bold {
+				b.WriteString(manBold)
+			}
+			if c.underline {
+				b.WriteString(manUnderline)
+			}
+			bold, underline = c.bold, c.underline
+		}
+		if !c.bold && !unicode.IsSpace(c.c) {
+			all = false
+		}
+		b.WriteRune(c.c)
+	}
+	if bold || underline {
+		b.WriteString(manRoman)
+	}
+	return b.String(), all
+}
+
+// roffWriter converts the man macros of roff into lines, a paragraph each.
+type roffWriter struct {
+	d      *document
+	lines  []string // the lines written, the document indexes them when done.
+	line   strings.Builder
+	font   string // SGR of the font in effect, "" for roman.
+	prev   string // the font before, \fP returns to it.
+	nofill bool   // lines are kept as they are, between .nf and .fi.
+	tag    bool   // the next line of text is the tag of a .TP paragraph.
+	next   string // font of the next line of text, for .B and .I without arguments.
+	title  int    // level of the heading on the next line of text, for .SH without arguments.
+	link   *link  // the link of .UR until .UE.
+	skip   string // the line ending a block skipped, like ".." of .de.
+	depth  int    // depth of the \{ \} blocks skipped.
+	cont   string // a line ending in a backslash, the next line goes on it.
+	orElse bool   // the condition of the last .ie was false, its .el is read.
+}
+
+// roffDocument converts roff source s of a man page.
+func roffDocument(s string) *document {
+	d := newDocument()
+	d.styled = true
+	w := &roffWriter{d: d}
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		w.input(l)
+	}
+	w.flush()
+	d.lines = lineIndexOf(w.lines)
+	return d
+}
+
+// input handles line l of the source, a request or text.
+func (w *roffWriter) input(l string) {
+	l, w.cont = w.cont+l, ""
+	if strings.HasSuffix(l, `\`) && !strings.HasSuffix(l, `\\`) {
+		w.cont = l[:len(l)-1]
+		return
+	}
+	if w.depth > 0 {
+		w.depth += strings.Count(l, `\{`) - strings.Count(l, `\}`)
+		return
+	}
+	if w.skip != "" {
+		if strings.TrimSpace(l) == w.skip {
+			w.skip = ""
+		}
+		return
+	}
+	if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+		w.request(strings.TrimLeft(l[1:], " \t"))
+		return
+	}
+	switch {
+	case w.nofill:
+		w.text(l)
+		w.flushLine()
+	case strings.TrimSpace(l) == "":
+		w.paragraph()
+	default:
+		if strings.HasPrefix(l, " ") {
+			w.flush()
+		}
+		w.text(l)
+	}
+	w.endTag()
+}
+
+// endTag ends the line of the tag of a .TP paragraph, after its text.
+func (w *roffWriter) endTag() {
+	if w.tag {
+		w.tag = false
+		w.flush()
+	}
+}
+
+// request handles the request or macro call l, without its leading dot.
+func (w *roffWriter) request(l string) {
+	name, rest := l, ""
+	if i := strings.IndexAny(l, " \t\\"); i >= 0 {
+		// .el\{ has no space before its block.
+		name, rest = l[:i], l[i:]
+	}
+	args := roffArgs(rest)
+	switch name {
+	case "TH":
+		if len(args) >= 2 {
+			w.d.meta.Title = args[0] + "(" + args[1] + ")"
+		}
+	case "SH", "SS":
+		w.paragraph()
+		w.title = 1
+		if name == "SS" {
+			w.title = 2
+		}
+		if len(args) > 0 {
+			w.heading(strings.Join(args, " "))
+		}
+	case "PP", "LP", "P", "HP":
+		w.paragraph()
+	case "TP":
+		w.paragraph()
+		w.tag = true
+	case "TQ":
+		w.flush()
+		w.tag = true
+	case "IP":
+		w.paragraph()
+		if len(args) > 0 && args[0] != "" {
+			w.text(args[0])
+			w.flush()
+		}
+	case "B", "I", "SB", "SM":
+		font := map[string]string{"B": manBold, "SB": manBold, "I": manUnderline}[name]
+		if len(args) == 0 {
+			w.next = font
+			return
+		}
+		w.styled([]string{strings.Join(args, " ")}, font, font)
+		w.endTag()
+	case "BR", "RB", "BI", "IB", "IR", "RI":
+		fonts := map[byte]string{'B': manBold, 'I': manUnderline, 'R': ""}
+		w.styled(args, fonts[name[0]], fonts[name[1]])
+		w.endTag()
+	case "br":
+		w.flush()
+	case "sp":
+		w.paragraph()
+	case "nf", "EX":
+		w.flush()
+		w.nofill = true
+	case "fi", "EE":
+		w.nofill = false
+	case "SY":
+		w.paragraph()
+		w.styled(args, manBold, manBold)
+	case "OP":
+		if len(args) > 0 {
+			w.space()
+			w.inline(`[\fB` + args[0] + `\fR`)
+			if len(args) > 1 {
+				w.inline(` \fI` + args[1] + `\fR`)
+			}
+			w.inline("]")
+		}
+	case "YS":
+		w.flush()
+	case "UR", "MT":
+		if len(args) > 0 {
+			target := roffPlain.Replace(args[0])
+			if name == "MT" {
+				target = "mailto:" + target
+			}
+			w.space()
+			w.link = &link{start: w.line.Len(), target: target}
+		}
+	case "UE", "ME":
+		if w.link != nil {
+			if w.line.Len() == w.link.start {
+				w.line.WriteString(strings.TrimPrefix(w.link.target, "mailto:"))
+			}
+			w.link.end = w.line.Len()
+			i := len(w.lines)
+			w.d.links[i] = append(w.d.links[i], *w.link)
+			w.link = nil
+			w.inline(strings.Join(args, ""))
+		}
+	case "de", "de1", "am", "ig":
+		w.skip = ".."
+		if name == "ig" && len(args) > 0 {
+			w.skip = "." + args[0]
+		}
+	case "if", "ie":
+		// only the conditions n and t are known, n being true as the page is read in a terminal.
+		cond, body, _ := strings.Cut(strings.TrimLeft(rest, " \t"), " ")
+		ok := cond == "n" || cond == "!t"
+		if name == "ie" {
+			w.orElse = cond == "t" || cond == "!n"
+		}
+		w.cond(ok, body)
+	case "el":
+		w.cond(w.orElse, rest)
+		w.orElse = false
+	}
+}
+
+// cond reads body, the rest of a condition line, if ok is true, otherwise it is skipped with the
+// block it opens.
+func (w *roffWriter) cond(ok bool, body string) {
+	if !ok {
+		w.depth = strings.Count(body, `\{`) - strings.Count(body, `\}`)
+		return
+	}
+	body = strings.TrimLeft(body, " \t")
+	if body = strings.TrimPrefix(body, `\{`); strings.TrimSpace(body) != "" {
+		w.input(body)
+	}
+}
+
+// heading writes section title t in bold on its own line, as a chapter.
+func (w *roffWriter) heading(t string) {
+	level := w.title
+	w.title = 0
+	w.flush()
+	start := len(w.lines)
+	if level == 2 {
+		w.line.WriteString("   ")
+	}
+	w.styled([]string{t}, manBold, manBold)
+	w.flush()
+	if t := strings.TrimSpace(stripControls(strings.Join(w.lines[start:], " "), false)); t != "" {
+		w.d.headings = append(w.d.headings, chapter{line: start, level: level, title: t})
+	}
+}
+
+// styled writes the arguments of a font macro, joined and in the fonts a and b by turns.
+func (w *roffWriter) styled(args []string, a, b string) {
+	w.space()
+	for i, s := range args {
+		font := a
+		if i%2 == 1 {
+			font = b
+		}
+		w.setFont(font)
+		w.inline(s)
+	}
+	w.setFont("")
+}
+
+// space separates the text to come from the text before on the line, as input lines are
+// joined in fill mode.
+func (w *roffWriter) space() {
+	if s := w.line.String(); s != "" && !strings.HasSuffix(s, " ") && !w.nofill {
+		w.line.WriteByte(' ')
+	}
+}
+
+// text writes line s of text, after the heading or font the request before asked for.
+func (w *roffWriter) text(s string) {
+	if w.title > 0 {
+		w.heading(strings.TrimSpace(s))
+		return
+	}
+	if !w.nofill {
+		w.space()
+		s = strings.TrimLeft(s, " ")
+	}
+	if w.next != "" {
+		font := w.next
+		w.next = ""
+		w.styled([]string{s}, font, font)
+		return
+	}
+	w.inline(s)
+}
+
+// setFont switches to the font of SGR sequence f, "" for roman.
+func (w *roffWriter) setFont(f string) {
+	if f == w.font {
+		return
+	}
+	if w.font != "" {
+		w.line.WriteString(manRoman)
+	}
+	w.line.WriteString(f)
+	w.prev, w.font = w.font, f
+}
+
+// roffGlyphs are the special characters of \(xx and \[name].
+var roffGlyphs = map[string]string{
+	"em": "—", "en": "–", "hy": "-", "mi": "−", "bu": "•", "co": "©", "rg": "®", "tm": "™",
+	"lq": "“", "rq": "”", "oq": "‘", "cq": "’", "aq": "'", "dq": `"`, "ga": "`", "ha": "^",
+	"ti": "~", "rs": `\`, "sl": "/", "ba": "|", "or": "|", "de": "°", "mu": "×", "di": "÷",
+	"+-": "±", "<=": "≤", ">=": "≥", "!=": "≠", "->": "→", "<-": "←", "da": "↓", "ua": "↑",
+	"Fo": "«", "Fc": "»", "fo": "‹", "fc": "›", "sc": "§", "ps": "¶", "dg": "†", "ct": "¢",
+	"Eu": "€", "eu": "€", "Po": "£", "Ye": "¥", "aa": "´", "at": "@", "sh": "#", "Do": "$",
+	"ss": "ß", "ru": "_", "ul": "_", "lB": "[", "rB": "]", "lC": "{", "rC": "}",
+}
+
+// roffStrings are the predefined strings of \*x and \*(xx of the man macros.
+var roffStrings = map[string]string{"R": "®", "Tm": "™", "lq": "“", "rq": "”"}
+
+// inline writes text s with its escapes resolved and its font changes as SGR sequences.
+func (w *roffWriter) inline(s string) {
+	name := func(i int) (string, int) {
+		// the name after an escape at s[i]: x, (xx or [name].
+		switch {
+		case i >= len(s):
+			return "", i
+		case s[i] == '(' && i+2 < len(s):
+			return s[i+1 : i+3], i + 3
+		case s[i] == '(':
+			return "", len(s)
+		case s[i] == '[':
+			if j := strings.IndexByte(s[i:], ']'); j > 0 {
+				return s[i+1 : i+j], i + j + 1
+			}
+			return "", len(s)
+		}
+		return s[i : i+1], i + 1
+	}
+	for i := 0; i < len(s); {
+		if s[i] != '\\' || i+1 == len(s) {
+			w.line.WriteByte(s[i])
+			i++
+			continue
+		}
+		c := s[i+1]
+		i += 2
+		switch c {
+		case 'f':
+			var f string
+			f, i = name(i)
+			switch f {
+			case "B", "3":
+				w.setFont(manBold)
+			case "I", "2":
+				w.setFont(manUnderline)
+			case "BI", "4":
+				w.setFont(manBold + manUnderline)
+			case "P":
+				w.setFont(w.prev)
+			default:
+				w.setFont("")
+			}
+		case '(', '[':
+			var g string
+			g, i = name(i - 1)
+			if t, ok := roffGlyphs[g]; ok {
+				w.line.WriteString(t)
+			} else if strings.HasPrefix(g, "u") && len(g) >= 5 {
+				var r rune
+				for _, h := range g[1:] {
+					r = r<<4 | rune(strings.IndexRune("0123456789ABCDEF", unicode.ToUpper(h)))
+				}
+				w.line.WriteRune(r)
+			}
+		case '*':
+			var t string
+			t, i = name(i)
+			w.line.WriteString(roffStrings[t])
+		case 'n', 'g', 'k', 'V', 'Y', 'F', 'm', 'M':
+			_, i = name(i)
+		case 's':
+			// \s+1, \s-1, \s0, \s(12, \s[12]
+			if i < len(s) && (s[i] == '+' || s[i] == '-') {
+				i++
+			}
+			if i < len(s) && (s[i] == '(' || s[i] == '[') {
+				_, i = name(i)
+			}
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+		case 'h', 'v', 'w', 'o', 'l', 'L', 'D', 'x', 'b', 'A', 'B', 'C', 'N', 'R', 'X', 'Z':
+			// an argument in quotes, drawing or measuring, which is left out.
+			if i < len(s) {
+				if j := strings.IndexByte(s[i+1:], s[i]); j >= 0 {
+					i += j + 2
+				} else {
+					i = len(s)
+				}
+			}
+		case '"', '#':
+			i = len(s)
+		case '-':
+			w.line.WriteByte('-')
+		case 'e', '\\':
+			w.line.WriteByte('\\')
+		case ' ', '~', '0', 't':
+			w.line.WriteByte(' ')
+		case '&', '|', '^', '%', 'c', 'z', ')', ':', '/', ',', 'p', 'a', 'd', 'u', 'r', '{', '}':
+		case '$':
+			_, i = name(i)
+		default:
+			w.line.WriteByte(c)
+		}
+	}
+}
+
+// roffPlain drops the escapes found in addresses, which only hint where to break.
+var roffPlain = strings.NewReplacer(`\:`, "", `\&`, "", `\%`, "", `\-`, "-", `\~`, "", `\.`, ".")
+
+// roffArgs splits the arguments of a request, "quoted ones" may have spaces.
+func roffArgs(s string) []string {
+	var args []string
+	for s = strings.TrimLeft(s, " \t"); s != ""; s = strings.TrimLeft(s, " \t") {
+		if strings.HasPrefix(s, `\"`) {
+			break
+		}
+		if s[0] == '"' {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				args, s = append(args, s[1:]), ""
+				continue
+			}
+			args, s = append(args, s[1:end+1]), s[end+2:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			if s[i] == '\\' {
+				i++ // "\ " is a space in the argument.
+			}
+			i++
+		}
+		i = min(i, len(s))
+		args, s = append(args, s[:i]), s[i:]
+	}
+	return args
+}
+
+// paragraph ends the paragraph with a blank line after it, none follows a heading.
+func (w *roffWriter) paragraph() {
+	w.flush()
+	n := len(w.lines)
+	if h := len(w.d.headings); h > 0 && w.d.headings[h-1].line == n-1 {
+		return
+	}
+	if n > 0 && w.lines[n-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+// flush ends the current line if it has text.
+func (w *roffWriter) flush() {
+	if strings.TrimSpace(stripControls(w.line.String(), false)) == "" {
+		w.line.Reset()
+		if w.font != "" {
+			w.line.WriteString(w.font)
+		}
+		return
+	}
+	w.flushLine()
+}
+
+// flushLine ends the current line, the font in effect goes on at the start of the next one.
+func (w *roffWriter) flushLine() {
+	s := strings.TrimRight(w.line.String(), " ")
+	if w.font != "" {
+		s += manRoman
+	}
+	w.lines = append(w.lines, s)
+	w.line.Reset()
+	if w.font != "" {
+		w.line.WriteString(w.font)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Meta is what is known about a book besides its text.
+type Meta struct {
+	Title    string `json:"title,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// Lines near the start of a text file which name the title, author or language,
+// the way Project Gutenberg and Chinese novel sites put them.
+var (
+	metaTitle    = regexp.MustCompile(`(?i)^(title|书名|書名)\s*[:：]\s*(.+)$`)
+	metaAuthor   = regexp.MustCompile(`(?i)^(author|作者|著)\s*[:：]\s*(.+)$`)
+	metaBy       = regexp.MustCompile(`(?i)^by\s+(\S.{0,40})$`)
+	metaLanguage = regexp.MustCompile(`(?i)^(language|语言|語言)\s*[:：]\s*(.+)$`)
+	metaBookName = regexp.MustCompile(`《([^》]{1,40})》`)
+)
+
+// metaHead is the number of non-empty lines the heuristics look at.
+const metaHead = 30
+
+// textMeta guesses the metadata of plain text file f from its first lines and its file name.
+func textMeta(f string, ll lineIndex) Meta {
+	var m Meta
+	n := 0
+	for _, l := range ll.all() {
+		if n >= metaHead {
+			break
+		}
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+		n++
+		if s := metaTitle.FindStringSubmatch(l); s != nil && m.Title == "" {
+			m.Title = strings.Trim(s[2], "《》 ")
+		} else if s := metaAuthor.FindStringSubmatch(l); s != nil && m.Author == "" {
+			m.Author = strings.TrimSpace(s[2])
+		} else if s := metaBy.FindStringSubmatch(l); s != nil && m.Author == "" && n <= 5 {
+			m.Author = strings.TrimSpace(s[1])
+		} else if s := metaLanguage.FindStringSubmatch(l); s != nil && m.Language == "" {
+			m.Language = strings.TrimSpace(s[2])
+		} else if s := metaBookName.FindStringSubmatch(l); s != nil && m.Title == "" && n <= 3 {
+			m.Title = s[1]
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	if s := metaBookName.FindStringSubmatch(name); s != nil {
+		if m.Title == "" {
+			m.Title = s[1]
+		}
+		if a, ok := strings.CutPrefix(strings.TrimSpace(name[strings.Index(name, "》")+len("》"):]), "作者"); ok && m.Author == "" {
+			m.Author = strings.TrimLeft(a, ":： ")
+		}
+	} else if t, a, ok := strings.Cut(name, " - "); ok {
+		if m.Title == "" {
+			m.Title = strings.TrimSpace(t)
+		}
+		if m.Author == "" {
+			m.Author = strings.TrimSpace(a)
+		}
+	}
+	if m.Language == "" {
+		m.Language = guessLanguage(ll)
+	}
+	return m
+}
+
+// guessLanguage tells languages apart by script, Latin text is taken as English
+// or German only if it reads like them.
+func guessLanguage(ll lineIndex) string {
+	var han, kana, hangul, latin, the, der int
+	for i, l := range ll.all() {
+		if i >= 500 {
+			break
+		}
+		for _, c := range l {
+			switch {
+			case unicode.Is(unicode.Hiragana, c), unicode.Is(unicode.Katakana, c):
+				kana++
+			case unicode.Is(unicode.Hangul, c):
+				hangul++
+			case unicode.Is(unicode.Han, c):
+				han++
+			case c < 0x80 && unicode.IsLetter(c):
+				latin++
+			}
+		}
+		for _, w := range strings.Fields(strings.ToLower(l)) {
+			switch w {
+			case "the", "and", "of":
+				the++
+			case "der", "die", "und":
+				der++
+			}
+		}
+	}
+	switch {
+	case kana > 0 && kana*10 > han:
+		return "ja"
+	case hangul > han && hangul > latin/2:
+		return "ko"
+	case han > latin/2 && han > 0:
+		return "zh"
+	case latin > 0 && the*200 > latin && the >= der:
+		return "en"
+	case latin > 0 && der*200 > latin:
+		return "de"
+	}
+	return ""
+}
+
+// title returns the title of the current book, its file name if it has none.
+func (r *Reader) title() string {
+	if r.doc != nil && r.doc.meta.Title != "" {
+		return r.doc.meta.Title
+	}
+	return filepath.Base(r.f)
+}
+
+// openInfo shows what is known about the current book.
+func (r *Reader) openInfo() {
+	m := r.doc.meta
+	unknown := func(s string) string {
+		if s == "" {
+			return tr("unknown")
+		}
+		return s
+	}
+	fields := [][2]string{
+		{"Title", r.title()},
+		{"Author", unknown(m.Author)},
+		{"Language", unknown(m.Language)},
+		{"File", r.f},
+		{"Lines", strconv.Itoa(r.totalLine)},
+		{"Chapters", strconv.Itoa(len(r.chapters))},
+		{"Progress", fmt.Sprintf("%.2f%%", r.percent())},
+	}
+	if s := r.book().Seconds; s > 0 {
+		fields = append(fields, [2]string{"Time read", readTime(s)})
+	}
+	if n := r.book().Pomodoros; n > 0 {
+		fields = append(fields, [2]string{"Pomodoros", strconv.Itoa(n)})
+	}
+	w := 0
+	for _, f := range fields {
+		w = max(w, strWidth(tr(f[0])))
+	}
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		name := tr(f[0])
+		lines[i] = name + ": " + strings.Repeat(" ", w-strWidth(name)) + f[1]
+	}
+	r.overlay = &box{title: "Book", lines: lines, book: r.f, meta: m}
+}
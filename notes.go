@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxQuoteLines is how many lines of the paragraph at a bookmark ExportNotes quotes.
+const maxQuoteLines = 5
+
+// ExportNotes returns the bookmarks of book f and their notes as Markdown, under the headings
+// of the chapters they are in, each with the text it marks and its line and percentage.
+func ExportNotes(f string) (string, error) {
+	c, b, e := loadBookConfig(f)
+	if e != nil {
+		return "", e
+	}
+	book, e := LoadBook(f)
+	if e != nil {
+		return "", e
+	}
+	if len(book.Marks) == 0 {
+		return "", fmt.Errorf("no bookmarks in %s, m marks the page while reading", filepath.Base(f))
+	}
+	d, e := loadDocument(f, c.Encoding)
+	if e != nil {
+		return "", e
+	}
+	if d, e = c.filter(d, b); e != nil {
+		return "", e
+	}
+	title := d.meta.Title
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	}
+	var sb strings.Builder
+	sb.WriteString("# " + title + "\n")
+	if d.meta.Author != "" {
+		sb.WriteString("\n" + d.meta.Author + "\n")
+	}
+	cc := d.chapters()
+	last := -1
+	for _, m := range book.Marks {
+		i := d.view(m.Line)
+		ch := -1
+		for k, h := range cc {
+			if h.line <= i {
+				ch = k
+			}
+		}
+		if ch != last && ch >= 0 {
+			sb.WriteString("\n## " + strings.TrimSpace(stripControls(cc[ch].title, false)) + "\n")
+		}
+		last = ch
+		var quote []string
+		for k := i; k < min(i+maxQuoteLines, d.lines.len()); k++ {
+			l := strings.TrimSpace(stripControls(d.lines.at(k), false))
+			if l == "" {
+				break
+			}
+			quote = append(quote, l)
+		}
+		sb.WriteString("\n> " + strings.Join(quote, "\n> ") + "\n")
+		if m.Note != "" {
+			sb.WriteString("\n" + m.Note + "\n")
+		}
+		percent := 0.0
+		if d.lines.len() > 0 {
+			percent = float64(i) / float64(d.lines.len()) * 100
+		}
+		_, _ = fmt.Fprintf(&sb, "\n*line %d, %.0f%%*\n", m.Line+1, percent)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Org files are notes of headings by stars, "* Chapter" and "** Section", with markup like
+// *bold* and [[target][links]]. The headings become chapters, the markup SGR sequences, and the
+// drawers of properties and logs folds which start collapsed.
+
+var (
+	orgHeading = regexp.MustCompile(`^(\*+)\s+(.*?)\s*$`)
+	orgTags    = regexp.MustCompile(`\s+(:[\w@#%:]+:)$`)
+	orgKeyword = regexp.MustCompile(`^#\+(\w+):\s*(.*?)\s*$`)
+	orgBlock   = regexp.MustCompile(`(?i)^\s*#\+(begin|end)_(\w+)`)
+	orgDrawer  = regexp.MustCompile(`^\s*:([\w-]+):\s*$`)
+	orgTarget  = regexp.MustCompile(`<<([^<>]+)>>`)
+)
+
+// The SGR sequences of the markers of Org emphasis, verbatim and code are bold like in HTML.
+var orgFonts = map[byte]string{'*': manBold, '/': "\x1b[3m", '_': manUnderline, '+': "\x1b[9m", '=': manBold, '~': manBold}
+
+// orgDocument converts Org file s. Headings up to the third level are chapters, without their
+// TODO keyword, priority and tags.
+func orgDocument(s string) *document {
+	d := newDocument()
+	d.styled = true
+	d.folds = make(map[int]fold)
+	todo := map[string]bool{"TODO": true, "DONE": true}
+	block, drawer := "", -1
+	var lb lineBuilder
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		i := lb.len()
+		if m := orgBlock.FindStringSubmatch(l); m != nil {
+			if strings.EqualFold(m[1], "begin") {
+				block = strings.ToLower(m[2])
+			} else {
+				block = ""
+			}
+			continue
+		}
+		switch block {
+		case "src", "example", "export":
+			lb.add(l)
+			continue
+		case "comment":
+			continue
+		}
+		if drawer >= 0 {
+			if strings.EqualFold(strings.TrimSpace(l), ":END:") {
+				n, what := i-drawer-1, "lines"
+				if n == 1 {
+					what = "line"
+				}
+				d.folds[drawer] = fold{end: i, level: 1, items: n, closed: true, summary: fmt.Sprintf("… %d %s :END:", n, what)}
+				drawer = -1
+			} else if k, v, ok := strings.Cut(strings.TrimSpace(l), " "); ok && strings.EqualFold(k, ":CUSTOM_ID:") && len(d.headings) > 0 {
+				d.anchors["#"+strings.TrimSpace(v)] = d.headings[len(d.headings)-1].line
+			}
+			lb.add(l)
+			continue
+		}
+		if m := orgKeyword.FindStringSubmatch(l); m != nil {
+			switch strings.ToUpper(m[1]) {
+			case "TITLE":
+				t := orgInline(d, i, m[2], manBold, len(manBold))
+				lb.add(manBold + t + manRoman)
+				d.meta.Title = stripControls(t, false)
+			case "AUTHOR":
+				d.meta.Author = m[2]
+			case "LANGUAGE":
+				d.meta.Language = m[2]
+			case "TODO", "SEQ_TODO", "TYP_TODO":
+				for _, k := range strings.Fields(m[2]) {
+					if k, _, _ = strings.Cut(k, "("); k != "|" {
+						todo[k] = true
+					}
+				}
+			}
+			continue
+		}
+		switch t := strings.TrimSpace(l); {
+		case strings.HasPrefix(t, "#") && (len(t) == 1 || t[1] == ' '):
+			// comments.
+		case orgHeading.MatchString(l):
+			m := orgHeading.FindStringSubmatch(l)
+			level, title := len(m[1]), orgTags.ReplaceAllString(m[2], "")
+			kw, rest, _ := strings.Cut(title, " ")
+			if todo[kw] {
+				title = strings.TrimSpace(rest)
+			} else {
+				kw = ""
+			}
+			if strings.HasPrefix(title, "[#") && len(title) > 3 && title[3] == ']' {
+				title = strings.TrimSpace(title[4:])
+			}
+			prefix := manBold
+			if kw != "" {
+				prefix += kw + " "
+			}
+			t := orgInline(d, i, title, manBold, len(prefix))
+			lb.add(prefix + t + manRoman)
+			if plain := stripControls(t, false); plain != "" {
+				if level <= 3 {
+					d.headings = append(d.headings, chapter{line: i, level: level, title: plain})
+				}
+				if _, ok := d.anchors["*"+plain]; !ok {
+					d.anchors["*"+plain] = i
+				}
+			}
+		case orgDrawer.MatchString(l) && !strings.EqualFold(t, ":END:"):
+			drawer = i
+			lb.add(l)
+		case strings.HasPrefix(t, ": ") || t == ":":
+			// fixed-width lines.
+			lb.add(strings.Replace(l, ":", " ", 1))
+		default:
+			indent := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+			if strings.HasPrefix(t, "- ") || strings.HasPrefix(t, "+ ") {
+				indent, t = indent+"• ", t[2:]
+			}
+			lb.add(indent + orgInline(d, i, t, "", len(indent)))
+		}
+	}
+	d.lines = lb.index()
+	if drawer >= 0 {
+		// a drawer without :END: is text.
+		delete(d.folds, drawer)
+	}
+	for k, l := range d.anchors {
+		// [[Heading]] leads to a heading as well as [[*Heading]], unless a <<target>> is named so.
+		if t, ok := strings.CutPrefix(k, "*"); ok {
+			if _, ok := d.anchors[t]; !ok {
+				d.anchors[t] = l
+			}
+		}
+	}
+	return d
+}
+
+// orgInline converts the markup of text s at offset off of line i, its links are added to d.
+// on is the SGR sequence s is shown in, restored after each emphasis ends.
+func orgInline(d *document, i int, s, on string, off int) string {
+	var b strings.Builder
+	for j := 0; j < len(s); {
+		if strings.HasPrefix(s[j:], "[[") {
+			if end := strings.Index(s[j:], "]]"); end > 0 {
+				target, label, ok := strings.Cut(s[j+2:j+end], "][")
+				if !ok {
+					label = strings.TrimLeft(strings.TrimPrefix(target, "file:"), "*#")
+				}
+				start := off + b.Len()
+				b.WriteString(label)
+				if target != "" {
+					d.links[i] = append(d.links[i], link{start: start, end: off + b.Len(), target: orgLink(target)})
+				}
+				j += end + 2
+				continue
+			}
+		}
+		if m := orgTarget.FindStringSubmatchIndex(s[j:]); m != nil && m[0] == 0 {
+			name := s[j+m[2] : j+m[3]]
+			d.anchors[name] = i
+			b.WriteString(name)
+			j += m[1]
+			continue
+		}
+		c := s[j]
+		if font, ok := orgFonts[c]; ok && orgOpens(s, j) {
+			if k := orgCloses(s, j); k > 0 {
+				inner := s[j+1 : k]
+				b.WriteString(font)
+				if c == '=' || c == '~' {
+					b.WriteString(inner)
+				} else {
+					b.WriteString(orgInline(d, i, inner, on+font, off+b.Len()))
+				}
+				b.WriteString(manRoman + on)
+				j = k + 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+		j++
+	}
+	return b.String()
+}
+
+// orgOpens tells if the marker at s[j] can open an emphasis: after a space, some punctuation or
+// the start, and before no space.
+func orgOpens(s string, j int) bool {
+	if j+1 >= len(s) || unicode.IsSpace(rune(s[j+1])) {
+		return false
+	}
+	if j == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(s[:j])
+	return unicode.IsSpace(r) || strings.ContainsRune(`-({'"`, r)
+}
+
+// orgCloses returns the index of the marker closing the one at s[j], 0 if none.
+func orgCloses(s string, j int) int {
+	for k := j + 2; k < len(s); k++ {
+		if s[k] != s[j] || unicode.IsSpace(rune(s[k-1])) {
+			continue
+		}
+		if k+1 == len(s) {
+			return k
+		}
+		if r, _ := utf8.DecodeRuneInString(s[k+1:]); unicode.IsSpace(r) || strings.ContainsRune(`-.,;:!?'")}[\`, r) {
+			return k
+		}
+	}
+	return 0
+}
+
+// orgLink returns the target of an Org link as a link.target: "*Heading", "#custom-id" and
+// <<target>> names are anchors, "file:" links to files as their path.
+func orgLink(target string) string {
+	if t, ok := strings.CutPrefix(target, "file:"); ok {
+		if path, _, _ := strings.Cut(t, "::"); path != "" {
+			return path
+		}
+	}
+	return target
+}
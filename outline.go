@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// outline is the sidebar of the headings, left of the page. While it is the overlay it takes
+// the keys, tab gives them back to the book and it stays open following the page.
+type outline struct {
+	sel       int          // chapter selected.
+	top       int          // first item shown.
+	collapsed map[int]bool // chapter:its subheadings are hidden.
+}
+
+// outlineWidth is the columns of the sidebar with its border, 0 if it is closed.
+func (r *Reader) outlineWidth() int {
+	if r.outline == nil {
+		return 0
+	}
+	return min(32, r.winWidth/3)
+}
+
+// openOutline opens the outline at the chapter of the page, or takes the keys to it if it is open.
+func (r *Reader) openOutline() {
+	if len(r.chapters) == 0 {
+		r.notice = tr("no chapters found")
+		return
+	}
+	if r.outline == nil {
+		r.outline = &outline{sel: max(0, r.chapterAt(r.currentLine)), collapsed: make(map[int]bool)}
+	}
+	r.overlay = r.outline
+}
+
+// subheadings tells if heading i has headings of lower levels under it.
+func (r *Reader) subheadings(i int) bool {
+	return i+1 < len(r.chapters) && r.chapters[i+1].level > r.chapters[i].level
+}
+
+// items are the chapters shown, those under collapsed headings aside.
+func (o *outline) items(r *Reader) []int {
+	var ii []int
+	hide := 0 // subheadings of a collapsed heading of this level are hidden, 0 for none.
+	for i, c := range r.chapters {
+		if hide > 0 && c.level > hide {
+			continue
+		}
+		hide = 0
+		ii = append(ii, i)
+		if o.collapsed[i] {
+			hide = c.level
+		}
+	}
+	return ii
+}
+
+func (o *outline) key(r *Reader, k string) bool {
+	ii := o.items(r)
+	at := max(0, slices.Index(ii, o.sel))
+	switch k {
+	case "down", "j":
+		o.sel = ii[min(at+1, len(ii)-1)]
+	case "up", "k":
+		o.sel = ii[max(at-1, 0)]
+	case "enter":
+		r.jump(r.chapters[o.sel].line)
+	case "right", "l":
+		delete(o.collapsed, o.sel)
+	case "left", "h":
+		if r.subheadings(o.sel) && !o.collapsed[o.sel] {
+			o.collapsed[o.sel] = true
+			break
+		}
+		// up to the heading it is under.
+		for i := o.sel - 1; i >= 0; i-- {
+			if r.chapters[i].level < r.chapters[o.sel].level {
+				o.sel = i
+				break
+			}
+		}
+	case "tab", "o":
+		return true
+	case "esc", "q":
+		r.outline = nil
+		return true
+	}
+	return false
+}
+
+func (o *outline) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	s := tr("-- OUTLINE -- enter jumps, ←→ fold, tab to the book, esc closes")
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K%s", r.winHeight, t.status(), cut(s, r.winWidth-1), t.base())
+}
+
+// outlineRows are the n rows of the sidebar. The selected heading is reversed while the outline
+// has the keys, else the chapter of the page is selected.
+func (r *Reader) outlineRows(n int) []string {
+	o, w := r.outline, r.outlineWidth()-2
+	focused := r.overlay == o
+	if !focused {
+		o.sel = max(0, r.chapterAt(r.currentLine))
+	}
+	ii := o.items(r)
+	at := slices.Index(ii, o.sel)
+	if at < 0 {
+		// under a collapsed heading, which is selected instead.
+		at = max(0, sortedBefore(ii, o.sel))
+	}
+	o.top = max(0, min(o.top, at), at-n+1)
+	t := r.theme()
+	rows := make([]string, n)
+	for j := range rows {
+		s := ""
+		if k := o.top + j; k < len(ii) {
+			c := r.chapters[ii[k]]
+			sign := "  "
+			if r.subheadings(ii[k]) {
+				sign = "▾ "
+				if o.collapsed[ii[k]] {
+					sign = "▸ "
+				}
+			}
+			s = cut(strings.Repeat("  ", max(0, c.level-1))+sign+stripControls(c.title, false), w)
+		}
+		s += strings.Repeat(" ", max(0, w-strWidth(s)))
+		switch {
+		case o.top+j != at:
+		case focused:
+			s = "\x1b[7m" + s + "\x1b[27m"
+		default:
+			s = "\x1b[1m" + s + "\x1b[22m"
+		}
+		rows[j] = s + t.mark() + "│ " + t.base()
+	}
+	return rows
+}
+
+// sortedBefore is the index of the last of the ascending ii below i, -1 if none is.
+func sortedBefore(ii []int, i int) int {
+	j, _ := slices.BinarySearch(ii, i)
+	return j - 1
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// overlay is a screen drawn over the page, it takes all keys until closed.
+type overlay interface {
+	// key handles key k, returns true when the overlay should be closed.
+	key(r *Reader, k string) bool
+	// draw appends the overlay to frame b, after the page has been drawn.
+	draw(r *Reader, b *strings.Builder)
+}
+
+// drawBox draws a bordered box with lines at the center of the screen. Line sel is highlighted
+// and kept visible, sel < 0 selects nothing. It returns the screen position of the first line,
+// 0, 0 if the screen is too small.
+func (r *Reader) drawBox(b *strings.Builder, title string, lines []string, sel int, foot string) (int, int) {
+	t := r.theme()
+	title, foot = tr(title), tr(foot)
+	if r.cfg.Accessible {
+		drawList(b, title, lines, sel, foot)
+		return 0, 0
+	}
+	w := strWidth(title) + 2
+	for _, l := range append(lines, foot) {
+		if lw := strWidth(l); lw > w {
+			w = lw
+		}
+	}
+	if w > r.winWidth-4 {
+		w = r.winWidth - 4
+	}
+	h := len(lines)
+	if h > r.winHeight-4 {
+		h = r.winHeight - 4
+	}
+	if w < 1 || h < 0 {
+		return 0, 0
+	}
+	top := max(0, min(sel-h/2, len(lines)-h))
+	y := (r.winHeight-h-2)/2 + 1
+	x := (r.winWidth-w-2)/2 + 1
+	x0, y0 := x+1, y+1
+	row := func(s string) {
+		_, _ = fmt.Fprintf(b, "\x1b[%d;%dH%s", y, x, t.status())
+		b.WriteString(s)
+		y++
+	}
+	title = cut(title, w-2)
+	row("┌─" + title + strings.Repeat("─", w-1-strWidth(title)) + "┐")
+	for i := top; i < top+h; i++ {
+		l := cut(lines[i], w)
+		l += strings.Repeat(" ", w-strWidth(l))
+		if i == sel {
+			l = "\x1b[7m" + l + t.status()
+		}
+		row("│" + l + "│")
+	}
+	if foot != "" {
+		foot = cut(foot, w)
+		row("├" + strings.Repeat("─", w) + "┤")
+		row("│" + foot + strings.Repeat(" ", w-strWidth(foot)) + "│")
+	}
+	row("└" + strings.Repeat("─", w) + "┘")
+	b.WriteString(t.base())
+	return x0, y0
+}
+
+// drawCoverBox draws a box like drawBox with the cover of book f left of the lines.
+func (r *Reader) drawCoverBox(b *strings.Builder, title string, lines []string, sel int, foot, f string, m Meta) {
+	cols, rows := r.coverSize()
+	if cols == 0 {
+		r.drawBox(b, title, lines, sel, foot)
+		return
+	}
+	pad := strings.Repeat(" ", cols+1)
+	ll := make([]string, max(len(lines), rows))
+	for i := range ll {
+		ll[i] = pad
+		if i < len(lines) {
+			ll[i] += lines[i]
+		}
+	}
+	if x, y := r.drawBox(b, title, ll, sel, foot); x > 0 && len(ll) <= r.winHeight-4 {
+		r.drawCover(b, x, y, cols, rows, f, m)
+	}
+}
+
+// choice is a one-line question in the status bar, answer returns true when k answers it.
+type choice struct {
+	text   string
+	answer func(r *Reader, k string) bool
+}
+
+func (c *choice) key(r *Reader, k string) bool {
+	return c.answer(r, k)
+}
+
+func (c *choice) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K%s", r.winHeight, t.status(), cut(c.text, r.winWidth-1), t.base())
+}
+
+// box shows lines until any key is pressed, with the cover of book if it is set.
+type box struct {
+	title string
+	lines []string
+	book  string
+	meta  Meta
+}
+
+func (x *box) key(*Reader, string) bool {
+	return true
+}
+
+func (x *box) draw(r *Reader, b *strings.Builder) {
+	if x.book != "" {
+		r.drawCoverBox(b, x.title, x.lines, -1, "", x.book, x.meta)
+		return
+	}
+	r.drawBox(b, x.title, x.lines, -1, "")
+}
+
+// list is an overlay to choose one of items, pick is called with the chosen index.
+type list struct {
+	title string
+	items []string
+	sel   int
+	foot  string
+	pick  func(r *Reader, i int)
+}
+
+func (l *list) key(r *Reader, k string) bool {
+	page := r.winHeight - 5
+	switch k {
+	case "up":
+		l.sel--
+	case "down":
+		l.sel++
+	case "pgup", "left":
+		l.sel -= page
+	case "pgdn", "right":
+		l.sel += page
+	case "home":
+		l.sel = 0
+	case "end":
+		l.sel = len(l.items) - 1
+	case "enter":
+		if l.pick != nil && len(l.items) > 0 {
+			l.pick(r, l.sel)
+		}
+		return true
+	case "esc", "q":
+		return true
+	}
+	l.sel = max(0, min(l.sel, len(l.items)-1))
+	return false
+}
+
+func (l *list) draw(r *Reader, b *strings.Builder) {
+	r.drawBox(b, l.title, l.items, l.sel, l.foot)
+}
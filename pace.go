@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+	"unicode"
+)
+
+// paceTurns is how many of the last page turns the reading speed is measured over.
+const paceTurns = 8
+
+// pace measures the reading speed from the page turns, for the wpm status field.
+type pace struct {
+	f     string    // the book measured.
+	line  int       // top line at the last turn.
+	at    time.Time // when it was.
+	turns []turn    // the last paceTurns turns, the oldest first.
+}
+
+// turn is a page turn forward, past words read in took.
+type turn struct {
+	words int
+	took  time.Duration
+}
+
+// words counts the words of line s. Chinese and Japanese have no spaces, every character is taken
+// for a word as reading speeds of those languages are given in characters.
+func words(s string) int {
+	n, in := 0, false
+	for _, c := range stripControls(s, false) {
+		switch {
+		case unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			n++
+			in = false
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			if !in {
+				n++
+			}
+			in = true
+		case c != '\'' && c != '’' && c != '-':
+			in = false
+		}
+	}
+	return n
+}
+
+// measurePace takes the move since the last command for a page turn if the top line went forward
+// at most a page. Jumps, moves back and turns after being away don't count.
+func (r *Reader) measurePace() {
+	p := &r.pace
+	now := time.Now()
+	if p.f != r.f {
+		*p = pace{f: r.f, line: r.currentLine, at: now}
+		return
+	}
+	if r.currentLine == p.line {
+		return
+	}
+	if from := p.line; r.currentLine > from && r.currentLine-from <= max(1, r.shown) && now.Sub(p.at) < idleAfter {
+		t := turn{took: now.Sub(p.at)}
+		for i := from; i < r.currentLine && i < r.index.len(); i++ {
+			t.words += words(r.index.at(i))
+		}
+		p.turns = append(p.turns, t)
+		if len(p.turns) > paceTurns {
+			p.turns = p.turns[1:]
+		}
+	}
+	p.line, p.at = r.currentLine, now
+}
+
+// rate is the words read a minute over the last page turns, 0 before the first one.
+func (p *pace) rate() float64 {
+	var n int
+	var d time.Duration
+	for _, t := range p.turns {
+		n, d = n+t.words, d+t.took
+	}
+	if d < time.Second {
+		return 0
+	}
+	return float64(n) / d.Minutes()
+}
+
+// wpm is the rate for the status bar, "" before the first page turn.
+func (r *Reader) wpm() string {
+	if w := r.pace.rate(); w > 0 {
+		return fmt.Sprintf(tr("%d wpm"), int(w+0.5))
+	}
+	return ""
+}
+
+// chapterLeft tells how long the rest of the chapter takes at the rate, like "~8 min to chapter end",
+// or how many lines it has before the rate is known. It is "" outside chapters.
+func (r *Reader) chapterLeft() string {
+	i := r.chapterAt(r.currentLine)
+	if i < 0 {
+		return ""
+	}
+	end := r.chapterEnd(i)
+	w := r.pace.rate()
+	if w == 0 {
+		return fmt.Sprintf(tr("%d lines to chapter end"), max(0, end-r.currentLine))
+	}
+	n := 0
+	for _, l := range r.index.slice(min(r.currentLine, end), end).all() {
+		n += words(l)
+	}
+	return fmt.Sprintf(tr("~%d min to chapter end"), int(math.Ceil(float64(n)/w)))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// pageWords is the words of a virtual page, about those of a printed one. Virtual pages
+// depend on the text only, so a page number is the same place at any window size.
+const pageWords = 250
+
+// wordCounts is the words of the base document before each of its lines, counted the first
+// time they are needed rather than before the first page.
+func (r *Reader) wordCounts() []int {
+	if r.wordsBefore == nil {
+		r.countWords()
+	}
+	return r.wordsBefore
+}
+
+// countWords counts the words of the base document before each of its lines.
+func (r *Reader) countWords() {
+	lines := r.base.lines
+	r.wordsBefore = make([]int, lines.len()+1)
+	// the chunks count the words of their lines, the sums are taken after.
+	eachChunk(lines.len(), func(from, to int) struct{} {
+		for i := from; i < to; i++ {
+			r.wordsBefore[i+1] = words(lines.at(i))
+		}
+		return struct{}{}
+	})
+	for i := range lines.len() {
+		r.wordsBefore[i+1] += r.wordsBefore[i]
+	}
+}
+
+// pages is the virtual pages of the book.
+func (r *Reader) pages() int {
+	wb := r.wordCounts()
+	return max(1, (wb[len(wb)-1]+pageWords-1)/pageWords)
+}
+
+// pageAt is the virtual page line i is on, counted from 1.
+func (r *Reader) pageAt(i int) int {
+	b := r.base.view(r.doc.source(i))
+	wb := r.wordCounts()
+	return min(wb[min(b, len(wb)-1)]/pageWords+1, r.pages())
+}
+
+// gotoPage jumps to the line virtual page n starts at.
+func (r *Reader) gotoPage(arg string) error {
+	n, e := strconv.Atoi(arg)
+	if e != nil || n < 1 {
+		return errors.New(tr("usage: :page <number>"))
+	}
+	if n > r.pages() {
+		return fmt.Errorf(tr("the book has %d pages"), r.pages())
+	}
+	// the first line starting on the page.
+	b := sort.SearchInts(r.wordCounts(), (n-1)*pageWords)
+	r.jump(r.doc.view(r.base.source(min(b, max(0, r.base.lines.len()-1)))))
+	return nil
+}
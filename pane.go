@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// paneHeights returns the rows of the upper and lower pane, the upper one only if the screen is not split.
+// A separator row is between them.
+func (r *Reader) paneHeights() (int, int) {
+	rows := r.pageRows()
+	if !r.split {
+		return rows, 0
+	}
+	up := (rows - 1) / 2
+	return up, rows - 1 - up
+}
+
+// pageHeight returns the rows of the pane being read.
+func (r *Reader) pageHeight() int {
+	up, down := r.paneHeights()
+	if r.lower {
+		return down
+	}
+	return up
+}
+
+// toggleSplit splits the screen into two panes showing the same place, or goes back to one.
+func (r *Reader) toggleSplit() {
+	r.split = !r.split
+	if !r.split {
+		r.lower = false
+		return
+	}
+	if r.winHeight < 8 {
+		r.split = false
+		r.notice = tr("the window is too small to split")
+		return
+	}
+	r.other = r.currentLine
+	r.notice = tr("w switches panes, W closes the other one")
+}
+
+// switchPane makes the other pane the one being read.
+func (r *Reader) switchPane() {
+	if !r.split {
+		r.notice = tr("the screen is not split, W splits it")
+		return
+	}
+	r.currentLine, r.other = r.other, r.currentLine
+	r.lower = !r.lower
+	r.displayBreakMark = false
+}
+
+// layoutPanes returns the rows of both panes and the separator between them, which points at
+// the pane being read, and the number of lines fully displayed in that pane.
+func (r *Reader) layoutPanes() ([]string, int) {
+	up, down := r.paneHeights()
+	if !r.split {
+		return r.layoutPage(r.currentLine, up)
+	}
+	top, bottom := r.currentLine, r.other
+	if r.lower {
+		top, bottom = r.other, r.currentLine
+	}
+	tr, ts := r.layoutPage(top, up)
+	br, bs := r.layoutPage(bottom, down)
+	tr = append(tr, make([]string, up-len(tr))...)
+	arrow := "▲"
+	if r.lower {
+		arrow = "▼"
+	}
+	sep := fmt.Sprintf("─%s─ other pane %d/%d ", arrow, r.other, r.totalLine)
+	t := r.theme()
+	rows := append(tr, t.mark()+sep+strings.Repeat("─", max(0, r.winWidth-r.outlineWidth()-strWidth(sep)))+t.base())
+	if r.lower {
+		return append(rows, br...), bs
+	}
+	return append(rows, br...), ts
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// isProse tells if d is running text, not code, data, a table or subtitles.
+func isProse(d *document) bool {
+	return !d.json && d.cols == nil && !d.styled && d.cues == nil
+}
+
+// lineParagraphs tells if each line of d is a paragraph, as in markup books and most web novels,
+// rather than paragraphs of several lines with blank lines between them.
+func lineParagraphs(d *document) bool {
+	if d.paras {
+		return true
+	}
+	gaps := 0 // blank lines before text.
+	for i := 1; i < d.lines.len(); i++ {
+		if strings.TrimSpace(d.lines.at(i-1)) == "" && strings.TrimSpace(d.lines.at(i)) != "" {
+			gaps++
+		}
+	}
+	return gaps*10 < d.lines.len()
+}
+
+// blankLine tells if line i of the document is empty or out of it.
+func (r *Reader) blankLine(i int) bool {
+	return i < 0 || i >= r.totalLine || strings.TrimSpace(stripControls(r.doc.lines.at(i), false)) == ""
+}
+
+// paragraph returns line i of text s indented by Config.Indent if it starts a paragraph,
+// and the number of blank rows of Config.Spacing to leave after it. Headings are not indented.
+func (r *Reader) paragraph(i int, s string) (string, int) {
+	if !r.prose || r.poetry() || r.blankLine(i) || i < r.doc.header() {
+		return s, 0
+	}
+	heading := slices.ContainsFunc(r.chapters, func(c chapter) bool { return c.line == i })
+	if r.cfg.Indent > 0 && !heading && r.paragraphStart(i) {
+		s = strings.Repeat(" ", r.cfg.Indent) + strings.TrimLeft(s, " \t　")
+	}
+	if r.lineParas && !r.blankLine(i+1) {
+		return s, r.cfg.Spacing
+	}
+	return s, 0
+}
+
+// paragraphStart tells if line i is the first of a paragraph.
+func (r *Reader) paragraphStart(i int) bool {
+	return !r.blankLine(i) && (r.lineParas || r.blankLine(i-1))
+}
+
+// moveParagraph turns the page to the start of the next paragraph, or of the one before if back is set.
+func (r *Reader) moveParagraph(back bool) {
+	step := 1
+	if back {
+		step = -1
+	}
+	for i := r.currentLine + step; i >= 0 && i < r.totalLine; i += step {
+		if r.paragraphStart(i) {
+			r.currentLine = i
+			return
+		}
+	}
+}
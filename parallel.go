@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// paragraph is a paragraph of a document, line is its first line.
+type paragraph struct {
+	line int
+	text string
+}
+
+// paragraphs returns the paragraphs of d. Markup has a paragraph on every line, text separates
+// them by blank lines unless it has hardly any, then every line is one.
+func paragraphs(d *document) []paragraph {
+	blank := 0
+	for _, s := range d.lines.all() {
+		if strings.TrimSpace(s) == "" {
+			blank++
+		}
+	}
+	perLine := d.paras || blank*10 < d.lines.len()-blank
+	var pp []paragraph
+	open := false
+	for i, s := range d.lines.all() {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "":
+			open = false
+		case perLine || !open:
+			pp = append(pp, paragraph{i, s})
+			open = true
+		default:
+			p := &pp[len(pp)-1]
+			if c, _ := utf8.DecodeLastRuneInString(p.text); !cjk(c) {
+				p.text += " "
+			}
+			p.text += s
+		}
+	}
+	return pp
+}
+
+// alignPath returns the path of the alignment file of book f, see loadAlignment.
+func alignPath(f string) string {
+	return f + ".align"
+}
+
+// loadAlignment reads the alignment file of book f. Every line has the numbers of two paragraphs,
+// counted from 1, which start together: of f, then of its translation. '#' starts a comment.
+// A missing file is no error and aligns nothing.
+func loadAlignment(f string) ([][2]int, error) {
+	fd, e := os.Open(alignPath(f))
+	if os.IsNotExist(e) {
+		return nil, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = fd.Close() }()
+	var aa [][2]int
+	sc := bufio.NewScanner(fd)
+	for n := 1; sc.Scan(); n++ {
+		s, _, _ := strings.Cut(sc.Text(), "#")
+		ff := strings.Fields(s)
+		if len(ff) == 0 {
+			continue
+		}
+		if len(ff) != 2 {
+			return nil, fmt.Errorf("%s:%d: want two paragraph numbers", alignPath(f), n)
+		}
+		a, e1 := strconv.Atoi(ff[0])
+		b, e2 := strconv.Atoi(ff[1])
+		if e1 != nil || e2 != nil || a < 1 || b < 1 {
+			return nil, fmt.Errorf("%s:%d: want two paragraph numbers", alignPath(f), n)
+		}
+		aa = append(aa, [2]int{a - 1, b - 1})
+	}
+	sort.Slice(aa, func(i, j int) bool { return aa[i][0] < aa[j][0] })
+	return aa, sc.Err()
+}
+
+// parallelDocument returns a and its translation b side by side, a line for every pair of
+// paragraphs. Paragraphs are paired in order, starting over at the pairs of align.
+// Positions in it are lines of a.
+func parallelDocument(a, b *document, align [][2]int) *document {
+	pa, pb := paragraphs(a), paragraphs(b)
+	n := newDocument()
+	n.meta = a.meta
+	n.right = []string{}
+	n.origin = []int{}
+	var lb lineBuilder
+	add := func(l, r string, src int) {
+		if lb.len() > 0 {
+			lb.add("")
+			n.right, n.origin = append(n.right, ""), append(n.origin, src)
+		}
+		lb.add(l)
+		n.right, n.origin = append(n.right, r), append(n.origin, src)
+	}
+	ia, ib := 0, 0
+	for _, x := range append(align, [2]int{len(pa), len(pb)}) {
+		ea, eb := min(max(x[0], ia), len(pa)), min(max(x[1], ib), len(pb))
+		for ia < ea || ib < eb {
+			l, r, src := "", "", a.source(a.lines.len())
+			if ia < ea {
+				l, src = pa[ia].text, a.source(pa[ia].line)
+				ia++
+			} else if ia < len(pa) {
+				src = a.source(pa[ia].line)
+			}
+			if ib < eb {
+				r = pb[ib].text
+				ib++
+			}
+			add(l, r, src)
+		}
+	}
+	n.lines = lb.index()
+	return n
+}
+
+// parallelWith returns d side by side with the translation being read along, filtered by the same rules.
+func (r *Reader) parallelWith(d *document, rr Rules) *document {
+	td, e := loadDocument(r.parallel, r.cfg.Encoding)
+	if e == nil {
+		td, e = r.cfg.filter(td, rr)
+	}
+	var aa [][2]int
+	if e == nil {
+		aa, e = loadAlignment(r.f)
+	}
+	if e != nil {
+		r.notice = e.Error()
+		return d
+	}
+	return parallelDocument(d, td, aa)
+}
+
+// layoutPair returns the rows of text l and its translation t side by side.
+func (r *Reader) layoutPair(l, t string) []string {
+	w := max(1, (r.textWidth()-3)/2)
+	ll, tt := r.layoutText(l, w), r.layoutText(t, w)
+	rows := make([]string, max(len(ll), len(tt)))
+	base := r.theme().base()
+	for i := range rows {
+		s := ""
+		if i < len(ll) {
+			s = ll[i]
+		}
+		rows[i] = s + base + strings.Repeat(" ", max(0, w-strWidth(s))) + " " + r.theme().mark() + "│" + base + " "
+		if i < len(tt) {
+			rows[i] += tt[i]
+		}
+	}
+	return rows
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// piped tells if text is piped to fish instead of a book being named, like `man ls | fish` or
+// fish being $MANPAGER.
+func piped() bool {
+	return !term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// pipedPath is the file pipedFile saved the piped text in, "" if nothing was piped.
+var pipedPath string
+
+// pipedFile saves the text piped to fish in a file in a new temporary directory, and reads the
+// keys from the terminal from then on. Pages from man, which sets $MAN_PN like "ls(1)", are
+// named after it.
+func pipedFile() (string, error) {
+	dd, e := io.ReadAll(os.Stdin)
+	if e != nil {
+		return "", e
+	}
+	tty, e := os.Open("/dev/tty")
+	if e != nil {
+		return "", e
+	}
+	os.Stdin = tty
+	name := "stdin.txt"
+	if pn := strings.Trim(nameJunk.ReplaceAllString(os.Getenv("MAN_PN"), "."), "."); pn != "" {
+		name = pn + ".man"
+	}
+	dir, e := os.MkdirTemp("", "fish-")
+	if e != nil {
+		return "", e
+	}
+	f := filepath.Join(dir, name)
+	if e := os.WriteFile(f, dd, 0600); e != nil {
+		_ = os.RemoveAll(dir)
+		return "", e
+	}
+	pipedPath = absPath(f) // as the reader names it.
+	return f, nil
+}
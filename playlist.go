@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// playlistExt is the extension of playlists, which list the files of a book in reading order.
+const playlistExt = ".fishlist"
+
+// playlistFiles returns the files of playlist f, one path a line relative to the playlist.
+// Blank lines and lines starting with # are left out.
+func playlistFiles(f string) ([]string, error) {
+	fd, e := os.Open(f)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = fd.Close() }()
+	var ff []string
+	sc := bufio.NewScanner(fd)
+	for n := 1; sc.Scan(); n++ {
+		l := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		p, e := homePath(l)
+		if e != nil {
+			return nil, e
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(f), p)
+		}
+		if strings.EqualFold(filepath.Ext(p), playlistExt) {
+			return nil, fmt.Errorf("%s:%d: playlists cannot list playlists", filepath.Base(f), n)
+		}
+		ff = append(ff, p)
+	}
+	if e := sc.Err(); e != nil {
+		return nil, e
+	}
+	if len(ff) == 0 {
+		return nil, errors.New(filepath.Base(f) + ": the playlist lists no files")
+	}
+	return ff, nil
+}
+
+// loadPlaylist reads the files of playlist f one after the other as one book, with one progress.
+func loadPlaylist(f, enc string) (*document, error) {
+	ff, e := playlistFiles(f)
+	if e != nil {
+		return nil, e
+	}
+	return joinParts(f, ff, func(i int) (*document, error) { return loadDocument(ff[i], enc) })
+}
+
+// joinParts joins the documents of the files ff, read by load, into book f. Each file is
+// a chapter titled like it, the headings in the file are under it.
+func joinParts(f string, ff []string, load func(i int) (*document, error)) (*document, error) {
+	d := newDocument()
+	d.meta = textMeta(f, lineIndex{})
+	d.paras = true
+	var lb lineBuilder
+	for i, p := range ff {
+		part, e := load(i)
+		if e != nil {
+			return nil, e
+		}
+		off := lb.len()
+		title := part.meta.Title
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		}
+		d.headings = append(d.headings, chapter{line: off, level: 1, title: title})
+		for _, c := range part.chapters() {
+			d.headings = append(d.headings, chapter{line: off + c.line, level: c.level + 1, title: c.title})
+		}
+		for k, l := range part.anchors {
+			d.anchors[k] = off + l
+		}
+		for l, ll := range part.links {
+			d.links[off+l] = ll
+		}
+		d.paras = d.paras && part.paras
+		d.styled = d.styled || part.styled
+		if d.meta.Author == "" {
+			d.meta.Author = part.meta.Author
+		}
+		if d.meta.Language == "" {
+			d.meta.Language = part.meta.Language
+		}
+		for _, s := range part.lines.all() {
+			lb.add(s)
+		}
+		if !part.paras {
+			lb.add("")
+		}
+	}
+	d.lines = lb.index()
+	return d, nil
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Plugin is an extension program in Config.Plugins. fish starts it with its command line and
+// speaks JSON-RPC 2.0 to it, a message per line on its standard input and output:
+//
+//	initialize {}                        → {"commands": [names], "filter": bool, "status": bool}
+//	command    {"name", "arg", "status"} → {"notice": text, "run": command line for fish}
+//	filter     {"file", "lines"}         → {"lines": the lines, null hides one}
+//	status     {"status"}                → {"text": shown in the plugins status field}
+//
+// Only the methods initialize answered for are called.
+type Plugin struct {
+	Name    string `json:"name"`
+	Command string `json:"command"` // run by sh.
+}
+
+const (
+	// pluginTimeout is how long fish waits for an answer, a plugin taking longer is stopped.
+	pluginTimeout = 2 * time.Second
+	// maxPluginDepth is how deep plugin commands may run each other, through the commands they answer.
+	maxPluginDepth = 8
+	// pluginStatusEvery is how often the status plugins are asked again while the state stays the same.
+	pluginStatusEvery = time.Second
+)
+
+// plugin is a running Plugin.
+type plugin struct {
+	Plugin
+	mu       sync.Mutex // held through a call, status calls run in the background.
+	process  *os.Process
+	cmd      *exec.Cmd
+	in       io.WriteCloser
+	answers  chan rpcAnswer
+	id       int
+	commands []string
+	filter   bool
+	status   bool
+}
+
+type rpcAnswer struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// startPlugin starts p and asks what it extends.
+func startPlugin(p Plugin) (*plugin, error) {
+	c := exec.Command("sh", "-c", p.Command)
+	in, e := c.StdinPipe()
+	if e != nil {
+		return nil, e
+	}
+	out, e := c.StdoutPipe()
+	if e != nil {
+		return nil, e
+	}
+	if e := c.Start(); e != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.Name, e)
+	}
+	pl := &plugin{Plugin: p, process: c.Process, cmd: c, in: in, answers: make(chan rpcAnswer, 1)}
+	go func() {
+		sc := bufio.NewScanner(out)
+		sc.Buffer(nil, 64<<20)
+		for sc.Scan() {
+			var a rpcAnswer
+			if json.Unmarshal(sc.Bytes(), &a) == nil {
+				pl.answers <- a
+			}
+		}
+		close(pl.answers)
+	}()
+	var caps struct {
+		Commands []string `json:"commands"`
+		Filter   bool     `json:"filter"`
+		Status   bool     `json:"status"`
+	}
+	if e := pl.call("initialize", struct{}{}, &caps); e != nil {
+		pl.stop()
+		return nil, e
+	}
+	pl.commands, pl.filter, pl.status = caps.Commands, caps.Filter, caps.Status
+	return pl, nil
+}
+
+// call calls method of p with params and decodes the result into res.
+func (p *plugin) call(method string, params, res any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		return fmt.Errorf("plugin %s stopped", p.Name)
+	}
+	p.id++
+	dd, e := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": p.id, "method": method, "params": params})
+	if e != nil {
+		return e
+	}
+	if _, e := p.in.Write(append(dd, '\n')); e != nil {
+		p.stop()
+		return fmt.Errorf("plugin %s: %w", p.Name, e)
+	}
+	timeout := time.After(pluginTimeout)
+	for {
+		select {
+		case a, ok := <-p.answers:
+			if !ok {
+				p.stop()
+				return fmt.Errorf("plugin %s quit", p.Name)
+			}
+			if a.ID != p.id {
+				continue // the answer to a call which timed out.
+			}
+			if a.Error != nil {
+				return fmt.Errorf("plugin %s: %s", p.Name, a.Error.Message)
+			}
+			if e := json.Unmarshal(a.Result, res); e != nil {
+				return fmt.Errorf("plugin %s: %w", p.Name, e)
+			}
+			return nil
+		case <-timeout:
+			p.stop()
+			return fmt.Errorf("plugin %s did not answer %s", p.Name, method)
+		}
+	}
+}
+
+// stop ends p, it is not called any more. The caller holds p.mu.
+func (p *plugin) stop() {
+	if p.cmd == nil {
+		return
+	}
+	_ = p.in.Close()
+	_ = p.cmd.Process.Kill()
+	go func(c *exec.Cmd) { _ = c.Wait() }(p.cmd)
+	p.cmd = nil
+}
+
+// startPlugins starts the plugins of the config in the background, the first page does not wait
+// for them. The main loop takes them when they answered, and loads the book again if one filters
+// it. The ones which fail are reported in the notice.
+func (r *Reader) startPlugins() {
+	config := r.conf.Plugins
+	if len(config) == 0 {
+		return
+	}
+	go func() {
+		var pp []*plugin
+		var ee []error
+		for _, p := range config {
+			pl, e := startPlugin(p)
+			if e != nil {
+				ee = append(ee, e)
+				continue
+			}
+			pp = append(pp, pl)
+		}
+		taken := r.call(func(r *Reader) {
+			r.plugins = pp
+			if e := errors.Join(ee...); e != nil {
+				r.notice = strings.ReplaceAll(e.Error(), "\n", "; ")
+			}
+			if slices.ContainsFunc(pp, func(p *plugin) bool { return p.filter }) {
+				r.reload()
+			}
+		})
+		if !taken {
+			// fish quit while they started.
+			for _, p := range pp {
+				p.mu.Lock()
+				p.stop()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// stopPlugins ends all plugins.
+func (r *Reader) stopPlugins() {
+	for _, p := range r.plugins {
+		_ = p.process.Kill() // ends a status call waiting for it.
+		p.mu.Lock()
+		p.stop()
+		p.mu.Unlock()
+	}
+}
+
+// pluginCommand runs command name of a plugin, it tells if a plugin has the command.
+func (r *Reader) pluginCommand(name, arg string) (bool, error) {
+	for _, p := range r.plugins {
+		if !slices.Contains(p.commands, name) {
+			continue
+		}
+		if r.pluginDepth >= maxPluginDepth {
+			return true, fmt.Errorf("plugin %s: commands run each other too deep", p.Name)
+		}
+		r.pluginDepth++
+		defer func() { r.pluginDepth-- }()
+		var res struct {
+			Notice string `json:"notice"`
+			Run    string `json:"run"`
+		}
+		if e := p.call("command", map[string]any{"name": name, "arg": arg, "status": r.status()}, &res); e != nil {
+			return true, e
+		}
+		if res.Notice != "" {
+			r.notice = res.Notice
+		}
+		if res.Run != "" && res.Run != name {
+			return true, r.command(res.Run)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// pluginFilter returns d as the filter plugins change it.
+func (r *Reader) pluginFilter(d *document) (*document, error) {
+	var ee []error
+	for _, p := range r.plugins {
+		if !p.filter {
+			continue
+		}
+		var res struct {
+			Lines []*string `json:"lines"`
+		}
+		if e := p.call("filter", map[string]any{"file": r.f, "lines": d.lines.strings()}, &res); e != nil {
+			ee = append(ee, e)
+			continue
+		}
+		if len(res.Lines) != d.lines.len() {
+			ee = append(ee, fmt.Errorf("plugin %s: %d lines for %d", p.Name, len(res.Lines), d.lines.len()))
+			continue
+		}
+		d = d.filter(func(i int, _ string) bool { return res.Lines[i] != nil })
+		kept := slices.DeleteFunc(res.Lines, func(s *string) bool { return s == nil })
+		var lb lineBuilder
+		for _, s := range kept {
+			lb.add(*s)
+		}
+		n := *d
+		n.lines = lb.index()
+		d = &n
+	}
+	return d, errors.Join(ee...)
+}
+
+// updatePluginStatus asks the status plugins for their status bar text, if the status bar shows it.
+// They are asked in the background, one update at a time, when the state changed or
+// pluginStatusEvery after the last update. The page is drawn again when they answered.
+func (r *Reader) updatePluginStatus() {
+	if !slices.Contains(r.cfg.Status, "plugins") {
+		r.pluginStatus = nil
+		return
+	}
+	s := r.status()
+	if r.pluginAsking || s == r.pluginState && time.Since(r.pluginAsked) < pluginStatusEvery {
+		return
+	}
+	var pp []*plugin
+	for _, p := range r.plugins {
+		if p.status {
+			pp = append(pp, p)
+		}
+	}
+	if len(pp) == 0 {
+		return
+	}
+	r.pluginAsking, r.pluginState = true, s
+	go func() {
+		var tt []string
+		var ee []error
+		for _, p := range pp {
+			var res struct {
+				Text string `json:"text"`
+			}
+			p.mu.Lock()
+			stopped := p.cmd == nil
+			p.mu.Unlock()
+			if stopped {
+				continue
+			}
+			if e := p.call("status", map[string]any{"status": s}, &res); e != nil {
+				ee = append(ee, e)
+				continue
+			}
+			if res.Text != "" {
+				tt = append(tt, res.Text)
+			}
+		}
+		r.call(func(r *Reader) {
+			r.pluginStatus, r.pluginAsking, r.pluginAsked = tt, false, time.Now()
+			if e := errors.Join(ee...); e != nil {
+				r.notice = strings.ReplaceAll(e.Error(), "\n", "; ")
+			}
+		})
+	}()
+}
@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// poetryModes are the values of Config.Poetry: lines centered if the book looks like poetry,
+// always, or never.
+var poetryModes = []string{"off", "auto", "on"}
+
+// isPoem tells if plain text d looks like poetry or lyrics: most of its lines are short.
+func isPoem(d *document) bool {
+	if !isProse(d) {
+		return false
+	}
+	lines, short, width := 0, 0, 0
+	for _, c := range eachChunk(d.lines.len(), func(from, to int) [3]int {
+		var c [3]int // lines, short ones and their width.
+		for _, l := range d.lines.slice(from, to).all() {
+			w := strWidth(strings.TrimSpace(stripControls(l, false)))
+			if w == 0 {
+				continue
+			}
+			c[0]++
+			c[2] += w
+			if w <= 60 {
+				c[1]++
+			}
+		}
+		return c
+	}) {
+		lines, short, width = lines+c[0], short+c[1], width+c[2]
+	}
+	return lines >= 8 && short*10 >= lines*9 && width <= lines*40
+}
+
+// poetry tells if lines are centered, see Config.Poetry.
+func (r *Reader) poetry() bool {
+	switch r.cfg.Poetry {
+	case "on":
+		return true
+	case "auto":
+		return r.poem
+	}
+	return false
+}
+
+// center puts the rows of a line in the middle of w columns, the spaces indenting them left out.
+func center(rows []string, w int) []string {
+	for i, row := range rows {
+		row = strings.TrimLeft(row, " ")
+		rows[i] = strings.Repeat(" ", max(0, (w-strWidth(row))/2)) + row
+	}
+	return rows
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pomodoro alternates reading and breaks, the screen is covered during breaks.
+type pomodoro struct {
+	read, rest time.Duration
+	end        time.Time // when the current period ends.
+	resting    bool
+}
+
+// startPomodoro starts reading periods of arg, "25/5" reads 25 minutes with 5 minute breaks.
+// Without arg it stops a running timer or starts one of 25/5.
+func (r *Reader) startPomodoro(arg string) error {
+	if arg == "" && r.pomo != nil {
+		r.pomo = nil
+		r.notice = tr("pomodoro off")
+		return nil
+	}
+	if arg == "" {
+		arg = "25/5"
+	}
+	rs, bs, _ := strings.Cut(arg, "/")
+	if bs == "" {
+		bs = "5"
+	}
+	read, e1 := strconv.Atoi(rs)
+	rest, e2 := strconv.Atoi(bs)
+	if e1 != nil || e2 != nil || read <= 0 || rest <= 0 {
+		return errors.New(tr("usage: :pomodoro [<read minutes>/<break minutes>]"))
+	}
+	r.pomo = &pomodoro{read: time.Duration(read) * time.Minute, rest: time.Duration(rest) * time.Minute}
+	r.pomo.end = time.Now().Add(r.pomo.read)
+	r.notice = fmt.Sprintf(tr("reading %d minutes, then a %d minute break"), read, rest)
+	return nil
+}
+
+// pomodoroTick switches between reading and breaks when the period is over.
+// It tells if the timer is running, so the countdown is redrawn.
+func (r *Reader) pomodoroTick() bool {
+	p := r.pomo
+	if _, ok := r.overlay.(*blankScreen); p == nil || ok {
+		return false
+	}
+	now := time.Now()
+	if now.Before(p.end) {
+		return true
+	}
+	if p.resting {
+		p.resting, p.end = false, now.Add(p.read)
+		if _, ok := r.overlay.(*pause); ok {
+			r.overlay = nil
+		}
+		r.notice = tr("break is over")
+		return true
+	}
+	p.resting, p.end = true, now.Add(p.rest)
+	r.book().Pomodoros++
+	r.writeProgress()
+	r.overlay = &pause{}
+	return true
+}
+
+// countdown is the time left of the current period, for the status bar.
+func (p *pomodoro) countdown() string {
+	left := max(0, time.Until(p.end).Round(time.Second))
+	s := fmt.Sprintf("%d:%02d", int(left.Minutes()), int(left.Seconds())%60)
+	if p.resting {
+		return tr("break ") + s
+	}
+	return tr("read ") + s
+}
+
+// pause covers the page during a break, esc ends the break early.
+type pause struct{}
+
+func (*pause) key(r *Reader, k string) bool {
+	if k != "esc" {
+		return false
+	}
+	if p := r.pomo; p != nil {
+		p.resting, p.end = false, time.Now().Add(p.read)
+	}
+	return true
+}
+
+func (*pause) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	b.WriteString("\x1b[H" + t.base() + "\x1b[2J")
+	lines := []string{tr("Take a break"), "", tr("esc skips the break")}
+	if p := r.pomo; p != nil {
+		lines[1] = p.countdown()
+	}
+	for i, l := range lines {
+		y := (r.winHeight-len(lines))/2 + i + 1
+		x := max(1, (r.winWidth-strWidth(l))/2+1)
+		_, _ = fmt.Fprintf(b, "\x1b[%d;%dH\x1b[2m%s", y, x, l)
+	}
+	b.WriteString(t.base())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// pruneOptions are the flags of fish progress prune.
+type pruneOptions struct {
+	days    int    // only entries not read for this many days, 0 for all.
+	dryRun  bool   // report what would be pruned, keep the file as it is.
+	archive string // file pruned entries are added to, "" drops them.
+}
+
+// Prune removes the entries of books whose files no longer exist from the progress file,
+// or moves them to an archive file in its format. It returns a report of what it did.
+func Prune(o pruneOptions) (string, error) {
+	p, e := progressPath()
+	if e != nil {
+		return "", e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return "", e
+	}
+	pruned := make(map[string]*Book)
+	for f, b := range progress {
+		if _, e := os.Stat(f); !os.IsNotExist(e) {
+			continue
+		}
+		if b != nil && o.days > 0 && time.Since(lastRead(b)) < time.Duration(o.days)*24*time.Hour {
+			continue
+		}
+		pruned[f] = b
+	}
+	var sb strings.Builder
+	verb, done := "removed", "removed"
+	switch {
+	case o.dryRun:
+		verb, done = "would remove", "would be removed"
+	case o.archive != "":
+		verb, done = "archived", "archived"
+	}
+	for _, f := range slices.Sorted(maps.Keys(pruned)) {
+		_, _ = fmt.Fprintf(&sb, "%s %s\n", verb, f)
+	}
+	_, _ = fmt.Fprintf(&sb, "%d of %d entries %s\n", len(pruned), len(progress), done)
+	if o.dryRun || len(pruned) == 0 {
+		return sb.String(), nil
+	}
+	if o.archive != "" {
+		a, e := homePath(o.archive)
+		if e != nil {
+			return "", e
+		}
+		old, e := readProgress(a)
+		if e != nil {
+			return "", e
+		}
+		for f, b := range pruned {
+			old[f] = b
+		}
+		if e := writeProgressFile(a, old); e != nil {
+			return "", e
+		}
+	}
+	for f := range pruned {
+		delete(progress, f)
+		if e := forget(f, -1); e != nil {
+			return "", e
+		}
+	}
+	if e := writeProgressFile(p, progress); e != nil {
+		return "", e
+	}
+	_ = os.Remove(journalPath(p))
+	return sb.String(), nil
+}
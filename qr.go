@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// qrVersion is the layout of a QR code version at error correction level M.
+type qrVersion struct {
+	ec     int       // error correction codewords per block.
+	groups [2][2]int // number of blocks and their data codewords, for the two groups of blocks.
+	align  []int     // centers of the alignment patterns.
+}
+
+// qrVersions are the versions 1 to 10, up to 213 bytes, more does not scan well from a terminal.
+var qrVersions = []qrVersion{
+	{10, [2][2]int{{1, 16}}, nil},
+	{16, [2][2]int{{1, 28}}, []int{6, 18}},
+	{26, [2][2]int{{1, 44}}, []int{6, 22}},
+	{18, [2][2]int{{2, 32}}, []int{6, 26}},
+	{24, [2][2]int{{2, 43}}, []int{6, 30}},
+	{16, [2][2]int{{4, 27}}, []int{6, 34}},
+	{18, [2][2]int{{4, 31}}, []int{6, 22, 38}},
+	{22, [2][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}},
+	{22, [2][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}},
+	{26, [2][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}},
+}
+
+// qrCode is a QR code matrix, indexed by row then column. fn marks the modules of patterns.
+type qrCode struct {
+	size   int
+	dark   [][]bool
+	fn     [][]bool
+	ver    int
+	layout qrVersion
+}
+
+// qrEncode encodes s in byte mode, in the smallest version it fits.
+func qrEncode(s string) (*qrCode, error) {
+	for i, v := range qrVersions {
+		ver := i + 1
+		n := v.groups[0][0]*v.groups[0][1] + v.groups[1][0]*v.groups[1][1]
+		cc := 8
+		if ver >= 10 {
+			cc = 16
+		}
+		if 4+cc+8*len(s) > 8*n {
+			continue
+		}
+		var bb qrBits
+		bb.add(0b0100, 4)
+		bb.add(len(s), cc)
+		for i := 0; i < len(s); i++ {
+			bb.add(int(s[i]), 8)
+		}
+		bb.add(0, min(4, 8*n-len(bb)))
+		bb.add(0, -len(bb)&7)
+		data := bb.bytes()
+		for pad := byte(0xec); len(data) < n; pad ^= 0xec ^ 0x11 {
+			data = append(data, pad)
+		}
+		q := &qrCode{size: 17 + 4*ver, ver: ver, layout: v}
+		q.dark, q.fn = make([][]bool, q.size), make([][]bool, q.size)
+		for y := range q.size {
+			q.dark[y], q.fn[y] = make([]bool, q.size), make([]bool, q.size)
+		}
+		q.drawPatterns()
+		q.drawCodewords(q.interleave(data))
+		q.applyBestMask()
+		return q, nil
+	}
+	return nil, errors.New("too long for a QR code")
+}
+
+// qrBits is a bit stream, a bool per bit.
+type qrBits []bool
+
+func (bb *qrBits) add(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, v>>i&1 == 1)
+	}
+}
+
+func (bb qrBits) bytes() []byte {
+	dd := make([]byte, len(bb)/8)
+	for i, b := range bb {
+		if b {
+			dd[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return dd
+}
+
+// interleave splits data into the blocks of the version, adds their error correction and
+// interleaves the codewords column by column.
+func (q *qrCode) interleave(data []byte) []byte {
+	var blocks, ecs [][]byte
+	div := rsDivisor(q.layout.ec)
+	for _, g := range q.layout.groups {
+		for range g[0] {
+			b := data[:g[1]]
+			data = data[g[1]:]
+			blocks = append(blocks, b)
+			ecs = append(ecs, rsRemainder(b, div))
+		}
+	}
+	var out []byte
+	for i := 0; ; i++ {
+		n := len(out)
+		for _, b := range blocks {
+			if i < len(b) {
+				out = append(out, b[i])
+			}
+		}
+		if len(out) == n {
+			break
+		}
+	}
+	for i := range q.layout.ec {
+		for _, e := range ecs {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// set sets the pattern module at column x, row y.
+func (q *qrCode) set(x, y int, dark bool) {
+	q.dark[y][x], q.fn[y][x] = dark, true
+}
+
+// drawPatterns draws the finder, timing and alignment patterns and reserves the format and version areas.
+func (q *qrCode) drawPatterns() {
+	n := q.size
+	for i := range n {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {n - 4, 3}, {3, n - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < n && y >= 0 && y < n {
+					d := max(abs(dx), abs(dy))
+					q.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+	aa := q.layout.align
+	for i, ax := range aa {
+		for j, ay := range aa {
+			last := len(aa) - 1
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue // on a finder pattern.
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	q.drawFormat(0)
+	if q.ver >= 7 {
+		rem := q.ver
+		for range 12 {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits := q.ver<<12 | rem
+		for i := range 18 {
+			a, b := n-11+i%3, i/3
+			q.set(a, b, bits>>i&1 == 1)
+			q.set(b, a, bits>>i&1 == 1)
+		}
+	}
+}
+
+// drawFormat draws the format bits of level M with mask, and the dark module.
+func (q *qrCode) drawFormat(mask int) {
+	data := mask // level M is 00.
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	n := q.size
+	for i := range 6 {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		q.set(n-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, n-15+i, bit(i))
+	}
+	q.set(8, n-8, true)
+}
+
+// drawCodewords places the bits of cw in the zigzag of two columns from the bottom right.
+func (q *qrCode) drawCodewords(cw []byte) {
+	i, n := 0, q.size
+	for right := n - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern.
+		}
+		for vert := range n {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = n - 1 - vert // upwards.
+				}
+				if !q.fn[y][x] && i < len(cw)*8 {
+					q.dark[y][x] = cw[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// qrMasks tell if the mask flips the module at column x, row y.
+var qrMasks = []func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+// mask flips the data modules with mask m, masking again undoes it.
+func (q *qrCode) mask(m int) {
+	for y := range q.size {
+		for x := range q.size {
+			if !q.fn[y][x] && qrMasks[m](x, y) {
+				q.dark[y][x] = !q.dark[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask masks the data with the mask of the lowest penalty, as scanners read best.
+func (q *qrCode) applyBestMask() {
+	best, low := 0, -1
+	for m := range qrMasks {
+		q.mask(m)
+		q.drawFormat(m)
+		if p := q.penalty(); low < 0 || p < low {
+			best, low = m, p
+		}
+		q.mask(m)
+	}
+	q.mask(best)
+	q.drawFormat(best)
+}
+
+// penalty scores the runs, blocks, finder-like patterns and dark balance of the code.
+func (q *qrCode) penalty() int {
+	n, p := q.size, 0
+	at := func(x, y int, col bool) bool {
+		if col {
+			return q.dark[x][y]
+		}
+		return q.dark[y][x]
+	}
+	finder := []bool{true, false, true, true, true, false, true, false, false, false, false}
+	for _, col := range []bool{false, true} {
+		for y := range n {
+			run := 1
+			for x := 1; x <= n; x++ {
+				if x < n && at(x, y, col) == at(x-1, y, col) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for x := 0; x+len(finder) <= n; x++ {
+				fwd, back := true, true
+				for i, f := range finder {
+					fwd = fwd && at(x+i, y, col) == f
+					back = back && at(x+len(finder)-1-i, y, col) == f
+				}
+				if fwd || back {
+					p += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := range n {
+		for x := range n {
+			if q.dark[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 && q.dark[y][x] == q.dark[y-1][x] && q.dark[y][x] == q.dark[y][x-1] && q.dark[y][x] == q.dark[y-1][x-1] {
+				p += 3
+			}
+		}
+	}
+	total := n * n
+	return p + ((abs(dark*20-total*10)+total-1)/total-1)*10
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of degree n, highest coefficient first
+// without the leading 1.
+func rsDivisor(n int) []byte {
+	d := make([]byte, n)
+	d[n-1] = 1
+	root := byte(1)
+	for range n {
+		for j := range n {
+			d[j] = gfMul(d[j], root)
+			if j+1 < n {
+				d[j] ^= d[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return d
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data, div []byte) []byte {
+	r := make([]byte, len(div))
+	for _, b := range data {
+		f := b ^ r[0]
+		copy(r, r[1:])
+		r[len(r)-1] = 0
+		for i := range r {
+			r[i] ^= gfMul(div[i], f)
+		}
+	}
+	return r
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8+x^4+x^3+x^2+1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11d
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// rows draws the code with half blocks, two modules per row, dark on light with a quiet zone.
+// restore is the escape sequence setting the colors back after each row.
+func (q *qrCode) rows(restore string) []string {
+	const quiet = 2
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < q.size && y < q.size && q.dark[y][x]
+	}
+	var ss []string
+	for y := -quiet; y < q.size+quiet; y += 2 {
+		var b strings.Builder
+		b.WriteString("\x1b[38;5;16;48;5;231m")
+		for x := -quiet; x < q.size+quiet; x++ {
+			switch top, bot := dark(x, y), dark(x, y+1); {
+			case top && bot:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bot:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(restore)
+		ss = append(ss, b.String())
+	}
+	return ss
+}
+
+// position describes the book and the reading position, for other readers.
+func (r *Reader) position() string {
+	st := r.status()
+	s := st.Title
+	if st.Author != "" {
+		s += " · " + st.Author
+	}
+	if st.Chapter != "" {
+		s += "\n" + st.Chapter
+	}
+	s += fmt.Sprintf("\nline %d of %d, %.1f%%", st.Line, st.Lines, st.Percent)
+	if f := filepath.Base(st.File); f != st.Title {
+		s += "\n" + f
+	}
+	return s
+}
+
+// sharePosition shows the reading position as a QR code to scan with a phone.
+func (r *Reader) sharePosition() {
+	s := r.position()
+	q, e := qrEncode(s)
+	if e != nil {
+		r.notice = tr("the position is too long for a QR code")
+		return
+	}
+	lines := q.rows(r.theme().status())
+	if len(lines)+strings.Count(s, "\n")+5 > r.winHeight || strWidth(lines[0])+4 > r.winWidth {
+		r.notice = tr("the window is too small for the QR code")
+		return
+	}
+	w := strWidth(lines[0])
+	for _, l := range strings.Split(s, "\n") {
+		w = max(w, strWidth(l))
+	}
+	pad := strings.Repeat(" ", (w-strWidth(lines[0]))/2)
+	for i := range lines {
+		lines[i] = pad + lines[i]
+	}
+	lines = append(lines, "")
+	lines = append(lines, strings.Split(s, "\n")...)
+	r.overlay = &box{title: "Scan to continue", lines: lines}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestRSRemainder checks the error correction codewords of HELLO WORLD at 1-M,
+// the example of the Thonky QR code tutorial.
+func TestRSRemainder(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+// TestQREncodeVersion checks the byte mode capacities of the versions at level M.
+func TestQREncodeVersion(t *testing.T) {
+	tests := []struct {
+		n, ver int
+	}{
+		{1, 1}, {14, 1}, {15, 2}, {26, 2}, {27, 3}, {42, 3}, {62, 4}, {84, 5},
+		{106, 6}, {122, 7}, {152, 8}, {180, 9}, {181, 10}, {213, 10},
+	}
+	for _, tt := range tests {
+		q, e := qrEncode(strings.Repeat("a", tt.n))
+		if e != nil {
+			t.Errorf("%d bytes: %v", tt.n, e)
+			continue
+		}
+		if q.ver != tt.ver || q.size != 17+4*tt.ver {
+			t.Errorf("%d bytes: version %d of size %d, want %d", tt.n, q.ver, q.size, tt.ver)
+		}
+	}
+	if _, e := qrEncode(strings.Repeat("a", 214)); e == nil {
+		t.Error("214 bytes encoded, want an error")
+	}
+}
+
+// qrFormats are the format bits of level M by mask, from the table of the standard.
+var qrFormats = []string{
+	"101010000010010",
+	"101000100100101",
+	"101111001111100",
+	"101101101001011",
+	"100010111111001",
+	"100000011001110",
+	"100111110010111",
+	"100101010100000",
+}
+
+// TestQREncode reads a version 1 code back as a scanner does: the mask from the format bits,
+// then the codewords in the zigzag with the mask undone.
+func TestQREncode(t *testing.T) {
+	q, e := qrEncode("hello")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if q.ver != 1 {
+		t.Fatalf("version %d, want 1", q.ver)
+	}
+	bit := func(x, y int) byte {
+		if q.dark[y][x] {
+			return '1'
+		}
+		return '0'
+	}
+	// the first copy along the top left finder, the second split beside the other two.
+	var first, second []byte
+	for x := range 6 {
+		first = append(first, bit(x, 8))
+	}
+	first = append(first, bit(7, 8), bit(8, 8), bit(8, 7))
+	for y := 5; y >= 0; y-- {
+		first = append(first, bit(8, y))
+	}
+	for y := 20; y > 13; y-- {
+		second = append(second, bit(8, y))
+	}
+	for x := 13; x < 21; x++ {
+		second = append(second, bit(x, 8))
+	}
+	if string(first) != string(second) {
+		t.Fatalf("format copies %s and %s differ", first, second)
+	}
+	mask := -1
+	for m, f := range qrFormats {
+		if f == string(first) {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %s are no level M format", first)
+	}
+	if !q.dark[13][8] {
+		t.Error("the dark module is light")
+	}
+
+	fn := func(x, y int) bool {
+		return x < 9 && y < 9 || x >= 13 && y < 9 || x < 9 && y >= 13 || x == 6 || y == 6
+	}
+	flip := []func(x, y int) bool{
+		func(x, y int) bool { return (y+x)%2 == 0 },
+		func(x, y int) bool { return y%2 == 0 },
+		func(x, y int) bool { return x%3 == 0 },
+		func(x, y int) bool { return (y+x)%3 == 0 },
+		func(x, y int) bool { return (y/2+x/3)%2 == 0 },
+		func(x, y int) bool { return y*x%2+y*x%3 == 0 },
+		func(x, y int) bool { return (y*x%2+y*x%3)%2 == 0 },
+		func(x, y int) bool { return ((y+x)%2+y*x%3)%2 == 0 },
+	}[mask]
+	var cw []byte
+	n := 0
+	up := true
+	for right := 20; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for i := range 21 {
+			y := i
+			if up {
+				y = 20 - i
+			}
+			for _, x := range []int{right, right - 1} {
+				if fn(x, y) {
+					continue
+				}
+				if n%8 == 0 {
+					cw = append(cw, 0)
+				}
+				if q.dark[y][x] != flip(x, y) {
+					cw[n/8] |= 1 << (7 - n%8)
+				}
+				n++
+			}
+		}
+		up = !up
+	}
+	// mode 0100, length 5, "hello", the terminator, then the pad bytes.
+	data := []byte{0x40, 0x56, 0x86, 0x56, 0xc6, 0xc6, 0xf0, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec}
+	want := append(data, rsRemainder(data, rsDivisor(10))...)
+	if !bytes.Equal(cw, want) {
+		t.Errorf("codewords with mask %d = %x, want %x", mask, cw, want)
+	}
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"math"
 	"os"
 	"os/signal"
 	"path"
-	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -26,88 +29,176 @@ const (
 	CmdPrevLine
 	CmdNextHalfPage
 	CmdSwitchScrolling
+	CmdSettings
+	CmdTOC
+	CmdCommand
+	CmdFootnote
+	CmdJumpBack
+	CmdJumpForward
+	CmdLinks
+	CmdInfo
+	CmdLibrary
+	CmdFold
+	CmdFoldAll
+	CmdScrollLeft
+	CmdScrollRight
+	CmdTiming
+	CmdFilter
+	CmdFollow
+	CmdNextHunk
+	CmdPrevHunk
+	CmdSplit
+	CmdSwitchPane
+	CmdBuffers
+	CmdRecord
+	CmdPlay
+	CmdShare
+	CmdDisguise
+	CmdBlank
+	CmdNextSentence
+	CmdPrevSentence
+	CmdNextParagraph
+	CmdPrevParagraph
+	CmdPercent
+	CmdBookmark
+	CmdBookmarks
+	CmdVisual
+	CmdOutline
+	CmdHeading
+	CmdScrollFaster
+	CmdScrollSlower
 	CmdNULL // CmdNULL is used to indicate no command received but call Reader.renderPage.
 )
 
 // Reader is a command-line reader designed for reading books/long-text file.
 //
-// Reader.pageFactor: Default 0.75 ,due to line wrapping of long single lines, the terminal height does not
-// always match the number of text lines, so precise page turns cannot be achieved.
-// To ensure that the number of lines turned is less than the actual terminal height,
-// the actual NextPage/PrevPage commands use fewer lines than the ideal count.
+// Reader.pageFactor: Default 0.75, NextPage/PrevPage commands turn this part of the lines
+// displayed on the page, so the last lines of the previous page stay as context.
 type Reader struct {
 	f                 string
-	data              string
-	progressFile      string // progress file path
+	doc               *document
+	diff              string         // file f is compared with, "" if none.
+	parallel          string         // translation of f shown beside it, "" if none.
+	base              *document      // doc with no folds collapsed.
+	folded            map[int]bool   // line of base:its fold is collapsed.
+	grep              *regexp.Regexp // lines of base shown in the filter view, nil shows all.
+	follow            bool           // the end of the file is shown as it grows.
+	stamp             fileStamp      // of the file when follow last checked it.
+	stream            *window        // part of a streamed book which is read, nil if the book is read whole.
+	progressFile      string         // progress file path
 	progressFD        *os.File
-	progress          map[string]int // map[abs-filepath]progress
+	progress          map[string]*Book // map[abs-filepath]book
 	previousSavedLine int
 	jumpBreakMark     int
 	pageFactor        float64 // see Reader doc.
 	displayBreakMark  bool
-	index             []string // line number:line content
+	resumeMark        int // line the book was resumed at, marked until the first page turn.
+	displayResumeMark bool
+	conf              Config  // configuration from the config file.
+	cfg               Config  // conf with overrides of the current book.
+	overlay           overlay // overlay drawn over the page, nil if none.
+	notice            string  // one-off message shown in place of the status bar.
+	shown             int     // lines fully displayed on the current page.
+	hscroll           int     // columns cut off the left of lines which are not wrapped.
+	split             bool    // the screen has two panes, the other one at line other.
+	other             int
+	lower             bool                   // the lower pane is being read.
+	covers            map[string]image.Image // book:cover, nil if it has none, see Reader.cover.
+	buffers           map[string]*buffer     // book:its state, of the books open besides f.
+	opened            []string               // books opened in the session, in order.
+	imageShown        bool                   // an image is on screen which the next frame must remove.
+	index             lineIndex              // line number:line content, the lines of doc.
+	chapters          []chapter
+	jumpBack          []int // jump list, positions before jumps.
+	jumpForward       []int // positions left by going back in the jump list.
 	totalLine         int
 	currentLine       int
 	winHeight         int
 	winWidth          int
-	scrollingLine     int
-	scrollingTk       <-chan time.Time
+	scrolling         time.Duration // time between lines of auto-scrolling, 0 is off.
+	lastScrolling     time.Duration // the speed toggleScrolling starts at.
+	scrollTicker      *time.Ticker
 	renderSignal      chan struct{}
 	eventSignal       chan byte
+	keySignal         chan string
 	quitSignal        chan struct{}
+	controlSignal     chan control
+	pulled            chan error                 // the result of the pull of the synced progress, nil if none is running.
+	forgotten         []deletion                 // deletions made during the pull, recorded after it, see forget.
+	listen            string                     // address of the HTTP control API, "" if off.
+	private           bool                       // the progress file is neither read nor written, the book leaves no trace, see --private.
+	noSave            bool                       // the progress file is read but nothing is written back to it or the config, see --no-save.
+	chapter           int                        // chapter the page started in at the last check, see checkEvents.
+	finished          map[string]bool            // book:its end was reached in this session.
+	macros            map[string][]string        // register:the keys of its macro.
+	recording         string                     // register keys are recorded to, "" if off.
+	macro             []string                   // the keys recorded so far.
+	playing           int                        // depth of the macros being played.
+	played            int                        // keys pressed by the macro being played.
+	count             int                        // count typed before the next command, as in 20@a or 50%.
+	titleShown        string                     // terminal title set by fish, "" if it did not.
+	paneTitle         string                     // tmux pane title before fish set it.
+	pomo              *pomodoro                  // the pomodoro timer, nil if off.
+	disguised         bool                       // the book is shown like Config.Disguise.
+	flags             map[string]json.RawMessage // settings given on the command line, over the config and the book's.
+	plugins           []*plugin                  // the running plugins of the config.
+	pluginStatus      []string                   // the status texts of the plugins after the last update.
+	pluginAsking      bool                       // the status plugins are being asked, see updatePluginStatus.
+	pluginAsked       time.Time                  // when they answered last.
+	pluginState       Status                     // the reading state they were asked with last.
+	pluginDepth       int                        // depth of the plugin commands running, see pluginCommand.
+	spoken            spoken                     // what was written last for Config.Accessible.
+	poem              bool                       // the book looks like poetry, see isPoem.
+	prose             bool                       // the book is running text, see isProse.
+	lineParas         bool                       // each line of the book is a paragraph, see lineParagraphs.
+	sentence          *place                     // start of the selected sentence, nil if none.
+	pace              pace                       // reading speed, see measurePace.
+	outline           *outline                   // the outline sidebar, nil if closed.
+	wordsBefore       []int                      // line of base:words before it, nil until wordCounts counts them.
+	active            time.Time                  // when the last key was pressed, see readingTick.
+	timeUnsaved       bool                       // reading time was counted since the progress file was written.
+	frame             []string                   // rows written last with Config.Slow, nil to write the next frame whole.
+	drawn             time.Time                  // when the last frame was written.
+	top               place                      // letter of the current line the page starts at, see topRow.
+	smoothShown       bool                       // smooth scroll mode is on, see drawSmooth.
+	hidden            bool                       // the status bar is hidden, see statusHidden.
 }
 
 // NewReader creates new reader, f must be absolute file path.
 func NewReader(f string) Reader {
 	return Reader{
-		f:            f,
-		index:        []string{},
-		progress:     make(map[string]int),
-		scrollingTk:  time.Tick(time.Second),
-		renderSignal: make(chan struct{}),
-		eventSignal:  make(chan byte),
-		quitSignal:   make(chan struct{}),
-		pageFactor:   0.75,
+		f:             f,
+		progress:      make(map[string]*Book),
+		buffers:       make(map[string]*buffer),
+		opened:        []string{f},
+		finished:      make(map[string]bool),
+		macros:        make(map[string][]string),
+		chapter:       noChapter,
+		scrollTicker:  time.NewTicker(time.Second),
+		renderSignal:  make(chan struct{}),
+		eventSignal:   make(chan byte),
+		keySignal:     make(chan string),
+		quitSignal:    make(chan struct{}),
+		controlSignal: make(chan control),
+		pageFactor:    0.75,
+		cfg:           DefaultConfig(),
 	}
 }
 
 func (r *Reader) daemonCatchInput() {
-	var b [3]byte
+	var b [64]byte
 	for {
 		select {
 		case <-r.quitSignal:
 			return
 		default:
 		}
-		_, err := os.Stdin.Read(b[:])
+		n, err := os.Stdin.Read(b[:])
 		if err != nil {
 			continue
 		}
-		switch b[0] {
-		case 0x03, 0x04, 'q': // ctrl + c = 0x03 | ctrl + d = 0x04
-			r.eventSignal <- CmdExit
-		case 'a':
-			r.eventSignal <- CmdSwitchScrolling
-		case 0x0d: // key: enter
-			r.eventSignal <- CmdNextLine
-		case ' ':
-			r.eventSignal <- CmdNextHalfPage
-		case 0x1b:
-			if b[1] != 0x5b {
-				continue
-			}
-			switch b[2] {
-			case 0x41: // up arrow
-				r.eventSignal <- CmdPrevLine
-			case 0x42: // down arrow
-				r.eventSignal <- CmdNextLine
-			case 0x43: // right arrow
-				r.eventSignal <- CmdNextPage
-			case 0x44: // left arrow
-				r.eventSignal <- CmdPrevPage
-			default:
-				continue
-			}
+		for _, k := range decodeKeys(b[:n]) {
+			r.keySignal <- k
 		}
 	}
 }
@@ -118,19 +209,28 @@ func (r *Reader) saveProgress() {
 		return
 	}
 	r.previousSavedLine = r.currentLine
-	r.progress[r.f] = r.previousSavedLine
-	pp, _ := json.MarshalIndent(r.progress, "", "  ")
-	_ = r.progressFD.Truncate(0)
-	_, _ = r.progressFD.Seek(0, 0)
-	_, _ = r.progressFD.Write(pp)
+	b := r.book()
+	b.Line, b.Percent = r.doc.source(r.currentLine), r.percent()
+	if r.stream != nil {
+		b.Line, b.Offset = 0, r.offset()
+	}
+	b.Read = time.Now()
+	r.writeProgress()
 }
 
 func (r *Reader) loadProgress() error {
-	u, e := os.UserHomeDir()
+	if r.private {
+		// the book opens as if never read, the history of the others stays unread too.
+		r.applyBook()
+		return nil
+	}
+	if r.noSave {
+		return r.loadReadOnly()
+	}
+	d, e := progressPath()
 	if e != nil {
 		return e
 	}
-	d := filepath.Join(u, ProgressFile)
 	if _, e := os.Stat(d); os.IsNotExist(e) {
 		if e := os.WriteFile(d, []byte("{}"), 0644); e != nil {
 			return e
@@ -146,51 +246,185 @@ func (r *Reader) loadProgress() error {
 	if e != nil {
 		return e
 	}
+	if pp, e = unlock(d, pp); e != nil {
+		return e
+	}
 	if e := json.Unmarshal(pp, &r.progress); e != nil {
 		return e
 	}
-	pos, ok := r.progress[r.f]
-	if ok {
-		r.currentLine = pos
-		r.previousSavedLine = pos
+	replayJournal(d, r.progress)
+	r.applyBook()
+	return nil
+}
+
+// loadReadOnly reads the progress file without creating or holding it, to take the position and
+// settings of books read before. The progress of the session stays in memory.
+func (r *Reader) loadReadOnly() error {
+	d, e := progressPath()
+	if e != nil {
+		return e
 	}
+	if r.progress, e = readProgress(d); e != nil {
+		return e
+	}
+	r.progressFile = d
+	r.applyBook()
 	return nil
 }
 
+// applyBook takes the saved position and settings of the current file,
+// positions are source lines until toView converts them.
+func (r *Reader) applyBook() {
+	r.cfg = r.conf.with(nil)
+	r.currentLine, r.previousSavedLine = 0, 0
+	r.hscroll = 0
+	r.grep, r.follow = nil, false
+	r.split, r.lower = false, false
+	r.displayResumeMark = false
+	b, ok := r.progress[r.f]
+	if ok && b != nil {
+		r.currentLine = b.Line
+		r.previousSavedLine = b.Line
+		r.resumeMark = b.Line
+		r.displayResumeMark = b.Line > 0
+		r.cfg = r.conf.with(b.Settings).with(r.flags)
+	}
+	r.setScrolling(scrollSpeed(r.cfg))
+}
+
+// toView converts the positions applyBook took to lines of the document.
+func (r *Reader) toView() {
+	r.currentLine = r.doc.view(r.currentLine)
+	r.previousSavedLine = r.currentLine
+	r.resumeMark = r.doc.view(r.resumeMark)
+}
+
 func (r *Reader) createIndex() error {
-	dd, e := os.ReadFile(r.f)
+	if streamed(r.f, r.cfg) {
+		return r.streamIndex()
+	}
+	r.stream = nil
+	d, e := loadDocument(r.f, r.cfg.Encoding)
 	if e != nil {
 		return e
 	}
-	r.data = string(dd)
-	r.index = strings.Split(r.data, "\n")
-	r.totalLine = len(r.index)
+	r.setDocument(d)
+	startup.lap("index")
 	return nil
 }
 
+// setDocument makes d the text being read.
+func (r *Reader) setDocument(d *document) {
+	b := r.book()
+	b.Meta = d.meta
+	rr, e1 := bookRules(r.f, b)
+	d, e2 := r.cfg.filter(d, rr)
+	d, e3 := r.pluginFilter(d)
+	if e := errors.Join(e1, e2, e3); e != nil {
+		r.notice = strings.ReplaceAll(e.Error(), "\n", "; ")
+	}
+	if r.diff != "" {
+		d = r.diffWith(d, rr)
+	}
+	if r.parallel != "" {
+		d = r.parallelWith(d, rr)
+	}
+	d = highlight(d, r.f, r.cfg.Highlight, r.theme())
+	if r.cfg.Log {
+		d = logColors(d, r.f)
+	}
+	r.base, r.folded = d, bigFolds(d)
+	r.wordsBefore = nil
+	r.poem, r.prose, r.lineParas = isPoem(d), isProse(d), lineParagraphs(d)
+	r.sentence = nil
+	r.doc = r.view()
+	r.index = r.doc.lines
+	r.totalLine = r.index.len()
+	r.chapters = r.doc.chapters()
+}
+
+// view returns the base document with the folds collapsed and the filter view applied.
+func (r *Reader) view() *document {
+	d := foldView(r.base, r.folded)
+	if r.grep != nil {
+		d = d.filter(func(_ int, s string) bool { return r.grep.MatchString(stripControls(s, false)) })
+	}
+	return d
+}
+
+// rebuild shows the document again after folds or the filter view changed, staying at the same line.
+func (r *Reader) rebuild() {
+	old := r.doc
+	r.doc = r.view()
+	r.index = r.doc.lines
+	r.totalLine = r.index.len()
+	r.chapters = r.doc.chapters()
+	r.remap(old)
+}
+
+// reload reads the file again, after a setting it depends on changed.
+func (r *Reader) reload() {
+	old := r.doc
+	if e := r.createIndex(); e != nil {
+		r.notice = e.Error()
+	}
+	r.remap(old)
+}
+
+// remap moves the positions in document old to the same source lines in the current one.
+func (r *Reader) remap(old *document) {
+	move := func(i int) int { return r.doc.view(old.source(i)) }
+	r.currentLine = min(move(r.currentLine), max(0, r.totalLine-1))
+	r.jumpBreakMark = move(r.jumpBreakMark)
+	r.resumeMark = move(r.resumeMark)
+	r.other = min(move(r.other), max(0, r.totalLine-1))
+	for i := range r.jumpBack {
+		r.jumpBack[i] = move(r.jumpBack[i])
+	}
+	for i := range r.jumpForward {
+		r.jumpForward[i] = move(r.jumpForward[i])
+	}
+}
+
+// resizeQuiet is how long the window must keep its size before the page is laid out again,
+// so dragging the window corner lays it out once.
+const resizeQuiet = 100 * time.Millisecond
+
+// updateWindowsSize takes the size of the terminal. Sizes which cannot be read keep the last
+// one, or 80×24 at the start.
 func (r *Reader) updateWindowsSize() {
 	width, height, err := term.GetSize(int(os.Stdout.Fd()))
-	if err != nil {
-		panic(err)
+	if err != nil || width <= 0 || height <= 0 {
+		if r.winWidth == 0 || r.winHeight == 0 {
+			r.winWidth, r.winHeight = 80, 24
+		}
+		return
 	}
 	r.winWidth = width
 	r.winHeight = height
-	r.renderPage()
+	r.frame = nil
 }
 
+// daemonUpdateWindowSize takes the new size resizeQuiet after the last SIGWINCH, in the main
+// loop which then draws the page.
 func (r *Reader) daemonUpdateWindowSize() {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGWINCH)
-	go func() {
-		for {
-			select {
-			case <-sigCh:
-				r.updateWindowsSize()
-			case <-r.quitSignal:
+	defer signal.Stop(sigCh)
+	quiet := time.NewTimer(resizeQuiet)
+	quiet.Stop()
+	for {
+		select {
+		case <-sigCh:
+			quiet.Reset(resizeQuiet)
+		case <-quiet.C:
+			if !r.call((*Reader).updateWindowsSize) {
 				return
 			}
+		case <-r.quitSignal:
+			return
 		}
-	}()
+	}
 }
 
 func (r *Reader) enterRawMode() (restore func(), err error) {
@@ -204,56 +438,161 @@ func (r *Reader) enterRawMode() (restore func(), err error) {
 	}, nil
 }
 
-func (r *Reader) printInfo() {
-	f := float64(r.currentLine) / float64(r.totalLine)
-	_, _ = fmt.Fprintf(os.Stdout, "> %s %d/%d %.02f%% [Q]:Quit [A]:Scroll(%s)", path.Base(r.f), r.currentLine, r.totalLine, f*100, r.scrollInfo())
+// statusFieldNames are all fields the status bar can show, in the default order.
+var statusFieldNames = []string{"name", "line", "percent", "chapter", "path", "keys", "scroll", "title", "author", "plugins", "wpm", "page", "left", "clock", "battery"}
+
+var statusFields = map[string]func(r *Reader) string{
+	"name":   func(r *Reader) string { return path.Base(r.f) },
+	"title":  func(r *Reader) string { return r.title() },
+	"author": func(r *Reader) string { return r.doc.meta.Author },
+	"line": func(r *Reader) string {
+		if r.stream != nil {
+			return r.streamInfo()
+		}
+		return fmt.Sprintf("%d/%d", r.currentLine, r.totalLine)
+	},
+	"percent": func(r *Reader) string {
+		return fmt.Sprintf("%.02f%%", r.percent())
+	},
+	"chapter": func(r *Reader) string { return r.chapterProgress() },
+	"path":    func(r *Reader) string { return r.breadcrumb() },
+	"keys":    func(r *Reader) string { return tr("[Q]:Quit [S]:Settings") },
+	"scroll":  func(r *Reader) string { return fmt.Sprintf(tr("[A]:Scroll(%s)"), r.scrollInfo()) },
+	"plugins": func(r *Reader) string { return strings.Join(r.pluginStatus, " ") },
+	"wpm":     func(r *Reader) string { return r.wpm() },
+	"left":    func(r *Reader) string { return r.chapterLeft() },
+	"page":    func(r *Reader) string { return fmt.Sprintf(tr("p. %d / %d"), r.pageAt(r.currentLine), r.pages()) },
+	"clock":   func(r *Reader) string { return time.Now().Format("15:04") },
+	"battery": func(r *Reader) string { return batteryInfo() },
+}
+
+func (r *Reader) printInfo(b *strings.Builder) {
+	s := r.notice
+	if s == "" && r.disguised {
+		s = r.disguiseStatus()
+	}
+	if s == "" {
+		ff := []string{">"}
+		if r.cfg.Accessible {
+			ff = nil
+		}
+		if r.recording != "" {
+			ff = append([]string{tr("recording @") + r.recording}, ff...)
+		}
+		if r.count > 0 {
+			ff = append(ff, strconv.Itoa(r.count))
+		}
+		if r.pomo != nil {
+			ff = append(ff, r.pomo.countdown())
+		}
+		for _, f := range r.cfg.Status {
+			if fn, ok := statusFields[f]; ok {
+				if v := fn(r); v != "" {
+					ff = append(ff, v)
+				}
+			}
+		}
+		s = strings.Join(ff, " ")
+	}
+	b.WriteString(r.theme().status() + cut(s, r.winWidth-1) + "\x1b[K" + r.theme().base())
+}
+
+// statusHidden tells if the status bar is to be hidden, after Config.HideStatus seconds without
+// a key. Notices and overlays bring it back.
+func (r *Reader) statusHidden() bool {
+	return r.cfg.HideStatus > 0 && r.notice == "" && r.overlay == nil && !r.cfg.Accessible &&
+		time.Since(r.active) >= time.Duration(r.cfg.HideStatus)*time.Second
 }
 
-func (r *Reader) scrollInfo() string {
-	switch r.scrollingLine {
-	case 0:
-		return "off"
-	case 1:
-		return "1"
-	case 2:
-		return "2"
-	default:
-		return "?"
+// pageRows is the rows of the page, the last row is the status bar's unless it is hidden.
+func (r *Reader) pageRows() int {
+	if r.hidden {
+		return r.winHeight
 	}
+	return r.winHeight - 1
+}
+
+// percent is how far the current line is into the book.
+func (r *Reader) percent() float64 {
+	if r.stream != nil {
+		return float64(r.offset()) / float64(max(1, r.stream.size)) * 100
+	}
+	return float64(r.currentLine) / float64(max(1, r.totalLine)) * 100
 }
 
 func (r *Reader) clearScreenRaw() {
 	_, _ = fmt.Fprint(os.Stdout, "\033[2J\033[H")
 }
 
+// enterAltScreen switches to the alternate screen, terminals without one are cleared on exit.
 func (r *Reader) enterAltScreen() {
-	_, _ = os.Stdout.Write([]byte("\x1b[?1049h"))
+	if caps.altScreen {
+		_, _ = os.Stdout.Write([]byte("\x1b[?1049h"))
+	}
 }
 
 func (r *Reader) exitAltScreen() {
-	_, _ = os.Stdout.Write([]byte("\x1b[?1049l"))
+	if r.smoothShown {
+		_, _ = os.Stdout.WriteString(smoothOff)
+	}
+	if !caps.altScreen {
+		_, _ = os.Stdout.Write([]byte("\x1b[0m\x1b[2J\x1b[H"))
+		return
+	}
+	_, _ = os.Stdout.Write([]byte("\x1b[0m\x1b[?1049l"))
+}
+
+// theme returns the theme in use.
+func (r *Reader) theme() Theme {
+	if t, ok := themes[r.cfg.Theme]; ok {
+		return t
+	}
+	return themes["default"]
 }
 
 func (r *Reader) renderPage() {
-	start := r.currentLine
-	r.clearScreenRaw()
-	pageLines := r.winHeight - 1
-	end := start + pageLines
-	if end > len(r.index) {
-		end = len(r.index)
-	}
-	for i := start; i < end; i++ {
-		if r.displayBreakMark && i == r.jumpBreakMark {
-			br := strings.Repeat("=", r.winHeight/2)
-			_, _ = fmt.Fprint(os.Stdout, br+"↓\r\n"+r.index[i]+"\r\n")
-		} else {
-			_, _ = fmt.Fprint(os.Stdout, r.index[i]+"\r\n")
+	if r.cfg.Accessible {
+		r.renderLinear()
+		return
+	}
+	var b strings.Builder
+	t := r.theme()
+	if r.imageShown {
+		b.WriteString(kittyClear)
+		r.imageShown, r.frame = false, nil
+	}
+	pageLines := r.pageRows()
+	rows, shown := r.layoutPanes()
+	r.shown = shown
+	var side []string
+	if r.outline != nil {
+		side = r.outlineRows(pageLines)
+	}
+	frame := make([]string, pageLines, pageLines+1)
+	for i := range frame {
+		if side != nil {
+			frame[i] = side[i]
+		}
+		if i < len(rows) {
+			frame[i] += rows[i] + t.base()
 		}
+		frame[i] += "\x1b[K"
+	}
+	if !r.hidden {
+		var info strings.Builder
+		r.printInfo(&info)
+		frame = append(frame, info.String())
+	}
+	r.drawSmooth(&b)
+	r.writeFrame(&b, frame)
+	if r.overlay != nil {
+		r.overlay.draw(r, &b)
 	}
-	for i := end - start; i < pageLines; i++ {
-		_, _ = fmt.Fprint(os.Stdout, "\r\n")
+	r.drawTitle(&b)
+	if out := b.String(); out != "" {
+		_, _ = os.Stdout.WriteString(termColors(out))
 	}
-	r.printInfo()
+	r.drawn = time.Now()
 	r.saveProgress()
 }
 
@@ -261,6 +600,7 @@ func (r *Reader) daemonRenderPage() {
 	for {
 		select {
 		case <-r.renderSignal:
+			r.coalesce()
 			r.renderPage()
 		case <-r.quitSignal:
 			return
@@ -271,13 +611,9 @@ func (r *Reader) daemonRenderPage() {
 func (r *Reader) daemonScrolling() {
 	for {
 		select {
-		case <-r.scrollingTk:
-			if r.scrollingLine > 0 {
-				if r.currentLine < r.totalLine-1 {
-					r.currentLine += r.scrollingLine
-				}
-				r.eventSignal <- CmdNULL
-			}
+		case <-r.scrollTicker.C:
+			// a row at a time in lines taller than the page.
+			r.eventSignal <- CmdNextLine
 		case <-r.quitSignal:
 			return
 		}
@@ -286,21 +622,51 @@ func (r *Reader) daemonScrolling() {
 
 func (r *Reader) Run() error {
 	defer r.close()
-	r.enterAltScreen()
-	defer r.exitAltScreen()
-	r.clearScreenRaw()
-	if e := r.createIndex(); e != nil {
+	defer startup.report()
+	if !term.IsTerminal(int(os.Stdout.Fd())) || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New(tr("fish reads in a terminal, convert writes a book to a file or pipe"))
+	}
+	if e := r.loadConfig(); e != nil {
 		return e
 	}
+	startup.lap("config")
+	if !caps.cursor {
+		// the page cannot be drawn, it is written as lines.
+		r.conf.Accessible, r.cfg.Accessible = true, true
+	}
+	defer r.restoreTitle()
+	if !r.cfg.Accessible {
+		r.enterAltScreen()
+		defer r.exitAltScreen()
+		r.clearScreenRaw()
+	}
 	if e := r.loadProgress(); e != nil {
 		return e
 	}
+	startup.lap("progress")
+	if e := r.createIndex(); e != nil {
+		return e
+	}
+	r.toView()
+	if r.listen != "" {
+		ln, e := listenLocal(r.listen)
+		if e != nil {
+			return e
+		}
+		defer func() { _ = ln.Close() }()
+		go r.serve(ln)
+	}
+	if ln := listenSocket(); ln != nil {
+		defer func() { _ = ln.Close() }()
+		go r.serve(ln)
+	}
 	r.updateWindowsSize()
 	rstore, e := r.enterRawMode()
 	if e != nil {
 		return e
 	}
 	defer rstore()
+	askPassword = nil // the keys are the reader's.
 	go r.daemonUpdateWindowSize()
 	go r.daemonScrolling()
 	go r.daemonRenderPage()
@@ -308,60 +674,262 @@ func (r *Reader) Run() error {
 	if r.currentLine > r.totalLine {
 		r.currentLine = 0
 	}
+	r.askResume()
+	startup.lap("terminal")
 	r.renderPage()
+	startup.finish()
+	r.startPlugins()
+	defer r.stopPlugins()
+	if dir := syncDir(r.conf); dir != "" && r.saves() {
+		r.pullInBackground(dir)
+	}
+	r.hook("open")
+	defer r.hook("quit")
+	tk := time.NewTicker(time.Second)
+	defer tk.Stop()
+	r.active = time.Now()
+	r.measurePace()
 	for {
-		switch <-r.eventSignal {
-		case CmdNULL:
-			// no op.
-		case CmdSwitchScrolling:
-			if r.scrollingLine == 2 {
-				r.scrollingLine = 0
-			} else {
-				r.scrollingLine++
+		var c byte
+		select {
+		case k := <-r.keySignal:
+			c = r.keyCommand(k)
+		case c = <-r.eventSignal:
+		case ctl := <-r.controlSignal:
+			ctl.fn(r)
+			close(ctl.done)
+			c = CmdNULL
+		case <-tk.C:
+			r.readingTick()
+			follow := r.follow && r.checkFile()
+			hide := r.statusHidden() != r.hidden
+			clock := r.clockChanged() && !r.hidden
+			if tick := r.pomodoroTick() && r.tickFrame(); !tick && !follow && !hide && !clock {
+				continue
 			}
-		case CmdExit:
+			c = CmdNULL
+		}
+		if c == CmdExit {
 			return nil
-		case CmdNextPage: // actually set to next 0.75 page
-			r.setBreakMark()
-			off := int(math.Round(float64(r.winHeight) * r.pageFactor))
-			if r.currentLine+off < r.totalLine {
-				r.currentLine += off
-			}
-		case CmdPrevPage: // actually set to prev 0.75 page
-			r.setBreakMark()
-			off := int(math.Round(float64(r.winHeight) * r.pageFactor))
-			if r.currentLine-off >= 0 {
-				r.currentLine -= off
-			} else {
-				r.currentLine = 0
-			}
-		case CmdNextLine:
-			if r.currentLine < r.totalLine-1 {
-				r.currentLine++
-			}
-		case CmdPrevLine:
-			if r.currentLine > 0 {
-				r.currentLine--
-			}
-		case CmdNextHalfPage:
-			r.setBreakMark()
-			off := r.winHeight / 2
-			if r.currentLine+r.winHeight-1 < r.totalLine {
-				r.currentLine += off
-			}
 		}
+		r.exec(c)
+		r.slideWindow()
+		if r.top.line != r.currentLine {
+			r.top = place{line: r.currentLine}
+		}
+		r.hidden = r.statusHidden()
+		r.checkEvents()
+		r.measurePace()
+		r.updatePluginStatus()
 		r.renderSignal <- struct{}{}
 	}
 }
 
+func (r *Reader) loadConfig() error {
+	c, e := LoadConfig()
+	if e != nil {
+		return e
+	}
+	r.conf = c.with(r.flags)
+	r.cfg = r.conf
+	setLanguage(r.conf)
+	useColors(r.conf)
+	r.setScrolling(scrollSpeed(r.conf))
+	return nil
+}
+
+// keyCommand returns the command bound to key k. While an overlay is open it takes the keys instead.
+func (r *Reader) keyCommand(k string) byte {
+	r.notice, r.active = "", time.Now()
+	if r.recording != "" && r.playing == 0 {
+		r.macro = append(r.macro, k)
+	}
+	if o := r.overlay; o != nil && k != "ctrl+c" {
+		// the overlay may have opened another one in its place.
+		if o.key(r, k) && r.overlay == o {
+			r.overlay = nil
+		}
+		return CmdNULL
+	}
+	if r.countKey(k) {
+		return CmdNULL
+	}
+	if line, ok := r.cfg.Keys[k]; ok {
+		r.count = 0
+		r.runCommand(line)
+		return CmdNULL
+	}
+	if c, ok := keymap[k]; ok {
+		if c != CmdPlay && c != CmdPercent {
+			r.count = 0
+		}
+		return c
+	}
+	r.count = 0
+	return CmdNULL
+}
+
+func (r *Reader) exec(c byte) {
+	switch c {
+	case CmdNULL:
+		// no op.
+	case CmdSwitchScrolling:
+		r.toggleScrolling()
+	case CmdScrollFaster:
+		r.scrollFaster(1)
+	case CmdScrollSlower:
+		r.scrollFaster(-1)
+	case CmdSettings:
+		r.overlay = newSettings()
+	case CmdTOC:
+		r.openTOC()
+	case CmdCommand:
+		r.overlay = &prompt{label: ":", done: (*Reader).runCommand}
+	case CmdFootnote:
+		r.openFootnote()
+	case CmdJumpBack:
+		r.jumpOlder()
+	case CmdJumpForward:
+		r.jumpNewer()
+	case CmdLinks:
+		r.selectLink()
+	case CmdInfo:
+		r.openInfo()
+	case CmdLibrary:
+		r.openLibrary()
+	case CmdRecord:
+		r.toggleRecording()
+	case CmdPlay:
+		r.askPlay(max(1, r.count))
+		r.count = 0
+	case CmdShare:
+		r.sharePosition()
+	case CmdDisguise:
+		r.toggleDisguise()
+	case CmdBlank:
+		r.blankOut()
+	case CmdNextSentence:
+		r.moveSentence(false)
+	case CmdPrevSentence:
+		r.moveSentence(true)
+	case CmdNextParagraph:
+		r.moveParagraph(false)
+	case CmdPrevParagraph:
+		r.moveParagraph(true)
+	case CmdBookmark:
+		r.addBookmark()
+	case CmdBookmarks:
+		r.openBookmarks()
+	case CmdVisual:
+		r.selectLines()
+	case CmdOutline:
+		r.openOutline()
+	case CmdHeading:
+		r.searchHeadings()
+	case CmdPercent:
+		r.jumpPercent(float64(min(r.count, 100)))
+		r.count = 0
+	case CmdFold:
+		r.selectFold()
+	case CmdFoldAll:
+		r.toggleFolds()
+	case CmdScrollLeft:
+		r.scrollSideways(-1)
+	case CmdScrollRight:
+		r.scrollSideways(1)
+	case CmdFilter:
+		r.overlay = &prompt{label: "&", done: (*Reader).setGrep}
+	case CmdFollow:
+		r.toggleFollow()
+	case CmdBuffers:
+		r.openBuffers()
+	case CmdSplit:
+		r.toggleSplit()
+	case CmdSwitchPane:
+		r.switchPane()
+	case CmdNextHunk:
+		r.nextHunk(false)
+	case CmdPrevHunk:
+		r.nextHunk(true)
+	case CmdTiming:
+		if r.doc.cues == nil {
+			r.notice = tr("no subtitles in this book")
+			break
+		}
+		r.cfg.Timing = !r.cfg.Timing
+	case CmdNextPage: // actually set to next 0.75 page
+		if r.rowDown(max(1, int(math.Round(float64(r.pageHeight())*r.pageFactor)))) {
+			r.displayBreakMark = false // the page stays in the line.
+			break
+		}
+		r.setBreakMark()
+		off := max(1, int(math.Round(float64(r.shown)*r.pageFactor)))
+		if r.cfg.Pages {
+			off = max(1, r.shown)
+		}
+		if r.currentLine+off < r.totalLine {
+			r.currentLine += off
+		}
+	case CmdPrevPage: // actually set to prev 0.75 page
+		if r.rowUp(max(1, int(math.Round(float64(r.pageHeight())*r.pageFactor)))) {
+			r.displayBreakMark = false
+			break
+		}
+		r.setBreakMark()
+		off := max(1, int(math.Round(float64(r.linesBefore(r.currentLine, r.pageHeight()))*r.pageFactor)))
+		if r.cfg.Pages {
+			off = max(1, r.linesBefore(r.currentLine, r.pageHeight()))
+		}
+		if r.currentLine-off >= 0 {
+			r.currentLine -= off
+		} else {
+			r.currentLine = 0
+		}
+	case CmdNextLine:
+		if !r.rowDown(1) && r.currentLine < r.totalLine-1 {
+			r.currentLine++
+		}
+	case CmdPrevLine:
+		if !r.rowUp(1) && r.currentLine > 0 {
+			r.currentLine--
+		}
+	case CmdNextHalfPage:
+		if r.rowDown(max(1, r.pageHeight()/2)) {
+			r.displayBreakMark = false
+			break
+		}
+		r.setBreakMark()
+		off := max(1, r.shown/2)
+		if r.currentLine+r.shown < r.totalLine {
+			r.currentLine += off
+		}
+	}
+}
+
 func (r *Reader) setBreakMark() {
-	r.jumpBreakMark = r.currentLine + r.winHeight - 1
+	r.jumpBreakMark = r.currentLine + r.shown
 	r.displayBreakMark = true
+	r.displayResumeMark = false
 }
 
 func (r *Reader) close() {
 	if r.progressFD != nil {
+		// git must be done with the repository before it is pushed.
+		r.takePulled()
+		if r.timeUnsaved {
+			r.writeProgress()
+		}
+		r.compactJournal()
 		_ = r.progressFD.Close()
+		if dir := syncDir(r.conf); dir != "" {
+			msg := "fish: progress"
+			if b := r.progress[r.f]; b != nil {
+				msg = fmt.Sprintf("fish: %s at %.0f%%", bookName(r.f, b), b.Percent)
+			}
+			if e := syncPush(dir, msg); e != nil {
+				_, _ = fmt.Fprintln(os.Stderr, "fish: sync:", e)
+			}
+		}
 	}
 	close(r.quitSignal)
 }
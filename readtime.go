@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// idleAfter is how long after the last key the reader is taken to be away, reading time
+// stops counting then. Auto-scroll counts as reading.
+const idleAfter = 5 * time.Minute
+
+// readingTick adds a second to the reading time of the current book, unless the reader is idle
+// or the page is covered. A minute of it is written to the progress file at once.
+func (r *Reader) readingTick() {
+	switch r.overlay.(type) {
+	case *blankScreen, *pause:
+		return
+	}
+	scrolling := r.scrolling > 0 && r.currentLine < r.totalLine-1
+	if !scrolling && time.Since(r.active) >= idleAfter {
+		return
+	}
+	b := r.book()
+	b.Seconds++
+	if b.Days == nil {
+		b.Days = make(map[string]int)
+	}
+	b.Days[time.Now().Format(time.DateOnly)]++
+	r.timeUnsaved = true
+	if b.Seconds%60 == 0 {
+		r.writeProgress()
+	}
+}
+
+// readTime formats seconds of reading as hours and minutes, like 14h 22m.
+func readTime(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// notifyCommand returns the shell command sending a desktop notification of $FISH_MESSAGE.
+func notifyCommand(c Config) string {
+	switch {
+	case c.Notify != "":
+		return c.Notify
+	case runtime.GOOS == "darwin":
+		return `osascript -e 'on run argv' -e 'display notification (item 1 of argv) with title "fish"' -e 'end run' "$FISH_MESSAGE"`
+	}
+	return `notify-send fish "$FISH_MESSAGE"`
+}
+
+// unread returns the book read last, and whether no book was read on the day of now.
+func unread(progress map[string]*Book, now time.Time) (string, bool) {
+	var last string
+	var at time.Time
+	for f, b := range progress {
+		if b == nil {
+			continue
+		}
+		t := b.Read
+		if b.Opened.After(t) {
+			t = b.Opened
+		}
+		if t.After(at) {
+			last, at = f, t
+		}
+	}
+	y, m, d := now.Date()
+	ay, am, ad := at.In(now.Location()).Date()
+	return last, y != ay || m != am || d != ad
+}
+
+// remindMessage is the notification asking to go on reading book f.
+func remindMessage(f string, b *Book) string {
+	if b == nil {
+		return tr("You have not read today.")
+	}
+	title := b.Meta.Title
+	if title == "" {
+		title = filepath.Base(f)
+	}
+	return fmt.Sprintf(tr("You have not read today, %s is at %.0f%%."), title, b.Percent)
+}
+
+// Remind runs until killed, and sends a notification at Config.Remind each day no book was read by then.
+// It is meant to be started with the desktop session.
+func Remind() error {
+	c, e := LoadConfig()
+	if e != nil {
+		return e
+	}
+	setLanguage(c)
+	if c.Remind == "" {
+		return errors.New(`set "remind": "20:00" in the config to be reminded at 20:00`)
+	}
+	at, e := time.Parse("15:04", c.Remind)
+	if e != nil {
+		return fmt.Errorf("remind: %w", e)
+	}
+	p, e := progressPath()
+	if e != nil {
+		return e
+	}
+	// a ticker, not a timer until the time, which would be late after the computer slept.
+	tk := time.NewTicker(time.Minute)
+	defer tk.Stop()
+	var reminded string
+	for now := time.Now(); ; now = <-tk.C {
+		day := now.Format(time.DateOnly)
+		due := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+		if reminded == day || now.Before(due) {
+			continue
+		}
+		reminded = day
+		progress, e := readProgress(p)
+		if e != nil {
+			return e
+		}
+		f, ok := unread(progress, now)
+		if !ok {
+			continue
+		}
+		cmd := exec.Command("sh", "-c", notifyCommand(c))
+		cmd.Env = append(os.Environ(), "FISH_MESSAGE="+remindMessage(f, progress[f]))
+		if e := cmd.Run(); e != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "fish: remind:", e)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// resumePercent is how far into a book the saved position must be to ask before resuming.
+const resumePercent = 5
+
+// askResume records the book is opened now, and asks whether to resume when the saved position
+// is far from the start and the book has not been opened for Config.Resume days.
+func (r *Reader) askResume() {
+	b := r.book()
+	last := b.Opened
+	b.Opened = time.Now()
+	r.writeProgress()
+	p := r.percent()
+	if r.cfg.Resume <= 0 || last.IsZero() || p < resumePercent ||
+		time.Since(last) < time.Duration(r.cfg.Resume)*24*time.Hour {
+		return
+	}
+	r.overlay = &choice{
+		text: fmt.Sprintf(tr("> Last read %d days ago. [R]esume at %.1f%% / [S]tart over / [C]hapters"),
+			int(time.Since(last).Hours()/24), p),
+		answer: func(r *Reader, k string) bool {
+			switch k {
+			case "r", "enter":
+			case "s":
+				if r.stream != nil {
+					r.jumpPercent(0)
+					break
+				}
+				r.currentLine = 0
+			case "c", "t":
+				r.openTOC()
+			default:
+				return false
+			}
+			return true
+		},
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rubyModes are the values of Config.Ruby: readings in brackets after their text, on a line
+// above it, left out, or the notation shown as it is.
+var rubyModes = []string{"inline", "above", "hide", "off"}
+
+// ruby is the reading of the text at start:end of a line without the notation.
+type ruby struct {
+	start, end int
+	reading    string
+}
+
+// parseRuby takes the ruby notation of Aozora Bunko out of s, 漢字《かんじ》 reads the kanji
+// before 《 and ｜東京《とうきょう》 the text after ｜. Without ｜ only kana are taken for readings,
+// so titles in 《》 of Chinese texts stay. Editor notes like ［＃「漢字」に傍点］ are dropped.
+func parseRuby(s string) (string, []ruby) {
+	var sb strings.Builder
+	var rr []ruby
+	bar := -1 // offset in sb after the last ｜.
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "｜"):
+			s = s[len("｜"):]
+			bar = sb.Len()
+			continue
+		case strings.HasPrefix(s, "［＃"):
+			if i := strings.Index(s, "］"); i > 0 {
+				s = s[i+len("］"):]
+				continue
+			}
+		case strings.HasPrefix(s, "《"):
+			reading, rest, ok := strings.Cut(s[len("《"):], "》")
+			text := sb.String()
+			start := bar
+			if start < 0 && kana(reading) {
+				start = len(text)
+				for start > 0 {
+					c, n := utf8.DecodeLastRuneInString(text[:start])
+					if !kanji(c) {
+						break
+					}
+					start -= n
+				}
+			}
+			if ok && reading != "" && start >= 0 && start < len(text) {
+				rr = append(rr, ruby{start, len(text), reading})
+				s, bar = rest, -1
+				continue
+			}
+		}
+		_, n := utf8.DecodeRuneInString(s)
+		sb.WriteString(s[:n])
+		s = s[n:]
+	}
+	return sb.String(), rr
+}
+
+// kanji tells if c can take a reading without ｜ before it.
+func kanji(c rune) bool {
+	return unicode.Is(unicode.Han, c) || strings.ContainsRune("々〆ヶ〇", c)
+}
+
+// kana tells if s is written in kana only.
+func kana(s string) bool {
+	for _, c := range s {
+		if !unicode.In(c, unicode.Hiragana, unicode.Katakana) && c != 'ー' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// rubyInline writes the readings of s in brackets after their text, or leaves them out if hide is set.
+func rubyInline(s string, rr []ruby, hide bool) string {
+	if hide {
+		return s
+	}
+	var sb strings.Builder
+	last := 0
+	for _, x := range rr {
+		sb.WriteString(s[last:x.end] + "（" + x.reading + "）")
+		last = x.end
+	}
+	return sb.String() + s[last:]
+}
+
+// rubyRows interleaves rows, the wrapped s, with rows of the readings above their text in SGR mark.
+// A row without ruby has no row above it.
+func rubyRows(s string, rr []ruby, rows []string, w int, mark, base string) []string {
+	vis := stripControls(s, false)
+	at := func(off int) int { return len(stripControls(s[:off], false)) }
+	var out []string
+	pos, k := 0, 0
+	for _, row := range rows {
+		rv := stripControls(row, false)
+		start := pos
+		if i := strings.Index(vis[pos:], rv); i >= 0 {
+			start += i
+		}
+		end := start + len(rv)
+		pos = end
+		var ann strings.Builder
+		col := 0
+		for ; k < len(rr) && at(rr[k].start) < end; k++ {
+			x := rr[k]
+			bs, be := at(x.start), min(at(x.end), end)
+			c := strWidth(vis[start:bs]) + (strWidth(vis[bs:be])-strWidth(x.reading))/2
+			c = max(c, col)
+			ann.WriteString(strings.Repeat(" ", c-col) + x.reading)
+			col = c + strWidth(x.reading)
+		}
+		if ann.Len() > 0 {
+			out = append(out, mark+cut(ann.String(), w)+base)
+		}
+		out = append(out, row)
+	}
+	return out
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Auto-scrolling moves the page a line at a time, its speed is the time between lines.
+const (
+	fastestScroll = 20 * time.Millisecond
+	slowestScroll = 10 * time.Second
+)
+
+// scrollSpeed is the time between lines of auto-scrolling at startup with config c, 0 is off.
+func scrollSpeed(c Config) time.Duration {
+	switch {
+	case c.ScrollMs > 0:
+		return time.Duration(c.ScrollMs) * time.Millisecond
+	case c.Scroll > 0:
+		return time.Second / time.Duration(c.Scroll)
+	}
+	return 0
+}
+
+// setScrolling scrolls a line every d, 0 stops scrolling.
+func (r *Reader) setScrolling(d time.Duration) {
+	if d <= 0 {
+		r.scrolling = 0
+		r.scrollTicker.Stop()
+		return
+	}
+	r.scrolling = max(fastestScroll, min(d, slowestScroll))
+	r.lastScrolling = r.scrolling
+	r.scrollTicker.Reset(r.scrolling)
+}
+
+// toggleScrolling starts scrolling at the last speed, or stops it.
+func (r *Reader) toggleScrolling() {
+	if r.scrolling > 0 {
+		r.setScrolling(0)
+		return
+	}
+	r.setScrolling(cmp.Or(r.lastScrolling, time.Second))
+}
+
+// scrollFaster scrolls a fifth faster for d 1, or slower for d -1. Going faster starts scrolling
+// at a line a second, going slower than the slowest stops it.
+func (r *Reader) scrollFaster(d int) {
+	switch {
+	case r.scrolling == 0 && d > 0:
+		r.setScrolling(time.Second)
+	case r.scrolling == 0:
+	case d > 0:
+		r.setScrolling(r.scrolling * 4 / 5)
+	case r.scrolling >= slowestScroll:
+		r.setScrolling(0)
+	default:
+		r.setScrolling(r.scrolling * 5 / 4)
+	}
+	r.cfg.Scroll, r.cfg.ScrollMs = 0, int(r.scrolling.Milliseconds())
+}
+
+func (r *Reader) scrollInfo() string {
+	if r.scrolling == 0 {
+		return tr("off")
+	}
+	return fmt.Sprintf(tr("%g/s"), math.Round(10*float64(time.Second)/float64(r.scrolling))/10)
+}
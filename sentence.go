@@ -0,0 +1,161 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// place is an offset in a line of the book.
+type place struct {
+	line, off int
+}
+
+// Sentences end at these, the full-width ones with no space needed after them, and take
+// the closing quotes and brackets after the end along.
+const (
+	stops    = ".!?…"
+	cjkStops = "。！？"
+	closings = "\"'”’」』）)]》"
+)
+
+// abbreviations end with a period which does not end a sentence, in English and German.
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "st": true, "jr": true, "sr": true, "prof": true,
+	"vs": true, "etc": true, "e.g": true, "i.e": true, "cf": true, "no": true, "vol": true, "ch": true,
+	"z.b": true, "bzw": true, "usw": true, "ca": true, "nr": true, "vgl": true, "d.h": true, "hr": true, "fr": true,
+}
+
+// sentenceStarts returns the offsets in s where sentences start, the first at its first letter.
+// A stop ends a sentence in Chinese and Japanese right away, in other languages there must be
+// a space after it and no small letter, so "e.g. this" and "J. R. R. Tolkien" stay one sentence.
+func sentenceStarts(s string) []int {
+	var ss []int
+	start := true
+	for i := 0; i < len(s); {
+		c, n := utf8.DecodeRuneInString(s[i:])
+		if start && !unicode.IsSpace(c) {
+			ss, start = append(ss, i), false
+		}
+		i += n
+		if !strings.ContainsRune(stops+cjkStops, c) || c == '.' && abbreviation(s[:i-n]) {
+			continue
+		}
+		for i < len(s) {
+			d, m := utf8.DecodeRuneInString(s[i:])
+			if !strings.ContainsRune(stops+cjkStops+closings, d) {
+				break
+			}
+			i += m
+		}
+		if strings.ContainsRune(cjkStops, c) {
+			start = true
+			continue
+		}
+		rest := strings.TrimLeftFunc(s[i:], unicode.IsSpace)
+		d, _ := utf8.DecodeRuneInString(rest)
+		start = (rest == "" || len(rest) < len(s[i:]) || cjk(d)) && !unicode.IsLower(d)
+	}
+	return ss
+}
+
+// abbreviation tells if the word s ends with is an abbreviation or an initial.
+func abbreviation(s string) bool {
+	i := strings.LastIndexFunc(s, func(c rune) bool { return !unicode.IsLetter(c) && c != '.' })
+	w := s[i+1:]
+	if i >= 0 {
+		_, n := utf8.DecodeRuneInString(s[i:])
+		w = s[i+n:]
+	}
+	return utf8.RuneCountInString(w) == 1 && unicode.IsUpper([]rune(w)[0]) || abbreviations[strings.ToLower(w)]
+}
+
+// sentenceStarts returns where the sentences of line i start. A line going on with the sentence
+// of the line before, as in text wrapped by hand, starts none at its first letter.
+func (r *Reader) sentenceStarts(i int) []int {
+	ss := sentenceStarts(r.index.at(i))
+	if len(ss) > 0 && i > 0 && !r.lineParas && !r.sentenceEnds(i-1) {
+		ss = ss[1:]
+	}
+	return ss
+}
+
+// sentenceEnds tells if line i is blank or ends a sentence, so the next line starts one.
+func (r *Reader) sentenceEnds(i int) bool {
+	s := strings.TrimRightFunc(stripControls(r.index.at(i), false), func(c rune) bool {
+		return unicode.IsSpace(c) || strings.ContainsRune(closings, c)
+	})
+	c, _ := utf8.DecodeLastRuneInString(s)
+	return s == "" || strings.ContainsRune(stops+cjkStops, c) ||
+		slices.ContainsFunc(r.chapters, func(c chapter) bool { return c.line == i })
+}
+
+// nextSentence returns where the sentence after the one starting at p starts, false at the end of the book.
+func (r *Reader) nextSentence(p place) (place, bool) {
+	for i := max(p.line, 0); i < r.totalLine; i++ {
+		for _, off := range r.sentenceStarts(i) {
+			if i > p.line || off > p.off {
+				return place{i, off}, true
+			}
+		}
+	}
+	return p, false
+}
+
+// prevSentence returns where the sentence before the one starting at p starts, false at the start of the book.
+func (r *Reader) prevSentence(p place) (place, bool) {
+	for i := min(p.line, r.totalLine-1); i >= 0; i-- {
+		ss := r.sentenceStarts(i)
+		for k := len(ss) - 1; k >= 0; k-- {
+			if i < p.line || ss[k] < p.off {
+				return place{i, ss[k]}, true
+			}
+		}
+	}
+	return p, false
+}
+
+// moveSentence selects the next sentence, or the one before if back is set, and turns the page
+// to it. Without a selected sentence on the page it starts from the top of the page.
+func (r *Reader) moveSentence(back bool) {
+	p, ok := place{}, false
+	switch on := r.sentence != nil && r.sentence.line >= r.currentLine && r.sentence.line < r.currentLine+max(1, r.shown); {
+	case on && back:
+		p, ok = r.prevSentence(*r.sentence)
+	case on:
+		p, ok = r.nextSentence(*r.sentence)
+	case back:
+		p, ok = r.prevSentence(place{r.currentLine, 0})
+	default:
+		p, ok = r.nextSentence(place{r.currentLine, -1})
+	}
+	if !ok {
+		return
+	}
+	r.sentence = &p
+	if p.line < r.currentLine || p.line >= r.currentLine+max(1, r.shown) {
+		r.currentLine = p.line
+	}
+}
+
+// sentenceSpan returns the part of line i the selected sentence covers, false if none.
+func (r *Reader) sentenceSpan(i int) (int, int, bool) {
+	p := r.sentence
+	if p == nil || i < p.line {
+		return 0, 0, false
+	}
+	start := 0
+	if i == p.line {
+		start = p.off
+	}
+	end := len(r.index.at(i))
+	if next, ok := r.nextSentence(*p); ok && next.line <= i {
+		if next.line < i {
+			return 0, 0, false
+		}
+		end = next.off
+	}
+	end = len(strings.TrimRightFunc(r.index.at(i)[:end], unicode.IsSpace))
+	return start, end, start < end
+}
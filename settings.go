@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// setting is one adjustable entry of the settings menu.
+type setting struct {
+	name   string
+	value  func(r *Reader) string
+	change func(r *Reader, d int) // d is -1 or +1.
+}
+
+func onOff(b bool) string {
+	if b {
+		return tr("on")
+	}
+	return tr("off")
+}
+
+func settingList() []setting {
+	ss := []setting{
+		{"Encoding", func(r *Reader) string { return r.cfg.Encoding },
+			func(r *Reader, d int) {
+				r.cfg.Encoding = cycle(encodings, r.cfg.Encoding, d)
+				r.reload()
+			}},
+		{"Wrap", func(r *Reader) string { return onOff(r.cfg.Wrap) },
+			func(r *Reader, _ int) { r.cfg.Wrap = !r.cfg.Wrap }},
+		{"Margin", func(r *Reader) string { return strconv.Itoa(r.cfg.Margin) },
+			func(r *Reader, d int) { r.cfg.Margin = max(0, min(r.cfg.Margin+d, r.winWidth/4)) }},
+		{"Tab width", func(r *Reader) string { return strconv.Itoa(r.cfg.Tab) },
+			func(r *Reader, d int) { r.cfg.Tab = max(1, min(r.cfg.Tab+d, 16)) }},
+		{"Show tabs", func(r *Reader) string { return onOff(r.cfg.ShowTabs) },
+			func(r *Reader, _ int) { r.cfg.ShowTabs = !r.cfg.ShowTabs }},
+		{"Theme", func(r *Reader) string { return r.cfg.Theme },
+			func(r *Reader, d int) { r.cfg.Theme = cycle(themeNames(), r.cfg.Theme, d) }},
+		{"Scroll speed", func(r *Reader) string { return r.scrollInfo() },
+			func(r *Reader, d int) { r.scrollFaster(d) }},
+		{"Strip Gutenberg", func(r *Reader) string { return onOff(r.cfg.Gutenberg) },
+			func(r *Reader, _ int) {
+				r.cfg.Gutenberg = !r.cfg.Gutenberg
+				r.reload()
+			}},
+		{"Squeeze blank lines", func(r *Reader) string { return onOff(r.cfg.Squeeze) },
+			func(r *Reader, _ int) {
+				r.cfg.Squeeze = !r.cfg.Squeeze
+				r.reload()
+			}},
+		{"ANSI colors", func(r *Reader) string { return onOff(r.cfg.ANSI) },
+			func(r *Reader, _ int) {
+				r.cfg.ANSI = !r.cfg.ANSI
+				r.reload()
+			}},
+		{"Code highlighting", func(r *Reader) string { return r.cfg.Highlight },
+			func(r *Reader, d int) {
+				r.cfg.Highlight = cycle(highlightStyles(), r.cfg.Highlight, d)
+				r.reload()
+			}},
+		{"Pretty JSON", func(r *Reader) string { return onOff(r.cfg.JSON) },
+			func(r *Reader, _ int) {
+				r.cfg.JSON = !r.cfg.JSON
+				r.reload()
+			}},
+		{"Log colors", func(r *Reader) string { return onOff(r.cfg.Log) },
+			func(r *Reader, _ int) {
+				r.cfg.Log = !r.cfg.Log
+				r.reload()
+			}},
+		{"Subtitle timing", func(r *Reader) string { return onOff(r.cfg.Timing) },
+			func(r *Reader, _ int) { r.cfg.Timing = !r.cfg.Timing }},
+		{"Terminal title", func(r *Reader) string { return onOff(r.cfg.Title) },
+			func(r *Reader, _ int) { r.cfg.Title = !r.cfg.Title }},
+		{"Disguise", func(r *Reader) string { return r.cfg.Disguise },
+			func(r *Reader, d int) { r.cfg.Disguise = cycle(disguises, r.cfg.Disguise, d) }},
+		{"Language", func(r *Reader) string { return r.cfg.Lang },
+			func(r *Reader, d int) {
+				r.cfg.Lang = cycle(languages, r.cfg.Lang, d)
+				setLanguage(r.cfg)
+			}},
+		{"Normalize punctuation", func(r *Reader) string { return onOff(r.cfg.Normalize) },
+			func(r *Reader, _ int) {
+				r.cfg.Normalize = !r.cfg.Normalize
+				r.reload()
+			}},
+		{"Smart typography", func(r *Reader) string { return onOff(r.cfg.Typography) },
+			func(r *Reader, _ int) { r.cfg.Typography = !r.cfg.Typography }},
+		{"Whole pages", func(r *Reader) string { return onOff(r.cfg.Pages) },
+			func(r *Reader, _ int) { r.cfg.Pages = !r.cfg.Pages }},
+		{"Paragraph indent", func(r *Reader) string { return strconv.Itoa(r.cfg.Indent) },
+			func(r *Reader, d int) { r.cfg.Indent = max(0, min(r.cfg.Indent+d, 8)) }},
+		{"Paragraph spacing", func(r *Reader) string { return strconv.Itoa(r.cfg.Spacing) },
+			func(r *Reader, d int) { r.cfg.Spacing = max(0, min(r.cfg.Spacing+d, 2)) }},
+		{"Poetry", func(r *Reader) string { return r.cfg.Poetry },
+			func(r *Reader, d int) { r.cfg.Poetry = cycle(poetryModes, r.cfg.Poetry, d) }},
+		{"Justify", func(r *Reader) string { return onOff(r.cfg.Justify) },
+			func(r *Reader, _ int) { r.cfg.Justify = !r.cfg.Justify }},
+		{"Hyphenation", func(r *Reader) string { return onOff(r.cfg.Hyphenate) },
+			func(r *Reader, _ int) { r.cfg.Hyphenate = !r.cfg.Hyphenate }},
+		{"Ruby", func(r *Reader) string { return r.cfg.Ruby },
+			func(r *Reader, d int) { r.cfg.Ruby = cycle(rubyModes, r.cfg.Ruby, d) }},
+		{"Hide status bar", func(r *Reader) string { return hideInfo(r.cfg.HideStatus) },
+			func(r *Reader, d int) { r.cfg.HideStatus = max(0, min(r.cfg.HideStatus+5*d, 60)) }},
+		{"Slow link", func(r *Reader) string { return onOff(r.cfg.Slow) },
+			func(r *Reader, _ int) { r.cfg.Slow = !r.cfg.Slow }},
+		{"Cover images", func(r *Reader) string { return r.cfg.Graphics },
+			func(r *Reader, d int) { r.cfg.Graphics = cycle(graphicsModes, r.cfg.Graphics, d) }},
+	}
+	for _, f := range statusFieldNames {
+		ss = append(ss, setting{fmt.Sprintf(tr("Status %s"), f),
+			func(r *Reader) string { return onOff(slices.Contains(r.cfg.Status, f)) },
+			func(r *Reader, _ int) {
+				if i := slices.Index(r.cfg.Status, f); i >= 0 {
+					r.cfg.Status = slices.Delete(slices.Clone(r.cfg.Status), i, i+1)
+				} else {
+					r.cfg.Status = append(slices.Clone(r.cfg.Status), f)
+				}
+			}})
+	}
+	return ss
+}
+
+func hideInfo(s int) string {
+	if s == 0 {
+		return tr("off")
+	}
+	return fmt.Sprintf(tr("after %ds"), s)
+}
+
+// cycle returns the element d steps away from cur in vv, wrapping around.
+func cycle(vv []string, cur string, d int) string {
+	i := slices.Index(vv, cur)
+	return vv[((i+d)%len(vv)+len(vv))%len(vv)]
+}
+
+// settings is the overlay to change settings while reading, changes take effect at once.
+type settings struct {
+	sel int
+	ss  []setting
+}
+
+func newSettings() *settings {
+	return &settings{ss: settingList()}
+}
+
+func (s *settings) key(r *Reader, k string) bool {
+	if !r.saves() && slices.Contains([]string{"w", "b", "x"}, k) {
+		r.notice = tr("nothing is saved in this session")
+		return false
+	}
+	switch k {
+	case "up":
+		s.sel = max(0, s.sel-1)
+	case "down":
+		s.sel = min(len(s.ss)-1, s.sel+1)
+	case "left":
+		s.ss[s.sel].change(r, -1)
+	case "right", "space", "enter":
+		s.ss[s.sel].change(r, 1)
+	case "w":
+		// keys overridden by the book keep their global value.
+		c := r.cfg.with(r.conf.values(slices.Collect(maps.Keys(r.book().Settings))))
+		if e := c.Save(); e != nil {
+			r.notice = e.Error()
+		} else {
+			r.conf = c
+			r.notice = tr("settings saved to ~/") + ConfigFile
+		}
+	case "b":
+		r.book().Settings = r.cfg.overrides(r.conf)
+		r.writeProgress()
+		r.notice = fmt.Sprintf(tr("settings saved for %s"), filepath.Base(r.f))
+	case "x":
+		r.book().Settings = nil
+		r.writeProgress()
+		r.cfg = r.conf.with(nil)
+		r.setScrolling(scrollSpeed(r.cfg))
+		r.notice = tr("book settings cleared")
+		r.reload()
+	case "esc", "q", "s":
+		return true
+	}
+	return false
+}
+
+func (s *settings) draw(r *Reader, b *strings.Builder) {
+	w := 0
+	for _, st := range s.ss {
+		w = max(w, strWidth(tr(st.name)))
+	}
+	lines := make([]string, len(s.ss))
+	for i, st := range s.ss {
+		name := tr(st.name)
+		if r.cfg.Accessible {
+			lines[i] = name + ": " + st.value(r)
+			continue
+		}
+		lines[i] = fmt.Sprintf(" %s%s  < %s > ", name, strings.Repeat(" ", w-strWidth(name)), st.value(r))
+	}
+	r.drawBox(b, "Settings", lines, s.sel, "←→:Change W:Save B:Save for book X:Clear book Esc:Close")
+}
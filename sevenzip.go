@@ -0,0 +1,757 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode/utf16"
+)
+
+// sevenZipMagic starts a 7z archive.
+const sevenZipMagic = "7z\xbc\xaf\x27\x1c"
+
+// errSevenZip is the error of a damaged 7z archive.
+var errSevenZip = errors.New("bad 7z archive")
+
+// the property IDs of the 7z headers.
+const (
+	szEnd = iota
+	szHeader
+	szArchiveProperties
+	szAdditionalStreamsInfo
+	szMainStreamsInfo
+	szFilesInfo
+	szPackInfo
+	szUnpackInfo
+	szSubStreamsInfo
+	szSize
+	szCRC
+	szFolders
+	szCodersUnpackSize
+	szNumUnpackStream
+	szEmptyStream
+	szEmptyFile
+	szAnti
+	szName
+	szEncodedHeader = 0x17
+)
+
+// szCoder is a coder of a folder, it decodes its input stream into its output stream.
+type szCoder struct {
+	id    string
+	props []byte
+}
+
+// szFolder is a stream of the archive, decoded by a chain of coders into one or more files.
+type szFolder struct {
+	coders []szCoder
+	bound  map[int]int // input:the output of the coder it is bound to, coder i has input and output i.
+	packed []int       // the inputs fed by the packed streams, in order.
+	sizes  []int64     // of the outputs.
+	main   int         // the output not bound to an input, the folder's.
+	crc    uint32      // of the main output, if hasCRC.
+	hasCRC bool
+	pack   int // index of the first packed stream of the folder.
+}
+
+// szStreams are the streams of a 7z archive: the packed streams and the folders decoding them,
+// split into substreams, the files.
+type szStreams struct {
+	packPos   int64
+	packSizes []int64
+	folders   []szFolder
+	subs      []int    // number of substreams of each folder.
+	subSizes  []int64  // the sizes of all substreams.
+	subCRCs   []uint32 // their CRCs, if subHasCRC.
+	subHasCRC []bool
+}
+
+// szFile is a file of a 7z archive, a substream of folder, at offset in its output.
+type szFile struct {
+	name         string
+	dir, empty   bool
+	folder       int
+	offset, size int64
+	crc          uint32
+	hasCRC       bool
+}
+
+// sevenZip is an open 7z archive.
+type sevenZip struct {
+	f        string
+	r        io.ReaderAt
+	size     int64
+	streams  *szStreams
+	files    []szFile
+	unpacked map[int][]byte // folder:its output, solid archives put many files in one.
+}
+
+// loadSevenZip reads the books in 7z archive f, encrypted or not, see archiveBooks.
+// Archives with encrypted headers ask for the password before listing their files.
+func loadSevenZip(f, enc string) (*document, error) {
+	fd, e := os.Open(f)
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = fd.Close() }()
+	fi, e := fd.Stat()
+	if e != nil {
+		return nil, e
+	}
+	z, e := openSevenZip(f, fd, fi.Size())
+	if e != nil {
+		return nil, e
+	}
+	var ff []szFile
+	for _, zf := range z.files {
+		if isBook(zf.name, zf.dir) {
+			ff = append(ff, zf)
+		}
+	}
+	slices.SortFunc(ff, func(a, b szFile) int { return cmp.Compare(a.name, b.name) })
+	names := make([]string, len(ff))
+	for i, zf := range ff {
+		names[i] = zf.name
+	}
+	return archiveBooks(f, names, enc, func(i int) ([]byte, error) { return z.read(ff[i]) })
+}
+
+// openSevenZip reads the headers of 7z archive f of size bytes, read from r.
+func openSevenZip(f string, r io.ReaderAt, size int64) (*sevenZip, error) {
+	bad := fmt.Errorf("%s: %w", filepath.Base(f), errSevenZip)
+	var sh [32]byte
+	if _, e := r.ReadAt(sh[:], 0); e != nil || string(sh[:6]) != sevenZipMagic {
+		return nil, bad
+	}
+	if crc32.ChecksumIEEE(sh[12:]) != binary.LittleEndian.Uint32(sh[8:]) {
+		return nil, bad
+	}
+	off, n := int64(binary.LittleEndian.Uint64(sh[12:])), int64(binary.LittleEndian.Uint64(sh[20:]))
+	if off < 0 || n <= 0 || off > size-32 || n > size-32-off {
+		return nil, bad
+	}
+	dd := make([]byte, n)
+	if _, e := r.ReadAt(dd, 32+off); e != nil {
+		return nil, e
+	}
+	if crc32.ChecksumIEEE(dd) != binary.LittleEndian.Uint32(sh[28:]) {
+		return nil, bad
+	}
+	z := &sevenZip{f: f, r: r, size: size, unpacked: make(map[int][]byte)}
+	for {
+		h := &szReader{dd: dd}
+		switch h.byte() {
+		case szHeader:
+			if e := z.readHeader(h); e != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Base(f), e)
+			}
+			return z, nil
+		case szEncodedHeader:
+			// the header is packed, and encrypted too when the file names are.
+			s := h.streams()
+			if h.e != nil || len(s.folders) == 0 {
+				return nil, bad
+			}
+			var e error
+			if dd, e = z.unpack(s, 0); e != nil {
+				return nil, e
+			}
+		default:
+			return nil, bad
+		}
+	}
+}
+
+// readHeader reads the streams and files of the header in h.
+func (z *sevenZip) readHeader(h *szReader) error {
+	id := h.byte()
+	if id == szArchiveProperties {
+		for h.number() != 0 && h.e == nil {
+			h.bytes(h.count())
+		}
+		id = h.byte()
+	}
+	if id == szAdditionalStreamsInfo {
+		return errors.New("7z archives with additional streams are not supported")
+	}
+	z.streams = &szStreams{}
+	if id == szMainStreamsInfo {
+		z.streams = h.streams()
+		id = h.byte()
+	}
+	if h.e != nil {
+		return h.e
+	}
+	if id == szFilesInfo {
+		z.files = h.files(z.streams)
+		id = h.byte()
+	}
+	if h.e != nil {
+		return h.e
+	}
+	if id != szEnd {
+		return errSevenZip
+	}
+	return nil
+}
+
+// read returns the contents of file zf, asking for the password of the archive if it is
+// encrypted and none was given yet.
+func (z *sevenZip) read(zf szFile) ([]byte, error) {
+	if zf.empty {
+		return nil, nil
+	}
+	dd, ok := z.unpacked[zf.folder]
+	if !ok {
+		var e error
+		if dd, e = z.unpack(z.streams, zf.folder); e != nil {
+			return nil, e
+		}
+		z.unpacked[zf.folder] = dd
+	}
+	if zf.offset+zf.size > int64(len(dd)) {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(z.f), errSevenZip)
+	}
+	dd = dd[zf.offset : zf.offset+zf.size]
+	if zf.hasCRC && crc32.ChecksumIEEE(dd) != zf.crc {
+		return nil, fmt.Errorf("%s: %s is damaged", filepath.Base(z.f), zf.name)
+	}
+	return dd, nil
+}
+
+// unpack returns the output of folder i of streams s, asking for the password if it is encrypted.
+func (z *sevenZip) unpack(s *szStreams, i int) ([]byte, error) {
+	fo := &s.folders[i]
+	if !slices.ContainsFunc(fo.coders, func(c szCoder) bool { return c.id == szAES }) {
+		return z.decode(s, fo, "")
+	}
+	return decryptArchive(z.f, func(pw string) ([]byte, error) {
+		dd, e := z.decode(s, fo, pw)
+		if errors.Is(e, errLZMA) || errors.Is(e, errSevenZip) {
+			// a wrong password decrypts to noise.
+			return nil, errPassword
+		}
+		return dd, e
+	})
+}
+
+// decode decodes folder fo of streams s with password pw, in memory.
+func (z *sevenZip) decode(s *szStreams, fo *szFolder, pw string) ([]byte, error) {
+	pos := 32 + s.packPos
+	for _, n := range s.packSizes[:fo.pack] {
+		pos += n
+	}
+	packed := make([][]byte, len(fo.packed))
+	for i := range packed {
+		n := s.packSizes[fo.pack+i]
+		if pos < 0 || pos+n > z.size {
+			return nil, errSevenZip
+		}
+		packed[i] = make([]byte, n)
+		if _, e := z.r.ReadAt(packed[i], pos); e != nil {
+			return nil, e
+		}
+		pos += n
+	}
+	var output func(o, depth int) ([]byte, error)
+	output = func(o, depth int) ([]byte, error) {
+		if depth > len(fo.coders) {
+			return nil, errSevenZip // the coders are bound in a loop.
+		}
+		var in []byte
+		if b, ok := fo.bound[o]; ok {
+			var e error
+			if in, e = output(b, depth+1); e != nil {
+				return nil, e
+			}
+		} else if j := slices.Index(fo.packed, o); j >= 0 {
+			in = packed[j]
+		} else {
+			return nil, errSevenZip
+		}
+		return decodeCoder(fo.coders[o], in, fo.sizes[o], pw)
+	}
+	dd, e := output(fo.main, 0)
+	if e != nil {
+		return nil, e
+	}
+	if fo.hasCRC && crc32.ChecksumIEEE(dd) != fo.crc {
+		return nil, errSevenZip
+	}
+	return dd, nil
+}
+
+// the IDs of the 7z coders fish decodes.
+const (
+	szCopy    = "\x00"
+	szLZMA    = "\x03\x01\x01"
+	szLZMA2   = "\x21"
+	szDeflate = "\x04\x01\x08"
+	szAES     = "\x06\xf1\x07\x01"
+)
+
+// decodeCoder decodes in with coder c into size bytes.
+func decodeCoder(c szCoder, in []byte, size int64, pw string) ([]byte, error) {
+	if size < 0 || size > 1<<40 {
+		return nil, errSevenZip
+	}
+	var dd []byte
+	var e error
+	switch c.id {
+	case szCopy:
+		dd = in
+	case szLZMA:
+		dd, e = unLZMA(in, c.props, int(size))
+	case szLZMA2:
+		dd, e = unLZMA2(in, int(size))
+	case szDeflate:
+		dd, e = io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(in)), size))
+		if e != nil {
+			e = errLZMA // as damaged as a bad LZMA stream.
+		}
+	case szAES:
+		dd, e = decrypt7zAES(in, c.props, pw)
+		if e == nil && int64(len(dd)) >= size {
+			dd = dd[:size] // the padding of the last block.
+		}
+	default:
+		return nil, fmt.Errorf("7z compression method %x is not supported", c.id)
+	}
+	if e != nil {
+		return nil, e
+	}
+	if int64(len(dd)) != size {
+		return nil, errSevenZip
+	}
+	return dd, nil
+}
+
+// decrypt7zAES decrypts in, encrypted with AES-256 in CBC mode under a key hashed from
+// password pw with SHA-256 as props tell, like 7-Zip does.
+func decrypt7zAES(in, props []byte, pw string) ([]byte, error) {
+	if len(props) < 1 {
+		return nil, errSevenZip
+	}
+	power := props[0] & 0x3f
+	var salt, iv []byte
+	if props[0]&0xc0 != 0 {
+		if len(props) < 2 {
+			return nil, errSevenZip
+		}
+		ns := int(props[0]>>7&1) + int(props[1]>>4)
+		ni := int(props[0]>>6&1) + int(props[1]&0x0f)
+		if len(props) != 2+ns+ni {
+			return nil, errSevenZip
+		}
+		salt, iv = props[2:2+ns], props[2+ns:]
+	}
+	if power > 24 && power != 0x3f {
+		return nil, errors.New("7z encryption with more than 2^24 rounds is not supported")
+	}
+	var pw16 []byte
+	for _, u := range utf16.Encode([]rune(pw)) {
+		pw16 = binary.LittleEndian.AppendUint16(pw16, u)
+	}
+	var key [32]byte
+	if power == 0x3f {
+		copy(key[copy(key[:], salt):], pw16)
+	} else {
+		h := sha256.New()
+		round := make([]byte, 0, len(salt)+len(pw16)+8)
+		round = append(append(round, salt...), pw16...)
+		for i := range uint64(1) << power {
+			h.Write(binary.LittleEndian.AppendUint64(round, i))
+		}
+		h.Sum(key[:0])
+	}
+	if len(in)%aes.BlockSize != 0 {
+		return nil, errSevenZip
+	}
+	block, e := aes.NewCipher(key[:])
+	if e != nil {
+		return nil, e
+	}
+	var iv16 [aes.BlockSize]byte
+	copy(iv16[:], iv)
+	out := make([]byte, len(in))
+	cipher.NewCBCDecrypter(block, iv16[:]).CryptBlocks(out, in)
+	return out, nil
+}
+
+// szReader reads the numbers and properties of a 7z header. Its first error stays in e,
+// reading on after it returns zeros.
+type szReader struct {
+	dd []byte
+	e  error
+}
+
+func (r *szReader) byte() byte {
+	if len(r.dd) == 0 {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return 0
+	}
+	b := r.dd[0]
+	r.dd = r.dd[1:]
+	return b
+}
+
+func (r *szReader) bytes(n int) []byte {
+	if n > len(r.dd) {
+		r.e = cmp.Or(r.e, errSevenZip)
+		n = len(r.dd)
+	}
+	b := r.dd[:n]
+	r.dd = r.dd[n:]
+	return b
+}
+
+// number reads a number of 7z, the high bits of the first byte tell how many bytes follow.
+func (r *szReader) number() uint64 {
+	first := r.byte()
+	var v uint64
+	for i := range 8 {
+		mask := byte(0x80) >> i
+		if first&mask == 0 {
+			return v | uint64(first&(mask-1))<<(8*i)
+		}
+		v |= uint64(r.byte()) << (8 * i)
+	}
+	return v
+}
+
+// count reads a number counting things of the header, each taking at least a bit.
+func (r *szReader) count() int {
+	n := r.number()
+	if n > uint64(len(r.dd))*8+8 {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return 0
+	}
+	return int(n)
+}
+
+// size reads the size of a stream.
+func (r *szReader) size() int64 {
+	n := r.number()
+	if n > 1<<62 {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return 0
+	}
+	return int64(n)
+}
+
+// bits reads a vector of n bits, the highest bit of a byte first.
+func (r *szReader) bits(n int) []bool {
+	bb := make([]bool, n)
+	var b byte
+	for i := range n {
+		if i%8 == 0 {
+			b = r.byte()
+		}
+		bb[i] = b&(0x80>>(i%8)) != 0
+	}
+	return bb
+}
+
+// digests reads the CRCs of n streams, those defined.
+func (r *szReader) digests(n int) ([]uint32, []bool) {
+	defined := make([]bool, n)
+	if all := r.byte(); all != 0 {
+		for i := range defined {
+			defined[i] = true
+		}
+	} else {
+		defined = r.bits(n)
+	}
+	crcs := make([]uint32, n)
+	for i := range n {
+		if defined[i] {
+			if b := r.bytes(4); len(b) == 4 {
+				crcs[i] = binary.LittleEndian.Uint32(b)
+			}
+		}
+	}
+	return crcs, defined
+}
+
+// streams reads the streams info of a header.
+func (r *szReader) streams() *szStreams {
+	s := &szStreams{}
+	id := r.byte()
+	if id == szPackInfo {
+		s.packPos = r.size()
+		s.packSizes = make([]int64, r.count())
+		for id = r.byte(); id != szEnd && r.e == nil; id = r.byte() {
+			switch id {
+			case szSize:
+				for i := range s.packSizes {
+					s.packSizes[i] = r.size()
+				}
+			case szCRC:
+				r.digests(len(s.packSizes))
+			default:
+				r.e = errSevenZip
+			}
+		}
+		id = r.byte()
+	}
+	if id == szUnpackInfo {
+		r.folders(s)
+		id = r.byte()
+	}
+	if r.e != nil {
+		// the folders read before the error may have no sizes.
+		return s
+	}
+	s.subs = make([]int, len(s.folders))
+	for i, fo := range s.folders {
+		s.subs[i] = 1
+		s.subSizes = append(s.subSizes, fo.sizes[fo.main])
+		s.subCRCs = append(s.subCRCs, fo.crc)
+		s.subHasCRC = append(s.subHasCRC, fo.hasCRC)
+	}
+	if id == szSubStreamsInfo {
+		r.subStreams(s)
+		id = r.byte()
+	}
+	if id != szEnd {
+		r.e = cmp.Or(r.e, errSevenZip)
+	}
+	return s
+}
+
+// folders reads the folders of the unpack info into s.
+func (r *szReader) folders(s *szStreams) {
+	if r.byte() != szFolders {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return
+	}
+	s.folders = make([]szFolder, r.count())
+	if r.byte() != 0 {
+		r.e = cmp.Or(r.e, errors.New("7z archives with external folders are not supported"))
+		return
+	}
+	pack := 0
+	for i := range s.folders {
+		fo := &s.folders[i]
+		fo.coders = make([]szCoder, r.count())
+		for j := range fo.coders {
+			flags := r.byte()
+			fo.coders[j].id = string(r.bytes(int(flags & 0x0f)))
+			if flags&0x10 != 0 {
+				// coders of several streams are for executables, not books.
+				if r.number() != 1 || r.number() != 1 {
+					r.e = cmp.Or(r.e, errors.New("7z coders of several streams are not supported"))
+					return
+				}
+			}
+			if flags&0x20 != 0 {
+				fo.coders[j].props = r.bytes(r.count())
+			}
+			if flags&0x80 != 0 {
+				r.e = cmp.Or(r.e, errSevenZip)
+				return
+			}
+		}
+		n := len(fo.coders)
+		if n == 0 {
+			r.e = cmp.Or(r.e, errSevenZip)
+			return
+		}
+		fo.bound = make(map[int]int)
+		outs := make([]bool, n)
+		for range n - 1 {
+			in, out := r.count(), r.count()
+			if in >= n || out >= n {
+				r.e = cmp.Or(r.e, errSevenZip)
+				return
+			}
+			fo.bound[in] = out
+			outs[out] = true
+		}
+		// n-1 of the n inputs are bound, the packed stream feeds the other one.
+		for in := range n {
+			if _, ok := fo.bound[in]; !ok {
+				fo.packed = append(fo.packed, in)
+			}
+		}
+		fo.main = slices.Index(outs, false)
+		if len(fo.packed) != 1 || fo.main < 0 {
+			r.e = cmp.Or(r.e, errSevenZip)
+			return
+		}
+		fo.pack = pack
+		pack += len(fo.packed)
+	}
+	if pack > len(s.packSizes) {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return
+	}
+	if r.byte() != szCodersUnpackSize {
+		r.e = cmp.Or(r.e, errSevenZip)
+		return
+	}
+	for i := range s.folders {
+		fo := &s.folders[i]
+		fo.sizes = make([]int64, len(fo.coders))
+		for j := range fo.sizes {
+			fo.sizes[j] = r.size()
+		}
+	}
+	id := r.byte()
+	if id == szCRC {
+		crcs, defined := r.digests(len(s.folders))
+		for i := range s.folders {
+			s.folders[i].crc, s.folders[i].hasCRC = crcs[i], defined[i]
+		}
+		id = r.byte()
+	}
+	if id != szEnd {
+		r.e = cmp.Or(r.e, errSevenZip)
+	}
+}
+
+// subStreams reads how the folders of s split into files.
+func (r *szReader) subStreams(s *szStreams) {
+	id := r.byte()
+	if id == szNumUnpackStream {
+		for i := range s.subs {
+			s.subs[i] = r.count()
+		}
+		id = r.byte()
+	}
+	s.subSizes, s.subCRCs, s.subHasCRC = nil, nil, nil
+	for i, fo := range s.folders {
+		if s.subs[i] == 0 {
+			continue
+		}
+		left := fo.sizes[fo.main]
+		if id == szSize {
+			for range s.subs[i] - 1 {
+				n := r.size()
+				s.subSizes = append(s.subSizes, n)
+				left -= n
+			}
+		} else if s.subs[i] > 1 {
+			r.e = cmp.Or(r.e, errSevenZip)
+			return
+		}
+		if left < 0 {
+			r.e = cmp.Or(r.e, errSevenZip)
+			return
+		}
+		s.subSizes = append(s.subSizes, left)
+	}
+	if id == szSize {
+		id = r.byte()
+	}
+	// the CRCs of the streams whose folder's CRC is not theirs.
+	unknown := 0
+	for i, fo := range s.folders {
+		if s.subs[i] != 1 || !fo.hasCRC {
+			unknown += s.subs[i]
+		}
+	}
+	var crcs []uint32
+	var defined []bool
+	if id == szCRC {
+		crcs, defined = r.digests(unknown)
+		id = r.byte()
+	}
+	k := 0
+	for i, fo := range s.folders {
+		for range s.subs[i] {
+			switch {
+			case s.subs[i] == 1 && fo.hasCRC:
+				s.subCRCs, s.subHasCRC = append(s.subCRCs, fo.crc), append(s.subHasCRC, true)
+			case k < len(crcs):
+				s.subCRCs, s.subHasCRC = append(s.subCRCs, crcs[k]), append(s.subHasCRC, defined[k])
+				k++
+			default:
+				s.subCRCs, s.subHasCRC = append(s.subCRCs, 0), append(s.subHasCRC, false)
+			}
+		}
+	}
+	if id != szEnd {
+		r.e = cmp.Or(r.e, errSevenZip)
+	}
+}
+
+// files reads the files info of a header, their data is in the substreams of s.
+func (r *szReader) files(s *szStreams) []szFile {
+	ff := make([]szFile, r.count())
+	var emptyStream, emptyFile []bool
+	for r.e == nil {
+		t := r.number()
+		if t == szEnd {
+			break
+		}
+		p := &szReader{dd: r.bytes(r.count())}
+		switch t {
+		case szEmptyStream:
+			emptyStream = p.bits(len(ff))
+		case szEmptyFile:
+			n := 0
+			for _, b := range emptyStream {
+				if b {
+					n++
+				}
+			}
+			emptyFile = p.bits(n)
+		case szName:
+			if p.byte() != 0 {
+				r.e = errors.New("7z archives with external names are not supported")
+				break
+			}
+			for i := range ff {
+				var u []uint16
+				for {
+					b := p.bytes(2)
+					if len(b) < 2 || b[0] == 0 && b[1] == 0 {
+						break
+					}
+					u = append(u, binary.LittleEndian.Uint16(b))
+				}
+				ff[i].name = filepath.ToSlash(string(utf16.Decode(u)))
+			}
+		}
+		r.e = cmp.Or(r.e, p.e)
+	}
+	folder, sub, k := 0, 0, 0
+	var off int64
+	empties := 0
+	for i := range ff {
+		zf := &ff[i]
+		if i < len(emptyStream) && emptyStream[i] {
+			zf.empty = true
+			zf.dir = empties >= len(emptyFile) || !emptyFile[empties]
+			empties++
+			continue
+		}
+		for folder < len(s.subs) && sub >= s.subs[folder] {
+			folder, sub = folder+1, 0
+		}
+		if folder >= len(s.subs) || k >= min(len(s.subSizes), len(s.subCRCs), len(s.subHasCRC)) {
+			r.e = cmp.Or(r.e, errSevenZip)
+			return nil
+		}
+		if sub == 0 {
+			off = 0
+		}
+		zf.folder, zf.offset, zf.size = folder, off, s.subSizes[k]
+		zf.crc, zf.hasCRC = s.subCRCs[k], s.subHasCRC[k]
+		off += zf.size
+		sub, k = sub+1, k+1
+	}
+	return ff
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// slowFrame is how long frames are held back with Config.Slow, keys pressed in the meantime
+// are drawn in the same frame.
+const slowFrame = 40 * time.Millisecond
+
+// writeFrame appends the rows of the page and the status bar to b. With Config.Slow only the
+// rows which differ from the last frame are written, after scrolling the rows still shown into
+// place when the page moved by a few lines. Overlays are drawn over the rows, while one is open
+// the frame is written whole.
+func (r *Reader) writeFrame(b *strings.Builder, frame []string) {
+	last := r.frame
+	r.frame = nil
+	if (r.cfg.Slow || r.smoothShown) && r.overlay == nil {
+		r.frame = frame
+	}
+	if last == nil || r.overlay != nil || len(last) != len(frame) {
+		b.WriteString("\x1b[H" + r.theme().base() + strings.Join(frame, "\r\n"))
+		return
+	}
+	if n := frameShift(last[:len(last)-1], frame[:len(frame)-1]); n != 0 && caps.scroll {
+		last = r.scrollRows(b, last, n)
+	}
+	moved := false
+	for i, row := range frame {
+		if row != last[i] {
+			_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s", i+1, r.theme().base(), row)
+			moved = i < len(frame)-1
+		}
+	}
+	if moved {
+		// the cursor back after the status bar.
+		_, _ = fmt.Fprintf(b, "\x1b[%d;%dH", len(frame), strWidth(frame[len(frame)-1])+1)
+	}
+}
+
+// frameShift is the rows the page moved up by from rows last to rows, negative if it moved down.
+// It is 0 if the page did not move or most rows are new.
+func frameShift(last, rows []string) int {
+	best, most := 0, 0
+	for i, row := range rows {
+		if row == last[i] {
+			most++
+		}
+	}
+	for n := 1; n < len(rows); n++ {
+		up, down := 0, 0
+		for i := range len(rows) - n {
+			if rows[i] == last[i+n] {
+				up++
+			}
+			if rows[i+n] == last[i] {
+				down++
+			}
+		}
+		if up > most {
+			best, most = n, up
+		}
+		if down > most {
+			best, most = -n, down
+		}
+	}
+	if most <= len(rows)/2 {
+		return 0
+	}
+	return best
+}
+
+// scrollRows appends scrolling the rows of the page by n to b, and returns the rows of frame
+// last as they are then. The rows scrolled in are blank.
+func (r *Reader) scrollRows(b *strings.Builder, last []string, n int) []string {
+	h := len(last) - 1
+	_, _ = fmt.Fprintf(b, "%s\x1b[1;%dr", r.theme().base(), h)
+	rows := make([]string, h, len(last))
+	if n > 0 {
+		// line feeds at the bottom of the region move the rows up.
+		_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s", h, strings.Repeat("\n", n))
+		copy(rows, last[n:h])
+	} else {
+		// reverse line feeds at the top move them down.
+		b.WriteString("\x1b[1;1H" + strings.Repeat("\x1bM", -n))
+		copy(rows[-n:], last[:h+n])
+	}
+	b.WriteString("\x1b[r")
+	return append(rows, last[h])
+}
+
+// tickFrame tells if a frame is drawn for the timers of the status bar, like the pomodoro
+// countdown. With Config.Slow they are drawn every Config.StatusTick seconds.
+func (r *Reader) tickFrame() bool {
+	return !r.cfg.Slow || time.Since(r.drawn) >= time.Duration(r.cfg.StatusTick)*time.Second
+}
+
+// coalesce waits slowFrame with Config.Slow for more frames to be asked for, which are drawn
+// as one.
+func (r *Reader) coalesce() {
+	if !r.cfg.Slow {
+		return
+	}
+	t := time.NewTimer(slowFrame)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.renderSignal:
+		case <-t.C:
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// Escape sequences of DECSCLM. In smooth scroll mode terminals move the rows scrolled by line
+// feeds a few pixels at a time instead of a row at once.
+const (
+	smoothOn  = "\x1b[?4h"
+	smoothOff = "\x1b[?4l"
+)
+
+// smoothest is the fastest auto-scrolling which is smooth, terminals take about this long to
+// move the rows.
+const smoothest = 100 * time.Millisecond
+
+// smooth tells if auto-scrolling moves the rows smoothly. Frames then scroll the rows still shown
+// like Config.Slow does.
+func (r *Reader) smooth() bool {
+	return r.cfg.Smooth && caps.smooth && caps.scroll && r.scrolling >= smoothest && r.overlay == nil
+}
+
+// drawSmooth appends switching smooth scroll mode on or off to frame b when smooth changed.
+func (r *Reader) drawSmooth(b *strings.Builder) {
+	s := r.smooth()
+	switch {
+	case s == r.smoothShown:
+		return
+	case s:
+		b.WriteString(smoothOn)
+	default:
+		b.WriteString(smoothOff)
+	}
+	r.smoothShown = s
+}
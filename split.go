@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// maxFileTitle is the rune length chapter titles are cut to in file names.
+const maxFileTitle = 60
+
+// chapterFileName returns the file name of chapter number i of n, named after title.
+func chapterFileName(i, n int, title string) string {
+	var sb strings.Builder
+	sep := false
+	for _, c := range title {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("-.,()'", c):
+			if sep && sb.Len() > 0 {
+				sb.WriteByte('_')
+			}
+			sep = false
+			sb.WriteRune(c)
+		default:
+			sep = true
+		}
+	}
+	name := []rune(strings.Trim(sb.String(), "."))
+	if len(name) > maxFileTitle {
+		name = name[:maxFileTitle]
+	}
+	width := len(fmt.Sprint(n))
+	if len(name) == 0 {
+		return fmt.Sprintf("%0*d.txt", width, i)
+	}
+	return fmt.Sprintf("%0*d-%s.txt", width, i, string(name))
+}
+
+// Split writes every chapter of book f to its own file in directory out, the text before
+// the first chapter too if there is any. It returns the number of files written.
+func Split(f, out string) (int, error) {
+	c, b, e := loadBookConfig(f)
+	if e != nil {
+		return 0, e
+	}
+	d, e := loadDocument(f, c.Encoding)
+	if e != nil {
+		return 0, e
+	}
+	if d, e = c.filter(d, b); e != nil {
+		return 0, e
+	}
+	cc := d.chapters()
+	if len(cc) == 0 {
+		return 0, fmt.Errorf("no chapters found in %s", filepath.Base(f))
+	}
+	type part struct {
+		title      string
+		start, end int
+	}
+	var pp []part
+	if front := strings.TrimSpace(d.lines.slice(0, cc[0].line).joined()); front != "" {
+		pp = append(pp, part{"front", 0, cc[0].line})
+	}
+	for i, ch := range cc {
+		end := d.lines.len()
+		if i+1 < len(cc) {
+			end = cc[i+1].line
+		}
+		pp = append(pp, part{ch.title, ch.line, end})
+	}
+	if e := os.MkdirAll(out, 0755); e != nil {
+		return 0, e
+	}
+	for i, p := range pp {
+		s := strings.Trim(d.lines.slice(p.start, p.end).joined(), "\n") + "\n"
+		if e := os.WriteFile(filepath.Join(out, chapterFileName(i+1, len(pp), p.title)), []byte(s), 0644); e != nil {
+			return i, e
+		}
+	}
+	return len(pp), nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Book is what the progress file remembers about one book.
+type Book struct {
+	Line      int                        `json:"line"`
+	Offset    int64                      `json:"offset,omitempty"`    // byte offset of the position in a streamed book, see window.
+	Opened    time.Time                  `json:"opened,omitzero"`     // when the book was opened last time.
+	Read      time.Time                  `json:"read,omitzero"`       // when the position moved last time.
+	Pomodoros int                        `json:"pomodoros,omitempty"` // reading periods of the pomodoro timer finished.
+	Settings  map[string]json.RawMessage `json:"settings,omitempty"`  // per-book config overrides, see bookSettings.
+	Meta      Meta                       `json:"meta,omitzero"`       // kept for the library, which does not open every book.
+	Percent   float64                    `json:"percent,omitempty"`   // progress at Line, of the lines shown.
+	Hide      []string                   `json:"hide,omitempty"`      // patterns of lines to hide, besides Config.Hide.
+	Marks     []Bookmark                 `json:"marks,omitempty"`     // bookmarks in the order of their lines.
+	Seconds   int                        `json:"seconds,omitempty"`   // time spent reading the book, idle time aside.
+	Days      map[string]int             `json:"days,omitempty"`      // date:seconds read that day, for the calendar.
+}
+
+// UnmarshalJSON also accepts a bare line number, which is how older versions stored progress.
+func (b *Book) UnmarshalJSON(dd []byte) error {
+	var line int
+	if json.Unmarshal(dd, &line) == nil {
+		*b = Book{Line: line}
+		return nil
+	}
+	type book Book
+	return json.Unmarshal(dd, (*book)(b))
+}
+
+// progressPath is ProgressFile in the home directory, or syncFile in the repository of Config.Sync.
+func progressPath() (string, error) {
+	if c, _ := LoadConfig(); syncDir(c) != "" {
+		return filepath.Join(syncDir(c), syncFile), nil
+	}
+	u, e := os.UserHomeDir()
+	if e != nil {
+		return "", e
+	}
+	return filepath.Join(u, ProgressFile), nil
+}
+
+// readProgress reads the progress file at p, a missing file has no books.
+func readProgress(p string) (map[string]*Book, error) {
+	progress := make(map[string]*Book)
+	pp, e := os.ReadFile(p)
+	if os.IsNotExist(e) {
+		return progress, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if pp, e = unlock(p, pp); e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(pp, &progress); e != nil {
+		return nil, e
+	}
+	replayJournal(p, progress)
+	return progress, nil
+}
+
+// writeProgressFile writes progress to the progress file at p, locked if Config.Lock is set.
+func writeProgressFile(p string, progress map[string]*Book) error {
+	pp, _ := json.MarshalIndent(progress, "", "  ")
+	pp, e := lock(p, pp)
+	if e != nil {
+		return e
+	}
+	return os.WriteFile(p, pp, 0644)
+}
+
+// LoadBook returns the record of book f, an empty one if there is none.
+func LoadBook(f string) (*Book, error) {
+	p, e := progressPath()
+	if e != nil {
+		return nil, e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return nil, e
+	}
+	if b := progress[f]; b != nil {
+		return b, nil
+	}
+	return &Book{}, nil
+}
+
+// sidecarPath is the file next to book f with rules for it.
+func sidecarPath(f string) string {
+	return f + ".fish.json"
+}
+
+// loadSidecar reads the rules of book f from its sidecar file, a missing file has none.
+func loadSidecar(f string) (Rules, error) {
+	var s Rules
+	dd, e := os.ReadFile(sidecarPath(f))
+	if os.IsNotExist(e) {
+		return s, nil
+	}
+	if e != nil {
+		return s, e
+	}
+	if e := json.Unmarshal(dd, &s); e != nil {
+		return s, fmt.Errorf("%s: %w", filepath.Base(sidecarPath(f)), e)
+	}
+	return s, nil
+}
+
+// bookRules returns the rules of book f in record b and its sidecar file.
+func bookRules(f string, b *Book) (Rules, error) {
+	s, e := loadSidecar(f)
+	s.Hide = append(slices.Clone(b.Hide), s.Hide...)
+	return s, e
+}
+
+// loadBookConfig returns the configuration in effect for book f and its rules.
+func loadBookConfig(f string) (Config, Rules, error) {
+	c, e := LoadConfig()
+	if e != nil {
+		return c, Rules{}, e
+	}
+	b, e := LoadBook(f)
+	if e != nil {
+		return c, Rules{}, e
+	}
+	rr, e := bookRules(f, b)
+	return c.with(b.Settings), rr, e
+}
+
+// ResetBook removes everything the progress file remembers about book f,
+// it returns false if there was nothing.
+func ResetBook(f string) (bool, error) {
+	p, e := progressPath()
+	if e != nil {
+		return false, e
+	}
+	progress, e := readProgress(p)
+	if e != nil {
+		return false, e
+	}
+	if _, ok := progress[f]; !ok {
+		return false, nil
+	}
+	delete(progress, f)
+	if e := writeProgressFile(p, progress); e != nil {
+		return true, e
+	}
+	if e := forget(f, -1); e != nil {
+		return true, e
+	}
+	// the journal is in the file now, replaying it would bring back the bookmarks of f.
+	_ = os.Remove(journalPath(p))
+	return true, nil
+}
+
+// book returns the record of the current file, creating it if there is none.
+func (r *Reader) book() *Book {
+	b, ok := r.progress[r.f]
+	if !ok || b == nil {
+		b = &Book{}
+		r.progress[r.f] = b
+	}
+	return b
+}
+
+// saves tells if the progress of the session is written, it is not with --private or --no-save.
+func (r *Reader) saves() bool {
+	return !r.private && !r.noSave
+}
+
+// writeProgress writes the whole progress file.
+func (r *Reader) writeProgress() {
+	if !r.saves() || r.pulled != nil {
+		// during a pull git reads and rewrites the file, takePulled writes the progress after it.
+		return
+	}
+	r.timeUnsaved = false
+	pp, _ := json.MarshalIndent(r.progress, "", "  ")
+	pp, e := lock(r.progressFile, pp)
+	if e != nil {
+		r.notice = e.Error()
+		return
+	}
+	_ = r.progressFD.Truncate(0)
+	_, _ = r.progressFD.Seek(0, 0)
+	_, _ = r.progressFD.Write(pp)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// A plain text file too big for Config.Memory is streamed: only a window of it around the page
+// is read, a quarter of the budget as the lines made of it take the rest. The window slides when
+// the page nears one of its ends, and percents are of the bytes of the file.
+
+// window is the part of a streamed book which is read.
+type window struct {
+	size       int64   // of the file.
+	start, end int64   // byte offsets of the window in the file.
+	offs       []int64 // source line of the window:its byte offset in the file.
+}
+
+// windowSize is the bytes of the window with config c.
+func windowSize(c Config) int64 {
+	return int64(c.Memory) << 20 / 4
+}
+
+// streamed tells if book f is streamed with config c, being plain text bigger than its window.
+func streamed(f string, c Config) bool {
+	if c.Memory <= 0 {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".epub", ".docx", playlistExt, ".zip", ".gz", ".7z", ".rar", ".html", ".htm", ".xhtml", ".gmi", ".gemini", ".org", ".tex", ".latex", ".ltx", ".srt", ".vtt", ".csv", ".tsv":
+		return false
+	}
+	fi, e := os.Stat(f)
+	return e == nil && fi.Size() > windowSize(c)
+}
+
+// readWindow reads the lines of file f between byte offsets from and to, from the first line
+// starting at or after from to the last one ending before to.
+func readWindow(f, enc string, from, to int64) (*document, *window, error) {
+	fd, e := os.Open(f)
+	if e != nil {
+		return nil, nil, e
+	}
+	defer func() { _ = fd.Close() }()
+	fi, e := fd.Stat()
+	if e != nil {
+		return nil, nil, e
+	}
+	size := fi.Size()
+	from, to = max(0, min(from, size)), max(0, min(to, size))
+	// a byte before the window tells if it starts at a line.
+	at := max(0, from-1)
+	dd := make([]byte, to-at)
+	n, e := fd.ReadAt(dd, at)
+	if e != nil && !errors.Is(e, io.EOF) {
+		return nil, nil, e
+	}
+	dd = dd[:n]
+	startup.lap("read")
+	if from > 0 {
+		if i := bytes.IndexByte(dd, '\n'); i >= 0 {
+			dd, from = dd[i+1:], at+int64(i)+1
+		} else {
+			dd = dd[from-at:] // a line longer than the window, read from the middle.
+		}
+	}
+	to = from + int64(len(dd))
+	if to < size {
+		if i := bytes.LastIndexByte(dd, '\n'); i > 0 {
+			dd, to = dd[:i], from+int64(i)+1
+		}
+	}
+	w := &window{size: size, start: from, end: to, offs: []int64{from}}
+	for i, c := range dd {
+		if c == '\n' {
+			w.offs = append(w.offs, from+int64(i)+1)
+		}
+	}
+	d, e := parseDocument(f, dd, enc)
+	if e != nil {
+		return nil, nil, e
+	}
+	if d.lines.len() != len(w.offs) {
+		// an encoding like UTF-16 whose line breaks are not the byte '\n', offsets go by the line.
+		w.offs = w.offs[:0]
+		for i := range d.lines.len() {
+			w.offs = append(w.offs, from+(to-from)*int64(i)/int64(d.lines.len()))
+		}
+	}
+	return d, w, nil
+}
+
+// readAround reads the window of the streamed book around byte off, it returns the source
+// line there.
+func (r *Reader) readAround(off int64) (int, error) {
+	half := windowSize(r.cfg) / 2
+	d, w, e := readWindow(r.f, r.cfg.Encoding, off-half, off+half)
+	if e != nil {
+		return 0, e
+	}
+	r.stream = w
+	r.setDocument(d)
+	i, _ := slices.BinarySearch(w.offs, off+1)
+	return max(0, i-1), nil
+}
+
+// openWindow reads the window of the streamed book around byte off and starts the page at the
+// line there.
+func (r *Reader) openWindow(off int64) error {
+	line, e := r.readAround(off)
+	if e != nil {
+		return e
+	}
+	r.currentLine = r.doc.view(line)
+	r.top = place{line: r.currentLine}
+	r.previousSavedLine = -1
+	// positions in the old window are lost.
+	r.jumpBack, r.jumpForward = nil, nil
+	r.displayBreakMark, r.displayResumeMark = false, false
+	return nil
+}
+
+// streamIndex reads the window of the streamed book at its saved position, at its end while
+// following it, or again at the same offsets when a setting it depends on changed so the
+// positions in it stay.
+func (r *Reader) streamIndex() error {
+	if r.stream == nil {
+		// a source line, as positions are until toView.
+		line, e := r.readAround(r.book().Offset)
+		r.currentLine = line
+		startup.lap("index")
+		return e
+	}
+	if r.follow {
+		fi, e := os.Stat(r.f)
+		if e != nil {
+			return e
+		}
+		return r.openWindow(fi.Size() - windowSize(r.cfg)/2)
+	}
+	d, w, e := readWindow(r.f, r.cfg.Encoding, r.stream.start, r.stream.start+windowSize(r.cfg))
+	if e != nil {
+		return e
+	}
+	r.stream = w
+	r.setDocument(d)
+	return nil
+}
+
+// offset is the byte offset of the line the page starts at in the streamed book.
+func (r *Reader) offset() int64 {
+	i := r.doc.source(r.currentLine)
+	if i < 0 || i >= len(r.stream.offs) {
+		return r.stream.end
+	}
+	return r.stream.offs[i]
+}
+
+// nearestLine is the source line of w starting nearest to byte off.
+func (w *window) nearestLine(off int64) int {
+	i, _ := slices.BinarySearch(w.offs, off)
+	if i > 0 && (i == len(w.offs) || off-w.offs[i-1] <= w.offs[i]-off) {
+		i--
+	}
+	return i
+}
+
+// seekPercent starts the page of the streamed book at the line nearest to percent p of its
+// bytes. A line in the window is jumped to, further ones are read without the lines between.
+func (r *Reader) seekPercent(p float64) error {
+	p = max(0, min(p, 100))
+	off := int64(p / 100 * float64(r.stream.size))
+	if margin := windowSize(r.cfg) / 4; (r.stream.start == 0 || off >= r.stream.start+margin) &&
+		(r.stream.end == r.stream.size || off < r.stream.end-margin) {
+		r.jump(r.doc.view(r.stream.nearestLine(off)))
+		return nil
+	}
+	if e := r.openWindow(off); e != nil {
+		return e
+	}
+	r.currentLine = r.doc.view(r.stream.nearestLine(off))
+	r.top = place{line: r.currentLine}
+	return nil
+}
+
+// slideWindow reads the window around the page again when the page nears one of its ends.
+func (r *Reader) slideWindow() {
+	if r.stream == nil {
+		return
+	}
+	margin := min(4*r.pageHeight(), r.totalLine/4)
+	top := r.currentLine < margin && r.stream.start > 0
+	end := r.currentLine >= r.totalLine-margin && r.stream.end < r.stream.size
+	if !top && !end {
+		return
+	}
+	if e := r.openWindow(r.offset()); e != nil {
+		r.notice = e.Error()
+	}
+}
+
+// streamInfo is the position in the streamed book in megabytes, for the line field of the status bar.
+func (r *Reader) streamInfo() string {
+	return fmt.Sprintf("%d/%d MB", r.offset()>>20, r.stream.size>>20)
+}
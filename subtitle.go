@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cueGap is the pause in seconds between subtitles which starts a new paragraph.
+const cueGap = 3
+
+var (
+	cueTiming = regexp.MustCompile(`^((?:\d+:)?\d+:\d+)[,.](\d+)\s+-->\s+((?:\d+:)?\d+:\d+)[,.](\d+)`)
+	cueTags   = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+)
+
+// seconds returns the seconds of timestamp t like 01:02:03 or 02:03.
+func seconds(t string) int {
+	s := 0
+	for _, p := range strings.Split(t, ":") {
+		n, _ := strconv.Atoi(p)
+		s = s*60 + n
+	}
+	return s
+}
+
+// subtitleDocument returns the dialogue of SRT or WebVTT text s, a line for every subtitle.
+// Sequence numbers, timestamps and markup are dropped, pauses start paragraphs.
+// Dialogue between speakers starting with dashes keeps a line for every speaker.
+func subtitleDocument(s string) *document {
+	d := newDocument()
+	d.cues = make(map[int]string)
+	end := -1
+	var lb lineBuilder
+	for _, block := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n\n") {
+		ll := strings.Split(strings.Trim(block, "\n"), "\n")
+		at := -1
+		for i, l := range ll {
+			if cueTiming.MatchString(l) {
+				at = i
+				break
+			}
+		}
+		if at < 0 {
+			continue // WEBVTT header, NOTE and STYLE blocks.
+		}
+		m := cueTiming.FindStringSubmatch(ll[at])
+		var text []string
+		for _, l := range ll[at+1:] {
+			if l = strings.TrimSpace(cueTags.ReplaceAllString(l, "")); l != "" {
+				text = append(text, l)
+			}
+		}
+		if len(text) == 0 {
+			continue
+		}
+		start := seconds(m[1])
+		if end >= 0 && start-end >= cueGap {
+			lb.add("")
+		}
+		end = seconds(m[3])
+		d.cues[lb.len()] = m[1]
+		if strings.HasPrefix(text[0], "-") {
+			for _, l := range text {
+				lb.add(l) // a line for every speaker.
+			}
+		} else {
+			lb.add(strings.Join(text, " "))
+		}
+	}
+	d.lines = lb.index()
+	return d
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// syncFile is the name of the progress file in the repository of Config.Sync.
+const syncFile = "progress.json"
+
+// deletedFile is the name of the file in the repository of Config.Sync which records what was
+// deleted from the progress file, so merging a copy which still has it does not bring it back.
+const deletedFile = "deleted.json"
+
+// syncTimeout is how long a git command may take, the remote can be slow or away.
+const syncTimeout = 20 * time.Second
+
+// Deleted is what deletedFile records.
+type Deleted struct {
+	Books map[string]time.Time         `json:"books,omitempty"` // book:when it was forgotten.
+	Marks map[string]map[int]time.Time `json:"marks,omitempty"` // book:line:when its bookmark was deleted.
+}
+
+// parseDeleted reads the deletions recorded in dd, from file p.
+func parseDeleted(p string, dd []byte) (Deleted, error) {
+	d := Deleted{Books: map[string]time.Time{}, Marks: map[string]map[int]time.Time{}}
+	if len(bytes.TrimSpace(dd)) == 0 {
+		return d, nil
+	}
+	dd, e := unlock(p, dd)
+	if e != nil {
+		return d, e
+	}
+	if e := json.Unmarshal(dd, &d); e != nil {
+		return d, fmt.Errorf("%s: %w", filepath.Base(p), e)
+	}
+	if d.Books == nil {
+		d.Books = map[string]time.Time{}
+	}
+	if d.Marks == nil {
+		d.Marks = map[string]map[int]time.Time{}
+	}
+	return d, nil
+}
+
+// readDeleted reads the deletions recorded in file p, a missing file has none.
+func readDeleted(p string) (Deleted, error) {
+	dd, e := os.ReadFile(p)
+	if e != nil && !os.IsNotExist(e) {
+		return Deleted{}, e
+	}
+	return parseDeleted(p, dd)
+}
+
+// writeDeleted writes d to file p, locked like the progress file.
+func writeDeleted(p string, d Deleted) error {
+	dd, _ := json.MarshalIndent(d, "", "  ")
+	dd, e := lock(p, dd)
+	if e != nil {
+		return e
+	}
+	return os.WriteFile(p, dd, 0644)
+}
+
+// merge adds the deletions of o to d, the later time of one recorded in both.
+func (d Deleted) merge(o Deleted) {
+	for f, t := range o.Books {
+		if t.After(d.Books[f]) {
+			d.Books[f] = t
+		}
+	}
+	for f, mm := range o.Marks {
+		if d.Marks[f] == nil {
+			d.Marks[f] = map[int]time.Time{}
+		}
+		for line, t := range mm {
+			if t.After(d.Marks[f][line]) {
+				d.Marks[f][line] = t
+			}
+		}
+	}
+}
+
+// forget records that book f was deleted from the progress file, or its bookmark on source line
+// line if line >= 0, in the repository of Config.Sync if the progress is synced.
+func forget(f string, line int) error {
+	c, _ := LoadConfig()
+	dir := syncDir(c)
+	if dir == "" {
+		return nil
+	}
+	p := filepath.Join(dir, deletedFile)
+	d, e := readDeleted(p)
+	if e != nil {
+		return e
+	}
+	if line < 0 {
+		d.Books[f] = time.Now()
+		delete(d.Marks, f)
+	} else {
+		if d.Marks[f] == nil {
+			d.Marks[f] = map[int]time.Time{}
+		}
+		d.Marks[f][line] = time.Now()
+	}
+	return writeDeleted(p, d)
+}
+
+// forget records the deletion of book f or its bookmark on line like forget does,
+// nothing is recorded without saving.
+func (r *Reader) forget(f string, line int) {
+	if !r.saves() {
+		return
+	}
+	if r.pulled != nil {
+		// the pull is reading the deletions, takePulled records these after it.
+		r.forgotten = append(r.forgotten, deletion{f, line})
+		return
+	}
+	if e := forget(f, line); e != nil {
+		r.notice = "sync: " + e.Error()
+	}
+}
+
+// git runs git with args in repository dir and returns its output. Git must not ask for
+// credentials, the terminal is the reader's: a remote which needs them fails at once.
+func git(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	c.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=true", "SSH_ASKPASS=true")
+	if os.Getenv("GIT_SSH_COMMAND") == "" {
+		c.Env = append(c.Env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+	var out bytes.Buffer
+	c.Stdout, c.Stderr = &out, &out
+	if e := c.Run(); e != nil {
+		return out.String(), fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(out.String()))
+	}
+	return out.String(), nil
+}
+
+// syncPull brings the progress file of repository dir up to date with its upstream, taking
+// the progress file in the home directory to begin with.
+func syncPull(dir string) error {
+	p := filepath.Join(dir, syncFile)
+	if _, e := os.Stat(p); os.IsNotExist(e) {
+		if home, e := os.UserHomeDir(); e == nil {
+			if dd, e := os.ReadFile(filepath.Join(home, ProgressFile)); e == nil {
+				_ = os.WriteFile(p, dd, 0644)
+			}
+		}
+	}
+	return syncMerge(dir, "fish: progress")
+}
+
+// deletion is a deletion of book f, or of its bookmark on source line line if line >= 0.
+type deletion struct {
+	f    string
+	line int
+}
+
+// pullInBackground pulls the progress of repository dir while the book is read,
+// the main loop then takes what it brought, see takePulled.
+func (r *Reader) pullInBackground(dir string) {
+	pulled := make(chan error, 1)
+	r.pulled = pulled
+	go func() {
+		pulled <- syncPull(dir)
+		r.call(func(r *Reader) { r.takePulled() })
+	}()
+}
+
+// takePulled waits for the pull of pullInBackground and takes the books it brought, but for the
+// ones opened in this session. The position of the book is taken too if it was read further
+// elsewhere and the page was not turned since the book was opened.
+func (r *Reader) takePulled() {
+	if r.pulled == nil {
+		return
+	}
+	e := <-r.pulled
+	r.pulled = nil
+	// the progress of the session was kept from the file while git had it, see writeProgress.
+	defer r.writeProgress()
+	forgotten := r.forgotten
+	r.forgotten = nil
+	for _, d := range forgotten {
+		r.forget(d.f, d.line)
+	}
+	if e != nil {
+		r.notice = "sync: " + e.Error()
+		return
+	}
+	pulled, e := readProgress(r.progressFile)
+	if e != nil {
+		r.notice = "sync: " + e.Error()
+		return
+	}
+	// what was deleted during the pull is not brought back by it.
+	for _, d := range forgotten {
+		if b := pulled[d.f]; b != nil && d.line >= 0 {
+			b.Marks = slices.DeleteFunc(b.Marks, func(m Bookmark) bool { return m.Line == d.line })
+		} else {
+			delete(pulled, d.f)
+		}
+	}
+	for f := range r.progress {
+		if _, ok := pulled[f]; !ok && !slices.Contains(r.opened, f) {
+			delete(r.progress, f)
+		}
+	}
+	for f, b := range pulled {
+		if !slices.Contains(r.opened, f) {
+			r.progress[f] = b
+		}
+	}
+	if p := pulled[r.f]; p != nil {
+		b := r.book()
+		if p.Read.After(b.Read) && r.stream == nil && r.currentLine == r.previousSavedLine {
+			b.Line, b.Percent, b.Read = p.Line, p.Percent, p.Read
+			r.currentLine = r.doc.view(p.Line)
+			r.previousSavedLine = r.currentLine
+			r.notice = fmt.Sprintf(tr("sync: read up to line %d elsewhere"), p.Line+1)
+		}
+		for _, m := range p.Marks {
+			if r.bookmark(m.Line) < 0 {
+				b.Marks = append(b.Marks, m)
+			}
+		}
+		slices.SortStableFunc(b.Marks, func(x, y Bookmark) int { return x.Line - y.Line })
+	}
+	// git may have put a new file in place of the one open.
+	if f, e := os.OpenFile(r.progressFile, os.O_RDWR, 0644); e == nil {
+		_ = r.progressFD.Close()
+		r.progressFD = f
+	}
+}
+
+// syncPush commits the progress file of repository dir with message msg and pushes it.
+func syncPush(dir, msg string) error {
+	if e := syncMerge(dir, msg); e != nil {
+		return e
+	}
+	up := syncUpstream(dir)
+	if _, e := git(dir, "rev-parse", "-q", "--verify", "HEAD"); e != nil || up == "" {
+		return nil // nothing to push, or nowhere.
+	}
+	remote, branch, _ := strings.Cut(up, "/")
+	_, e := git(dir, "push", "-q", "-u", remote, "HEAD:"+branch)
+	return e
+}
+
+// syncUpstream returns the upstream branch of repository dir like origin/main, the branch of
+// the same name of its first remote if it has none yet, "" if it has no remote.
+func syncUpstream(dir string) string {
+	if out, e := git(dir, "rev-parse", "--abbrev-ref", "@{u}"); e == nil {
+		return strings.TrimSpace(out)
+	}
+	out, _ := git(dir, "remote")
+	remote, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	branch, e := git(dir, "symbolic-ref", "--short", "HEAD")
+	if remote == "" || e != nil {
+		return ""
+	}
+	return remote + "/" + strings.TrimSpace(branch)
+}
+
+// syncMerge commits the progress file of repository dir and merges the upstream's into it.
+// Git cannot merge the JSON, so the merge keeps ours and mergeProgress takes both.
+func syncMerge(dir, msg string) error {
+	if e := syncCommit(dir, msg); e != nil {
+		return e
+	}
+	up := syncUpstream(dir)
+	if up == "" {
+		return nil
+	}
+	remote, _, _ := strings.Cut(up, "/")
+	if _, e := git(dir, "fetch", "-q", remote); e != nil {
+		return e
+	}
+	if _, e := git(dir, "rev-parse", "-q", "--verify", up); e != nil {
+		return nil // the remote has no progress yet.
+	}
+	if _, e := git(dir, "rev-parse", "-q", "--verify", "HEAD"); e != nil {
+		// a new clone without progress of its own.
+		_, e := git(dir, "reset", "-q", "--hard", up)
+		return e
+	}
+	if out, _ := git(dir, "rev-list", "--count", "HEAD.."+up); strings.TrimSpace(out) == "0" {
+		return nil
+	}
+	theirs, e := git(dir, "show", up+":"+syncFile)
+	if e != nil {
+		// the upstream has no progress yet.
+		theirs = "{}"
+	}
+	p := filepath.Join(dir, syncFile)
+	ours, e := os.ReadFile(p)
+	if e != nil && !os.IsNotExist(e) {
+		return e
+	}
+	if ours, e = unlock(p, ours); e != nil {
+		return e
+	}
+	theirsPlain, e := unlock(p, []byte(theirs))
+	if e != nil {
+		return e
+	}
+	dp := filepath.Join(dir, deletedFile)
+	deleted, e := readDeleted(dp)
+	if e != nil {
+		return e
+	}
+	// the upstream may have recorded no deletions yet.
+	theirsDeleted, _ := git(dir, "show", up+":"+deletedFile)
+	td, e := parseDeleted(dp, []byte(theirsDeleted))
+	if e != nil {
+		return e
+	}
+	deleted.merge(td)
+	merged, e := mergeProgress(ours, theirsPlain, deleted)
+	if e != nil {
+		return e
+	}
+	if merged, e = lock(p, merged); e != nil {
+		return e
+	}
+	if _, e := git(dir, "merge", "-q", "-s", "ours", "--no-edit", up); e != nil {
+		return e
+	}
+	if e := os.WriteFile(p, merged, 0644); e != nil {
+		return e
+	}
+	if len(deleted.Books)+len(deleted.Marks) > 0 {
+		if e := writeDeleted(dp, deleted); e != nil {
+			return e
+		}
+	}
+	return syncCommit(dir, "fish: merge progress")
+}
+
+// syncCommit commits the progress file of repository dir and its deletions if they changed.
+func syncCommit(dir, msg string) error {
+	var ff []string
+	for _, f := range []string{syncFile, deletedFile} {
+		if _, e := os.Stat(filepath.Join(dir, f)); e == nil {
+			ff = append(ff, f)
+		}
+	}
+	if len(ff) == 0 {
+		return nil
+	}
+	if _, e := git(dir, append([]string{"add"}, ff...)...); e != nil {
+		return e
+	}
+	if _, e := git(dir, "diff", "--cached", "--quiet"); e == nil {
+		return nil
+	}
+	_, e := git(dir, "commit", "-q", "-m", msg)
+	return e
+}
+
+// mergeProgress merges two progress files. Of a book in both the one read last is taken,
+// with the bookmarks of the other on lines it has none of. A book is left out if it was deleted
+// after it was last read, and a bookmark if it was deleted after it was made.
+func mergeProgress(ours, theirs []byte, deleted Deleted) ([]byte, error) {
+	a, b := map[string]*Book{}, map[string]*Book{}
+	if len(bytes.TrimSpace(ours)) > 0 {
+		if e := json.Unmarshal(ours, &a); e != nil {
+			return nil, e
+		}
+	}
+	if e := json.Unmarshal(theirs, &b); e != nil {
+		return nil, errors.New("the progress file upstream is broken: " + e.Error())
+	}
+	kept := func(f string, o *Book) bool {
+		t, ok := deleted.Books[f]
+		return !ok || o != nil && lastRead(o).After(t)
+	}
+	marked := func(f string, m Bookmark) bool {
+		t, ok := deleted.Marks[f][m.Line]
+		return !ok || m.At.After(t)
+	}
+	for f, o := range a {
+		if !kept(f, o) {
+			delete(a, f)
+		}
+	}
+	for f, t := range b {
+		if !kept(f, t) {
+			continue
+		}
+		o := a[f]
+		if o == nil || t == nil {
+			if o == nil {
+				a[f] = t
+			}
+			continue
+		}
+		newer, older := o, t
+		if lastRead(t).After(lastRead(o)) {
+			newer, older = t, o
+		}
+		for _, m := range older.Marks {
+			if !slices.ContainsFunc(newer.Marks, func(n Bookmark) bool { return n.Line == m.Line }) {
+				newer.Marks = append(newer.Marks, m)
+			}
+		}
+		slices.SortStableFunc(newer.Marks, func(x, y Bookmark) int { return x.Line - y.Line })
+		a[f] = newer
+	}
+	for f, o := range a {
+		if o != nil {
+			o.Marks = slices.DeleteFunc(o.Marks, func(m Bookmark) bool { return !marked(f, m) })
+		}
+	}
+	return json.MarshalIndent(a, "", "  ")
+}
+
+// syncDir returns the repository of Config.Sync, "" if progress is not synced.
+func syncDir(c Config) string {
+	if c.Sync == "" {
+		return ""
+	}
+	dir, e := homePath(c.Sync)
+	if e != nil {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+)
+
+// colSep separates the cells of table rows.
+const colSep = " │ "
+
+// tableDocument returns the CSV text s, with fields separated by comma, as an aligned table.
+// Columns of numbers are aligned to the right. It returns nil if s does not parse.
+func tableDocument(s string, comma rune) *document {
+	cr := csv.NewReader(strings.NewReader(s))
+	cr.Comma = comma
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	rows, e := cr.ReadAll()
+	if e != nil || len(rows) == 0 {
+		return nil
+	}
+	var widths []int
+	var numeric []bool
+	for i, row := range rows {
+		for j, c := range row {
+			c = strings.Join(strings.Fields(c), " ")
+			row[j] = c
+			if j == len(widths) {
+				widths = append(widths, 0)
+				numeric = append(numeric, true)
+			}
+			widths[j] = max(widths[j], strWidth(c))
+			if _, e := strconv.ParseFloat(c, 64); i > 0 && c != "" && e != nil {
+				numeric[j] = false
+			}
+		}
+	}
+	d := newDocument()
+	d.cols = make([]int, len(widths))
+	for j := 1; j < len(widths); j++ {
+		d.cols[j] = d.cols[j-1] + widths[j-1] + strWidth(colSep)
+	}
+	var lb lineBuilder
+	for i, row := range rows {
+		var sb strings.Builder
+		for j, w := range widths {
+			c := ""
+			if j < len(row) {
+				c = row[j]
+			}
+			if j > 0 {
+				sb.WriteString(colSep)
+			}
+			pad := strings.Repeat(" ", w-strWidth(c))
+			if numeric[j] && i > 0 {
+				sb.WriteString(pad + c)
+			} else if j < len(widths)-1 {
+				sb.WriteString(c + pad)
+			} else {
+				sb.WriteString(c)
+			}
+		}
+		lb.add(sb.String())
+		if i == 0 {
+			rule := make([]string, len(widths))
+			for j, w := range widths {
+				rule[j] = strings.Repeat("─", w)
+			}
+			lb.add(strings.Join(rule, "─┼─"))
+		}
+	}
+	d.lines = lb.index()
+	return d
+}
+
+// header returns the number of lines at the top of the document which stay on screen.
+func (d *document) header() int {
+	if d.cols == nil {
+		return 0
+	}
+	return min(2, d.lines.len())
+}
+
+// scrollSideways moves the view of cut lines n columns right, or to the next table column.
+func (r *Reader) scrollSideways(n int) {
+	if r.cfg.Wrap && r.doc.cols == nil {
+		r.notice = tr("lines are wrapped, turn off wrap to scroll sideways")
+		return
+	}
+	if r.doc.cols == nil {
+		r.hscroll = max(0, r.hscroll+8*n)
+		return
+	}
+	at := 0
+	for i, c := range r.doc.cols {
+		if c <= r.hscroll {
+			at = i
+		}
+	}
+	r.hscroll = r.doc.cols[max(0, min(at+n, len(r.doc.cols)-1))]
+}
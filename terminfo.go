@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// trueColor is termCaps.colors of terminals taking 24-bit colors.
+const trueColor = 1 << 24
+
+// termCaps is what the terminal can do of the sequences fish writes.
+type termCaps struct {
+	cursor    bool // moves the cursor, without it pages are written as lines like Config.Accessible.
+	altScreen bool // has the alternate screen, which leaves the shell's screen as it was.
+	colors    int  // 0, 8, 16, 256 or trueColor, colors are written as the nearest it has.
+	title     bool // takes the window title.
+	scroll    bool // has scroll regions, which move the rows of the page without writing them again.
+	smooth    bool // has the smooth scroll mode of DECSCLM.
+}
+
+// caps are the capabilities of the terminal of $TERM.
+var caps = detectCaps(os.Getenv("TERM"), os.Getenv("COLORTERM"), os.Getenv("XTERM_VERSION"))
+
+// detectCaps finds the capabilities of terminal term in its terminfo entry. 24-bit colors
+// are not in terminfo, terminals having them tell by $COLORTERM. A terminal without an entry
+// is taken for an xterm, as fish did before looking. Smooth scrolling is not in terminfo either,
+// the real xterm sets $XTERM_VERSION unlike the many terminals taking its name.
+func detectCaps(term, colorterm, xterm string) termCaps {
+	if term == "" || term == "dumb" {
+		return termCaps{}
+	}
+	c := termCaps{cursor: true, altScreen: true, colors: trueColor, title: true, scroll: true}
+	ti, e := loadTerminfo(term)
+	if e == nil {
+		c.cursor = ti.strings[tiCursorAddress]
+		c.altScreen = ti.strings[tiEnterCAMode]
+		c.scroll = ti.strings[tiChangeScrollRegion]
+		c.colors = colorDepth(ti.colors)
+	}
+	if colorterm == "truecolor" || colorterm == "24bit" {
+		c.colors = trueColor
+	}
+	c.smooth = xterm != "" || strings.HasPrefix(term, "mlterm") || strings.HasPrefix(term, "vt") && !strings.HasPrefix(term, "vt52")
+	// the console of Linux and BSD and the old hardware terminals have no window to title.
+	for _, p := range []string{"linux", "cons", "vt", "wsvt", "pcvt", "sun"} {
+		if strings.HasPrefix(term, p) {
+			c.title = false
+		}
+	}
+	return c
+}
+
+// colorDepth is the colors of termCaps of a terminal with n colors.
+func colorDepth(n int) int {
+	for _, d := range []int{trueColor, 256, 16, 8} {
+		if n >= d {
+			return d
+		}
+	}
+	return 0
+}
+
+// colorNames are the values of Config.Colors besides auto, by their termCaps.colors.
+var colorNames = map[string]int{"truecolor": trueColor, "256": 256, "16": 16, "8": 8, "none": 0}
+
+// useColors takes the colors of Config.Colors over the detected ones.
+func useColors(c Config) {
+	if n, ok := colorNames[c.Colors]; ok {
+		caps.colors = n
+	}
+}
+
+// Indexes of the terminfo capabilities fish looks at, in the order of term(5).
+const (
+	tiColors             = 13 // colors, a number.
+	tiChangeScrollRegion = 3  // csr, a string.
+	tiCursorAddress      = 10 // cup, a string.
+	tiEnterCAMode        = 28 // smcup, a string.
+)
+
+// terminfo is what fish reads of a compiled terminfo entry.
+type terminfo struct {
+	colors  int
+	strings map[int]bool // index:the string capability is there.
+}
+
+// terminfoDirs are the directories searched for terminfo entries, as ncurses does.
+func terminfoDirs() []string {
+	var dd []string
+	if d := os.Getenv("TERMINFO"); d != "" {
+		dd = append(dd, d)
+	}
+	if home, e := os.UserHomeDir(); e == nil {
+		dd = append(dd, filepath.Join(home, ".terminfo"))
+	}
+	system := []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo", "/usr/share/lib/terminfo"}
+	if ds := os.Getenv("TERMINFO_DIRS"); ds != "" {
+		for _, d := range strings.Split(ds, ":") {
+			if d == "" {
+				dd = append(dd, system...)
+			} else {
+				dd = append(dd, d)
+			}
+		}
+		return dd
+	}
+	return append(dd, system...)
+}
+
+// loadTerminfo reads the compiled terminfo entry of terminal name.
+func loadTerminfo(name string) (*terminfo, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") {
+		return nil, errors.New("no terminal")
+	}
+	for _, d := range terminfoDirs() {
+		// a directory by the first letter, or its hex code on macOS.
+		for _, sub := range []string{name[:1], fmt.Sprintf("%02x", name[0])} {
+			if dd, e := os.ReadFile(filepath.Join(d, sub, name)); e == nil {
+				return parseTerminfo(dd)
+			}
+		}
+	}
+	return nil, errors.New("no terminfo entry for " + name)
+}
+
+// parseTerminfo parses a compiled terminfo entry in the format of term(5), in the legacy
+// format with 16-bit numbers or the extended one with 32-bit numbers.
+func parseTerminfo(dd []byte) (*terminfo, error) {
+	bad := errors.New("bad terminfo entry")
+	if len(dd) < 12 {
+		return nil, bad
+	}
+	h := make([]int, 6)
+	for i := range h {
+		h[i] = int(int16(binary.LittleEndian.Uint16(dd[2*i:])))
+	}
+	numSize := 2
+	switch h[0] {
+	case 0o432:
+	case 0o1036:
+		numSize = 4
+	default:
+		return nil, bad
+	}
+	names, bools, nums, strs, table := h[1], h[2], h[3], h[4], h[5]
+	if names < 0 || bools < 0 || nums < 0 || strs < 0 || table < 0 {
+		return nil, bad
+	}
+	at := 12 + names + bools
+	at += at % 2 // numbers start on an even byte.
+	if len(dd) < at+nums*numSize+strs*2 {
+		return nil, bad
+	}
+	ti := &terminfo{colors: -1, strings: make(map[int]bool)}
+	if tiColors < nums {
+		p := dd[at+tiColors*numSize:]
+		if numSize == 2 {
+			ti.colors = int(int16(binary.LittleEndian.Uint16(p)))
+		} else {
+			ti.colors = int(int32(binary.LittleEndian.Uint32(p)))
+		}
+	}
+	at += nums * numSize
+	for i := range strs {
+		// -1 is absent, -2 cancelled.
+		if off := int16(binary.LittleEndian.Uint16(dd[at+2*i:])); off >= 0 {
+			ti.strings[i] = true
+		}
+	}
+	return ti, nil
+}
+
+// termColors writes the colors of the SGR sequences in s as the nearest the terminal has.
+func termColors(s string) string {
+	switch {
+	case noColor || caps.colors == 0:
+		return uncolor(s)
+	case caps.colors < trueColor:
+		return recolor(s, caps.colors)
+	}
+	return s
+}
+
+// recolor turns the 24-bit colors of the SGR sequences in s into colors of the 256 color palette,
+// or of the 16 or 8 basic colors.
+func recolor(s string, colors int) string {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			return sb.String() + s
+		}
+		sb.WriteString(s[:i])
+		n := max(1, escLen(s[i:]))
+		seq := s[i : i+n]
+		s = s[i+n:]
+		if !strings.HasSuffix(seq, "m") || !strings.Contains(seq, "8;2;") && (colors >= 256 || !strings.Contains(seq, "8;5;")) {
+			sb.WriteString(seq)
+			continue
+		}
+		pp := strings.Split(seq[2:len(seq)-1], ";")
+		var out []string
+		for j := 0; j < len(pp); j++ {
+			if (pp[j] == "38" || pp[j] == "48") && j+2 < len(pp) && (pp[j+1] == "5" && colors < 256 || pp[j+1] == "2" && j+4 < len(pp)) {
+				var c [3]int
+				args := 4 // 2;r;g;b or 5;n.
+				if pp[j+1] == "5" {
+					n, _ := strconv.Atoi(pp[j+2])
+					c, args = paletteColor(n), 2
+				} else {
+					for k := range c {
+						c[k], _ = strconv.Atoi(pp[j+2+k])
+					}
+				}
+				if colors >= 256 {
+					out = append(out, pp[j], "5", strconv.Itoa(palette256(c)))
+				} else {
+					i, base := basicColor(c, colors), 30
+					if i >= 8 {
+						i, base = i-8, 90 // the bright colors.
+					}
+					if pp[j] == "48" {
+						base += 10
+					}
+					out = append(out, strconv.Itoa(base+i))
+				}
+				j += args
+				continue
+			}
+			out = append(out, pp[j])
+		}
+		sb.WriteString("\x1b[" + strings.Join(out, ";") + "m")
+	}
+}
+
+// palette256 is the nearest color to c of the 6×6×6 cube and the gray ramp of the 256 colors.
+func palette256(c [3]int) int {
+	levels := []int{0, 95, 135, 175, 215, 255}
+	nearest := func(v int) int {
+		best := 0
+		for i, l := range levels {
+			if abs(v-l) < abs(v-levels[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+	r, g, b := nearest(c[0]), nearest(c[1]), nearest(c[2])
+	cube := 16 + 36*r + 6*g + b
+	cd := sq(c[0]-levels[r]) + sq(c[1]-levels[g]) + sq(c[2]-levels[b])
+	avg := (c[0] + c[1] + c[2]) / 3
+	gi := max(0, min(23, (avg-8+5)/10))
+	gv := 8 + 10*gi
+	if sq(c[0]-gv)+sq(c[1]-gv)+sq(c[2]-gv) < cd {
+		return 232 + gi
+	}
+	return cube
+}
+
+// paletteColor is the color n of the 256 color palette.
+func paletteColor(n int) [3]int {
+	switch {
+	case n < 0 || n > 255:
+		return [3]int{}
+	case n < 16:
+		return basicColors[n]
+	case n >= 232:
+		g := 8 + 10*(n-232)
+		return [3]int{g, g, g}
+	}
+	levels := []int{0, 95, 135, 175, 215, 255}
+	n -= 16
+	return [3]int{levels[n/36], levels[n/6%6], levels[n%6]}
+}
+
+// basicColors are black, red, green, yellow, blue, magenta, cyan and white as xterm shows them,
+// then their bright versions.
+var basicColors = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// basicColor is the index of the nearest color to c of the first n of basicColors.
+func basicColor(c [3]int, n int) int {
+	best, bd := 0, -1
+	for i, b := range basicColors[:n] {
+		if d := sq(c[0]-b[0]) + sq(c[1]-b[1]) + sq(c[2]-b[2]); bd < 0 || d < bd {
+			best, bd = i, d
+		}
+	}
+	return best
+}
+
+func sq(n int) int {
+	return n * n
+}
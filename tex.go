@@ -0,0 +1,791 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// LaTeX manuscripts are read without compiling them: the commands and environments are left
+// out or replaced by what they print, as far as that is simple. \part to \subsubsection become
+// chapters, \emph and \textbf the fonts of man pages, and the macros of \newcommand and \def are
+// expanded. Math is shown as its source.
+
+// texDepth is how deep macros and \input files are expanded, against ones calling themselves.
+const texDepth = 32
+
+// The sectioning commands by their rank, the highest one used in a manuscript is level 1.
+var texSections = map[string]int{"part": 1, "chapter": 2, "section": 3, "subsection": 4, "subsubsection": 5}
+
+// texMacro is a command or environment defined by the manuscript or by fish.
+type texMacro struct {
+	args  int    // number of arguments, #1 to #9 in the body.
+	opt   string // default of the first argument, which is optional if set.
+	body  string // the definition, or what \begin writes for an environment.
+	end   string // what \end writes for an environment.
+	isOpt bool   // the first argument is optional.
+}
+
+// texEnvs are the built-in environments which print something.
+var texEnvs = map[string]texMacro{
+	"abstract": {body: `\textbf{Abstract}\par `},
+	"proof":    {body: `\emph{Proof.} `, end: " ∎"},
+}
+
+// texDrop is the number of arguments of commands which print none of them.
+var texDrop = map[string]int{
+	"documentclass": 1, "usepackage": 1, "RequirePackage": 1, "includegraphics": 1, "index": 1,
+	"vspace": 1, "hspace": 1, "setlength": 2, "addtolength": 2, "setcounter": 2,
+	"addtocounter": 2, "newcounter": 1, "newlength": 1, "pagestyle": 1, "thispagestyle": 1,
+	"pagenumbering": 1, "bibliographystyle": 1, "bibliography": 1, "addbibresource": 1, "nocite": 1,
+	"textcolor": 1, "color": 1, "colorbox": 1, "definecolor": 3, "geometry": 1, "hypersetup": 1,
+	"graphicspath": 1, "addcontentsline": 3, "markboth": 2, "markright": 1, "fontsize": 2,
+	"selectlanguage": 1, "hyphenation": 1, "lstset": 1, "rule": 2, "raisebox": 1,
+	"DeclareMathOperator": 2, "numberwithin": 2, "setmainfont": 1, "date": 1, "let": 2,
+}
+
+// texEnvArgs is the number of arguments of environments which print none of them.
+var texEnvArgs = map[string]int{"tabular": 1, "tabular*": 2, "tabularx": 2, "longtable": 1, "minipage": 1, "multicols": 1, "wrapfigure": 2, "minted": 1, "thebibliography": 1}
+
+// texRaw are the environments shown as their source, and those left out.
+var (
+	texRaw  = map[string]bool{"verbatim": true, "verbatim*": true, "Verbatim": true, "lstlisting": true, "minted": true, "alltt": true}
+	texMath = map[string]bool{"equation": true, "equation*": true, "align": true, "align*": true, "gather": true, "gather*": true, "multline": true, "multline*": true, "eqnarray": true, "eqnarray*": true, "flalign": true, "flalign*": true, "displaymath": true, "math": true}
+	texSkip = map[string]bool{"comment": true, "tikzpicture": true, "filecontents": true, "filecontents*": true}
+)
+
+// texSymbols are the commands of characters.
+var texSymbols = map[string]string{
+	"&": "&", "%": "%", "$": "$", "#": "#", "_": "_", "{": "{", "}": "}", " ": " ", ",": " ",
+	";": " ", ":": " ", "!": "", "@": "", "/": "", "-": "", "quad": " ", "qquad": "  ",
+	"ldots": "…", "dots": "…", "LaTeX": "LaTeX", "TeX": "TeX", "LaTeXe": "LaTeX2ε", "ss": "ß",
+	"ae": "æ", "AE": "Æ", "oe": "œ", "OE": "Œ", "o": "ø", "O": "Ø", "aa": "å", "AA": "Å", "l": "ł",
+	"L": "Ł", "i": "ı", "j": "ȷ", "S": "§", "P": "¶", "dag": "†", "ddag": "‡", "copyright": "©",
+	"textendash": "–", "textemdash": "—", "textbackslash": "\\", "textasciitilde": "~",
+	"textbullet": "•", "textellipsis": "…", "guillemotleft": "«", "guillemotright": "»",
+	"slash": "/", "textquotedblleft": "“", "textquotedblright": "”", "textdegree": "°", "euro": "€",
+	"pounds": "£", "ldquo": "“", "rdquo": "”",
+}
+
+// texAccents are the accent commands as combining characters.
+var texAccents = map[string]string{
+	"'": "\u0301", "`": "\u0300", "^": "\u0302", `"`: "\u0308", "~": "\u0303", "=": "\u0304",
+	".": "\u0307", "u": "\u0306", "v": "\u030c", "H": "\u030b", "c": "\u0327", "k": "\u0328", "r": "\u030a",
+}
+
+// texText replaces the ligatures of TeX in text.
+var texText = strings.NewReplacer("---", "—", "--", "–", "``", "“", "''", "”", "`", "‘", "'", "’", "~", "\u00a0")
+
+// texLabel matches a sectioning command followed by its \label, for the text of references.
+var texLabel = regexp.MustCompile(`\\(?:part|chapter|section|subsection|subsubsection)\*?(?:\[[^\]]*\])?\{([^{}]*)\}\s*\\label\{([^{}]*)\}`)
+
+// texDocument converts LaTeX manuscript s, whose \input files are read from dir.
+func texDocument(s, dir string) *document {
+	d := newDocument()
+	d.paras, d.styled = true, true
+	w := &texWriter{htmlWriter: htmlWriter{d: d}, dir: dir, after: -1, macros: make(map[string]texMacro), envs: make(map[string]texMacro), labels: make(map[string]string)}
+	for k, v := range texEnvs {
+		w.envs[k] = v
+	}
+	if end := strings.Index(s, `\end{document}`); end >= 0 {
+		s = s[:end]
+	}
+	if begin := strings.Index(s, `\begin{document}`); begin >= 0 {
+		// the preamble prints nothing, it defines the macros, title and author.
+		w.write(s[:begin])
+		w.line.Reset()
+		w.lines, d.links, d.anchors, d.headings = nil, make(map[int][]link), make(map[string]int), nil
+		s = s[begin+len(`\begin{document}`):]
+	}
+	w.findLabels(s, 0)
+	w.write(s)
+	w.flush()
+	d.lines = lineIndexOf(w.lines)
+	d.meta.Title, d.meta.Author = w.title, w.author
+	// the headings get levels from the highest rank used, those below the third are no chapters.
+	top := 0
+	for _, h := range d.headings {
+		if top == 0 || h.level < top {
+			top = h.level
+		}
+	}
+	hh := d.headings[:0]
+	for _, h := range d.headings {
+		if h.level = h.level - top + 1; h.level <= 3 {
+			hh = append(hh, h)
+		}
+	}
+	d.headings = hh
+	return d
+}
+
+// texWriter converts LaTeX into lines, by the htmlWriter for the lines, links and anchors.
+type texWriter struct {
+	htmlWriter
+	dir           string              // directory of the manuscript.
+	macros        map[string]texMacro // commands by name, without the backslash.
+	envs          map[string]texMacro // environments by name.
+	labels        map[string]string   // \label key:text of its heading.
+	title, author string
+	font          string   // SGR sequences of the current font.
+	fonts         []string // the fonts at the start of the environments being written.
+	lists         []int    // the lists being written, -1 for itemize, the last number for enumerate.
+	table         int      // depth of tabular environments, where & separates cells.
+	after         int      // line of the heading just written, where a \label after it refers to.
+	depth         int      // depth of expanded macros and \input files.
+}
+
+// plain returns what LaTeX s prints, as plain text on one line.
+func (w *texWriter) plain(s string) string {
+	sub := *w
+	sub.htmlWriter = htmlWriter{d: newDocument()}
+	sub.font, sub.fonts, sub.lists, sub.table = "", nil, nil, 0
+	sub.write(s)
+	sub.flush()
+	return strings.TrimSpace(stripControls(strings.Join(sub.lines, " "), false))
+}
+
+// out writes text, runs of white space become a space like TeX prints them.
+func (w *texWriter) out(s string) {
+	if strings.TrimSpace(s) != "" {
+		w.after = -1
+	}
+	if cur := w.line.String(); strings.HasSuffix(cur, "m") {
+		// the space before a font is not written again after it.
+		tail := cur[max(0, len(cur)-32):]
+		if t := texSGREnd.ReplaceAllString(tail, ""); t != tail && (strings.HasSuffix(t, " ") || t == "" && tail == cur) {
+			s = strings.TrimLeft(s, " \t\r\n")
+		}
+	}
+	w.text(s)
+}
+
+// texSGREnd matches the SGR sequences at the end of a line.
+var texSGREnd = regexp.MustCompile(`(\x1b\[[0-9;]*m)+$`)
+
+// write writes LaTeX s.
+func (w *texWriter) write(s string) {
+	var chunk strings.Builder
+	out := func() {
+		if chunk.Len() > 0 {
+			w.out(texText.Replace(chunk.String()))
+			chunk.Reset()
+		}
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '%':
+			// a comment, the line break after it and the indent of the next line.
+			out()
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				return
+			}
+			for i += j + 1; i < len(s) && (s[i] == ' ' || s[i] == '\t'); i++ {
+			}
+		case c == '\n' && texBlank(s[i+1:]):
+			out()
+			w.flush()
+			for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+				i++
+			}
+		case c == '\\':
+			out()
+			i = w.command(s, i+1)
+		case c == '{':
+			out()
+			inner, next := texArg(s, i)
+			font := w.font
+			w.write(inner)
+			w.restore(font)
+			i = next
+		case c == '}':
+			i++
+		case c == '$':
+			out()
+			i = w.math(s, i)
+		case c == '&' && w.table > 0:
+			out()
+			w.separate(" | ")
+			i++
+		default:
+			chunk.WriteByte(c)
+			i++
+		}
+	}
+	out()
+}
+
+// texBlank tells if s starts with a line of white space, so the line break before it ends a
+// paragraph.
+func texBlank(s string) bool {
+	l, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(l) == "" && strings.Contains(s, "\n")
+}
+
+// math writes the math at s[i] as its source: $x$ in the line, $$x$$ on lines of its own.
+func (w *texWriter) math(s string, i int) int {
+	if strings.HasPrefix(s[i:], "$$") {
+		end := strings.Index(s[i+2:], "$$")
+		if end < 0 {
+			end = len(s) - i - 2
+		}
+		w.display(s[i+2 : i+2+end])
+		return min(len(s), i+end+4)
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '$':
+			w.out(s[i : j+1])
+			return j + 1
+		}
+	}
+	w.out(s[i:])
+	return len(s)
+}
+
+// separate writes sep after the text of the line, without the spaces at its end.
+func (w *texWriter) separate(sep string) {
+	cur := strings.TrimRight(w.line.String(), " ")
+	w.line.Reset()
+	w.line.WriteString(cur + sep)
+}
+
+// texEqLabel matches the \label of an equation.
+var texEqLabel = regexp.MustCompile(`\s*\\label\{([^{}]*)\}`)
+
+// display writes display math s on lines of its own, its labels refer to them.
+func (w *texWriter) display(s string) {
+	w.flush()
+	for _, l := range strings.Split(s, "\n") {
+		for _, m := range texEqLabel.FindAllStringSubmatch(l, -1) {
+			w.d.anchors[m[1]] = len(w.lines)
+		}
+		if l = strings.TrimSpace(texEqLabel.ReplaceAllString(l, "")); l != "" {
+			w.lines = append(w.lines, "  "+l)
+		}
+	}
+	w.after = -1
+}
+
+// restore ends the fonts started after font, before the spaces at the end of the line so that
+// they are not underlined.
+func (w *texWriter) restore(font string) {
+	if w.font != font {
+		cur := w.line.String()
+		t := strings.TrimRight(cur, " ")
+		w.line.Reset()
+		w.line.WriteString(t + manRoman + font + cur[len(t):])
+		w.font = font
+	}
+}
+
+// styled writes LaTeX s in font.
+func (w *texWriter) styled(s, font string) {
+	saved := w.font
+	w.font += font
+	w.line.WriteString(font)
+	w.write(s)
+	w.restore(saved)
+}
+
+// command writes the command whose name starts at s[i], after its backslash. It returns where
+// the text after the command and its arguments starts.
+func (w *texWriter) command(s string, i int) int {
+	name, i := texName(s, i)
+	if m, ok := w.macros[name]; ok {
+		return w.expand(m, s, i)
+	}
+	if t, ok := texSymbols[name]; ok {
+		w.out(t)
+		return i
+	}
+	if mark, ok := texAccents[name]; ok {
+		arg, next := texArg(s, i)
+		if arg = strings.TrimSpace(arg); arg == `\i` || arg == `\j` {
+			arg = arg[1:]
+		}
+		if _, n := utf8.DecodeRuneInString(arg); n > 0 {
+			w.out(norm.NFC.String(arg[:n] + mark + arg[n:]))
+		}
+		return next
+	}
+	if rank, ok := texSections[name]; ok {
+		_, _, i = texOpt(s, i)
+		title, next := texArg(s, i)
+		w.heading(title, rank)
+		return next
+	}
+	var arg string
+	switch name {
+	case "emph", "textit", "textsl", "mathit":
+		arg, i = texArg(s, i)
+		w.styled(arg, "\x1b[3m")
+	case "textbf", "mathbf":
+		arg, i = texArg(s, i)
+		w.styled(arg, manBold)
+	case "underline", "uline":
+		arg, i = texArg(s, i)
+		w.styled(arg, manUnderline)
+	case "sout":
+		arg, i = texArg(s, i)
+		w.styled(arg, "\x1b[9m")
+	case "em", "it", "itshape", "sl", "slshape":
+		w.font += "\x1b[3m"
+		w.line.WriteString("\x1b[3m")
+	case "bf", "bfseries":
+		w.font += manBold
+		w.line.WriteString(manBold)
+	case "rm", "normalfont", "upshape", "mdseries":
+		w.restore("")
+	case "paragraph", "subparagraph":
+		_, _, i = texOpt(s, i)
+		arg, i = texArg(s, i)
+		w.flush()
+		w.styled(arg, manBold)
+		w.out(" ")
+	case "and":
+		w.separate(", ")
+	case "\\":
+		_, _, i = texOpt(s, i)
+		w.flush()
+	case "par", "newline", "linebreak":
+		w.flush()
+	case "item":
+		w.item(s, &i)
+	case "bibitem":
+		_, _, i = texOpt(s, i)
+		arg, i = texArg(s, i)
+		w.flush()
+		w.out("[" + arg + "] ")
+	case "label":
+		arg, i = texArg(s, i)
+		line := len(w.lines)
+		if w.after >= 0 {
+			line = w.after
+		}
+		w.d.anchors[arg] = line
+	case "ref", "autoref", "cref", "Cref", "eqref", "pageref", "nameref":
+		arg, i = texArg(s, i)
+		t := w.labels[arg]
+		if t == "" {
+			t = arg
+		}
+		w.linked(arg, func() { w.out(t) })
+	case "url":
+		arg, i = texArg(s, i)
+		w.linked(arg, func() { w.out(arg) })
+	case "href":
+		var text string
+		arg, i = texArg(s, i)
+		text, i = texArg(s, i)
+		w.linked(arg, func() { w.write(text) })
+	case "verb":
+		if i < len(s) {
+			end := strings.IndexByte(s[i+1:], s[i])
+			if end < 0 {
+				end = len(s) - i - 1
+			}
+			w.out(s[i+1 : i+1+end])
+			i = min(len(s), i+end+2)
+		}
+	case "footnote":
+		_, _, i = texOpt(s, i)
+		arg, i = texArg(s, i)
+		w.out("[")
+		w.write(arg)
+		w.out("]")
+	case "cite", "citep", "citet", "parencite", "textcite", "autocite":
+		opt, ok, next := texOpt(s, i)
+		arg, i = texArg(s, next)
+		keys := strings.Split(strings.ReplaceAll(arg, " ", ""), ",")
+		if ok && opt != "" {
+			keys = append(keys, w.plain(opt))
+		}
+		w.out("[" + strings.Join(keys, ", ") + "]")
+	case "caption":
+		_, _, i = texOpt(s, i)
+		arg, i = texArg(s, i)
+		w.flush()
+		w.write(arg)
+		w.flush()
+	case "title":
+		_, _, i = texOpt(s, i)
+		arg, i = texArg(s, i)
+		w.title = w.plain(arg)
+	case "author":
+		arg, i = texArg(s, i)
+		w.author = w.plain(arg)
+	case "maketitle":
+		w.flush()
+		if w.title != "" {
+			w.lines = append(w.lines, manBold+w.title+manRoman)
+		}
+		if w.author != "" {
+			w.lines = append(w.lines, w.author)
+		}
+		w.blank()
+	case "input", "include", "subfile":
+		arg, i = texArg(s, i)
+		w.input(arg)
+	case "newcommand", "renewcommand", "providecommand", "DeclareRobustCommand":
+		i = w.define(s, i)
+	case "def", "gdef", "edef":
+		i = w.def(s, i)
+	case "newenvironment", "renewenvironment":
+		i = w.environment(s, i)
+	case "newtheorem":
+		var env, text string
+		env, i = texArg(s, i)
+		_, _, i = texOpt(s, i)
+		text, i = texArg(s, i)
+		_, _, i = texOpt(s, i)
+		w.envs[env] = texMacro{body: `\textbf{` + text + `.} `}
+	case "begin":
+		i = w.begin(s, i)
+	case "end":
+		arg, i = texArg(s, i)
+		w.end(arg)
+	case "[":
+		end := strings.Index(s[i:], `\]`)
+		if end < 0 {
+			end = len(s) - i
+		}
+		w.display(s[i : i+end])
+		i = min(len(s), i+end+2)
+	case "(":
+		end := strings.Index(s[i:], `\)`)
+		if end < 0 {
+			end = len(s) - i
+		}
+		w.out("$" + s[i:i+end] + "$")
+		i = min(len(s), i+end+2)
+	default:
+		if n, ok := texDrop[name]; ok {
+			for range n {
+				_, _, i = texOpt(s, i)
+				_, i = texArg(s, i)
+			}
+		}
+		// other commands print nothing but their arguments.
+		_, _, i = texOpt(s, i)
+	}
+	return i
+}
+
+// heading writes the title of a sectioning command of rank as a chapter.
+func (w *texWriter) heading(title string, rank int) {
+	w.flush()
+	w.blank()
+	start := len(w.lines)
+	w.styled(title, manBold)
+	w.flush()
+	if t := strings.TrimSpace(stripControls(strings.Join(w.lines[start:], " "), false)); t != "" {
+		w.d.headings = append(w.d.headings, chapter{line: start, level: rank, title: t})
+	}
+	w.blank()
+	w.after = start
+}
+
+// linked writes a link to target by write.
+func (w *texWriter) linked(target string, write func()) {
+	start := w.line.Len()
+	write()
+	if target != "" {
+		w.links = append(w.links, link{start: start, end: w.line.Len(), target: target})
+	}
+}
+
+// item starts an item of the current list, after its optional label at s[*i].
+func (w *texWriter) item(s string, i *int) {
+	label, ok, next := texOpt(s, *i)
+	*i = next
+	w.flush()
+	if n := len(w.lists); n > 0 {
+		w.line.WriteString(strings.Repeat("  ", n-1))
+		switch {
+		case ok:
+		case w.lists[n-1] >= 0:
+			w.lists[n-1]++
+			w.line.WriteString(strconv.Itoa(w.lists[n-1]) + ". ")
+		default:
+			w.line.WriteString("• ")
+		}
+	}
+	if ok {
+		w.styled(label, manBold)
+		w.out(" ")
+	}
+}
+
+// begin starts the environment named at s[i], shown as its source for verbatim and math.
+func (w *texWriter) begin(s string, i int) int {
+	name, i := texArg(s, i)
+	if env, ok := w.envs[name]; ok {
+		w.flush()
+		w.fonts = append(w.fonts, w.font)
+		return w.expand(env, s, i)
+	}
+	if texRaw[name] || texMath[name] || texSkip[name] {
+		end := strings.Index(s[i:], `\end{`+name+`}`)
+		if end < 0 {
+			end = len(s) - i
+		}
+		body := s[i : i+end]
+		i = min(len(s), i+end+len(`\end{}`)+len(name))
+		switch {
+		case texMath[name]:
+			w.display(body)
+		case texRaw[name]:
+			// the rest of the \begin line has the options of the listing.
+			_, body, _ = strings.Cut(body, "\n")
+			w.flush()
+			for _, l := range strings.Split(strings.TrimRight(body, " \t\n"), "\n") {
+				w.lines = append(w.lines, l)
+			}
+			w.after = -1
+		}
+		return i
+	}
+	_, _, i = texOpt(s, i)
+	for range texEnvArgs[name] {
+		_, i = texArg(s, i)
+	}
+	w.flush()
+	w.fonts = append(w.fonts, w.font)
+	switch name {
+	case "itemize", "description":
+		w.lists = append(w.lists, -1)
+	case "enumerate", "thebibliography":
+		w.lists = append(w.lists, 0)
+	case "tabular", "tabular*", "tabularx", "longtable":
+		w.table++
+	}
+	return i
+}
+
+// end ends environment name, like a group it ends the fonts started in it.
+func (w *texWriter) end(name string) {
+	if env, ok := w.envs[name]; ok {
+		w.write(env.end)
+	}
+	if n := len(w.fonts); n > 0 {
+		w.restore(w.fonts[n-1])
+		w.fonts = w.fonts[:n-1]
+	}
+	w.flush()
+	switch name {
+	case "itemize", "description", "enumerate", "thebibliography":
+		if len(w.lists) > 0 {
+			w.lists = w.lists[:len(w.lists)-1]
+		}
+	case "tabular", "tabular*", "tabularx", "longtable":
+		w.table = max(0, w.table-1)
+	}
+}
+
+// expand writes macro m, whose arguments start at s[i].
+func (w *texWriter) expand(m texMacro, s string, i int) int {
+	args := make([]string, m.args)
+	for k := range args {
+		if k == 0 && m.isOpt {
+			opt, ok, next := texOpt(s, i)
+			if args[0], i = opt, next; !ok {
+				args[0] = m.opt
+			}
+			continue
+		}
+		args[k], i = texArg(s, i)
+	}
+	if w.depth >= texDepth {
+		return i
+	}
+	body := m.body
+	for k := len(args); k > 0; k-- {
+		body = strings.ReplaceAll(body, "#"+strconv.Itoa(k), args[k-1])
+	}
+	w.depth++
+	w.write(body)
+	w.depth--
+	return i
+}
+
+// define reads a \newcommand at s[i]: {\name}[args][default]{body}.
+func (w *texWriter) define(s string, i int) int {
+	name, i := texArg(s, i)
+	var m texMacro
+	m.args, i = texArgCount(s, i)
+	m.opt, m.isOpt, i = texOpt(s, i)
+	m.body, i = texArg(s, i)
+	if n := strings.TrimPrefix(strings.TrimSpace(name), `\`); n != "" {
+		w.macros[n] = m
+	}
+	return i
+}
+
+// def reads a \def at s[i]: \name#1#2{body}.
+func (w *texWriter) def(s string, i int) int {
+	name, i := texArg(s, i)
+	brace := strings.IndexByte(s[i:], '{')
+	if brace < 0 {
+		return len(s)
+	}
+	m := texMacro{args: strings.Count(s[i:i+brace], "#")}
+	m.body, i = texArg(s, i+brace)
+	if n := strings.TrimPrefix(name, `\`); n != "" {
+		w.macros[n] = m
+	}
+	return i
+}
+
+// environment reads a \newenvironment at s[i]: {name}[args][default]{begin}{end}.
+func (w *texWriter) environment(s string, i int) int {
+	name, i := texArg(s, i)
+	var m texMacro
+	m.args, i = texArgCount(s, i)
+	m.opt, m.isOpt, i = texOpt(s, i)
+	m.body, i = texArg(s, i)
+	m.end, i = texArg(s, i)
+	w.envs[name] = m
+	return i
+}
+
+// texInput matches the commands reading another file.
+var texInput = regexp.MustCompile(`\\(?:input|include|subfile)\{([^{}]*)\}`)
+
+// findLabels finds the headings of labels in s and the files it reads, so that references show
+// them even before the label.
+func (w *texWriter) findLabels(s string, depth int) {
+	for _, m := range texLabel.FindAllStringSubmatch(s, -1) {
+		w.labels[m[2]] = w.plain(m[1])
+	}
+	if depth >= texDepth {
+		return
+	}
+	for _, m := range texInput.FindAllStringSubmatch(s, -1) {
+		if dd, e := os.ReadFile(w.inputPath(m[1])); e == nil {
+			w.findLabels(string(dd), depth+1)
+		}
+	}
+}
+
+// inputPath returns the path of the file named by \input, relative to the manuscript.
+func (w *texWriter) inputPath(name string) string {
+	p := filepath.Join(w.dir, strings.TrimSpace(name))
+	if filepath.Ext(p) == "" {
+		p += ".tex"
+	}
+	return p
+}
+
+// input writes the file named by \input.
+func (w *texWriter) input(name string) {
+	dd, e := os.ReadFile(w.inputPath(name))
+	if e != nil || w.depth >= texDepth {
+		return
+	}
+	w.depth++
+	w.write(string(dd))
+	w.depth--
+}
+
+// texName reads the name of a command starting at s[i], a run of letters or another character.
+// Like TeX the spaces after a name of letters are skipped, a star is part of the name.
+func texName(s string, i int) (string, int) {
+	j := i
+	for j < len(s) && (s[j] >= 'a' && s[j] <= 'z' || s[j] >= 'A' && s[j] <= 'Z' || s[j] == '@') {
+		j++
+	}
+	if j == i {
+		if i >= len(s) {
+			return "", i
+		}
+		return s[i : i+1], i + 1
+	}
+	name := s[i:j]
+	if j < len(s) && s[j] == '*' {
+		j++
+	}
+	for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+		j++
+	}
+	if j < len(s) && s[j] == '\n' && !texBlank(s[j+1:]) {
+		j++
+	}
+	return name, j
+}
+
+// texArg reads the argument at s[i]: a group in braces, a command or a character.
+func texArg(s string, i int) (string, int) {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) >= 0 {
+		i++
+	}
+	if i >= len(s) {
+		return "", i
+	}
+	switch s[i] {
+	case '{':
+		depth := 0
+		for j := i; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '{':
+				depth++
+			case '}':
+				if depth--; depth == 0 {
+					return s[i+1 : j], j + 1
+				}
+			}
+		}
+		return s[i+1:], len(s)
+	case '\\':
+		name, j := texName(s, i+1)
+		return `\` + name, j
+	}
+	_, n := utf8.DecodeRuneInString(s[i:])
+	return s[i : i+n], i + n
+}
+
+// texOpt reads the optional argument in brackets at s[i], if any.
+func texOpt(s string, i int) (opt string, ok bool, next int) {
+	j := i
+	for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+		j++
+	}
+	if j >= len(s) || s[j] != '[' {
+		return "", false, i
+	}
+	depth := 0
+	for k := j + 1; k < len(s); k++ {
+		switch s[k] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ']':
+			if depth == 0 {
+				return s[j+1 : k], true, k + 1
+			}
+		}
+	}
+	return "", false, i
+}
+
+// texArgCount reads the number of arguments of a definition, [2], 0 if not given.
+func texArgCount(s string, i int) (int, int) {
+	opt, ok, next := texOpt(s, i)
+	if !ok {
+		return 0, i
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(opt))
+	return min(max(n, 0), 9), next
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Theme colors are "#rrggbb", empty means the terminal default color.
+type Theme struct {
+	Fg       string
+	Bg       string
+	StatusFg string
+	StatusBg string
+	Mark     string // break marks and other decorations.
+}
+
+var themes = map[string]Theme{
+	"default": {},
+	"dark":    {Fg: "#c5c8c6", Bg: "#1d1f21", StatusFg: "#1d1f21", StatusBg: "#81a2be", Mark: "#5f6366"},
+	"light":   {Fg: "#383a42", Bg: "#fafafa", StatusFg: "#fafafa", StatusBg: "#4078f2", Mark: "#a0a1a7"},
+	"sepia":   {Fg: "#5b4636", Bg: "#f4ecd8", StatusFg: "#f4ecd8", StatusBg: "#8b6f47", Mark: "#b8a78a"},
+	// white on black with a yellow status bar, for low vision.
+	"high-contrast": {Fg: "#ffffff", Bg: "#000000", StatusFg: "#000000", StatusBg: "#ffff00", Mark: "#00ffff"},
+}
+
+// noColor is set by $NO_COLOR, see https://no-color.org. Frames are drawn without colors,
+// bold, dim and reverse video are kept.
+var noColor = os.Getenv("NO_COLOR") != ""
+
+// themeNames returns the names of all built-in themes in a stable order.
+func themeNames() []string {
+	nn := make([]string, 0, len(themes))
+	for n := range themes {
+		nn = append(nn, n)
+	}
+	sort.Strings(nn)
+	return nn
+}
+
+// sgr returns the escape sequence that resets all attributes then applies fg and bg.
+func sgr(fg, bg string) string {
+	s := "\x1b[0"
+	if c, ok := rgb(fg); ok {
+		s += fmt.Sprintf(";38;2;%d;%d;%d", c[0], c[1], c[2])
+	}
+	if c, ok := rgb(bg); ok {
+		s += fmt.Sprintf(";48;2;%d;%d;%d", c[0], c[1], c[2])
+	}
+	return s + "m"
+}
+
+// uncolor removes the colors from the SGR escape sequences in s and keeps their other attributes.
+func uncolor(s string) string {
+	var sb strings.Builder
+	for {
+		i := strings.Index(s, "\x1b[")
+		if i < 0 {
+			return sb.String() + s
+		}
+		sb.WriteString(s[:i])
+		n := max(1, escLen(s[i:]))
+		seq := s[i : i+n]
+		s = s[i+n:]
+		if !strings.HasSuffix(seq, "m") {
+			sb.WriteString(seq)
+			continue
+		}
+		var kept []string
+		pp := strings.Split(seq[2:len(seq)-1], ";")
+		for j := 0; j < len(pp); j++ {
+			p := pp[j]
+			if strings.Contains(p, ":") {
+				p, _, _ = strings.Cut(p, ":") // colon form of 38:2::r:g:b, its numbers are in p.
+			} else if (p == "38" || p == "48" || p == "58") && j+1 < len(pp) {
+				// 5;n or 2;r;g;b follow.
+				if pp[j+1] == "5" {
+					j += 2
+				} else {
+					j += 4
+				}
+				continue
+			}
+			n, e := strconv.Atoi(p)
+			if e == nil && (n >= 30 && n <= 49 || n >= 58 && n <= 59 || n >= 90 && n <= 107) {
+				continue
+			}
+			kept = append(kept, pp[j])
+		}
+		if len(kept) > 0 || seq == "\x1b[m" {
+			sb.WriteString("\x1b[" + strings.Join(kept, ";") + "m")
+		}
+	}
+}
+
+func rgb(c string) ([3]uint8, bool) {
+	var v [3]uint8
+	if len(c) != 7 || c[0] != '#' {
+		return v, false
+	}
+	for i := range v {
+		n, e := strconv.ParseUint(c[1+i*2:3+i*2], 16, 8)
+		if e != nil {
+			return v, false
+		}
+		v[i] = uint8(n)
+	}
+	return v, true
+}
+
+// base is the escape sequence for page text.
+func (t Theme) base() string {
+	return sgr(t.Fg, t.Bg)
+}
+
+// status is the escape sequence for the status bar, same as page text when the theme leaves it unset.
+func (t Theme) status() string {
+	if t.StatusFg == "" && t.StatusBg == "" {
+		return sgr(t.Fg, t.Bg)
+	}
+	return sgr(t.StatusFg, t.StatusBg)
+}
+
+// mark is the escape sequence for decorations drawn between text lines.
+func (t Theme) mark() string {
+	if t.Mark == "" {
+		return sgr(t.Fg, t.Bg) + "\x1b[2m"
+	}
+	return sgr(t.Mark, t.Bg)
+}
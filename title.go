@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Escape sequences saving and restoring the terminal title on the title stack of xterm.
+// tmux has no title stack, saveTitle asks it for the pane title instead.
+const (
+	pushTitle = "\x1b[22;0t"
+	popTitle  = "\x1b[23;0t"
+)
+
+// drawTitle appends setting the terminal title to "book — 42%" to frame b when it changed.
+// Turning the title off in the settings restores the old one.
+func (r *Reader) drawTitle(b *strings.Builder) {
+	s := ""
+	_, blank := r.overlay.(*blankScreen)
+	switch {
+	case !r.cfg.Title || blank || !caps.title:
+	case r.disguised:
+		s = disguiseNames[r.disguise()]
+	default:
+		s = fmt.Sprintf("%s — %.0f%%", stripControls(r.title(), false), r.percent())
+	}
+	switch {
+	case s == r.titleShown:
+		return
+	case s == "":
+		b.WriteString(r.oldTitle())
+	case r.titleShown == "":
+		r.saveTitle()
+		b.WriteString(pushTitle)
+	}
+	if s != "" {
+		b.WriteString("\x1b]2;" + s + "\x07")
+	}
+	r.titleShown = s
+}
+
+// saveTitle remembers the title of the tmux pane fish runs in.
+func (r *Reader) saveTitle() {
+	if os.Getenv("TMUX") == "" {
+		return
+	}
+	if out, e := exec.Command("tmux", "display-message", "-p", "#{pane_title}").Output(); e == nil {
+		r.paneTitle = strings.TrimSpace(string(out))
+	}
+}
+
+// oldTitle returns the escape sequences setting the title back to the one before fish.
+func (r *Reader) oldTitle() string {
+	s := popTitle
+	if r.paneTitle != "" {
+		s += "\x1b]2;" + stripControls(r.paneTitle, false) + "\x07"
+	}
+	return s
+}
+
+// restoreTitle sets the title back when fish quits.
+func (r *Reader) restoreTitle() {
+	if r.titleShown != "" {
+		_, _ = os.Stdout.WriteString(r.oldTitle())
+	}
+}
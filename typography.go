@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// typography returns s with straight quotes curled, "--" and "---" as en and em dashes and
+// "..." as an ellipsis. Escape sequences in s are kept as they are.
+func typography(s string) string {
+	if !strings.ContainsAny(s, `"'-.`) {
+		return s
+	}
+	var sb strings.Builder
+	prev := ' ' // last character written, a line starts like after a space.
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			sb.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+		c, n := utf8.DecodeRuneInString(s[i:])
+		// opening quotes follow spaces and opening brackets or dashes.
+		open := unicode.IsSpace(prev) || strings.ContainsRune("([{<—–“‘", prev)
+		switch {
+		case strings.HasPrefix(s[i:], "---"):
+			c, n = '—', 3
+		case strings.HasPrefix(s[i:], "--"):
+			c, n = '–', 2
+		case strings.HasPrefix(s[i:], "..."):
+			c, n = '…', 3
+		case c == '"' && open:
+			c = '“'
+		case c == '"':
+			c = '”'
+		case c == '\'' && open:
+			c = '‘'
+		case c == '\'':
+			c = '’' // also apostrophes.
+		}
+		sb.WriteRune(c)
+		prev = c
+		i += n
+	}
+	return sb.String()
+}
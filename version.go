@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set by the linker in releases, see the Makefile:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=abc1234 -X main.date=2025-01-31"
+var (
+	version = "dev"
+	commit  string
+	date    string
+)
+
+// versionInfo returns the version, commit and build date. Without the linker setting them,
+// the commit and its time come from the build info of the Go toolchain.
+func versionInfo() string {
+	c, d := commit, date
+	if bi, ok := debug.ReadBuildInfo(); ok && c == "" {
+		dirty := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				c = s.Value[:min(len(s.Value), 7)]
+			case "vcs.time":
+				d = s.Value
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+		if dirty && c != "" {
+			c += "-dirty"
+		}
+	}
+	s := "fish " + version
+	switch {
+	case c != "" && d != "":
+		s += fmt.Sprintf(" (%s, %s)", c, d)
+	case c != "":
+		s += " (" + c + ")"
+	}
+	return s + " " + runtime.GOOS + "/" + runtime.GOARCH
+}
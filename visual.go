@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// visual is the overlay selecting the lines from start to end, in either order, end moves.
+type visual struct {
+	start, end int
+}
+
+// selectLines starts selecting lines at the top of the page.
+func (r *Reader) selectLines() {
+	r.overlay = &visual{r.currentLine, r.currentLine}
+}
+
+// selected tells if line i is in the selection of the visual overlay.
+func (r *Reader) selected(i int) bool {
+	v, ok := r.overlay.(*visual)
+	return ok && i >= min(v.start, v.end) && i <= max(v.start, v.end)
+}
+
+func (v *visual) key(r *Reader, k string) bool {
+	switch k {
+	case "down", "j", "enter":
+		v.end = min(v.end+1, r.totalLine-1)
+	case "up", "k":
+		v.end = max(v.end-1, 0)
+	case "y":
+		if e := r.saveQuote(min(v.start, v.end), max(v.start, v.end)); e != nil {
+			r.notice = e.Error()
+		}
+		return true
+	case "c":
+		if e := r.saveCard(min(v.start, v.end), max(v.start, v.end)); e != nil {
+			r.notice = e.Error()
+		}
+		return true
+	case "esc", "q", "v":
+		return true
+	}
+	if v.end >= r.currentLine+max(1, r.shown) {
+		r.currentLine++
+	}
+	r.currentLine = min(r.currentLine, v.end)
+	return false
+}
+
+func (v *visual) draw(r *Reader, b *strings.Builder) {
+	t := r.theme()
+	s := fmt.Sprintf(tr("-- VISUAL -- %d lines, y saves them to %s, c makes a card, esc cancels"), max(v.start, v.end)-min(v.start, v.end)+1, r.cfg.Quotes)
+	_, _ = fmt.Fprintf(b, "\x1b[%d;1H%s%s\x1b[K%s", r.winHeight, t.status(), cut(s, r.winWidth-1), t.base())
+}
+
+// saveQuote appends lines start to end to the file of Config.Quotes as a Markdown quote,
+// under a heading with the title of the book and the position.
+func (r *Reader) saveQuote(start, end int) error {
+	p, e := homePath(r.cfg.Quotes)
+	if e != nil {
+		return e
+	}
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "## %s · line %d, %.0f%% · %s\n\n", stripControls(r.title(), false), r.doc.source(start)+1,
+		float64(start)/float64(max(1, r.totalLine))*100, time.Now().Format(time.DateOnly))
+	for i := start; i <= end; i++ {
+		sb.WriteString(strings.TrimRight("> "+stripControls(r.index.at(i), false), " ") + "\n")
+	}
+	sb.WriteString("\n")
+	f, e := os.OpenFile(p, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if e != nil {
+		return e
+	}
+	if _, e := f.WriteString(sb.String()); e != nil {
+		_ = f.Close()
+		return e
+	}
+	if e := f.Close(); e != nil {
+		return e
+	}
+	r.notice = fmt.Sprintf(tr("%d lines saved to %s"), end-start+1, r.cfg.Quotes)
+	return nil
+}